@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/HcashOrg/hcashd/blockchain/indexers"
+	"github.com/HcashOrg/hcashd/hcashec/edwards"
 	"github.com/HcashOrg/hcashd/limits"
 )
 
@@ -56,6 +57,13 @@ func hcashdMain(serverChan chan<- *server) error {
 	hcashdLog.Infof("Version %s (Go version %s)", version(), runtime.Version())
 	hcashdLog.Infof("Home dir: %s", cfg.HomeDir)
 
+	// Refuse to start if the Edwards signature code doesn't produce its
+	// known answers, since a broken build could accept invalid signatures.
+	if err := edwards.SelfTest(); err != nil {
+		hcashdLog.Errorf("%v", err)
+		return err
+	}
+
 	// Enable http profiling server if requested.
 	if cfg.Profile != "" {
 		go func() {
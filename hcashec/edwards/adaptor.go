@@ -0,0 +1,190 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// AdaptorSignature is a Schnorr signature encrypted under an adaptor point
+// T = tG. It is not a valid signature by itself, but adding the secret t to
+// S turns it into one with R = Nonce + T. Conversely, anyone holding both the
+// adaptor signature and the completed signature learns t, which is what
+// makes atomic swaps with scriptless scripts possible.
+type AdaptorSignature struct {
+	Nonce        *PublicKey
+	AdaptorPoint *PublicKey
+	S            *big.Int
+}
+
+// adaptorChallenge computes h = hash512(R || A || M) reduced mod N, where R
+// is the sum of the nonce and the adaptor point.
+func adaptorChallenge(curve *TwistedEdwardsCurve, pub *PublicKey,
+	msg []byte, asig *AdaptorSignature) (*big.Int, error) {
+	rX, rY := curve.Add(asig.Nonce.GetX(), asig.Nonce.GetY(),
+		asig.AdaptorPoint.GetX(), asig.AdaptorPoint.GetY())
+	if !curve.IsOnCurve(rX, rY) {
+		return nil, fmt.Errorf("adaptor nonce sum is off curve")
+	}
+	encodedR := BigIntPointToEncodedBytes(rX, rY)
+
+	var hramDigest [64]byte
+	h := sha512.New()
+	h.Write(encodedR[:])
+	h.Write(pub.Serialize())
+	h.Write(msg)
+	h.Sum(hramDigest[:0])
+	var hramDigestReduced [32]byte
+	edwards25519.ScReduce(&hramDigestReduced, &hramDigest)
+
+	return EncodedBytesToBigInt(&hramDigestReduced), nil
+}
+
+// SignAdaptor creates an adaptor signature of the 32 byte message hash msg,
+// encrypted under adaptorPoint. The nonce k is derived deterministically
+// from the private key, the message and the adaptor point, and then the
+// partial signing machinery produces s' = k + hash512(kG + T || A || M) * a.
+func SignAdaptor(curve *TwistedEdwardsCurve, priv *PrivateKey, msg []byte,
+	adaptorPoint *PublicKey) (*AdaptorSignature, error) {
+	if priv == nil || msg == nil || adaptorPoint == nil ||
+		adaptorPoint.GetX() == nil || adaptorPoint.GetY() == nil {
+		return nil, fmt.Errorf("nil input")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(adaptorPoint.GetX(), adaptorPoint.GetY()) {
+		return nil, fmt.Errorf("adaptor point is off curve")
+	}
+
+	// Sign with the reduced scalar, which has the same public key. This
+	// handles keys derived from a secret as well as bare scalars.
+	scalar := priv.reducedScalar(curve)
+	defer scalar.SetInt64(0)
+	scalarBytes := BigIntToEncodedBytesNoReverse(scalar)
+	defer zeroSlice(scalarBytes[:])
+	reducedPriv, pub, err := PrivKeyFromScalar(curve, scalarBytes[:])
+	if err != nil {
+		return nil, err
+	}
+	defer reducedPriv.Wipe()
+
+	k := nonceRFC6979(curve, scalarBytes[:], msg, adaptorPoint.Serialize(),
+		Sha512VersionStringRFC6979)
+	defer zeroSlice(k)
+	privNonce, pubNonce, err := PrivKeyFromScalar(curve, k)
+	if err != nil {
+		return nil, err
+	}
+	defer privNonce.Wipe()
+
+	rX, rY := curve.Add(pubNonce.GetX(), pubNonce.GetY(),
+		adaptorPoint.GetX(), adaptorPoint.GetY())
+	if !curve.IsOnCurve(rX, rY) {
+		return nil, fmt.Errorf("adaptor nonce sum is off curve")
+	}
+	pubNonceSum := NewPublicKey(curve, rX, rY)
+
+	_, s, err := SchnorrPartialSign(curve, msg, reducedPriv, pub, privNonce,
+		pubNonceSum)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdaptorSignature{
+		Nonce:        pubNonce,
+		AdaptorPoint: adaptorPoint,
+		S:            s,
+	}, nil
+}
+
+// VerifyAdaptor checks that the adaptor signature asig of msg is valid for
+// the public key pub, that is s'G = Nonce + hash512(Nonce + T || A || M) * A.
+// A valid adaptor signature guarantees that learning the discrete log of the
+// adaptor point is enough to complete it.
+func VerifyAdaptor(curve *TwistedEdwardsCurve, pub *PublicKey, msg []byte,
+	asig *AdaptorSignature) bool {
+	if pub == nil || pub.GetX() == nil || pub.GetY() == nil || msg == nil ||
+		asig == nil || asig.Nonce == nil || asig.AdaptorPoint == nil ||
+		asig.S == nil {
+		return false
+	}
+	if asig.S.Sign() <= 0 || asig.S.Cmp(curve.N) >= 0 {
+		return false
+	}
+	if !curve.IsOnCurve(asig.Nonce.GetX(), asig.Nonce.GetY()) ||
+		!curve.IsOnCurve(asig.AdaptorPoint.GetX(), asig.AdaptorPoint.GetY()) {
+		return false
+	}
+
+	h, err := adaptorChallenge(curve, pub, msg, asig)
+	if err != nil {
+		return false
+	}
+
+	lX, lY := curve.ScalarBaseMult(asig.S.Bytes())
+	hX, hY := curve.ScalarMult(pub.GetX(), pub.GetY(), h.Bytes())
+	rX, rY := curve.Add(asig.Nonce.GetX(), asig.Nonce.GetY(), hX, hY)
+
+	return lX.Cmp(rX) == 0 && lY.Cmp(rY) == 0
+}
+
+// CompleteAdaptor decrypts the adaptor signature asig with the discrete log
+// secret of its adaptor point, producing a regular signature with
+// R = Nonce + T and s = s' + t.
+func CompleteAdaptor(curve *TwistedEdwardsCurve, asig *AdaptorSignature,
+	secret *big.Int) (*Signature, error) {
+	if asig == nil || asig.Nonce == nil || asig.AdaptorPoint == nil ||
+		asig.S == nil || secret == nil {
+		return nil, fmt.Errorf("nil input")
+	}
+
+	tX, tY := curve.ScalarBaseMult(secret.Bytes())
+	if !NewPublicKey(curve, tX, tY).ConstantTimeEqual(asig.AdaptorPoint) {
+		return nil, fmt.Errorf("secret does not match the adaptor point")
+	}
+
+	rX, rY := curve.Add(asig.Nonce.GetX(), asig.Nonce.GetY(), tX, tY)
+	r := EncodedBytesToBigInt(BigIntPointToEncodedBytes(rX, rY))
+	s := scalarMulAdd(one, asig.S, secret)
+	if s.Sign() == 0 {
+		return nil, fmt.Errorf("completed sig s is zero")
+	}
+
+	return NewSignature(r, s), nil
+}
+
+// ExtractSecret recovers the discrete log t of the adaptor point from an
+// adaptor signature and the signature completed from it, as t = s - s'.
+func ExtractSecret(curve *TwistedEdwardsCurve, asig *AdaptorSignature,
+	sig *Signature) (*big.Int, error) {
+	if asig == nil || asig.Nonce == nil || asig.AdaptorPoint == nil ||
+		asig.S == nil || sig == nil || sig.GetR() == nil || sig.GetS() == nil {
+		return nil, fmt.Errorf("nil input")
+	}
+
+	rX, rY := curve.Add(asig.Nonce.GetX(), asig.Nonce.GetY(),
+		asig.AdaptorPoint.GetX(), asig.AdaptorPoint.GetY())
+	r := EncodedBytesToBigInt(BigIntPointToEncodedBytes(rX, rY))
+	if r.Cmp(sig.GetR()) != 0 {
+		return nil, fmt.Errorf("signature was not completed from this " +
+			"adaptor signature")
+	}
+
+	t := new(big.Int).Sub(sig.GetS(), asig.S)
+	t.Mod(t, curve.N)
+
+	tX, tY := curve.ScalarBaseMult(t.Bytes())
+	if !NewPublicKey(curve, tX, tY).ConstantTimeEqual(asig.AdaptorPoint) {
+		return nil, fmt.Errorf("extracted secret does not match the " +
+			"adaptor point")
+	}
+
+	return t, nil
+}
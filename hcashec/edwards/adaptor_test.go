@@ -0,0 +1,83 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"testing"
+)
+
+// TestAdaptorSwap tests a complete swap with adaptor signatures, making sure
+// the adaptor secret can be extracted from the published signature
+func TestAdaptorSwap(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := sha256.Sum256([]byte("Hello World in TestAdaptorSwap"))
+
+	// The signer holds a regular key derived from a secret, the other
+	// party knows the adaptor secret.
+	alice := mockUpSecKeysByBytes(curve, 1)[0]
+	alicePubX, alicePubY := alice.Public()
+	alicePub := NewPublicKey(curve, alicePubX, alicePubY)
+	adaptorSecret := mockUpSecKeysByScalars(curve, 1)[0]
+	tX, tY := adaptorSecret.Public()
+	adaptorPoint := NewPublicKey(curve, tX, tY)
+
+	asig, err := SignAdaptor(curve, alice, msg[:], adaptorPoint)
+	if err != nil {
+		t.Fatalf("unexpected adaptor signing error: %v", err)
+	}
+	if !VerifyAdaptor(curve, alicePub, msg[:], asig) {
+		t.Fatalf("adaptor signature failed to verify")
+	}
+
+	// The adaptor signature must not verify against the wrong key or
+	// message, nor pass as a regular signature.
+	otherPubX, otherPubY := curve.Double(alicePubX, alicePubY)
+	otherPub := NewPublicKey(curve, otherPubX, otherPubY)
+	if VerifyAdaptor(curve, otherPub, msg[:], asig) {
+		t.Fatalf("adaptor signature verified for the wrong key")
+	}
+	otherMsg := sha256.Sum256([]byte("some other message"))
+	if VerifyAdaptor(curve, alicePub, otherMsg[:], asig) {
+		t.Fatalf("adaptor signature verified for the wrong message")
+	}
+	nonceR := EncodedBytesToBigInt(BigIntPointToEncodedBytes(
+		asig.Nonce.GetX(), asig.Nonce.GetY()))
+	if Verify(alicePub, msg[:], nonceR, asig.S) {
+		t.Fatalf("adaptor signature verified as a regular signature")
+	}
+
+	// Completing the adaptor signature yields a regular signature.
+	sig, err := CompleteAdaptor(curve, asig, adaptorSecret.GetD())
+	if err != nil {
+		t.Fatalf("unexpected completion error: %v", err)
+	}
+	if !Verify(alicePub, msg[:], sig.GetR(), sig.GetS()) {
+		t.Fatalf("completed signature failed to verify")
+	}
+	if _, err := CompleteAdaptor(curve, asig, big.NewInt(1)); err == nil {
+		t.Fatalf("completed with a secret not matching the adaptor point")
+	}
+
+	// Once the signature is published the secret can be extracted.
+	secret, err := ExtractSecret(curve, asig, sig)
+	if err != nil {
+		t.Fatalf("unexpected extraction error: %v", err)
+	}
+	if secret.Cmp(adaptorSecret.GetD()) != 0 {
+		t.Fatalf("extracted secret %v, want %v", secret,
+			adaptorSecret.GetD())
+	}
+
+	unrelated, err := SignDeterministic(curve, alice, msg[:])
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+	if _, err := ExtractSecret(curve, asig, unrelated); err == nil {
+		t.Fatalf("extracted a secret from an unrelated signature")
+	}
+}
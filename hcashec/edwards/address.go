@@ -0,0 +1,82 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"fmt"
+
+	"github.com/HcashOrg/hcashd/chaincfg"
+	"github.com/HcashOrg/hcashd/chaincfg/chainhash"
+	"github.com/HcashOrg/hcashutil/base58"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// AddressHashSize is the size of the public key hash encoded in an address.
+const AddressHashSize = ripemd160.Size
+
+// pubKeyHash returns RIPEMD160(BLAKE256(b)), the hash of a serialized public
+// key as used in pay-to-pubkey-hash addresses.
+func pubKeyHash(b []byte) []byte {
+	h := ripemd160.New()
+	h.Write(chainhash.HashB(b))
+	return h.Sum(nil)
+}
+
+// EncodeAddress returns the Edwards pay-to-pubkey-hash address of the public
+// key pub for the network netParams: the hash RIPEMD160(BLAKE256(pub)) of
+// the compressed key, prefixed with the PKHEdwardsAddrID of the network and
+// base58check encoded. The address is the same as the one of the
+// corresponding hcashutil address type. Only Ed25519 keys have an address
+// format, keys on other curves are rejected.
+func EncodeAddress(pub *PublicKey, netParams *chaincfg.Params) (string,
+	error) {
+	if pub == nil || pub.GetX() == nil || pub.GetY() == nil {
+		return "", fmt.Errorf("public key is nil")
+	}
+	if netParams == nil {
+		return "", fmt.Errorf("network parameters are nil")
+	}
+	curve, ok := pub.Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil || curve.isEd448() {
+		return "", fmt.Errorf("no address format for keys on this curve")
+	}
+
+	return base58.CheckEncode(pubKeyHash(pub.SerializeCompressed()),
+		netParams.PKHEdwardsAddrID), nil
+}
+
+// DecodeAddress decodes an Edwards pay-to-pubkey-hash address of the network
+// netParams, as produced by EncodeAddress, and returns the public key hash
+// it encodes. Addresses with a bad checksum, of another network or of
+// another address type are rejected. Since the address only holds a hash of
+// the key, AddressMatches checks it against a given public key.
+func DecodeAddress(addr string, netParams *chaincfg.Params) ([]byte, error) {
+	if netParams == nil {
+		return nil, fmt.Errorf("network parameters are nil")
+	}
+
+	hash, netID, err := base58.CheckDecode(addr)
+	if err != nil {
+		return nil, fmt.Errorf("malformed address: %v", err)
+	}
+	if netID != netParams.PKHEdwardsAddrID {
+		return nil, fmt.Errorf("address is not an Edwards pubkey hash "+
+			"address of network %v", netParams.Name)
+	}
+	if len(hash) != AddressHashSize {
+		return nil, fmt.Errorf("bad address hash size; have %v, want %v",
+			len(hash), AddressHashSize)
+	}
+
+	return hash, nil
+}
+
+// AddressMatches returns whether or not addr is the address of the public
+// key pub on the network netParams.
+func AddressMatches(addr string, pub *PublicKey,
+	netParams *chaincfg.Params) bool {
+	want, err := EncodeAddress(pub, netParams)
+	return err == nil && addr == want
+}
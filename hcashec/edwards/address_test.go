@@ -0,0 +1,85 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/HcashOrg/hcashd/chaincfg"
+)
+
+// TestAddress tests encoding and decoding the addresses of public keys on
+// the main and test networks, and that corrupted addresses are detected
+func TestAddress(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	nets := []struct {
+		params *chaincfg.Params
+		prefix string
+	}{
+		{&chaincfg.MainNetParams, "H"},
+		{&chaincfg.TestNet2Params, "T"},
+	}
+	for i, sk := range mockUpSecKeysByBytes(curve, 5) {
+		pub := sk.PubKey()
+		wantHash := pubKeyHash(pub.SerializeCompressed())
+
+		for _, net := range nets {
+			addr, err := EncodeAddress(pub, net.params)
+			if err != nil {
+				t.Fatalf("test %d: unexpected encoding error: %v", i, err)
+			}
+			if !strings.HasPrefix(addr, net.prefix) {
+				t.Fatalf("test %d: address %v on %v does not start with %v",
+					i, addr, net.params.Name, net.prefix)
+			}
+			hash, err := DecodeAddress(addr, net.params)
+			if err != nil {
+				t.Fatalf("test %d: unexpected decoding error: %v", i, err)
+			}
+			if !bytes.Equal(hash, wantHash) {
+				t.Fatalf("test %d: decoded hash %x, want %x", i, hash,
+					wantHash)
+			}
+			if !AddressMatches(addr, pub, net.params) {
+				t.Fatalf("test %d: address does not match its key", i)
+			}
+			if AddressMatches(addr, mockUpSecKeysByBytes(curve, 6)[5].PubKey(),
+				net.params) {
+				t.Fatalf("test %d: address matches another key", i)
+			}
+
+			// A changed character breaks the checksum.
+			for _, pos := range []int{2, len(addr) / 2, len(addr) - 1} {
+				c := byte('2')
+				if addr[pos] == c {
+					c = '3'
+				}
+				corrupted := addr[:pos] + string(c) + addr[pos+1:]
+				if _, err := DecodeAddress(corrupted, net.params); err == nil {
+					t.Fatalf("test %d: decoded corrupted address %v", i,
+						corrupted)
+				}
+			}
+		}
+
+		// Addresses of one network are rejected on the other.
+		mainAddr, _ := EncodeAddress(pub, &chaincfg.MainNetParams)
+		if _, err := DecodeAddress(mainAddr,
+			&chaincfg.TestNet2Params); err == nil {
+			t.Fatalf("test %d: decoded a mainnet address on testnet", i)
+		}
+	}
+
+	if _, err := EncodeAddress(nil, &chaincfg.MainNetParams); err == nil {
+		t.Fatalf("encoded the address of a nil key")
+	}
+	if _, err := DecodeAddress("", &chaincfg.MainNetParams); err == nil {
+		t.Fatalf("decoded an empty address")
+	}
+}
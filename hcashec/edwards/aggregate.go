@@ -0,0 +1,163 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// aggregateListTag is the domain separation tag of the commitment to the
+// signers and messages of an aggregate signature.
+const aggregateListTag = "edwards25519 aggregate list"
+
+// aggregateChallengeTag is the domain separation tag of the challenges of
+// aggregate signatures.
+const aggregateChallengeTag = "edwards25519 aggregate challenge"
+
+// aggregateListHash computes L = taggedHash(tag, pk_1 || len(m_1) || m_1 ||
+// ... || pk_n || len(m_n) || m_n), the commitment to every signer and
+// message pair that each challenge of an aggregate signature depends on.
+func aggregateListHash(pubs []*PublicKey, msgs [][]byte) []byte {
+	data := make([][]byte, 0, 3*len(pubs))
+	for i := range pubs {
+		size := make([]byte, 4)
+		binary.BigEndian.PutUint32(size, uint32(len(msgs[i])))
+		data = append(data, pubs[i].Serialize(), size, msgs[i])
+	}
+	return taggedHash(aggregateListTag, data...)
+}
+
+// aggregateChallenge computes c_i = taggedHash(tag, R || L || pk_i || m_i)
+// mod N, the challenge of a single signer of an aggregate signature.
+func aggregateChallenge(encodedR []byte, l []byte, pub *PublicKey,
+	msg []byte) *big.Int {
+	return EncodedBytesToBigInt(taggedScalar(aggregateChallengeTag, encodedR,
+		l, pub.Serialize(), msg))
+}
+
+// checkAggregateInput makes sure there is one message for every public key
+// and that none of them is missing.
+func checkAggregateInput(pubs []*PublicKey, msgs [][]byte) error {
+	if len(pubs) == 0 {
+		return fmt.Errorf("no signers")
+	}
+	if len(pubs) != len(msgs) {
+		return fmt.Errorf("got %v public keys but %v messages", len(pubs),
+			len(msgs))
+	}
+	for i := range pubs {
+		if pubs[i] == nil || pubs[i].GetX() == nil || pubs[i].GetY() == nil {
+			return fmt.Errorf("public key %v is nil", i)
+		}
+		if msgs[i] == nil {
+			return fmt.Errorf("message %v is nil", i)
+		}
+	}
+
+	return nil
+}
+
+// SignAggregateMulti creates the partial signature of the signer at index of
+// pubs for an aggregate signature where every signer signs its own message,
+// msgs[index] for this one. Unlike SchnorrPartialSign, each signer gets its
+// own challenge c_i = taggedHash(tag, R || L || pk_i || m_i), where L commits to all
+// the public key and message pairs, so that s_i = k_i + c_i * a_i. The
+// partial signatures of all signers, made with the same pubNonceSum, are
+// combined with SchnorrCombineSigs and the result is checked with
+// VerifyAggregateMulti. As with any multisignature, a secret nonce must never
+// be used for two signing sessions.
+func SignAggregateMulti(curve *TwistedEdwardsCurve, priv *PrivateKey,
+	privNonce *PrivateKey, pubs []*PublicKey, msgs [][]byte, index int,
+	pubNonceSum *PublicKey) (*big.Int, *big.Int, error) {
+	if priv == nil || privNonce == nil || pubNonceSum == nil ||
+		pubNonceSum.GetX() == nil || pubNonceSum.GetY() == nil {
+		return nil, nil, fmt.Errorf("nil input")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, nil, err
+	}
+	if err := privNonce.signingErr(); err != nil {
+		return nil, nil, err
+	}
+	if err := checkAggregateInput(pubs, msgs); err != nil {
+		return nil, nil, err
+	}
+	if index < 0 || index >= len(pubs) {
+		return nil, nil, fmt.Errorf("signer index %v out of range", index)
+	}
+	if !priv.PubKey().Equal(pubs[index]) {
+		return nil, nil, fmt.Errorf("private key does not match public key "+
+			"%v", index)
+	}
+	if !curve.IsOnCurve(pubNonceSum.GetX(), pubNonceSum.GetY()) {
+		return nil, nil, fmt.Errorf("public nonce sum is off curve")
+	}
+
+	encodedR := BigIntPointToEncodedBytes(pubNonceSum.GetX(),
+		pubNonceSum.GetY())
+	c := aggregateChallenge(encodedR[:], aggregateListHash(pubs, msgs),
+		pubs[index], msgs[index])
+
+	a := priv.reducedScalar(curve)
+	defer a.SetInt64(0)
+	k := privNonce.reducedScalar(curve)
+	defer k.SetInt64(0)
+	s := scalarMulAdd(c, a, k)
+	if s.Sign() == 0 {
+		return nil, nil, fmt.Errorf("partial sig s is zero")
+	}
+
+	return EncodedBytesToBigInt(encodedR), s, nil
+}
+
+// VerifyAggregateMulti verifies an aggregate signature where each public key
+// of pubs signed the message of msgs at the same index, as produced by
+// combining the partial signatures of SignAggregateMulti, that is
+// sG = R + sum c_i * pk_i. Non-canonical signatures are rejected.
+func VerifyAggregateMulti(curve *TwistedEdwardsCurve, pubs []*PublicKey,
+	msgs [][]byte, sig *Signature) bool {
+	if sig == nil || sig.GetR() == nil || sig.GetS() == nil ||
+		!IsCanonical(sig) {
+		return false
+	}
+	if checkAggregateInput(pubs, msgs) != nil {
+		return false
+	}
+
+	encodedR := BigIntToEncodedBytes(sig.GetR())
+	var r edwards25519.ExtendedGroupElement
+	if !r.FromBytes(encodedR) {
+		return false
+	}
+
+	// Compute sG + sum (N - c_i) * pk_i, which must be R.
+	var g edwards25519.ExtendedGroupElement
+	g.FromBytes(BigIntPointToEncodedBytes(curve.Gx, curve.Gy))
+	scalars := []*[32]byte{BigIntToEncodedBytes(sig.GetS())}
+	points := []*edwards25519.ExtendedGroupElement{&g}
+	l := aggregateListHash(pubs, msgs)
+	for i := range pubs {
+		p := new(edwards25519.ExtendedGroupElement)
+		if !p.FromBytes(BigIntPointToEncodedBytes(pubs[i].GetX(),
+			pubs[i].GetY())) {
+			return false
+		}
+		c := aggregateChallenge(encodedR[:], l, pubs[i], msgs[i])
+		scalars = append(scalars, BigIntToEncodedBytes(
+			new(big.Int).Sub(curve.N, c)))
+		points = append(points, p)
+	}
+
+	var check edwards25519.ExtendedGroupElement
+	multiScalarMultVartime(&check, scalars, points)
+	var checkR [32]byte
+	check.ToBytes(&checkR)
+
+	return checkR == *encodedR
+}
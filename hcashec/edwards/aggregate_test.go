@@ -0,0 +1,80 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+// TestAggregateMulti tests aggregating the signatures of signers which each
+// sign a different message
+func TestAggregateMulti(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	const numSigners = 3
+	msgs := make([][]byte, numSigners)
+	for i := range msgs {
+		msg := sha256.Sum256([]byte(fmt.Sprintf("input %v in "+
+			"TestAggregateMulti", i)))
+		msgs[i] = msg[:]
+	}
+	keyVec := mockUpSchnorrKeyVec(curve, numSigners, msgs[0])
+	sks, pubs := keyVec.skVec, keyVec.pkVec
+	privNonces, pubNonceSum := keyVec.secNonceVec, keyVec.pubNonceVecSum
+
+	partials := make([]*Signature, numSigners)
+	for i, sk := range sks {
+		r, s, err := SignAggregateMulti(curve, sk, privNonces[i], pubs, msgs,
+			i, pubNonceSum)
+		if err != nil {
+			t.Fatalf("unexpected partial signing error: %v", err)
+		}
+		partials[i] = NewSignature(r, s)
+	}
+	sig, err := SchnorrCombineSigs(curve, partials)
+	if err != nil {
+		t.Fatalf("unexpected combining error: %v", err)
+	}
+	if !VerifyAggregateMulti(curve, pubs, msgs, sig) {
+		t.Fatalf("aggregate signature failed to verify")
+	}
+
+	// Each key must be tied to its own message.
+	swapped := [][]byte{msgs[1], msgs[0], msgs[2]}
+	if VerifyAggregateMulti(curve, pubs, swapped, sig) {
+		t.Fatalf("aggregate signature verified with swapped messages")
+	}
+	other := sha256.Sum256([]byte("some other message"))
+	if VerifyAggregateMulti(curve, pubs, [][]byte{msgs[0], msgs[1],
+		other[:]}, sig) {
+		t.Fatalf("aggregate signature verified with the wrong message")
+	}
+	if VerifyAggregateMulti(curve, pubs[:2], msgs[:2], sig) {
+		t.Fatalf("aggregate signature verified for a subset of signers")
+	}
+	if VerifyAggregateMulti(curve, pubs, msgs[:2], sig) {
+		t.Fatalf("aggregate signature verified with a missing message")
+	}
+	if VerifyAggregateMulti(curve, pubs, msgs, partials[0]) {
+		t.Fatalf("partial signature verified as the aggregate")
+	}
+
+	// A signer can only sign at its own index.
+	if _, _, err := SignAggregateMulti(curve, sks[0], privNonces[0], pubs,
+		msgs, 1, pubNonceSum); err == nil {
+		t.Fatalf("expected error signing at another signer's index")
+	}
+	if _, _, err := SignAggregateMulti(curve, sks[0], privNonces[0], pubs,
+		msgs, numSigners, pubNonceSum); err == nil {
+		t.Fatalf("expected error signing at an out of range index")
+	}
+	if _, _, err := SignAggregateMulti(curve, sks[0], privNonces[0], pubs,
+		msgs[:2], 0, pubNonceSum); err == nil {
+		t.Fatalf("expected error signing with a missing message")
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/subtle"
+	"math/big"
+	"sync"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+var (
+	// baseTable holds j * 16^i * G for every 4-bit window i of a scalar
+	// and every window value j, so that a base point multiplication takes
+	// only 64 additions and no doublings.
+	baseTable     *[64][16]cachedGroupElement
+	baseTableOnce sync.Once
+)
+
+// buildMultTable fills table with j * 16^i * p for every 4-bit window i of a
+// scalar and every window value j.
+func buildMultTable(table *[64][16]cachedGroupElement,
+	p edwards25519.ExtendedGroupElement) {
+	for i := range table {
+		// table[i][0] is the identity, table[i][1] = 16^i * p.
+		var acc edwards25519.ExtendedGroupElement
+		acc.Zero()
+		toCached(&table[i][0], &acc)
+		toCached(&table[i][1], &p)
+		acc = p
+		for j := 2; j < 16; j++ {
+			var c edwards25519.CompletedGroupElement
+			geAdd(&c, &acc, &table[i][1])
+			c.ToExtended(&acc)
+			toCached(&table[i][j], &acc)
+		}
+
+		// Move on to 16^(i+1) * p.
+		for k := 0; k < 4; k++ {
+			var c edwards25519.CompletedGroupElement
+			p.Double(&c)
+			c.ToExtended(&p)
+		}
+	}
+}
+
+// getBaseTable returns the precomputed table of base point multiples,
+// building it on first use.
+func getBaseTable(curve *TwistedEdwardsCurve) *[64][16]cachedGroupElement {
+	baseTableOnce.Do(func() {
+		table := new([64][16]cachedGroupElement)
+
+		var g edwards25519.ExtendedGroupElement
+		g.FromBytes(BigIntPointToEncodedBytes(curve.Gx, curve.Gy))
+		buildMultTable(table, g)
+
+		baseTable = table
+	})
+
+	return baseTable
+}
+
+// tableMultAddVartime adds k*p to r, where table holds the multiples of p
+// built by buildMultTable and k is a reduced little endian scalar. Windows of
+// k that are zero are skipped, so it must only be used with public scalars.
+func tableMultAddVartime(r *edwards25519.ExtendedGroupElement,
+	table *[64][16]cachedGroupElement, k *[32]byte) {
+	for i := 0; i < 64; i++ {
+		nibble := (k[i/2] >> (uint(i%2) * 4)) & 0x0f
+		if nibble == 0 {
+			continue
+		}
+
+		var c edwards25519.CompletedGroupElement
+		geAdd(&c, r, &table[i][nibble])
+		c.ToExtended(r)
+	}
+}
+
+// selectCached sets r to row[index] in constant time, scanning the whole
+// row so the memory access pattern doesn't depend on the secret index.
+func selectCached(r *cachedGroupElement, row *[16]cachedGroupElement,
+	index byte) {
+	*r = row[0]
+	for j := 1; j < 16; j++ {
+		b := int32(subtle.ConstantTimeByteEq(byte(j), index))
+		edwards25519.FeCMove(&r.yPlusX, &row[j].yPlusX, b)
+		edwards25519.FeCMove(&r.yMinusX, &row[j].yMinusX, b)
+		edwards25519.FeCMove(&r.Z, &row[j].Z, b)
+		edwards25519.FeCMove(&r.T2d, &row[j].T2d, b)
+	}
+}
+
+// BaseMult returns k*G, where G is the base point of the group and k is an
+// integer in big-endian form, using a table of precomputed multiples of G.
+// The table is built once on first use. The table lookups are constant time,
+// so it's suitable for secret scalars such as private keys and nonces. On
+// Ed448 there is no table and the constant time ScalarMultConstantTime is
+// used instead.
+func (curve *TwistedEdwardsCurve) BaseMult(k []byte) (x, y *big.Int) {
+	if curve.isEd448() {
+		return curve.ScalarMultConstantTime(curve.Gx, curve.Gy, k)
+	}
+
+	table := getBaseTable(curve)
+
+	// G has order N, so k can be reduced first.
+	kLE := make([]byte, len(k))
+	for i, b := range k {
+		kLE[len(k)-1-i] = b
+	}
+	scalar := ScalarReduce(kLE)
+	zeroSlice(kLE)
+	defer zeroSlice(scalar[:])
+
+	var r edwards25519.ExtendedGroupElement
+	r.Zero()
+	for i := 0; i < 64; i++ {
+		nibble := (scalar[i/2] >> (uint(i%2) * 4)) & 0x0f
+
+		var q cachedGroupElement
+		selectCached(&q, &table[i], nibble)
+		var c edwards25519.CompletedGroupElement
+		geAdd(&c, &r, &q)
+		c.ToExtended(&r)
+	}
+
+	finalBytes := new([32]byte)
+	r.ToBytes(finalBytes)
+
+	var err error
+	x, y, err = curve.EncodedBytesToBigIntPoint(finalBytes)
+	if err != nil {
+		return nil, nil
+	}
+
+	return
+}
@@ -0,0 +1,271 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"io"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// batchCoefficientSize is the size in bytes of the random coefficients used
+// to combine the signatures of a batch. 128 bits make the chance of a forged
+// batch going undetected negligible while keeping the multiplications short.
+const batchCoefficientSize = 16
+
+// multiScalarMultVartime computes the sum of scalars[i] * points[i] using
+// Straus' interleaving method with 4-bit fixed windows, storing the result
+// in r. All the points share the same doublings, which is where the saving
+// over computing each multiplication separately comes from. The scalars are
+// expected to be 32 byte little endian integers. This is variable time and
+// must only be used with public inputs.
+func multiScalarMultVartime(r *edwards25519.ExtendedGroupElement,
+	scalars []*[32]byte, points []*edwards25519.ExtendedGroupElement) {
+	// Precompute 0P, 1P, ..., 15P for every point.
+	tables := make([][16]cachedGroupElement, len(points))
+	for i, p := range points {
+		var acc edwards25519.ExtendedGroupElement
+		acc.Zero()
+		toCached(&tables[i][0], &acc)
+		toCached(&tables[i][1], p)
+		acc = *p
+		for j := 2; j < 16; j++ {
+			var c edwards25519.CompletedGroupElement
+			geAdd(&c, &acc, &tables[i][1])
+			c.ToExtended(&acc)
+			toCached(&tables[i][j], &acc)
+		}
+	}
+
+	r.Zero()
+	for w := 63; w >= 0; w-- {
+		if w != 63 {
+			for k := 0; k < 4; k++ {
+				var c edwards25519.CompletedGroupElement
+				r.Double(&c)
+				c.ToExtended(r)
+			}
+		}
+
+		for i, s := range scalars {
+			nibble := (s[w/2] >> (uint(w%2) * 4)) & 0x0f
+			if nibble == 0 {
+				continue
+			}
+			var c edwards25519.CompletedGroupElement
+			geAdd(&c, r, &tables[i][nibble])
+			c.ToExtended(r)
+		}
+	}
+}
+
+// BatchVerify verifies a batch of signatures over the messages msgs using
+// the corresponding public keys pubkeys. It checks the random linear
+// combination [sum z_i*S_i]B = sum [z_i]R_i + sum [z_i*h_i]A_i with random
+// 128-bit coefficients z_i, so that the whole batch costs a single base
+// point multiplication and one multiscalar multiplication instead of two
+// scalar multiplications per signature.
+//
+// The result is the same as that of verifying every signature with Verify.
+// Signatures whose R or public key has a small order component, on which
+// the batch equation would depend on the coefficients, are left out of the
+// batch and verified with Verify directly.
+//
+// If the batch verifies, true and a nil slice are returned. Otherwise, each
+// signature is verified individually with Verify and false is returned along
+// with the indices of the signatures that failed. Empty input verifies
+// trivially. Slices of mismatched lengths are malformed input and make
+// BatchVerify return false with a nil slice of indices.
+func BatchVerify(curve *TwistedEdwardsCurve, pubkeys []*PublicKey,
+	msgs [][]byte, sigs []*Signature) (bool, []int) {
+	if len(pubkeys) != len(msgs) || len(pubkeys) != len(sigs) {
+		return false, nil
+	}
+	if len(sigs) == 0 {
+		return true, nil
+	}
+
+	return batchVerify(curve, rand.Reader, pubkeys, msgs, sigs)
+}
+
+// BatchMode selects how BatchVerifyWithMode verifies a batch of signatures.
+type BatchMode int
+
+// These constants define the available batch verification modes.
+const (
+	// BatchAggregate checks the whole batch at once like BatchVerify, and
+	// only verifies the signatures one by one to find the culprits if the
+	// batch fails. It's the fastest way to verify a batch of valid
+	// signatures.
+	BatchAggregate BatchMode = iota
+
+	// BatchFailFast verifies the signatures one by one with Verify, in
+	// order, and stops at the first that fails. That's faster than
+	// BatchAggregate when invalid signatures are likely and tend to come
+	// early, such as for a block that can be rejected as soon as one of its
+	// signatures is bad.
+	BatchFailFast
+)
+
+// String returns the BatchMode as a human-readable name.
+func (m BatchMode) String() string {
+	switch m {
+	case BatchAggregate:
+		return "BatchAggregate"
+	case BatchFailFast:
+		return "BatchFailFast"
+	}
+	return "Unknown BatchMode"
+}
+
+// BatchVerifyWithMode verifies a batch of signatures like BatchVerify, with
+// the strategy selected by mode. BatchAggregate is the same as BatchVerify.
+// With BatchFailFast, the signatures are verified serially and false is
+// returned along with only the index of the first signature that failed,
+// without looking at the rest of the batch. Empty input verifies trivially,
+// while slices of mismatched lengths and unknown modes make it return false
+// with a nil slice of indices.
+func BatchVerifyWithMode(curve *TwistedEdwardsCurve, pubkeys []*PublicKey,
+	msgs [][]byte, sigs []*Signature, mode BatchMode) (bool, []int) {
+	if mode == BatchAggregate {
+		return BatchVerify(curve, pubkeys, msgs, sigs)
+	}
+	if mode != BatchFailFast {
+		return false, nil
+	}
+	if len(pubkeys) != len(msgs) || len(pubkeys) != len(sigs) {
+		return false, nil
+	}
+
+	for i, sig := range sigs {
+		if sig == nil || !Verify(pubkeys[i], msgs[i], sig.R, sig.S) {
+			return false, []int{i}
+		}
+	}
+
+	return true, nil
+}
+
+// batchVerify is the implementation of BatchVerify, taking the source of
+// randomness for the batch coefficients as an argument.
+func batchVerify(curve *TwistedEdwardsCurve, rand io.Reader,
+	pubkeys []*PublicKey, msgs [][]byte, sigs []*Signature) (bool, []int) {
+	numSigs := len(sigs)
+	scalars := make([]*[32]byte, 0, 2*numSigs)
+	points := make([]*edwards25519.ExtendedGroupElement, 0, 2*numSigs)
+	sumS := new(big.Int)
+	batchFailed := false
+
+	// Signatures which can't go through the batch equation are verified
+	// directly instead.
+	direct := make([]bool, numSigs)
+	for i := 0; i < numSigs; i++ {
+		pub, msg, sig := pubkeys[i], msgs[i], sigs[i]
+		encodedA, encodedR, A, R, ok := batchTerms(pub, msg, sig)
+		if !ok {
+			direct[i] = true
+			continue
+		}
+
+		var zBytes [32]byte
+		if _, err := io.ReadFull(rand, zBytes[:batchCoefficientSize]); err != nil {
+			batchFailed = true
+			break
+		}
+		z := EncodedBytesToBigInt(&zBytes)
+
+		// h = hash512(R || A || M)
+		var hramDigest [64]byte
+		h := sha512.New()
+		h.Write(encodedR[:])
+		h.Write(encodedA[:])
+		h.Write(msg)
+		h.Sum(hramDigest[:0])
+		var hramDigestReduced [32]byte
+		edwards25519.ScReduce(&hramDigestReduced, &hramDigest)
+		hram := EncodedBytesToBigInt(&hramDigestReduced)
+
+		zh := new(big.Int).Mul(z, hram)
+		zh.Mod(zh, curve.N)
+		scalars = append(scalars, &zBytes, BigIntToEncodedBytes(zh))
+		points = append(points, R, A)
+
+		zs := new(big.Int).Mul(z, sig.S)
+		sumS.Add(sumS, zs)
+		sumS.Mod(sumS, curve.N)
+	}
+
+	if !batchFailed {
+		var lhs edwards25519.ExtendedGroupElement
+		edwards25519.GeScalarMultBase(&lhs, BigIntToEncodedBytes(sumS))
+		var rhs edwards25519.ExtendedGroupElement
+		multiScalarMultVartime(&rhs, scalars, points)
+
+		var lhsBytes, rhsBytes [32]byte
+		lhs.ToBytes(&lhsBytes)
+		rhs.ToBytes(&rhsBytes)
+		batchFailed = lhsBytes != rhsBytes
+	}
+
+	// Verify the signatures left out of the batch, and if the batch failed,
+	// every signature on its own to find the culprits.
+	var failed []int
+	for i := 0; i < numSigs; i++ {
+		if !direct[i] && !batchFailed {
+			continue
+		}
+		sig := sigs[i]
+		if sig == nil || !Verify(pubkeys[i], msgs[i], sig.R, sig.S) {
+			failed = append(failed, i)
+		}
+	}
+	if len(failed) == 0 {
+		return true, nil
+	}
+
+	return false, failed
+}
+
+// batchTerms decodes the public key and nonce point of the signature sig of
+// msg under pub for the batch equation, returning their encodings and
+// points. It returns false for any signature the batch equation might not
+// judge the way Verify does. The equation holds for every signature with R
+// and A in the prime order subgroup that Verify accepts, and for no other
+// such signature except with negligible probability, but a small order
+// component in R or A adds a torsion term that, depending on the random
+// coefficient, may cancel out or not. So R and A must both be canonically
+// encoded points of the prime order subgroup, S must be canonical and the
+// key must be an Ed25519 key.
+func batchTerms(pub *PublicKey, msg []byte, sig *Signature) (*[32]byte,
+	*[32]byte, *edwards25519.ExtendedGroupElement,
+	*edwards25519.ExtendedGroupElement, bool) {
+	if pub == nil || pub.X == nil || pub.Y == nil || msg == nil ||
+		sig == nil || !IsCanonical(sig) {
+		return nil, nil, nil, nil, false
+	}
+	curve, ok := pub.Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil || curve.isEd448() {
+		return nil, nil, nil, nil, false
+	}
+
+	encodedA := BigIntPointToEncodedBytes(pub.X, pub.Y)
+	encodedR := BigIntToEncodedBytes(sig.R)
+	A := new(edwards25519.ExtendedGroupElement)
+	R := new(edwards25519.ExtendedGroupElement)
+	if !A.FromBytes(encodedA) || !R.FromBytes(encodedR) {
+		return nil, nil, nil, nil, false
+	}
+	var reencodedR [32]byte
+	R.ToBytes(&reencodedR)
+	if reencodedR != *encodedR || !curve.isPrimeOrderPoint(R) ||
+		!curve.isPrimeOrderPoint(A) {
+		return nil, nil, nil, nil, false
+	}
+
+	return encodedA, encodedR, A, R, true
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+// sigListToSlices splits a list of signature records into the parallel
+// slices taken by BatchVerify
+func sigListToSlices(sigList []*SignatureVerParams) ([]*PublicKey, [][]byte,
+	[]*Signature) {
+	pubs := make([]*PublicKey, len(sigList))
+	msgs := make([][]byte, len(sigList))
+	sigs := make([]*Signature, len(sigList))
+	for i, sv := range sigList {
+		pubs[i], msgs[i], sigs[i] = sv.pubkey, sv.msg, sv.sig
+	}
+
+	return pubs, msgs, sigs
+}
+
+// TestBatchVerify tests batch verification of valid and invalid batches
+func TestBatchVerify(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	pubs, msgs, sigs := sigListToSlices(mockUpSigList(curve, 16))
+	if ok, failed := BatchVerify(curve, pubs, msgs, sigs); !ok || failed != nil {
+		t.Fatalf("valid batch failed to verify, failed indices %v", failed)
+	}
+
+	// Empty input verifies trivially.
+	if ok, failed := BatchVerify(curve, nil, nil, nil); !ok || failed != nil {
+		t.Fatalf("empty batch failed to verify")
+	}
+
+	// Mismatched lengths are malformed.
+	if ok, failed := BatchVerify(curve, pubs, msgs[1:], sigs); ok ||
+		failed != nil {
+		t.Fatalf("expected mismatched batch to be rejected")
+	}
+
+	// Corrupt a couple of signatures and make sure exactly those are
+	// reported.
+	badSigs := make([]*Signature, len(sigs))
+	copy(badSigs, sigs)
+	badSigs[3] = NewSignature(sigs[3].R, new(big.Int).Add(sigs[3].S, one))
+	badSigs[11] = NewSignature(sigs[12].R, sigs[12].S)
+	ok, failed := BatchVerify(curve, pubs, msgs, badSigs)
+	if ok {
+		t.Fatalf("batch with bad signatures verified")
+	}
+	if len(failed) != 2 || failed[0] != 3 || failed[1] != 11 {
+		t.Fatalf("want failed indices [3 11], got %v", failed)
+	}
+
+	// An R which isn't a point and a nil signature must not panic.
+	badSigs = make([]*Signature, len(sigs))
+	copy(badSigs, sigs)
+	badSigs[0] = NewSignature(new(big.Int).SetInt64(2), sigs[0].S)
+	badSigs[5] = nil
+	ok, failed = BatchVerify(curve, pubs, msgs, badSigs)
+	if ok || len(failed) != 2 || failed[0] != 0 || failed[1] != 5 {
+		t.Fatalf("want failed indices [0 5], got %v", failed)
+	}
+}
+
+// TestBatchVerifyFailFast tests that BatchFailFast accepts valid batches
+// and reports only the first invalid signature of a batch, while
+// BatchAggregate reports all of them
+func TestBatchVerifyFailFast(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	pubs, msgs, sigs := sigListToSlices(mockUpSigList(curve, 12))
+	for _, mode := range []BatchMode{BatchAggregate, BatchFailFast} {
+		if ok, failed := BatchVerifyWithMode(curve, pubs, msgs, sigs,
+			mode); !ok || failed != nil {
+			t.Fatalf("%v: valid batch failed to verify, failed indices %v",
+				mode, failed)
+		}
+		if ok, failed := BatchVerifyWithMode(curve, nil, nil, nil,
+			mode); !ok || failed != nil {
+			t.Fatalf("%v: empty batch failed to verify", mode)
+		}
+		if ok, failed := BatchVerifyWithMode(curve, pubs, msgs[1:], sigs,
+			mode); ok || failed != nil {
+			t.Fatalf("%v: expected mismatched batch to be rejected", mode)
+		}
+	}
+	if ok, _ := BatchVerifyWithMode(curve, pubs, msgs, sigs,
+		BatchMode(7)); ok {
+		t.Fatalf("unknown mode verified a batch")
+	}
+
+	// Bad signatures at 4, 7 and 10, including a nil one.
+	badSigs := make([]*Signature, len(sigs))
+	copy(badSigs, sigs)
+	badSigs[4] = NewSignature(sigs[4].R, new(big.Int).Add(sigs[4].S, one))
+	badSigs[7] = nil
+	badSigs[10] = NewSignature(sigs[9].R, sigs[9].S)
+	ok, failed := BatchVerifyWithMode(curve, pubs, msgs, badSigs,
+		BatchFailFast)
+	if ok || len(failed) != 1 || failed[0] != 4 {
+		t.Fatalf("want failed indices [4], got %v", failed)
+	}
+	ok, failed = BatchVerifyWithMode(curve, pubs, msgs, badSigs,
+		BatchAggregate)
+	if ok || len(failed) != 3 || failed[0] != 4 || failed[1] != 7 ||
+		failed[2] != 10 {
+		t.Fatalf("want failed indices [4 7 10], got %v", failed)
+	}
+
+	// A bad first signature stops the batch right away.
+	badSigs[0] = NewSignature(sigs[1].R, sigs[1].S)
+	ok, failed = BatchVerifyWithMode(curve, pubs, msgs, badSigs,
+		BatchFailFast)
+	if ok || len(failed) != 1 || failed[0] != 0 {
+		t.Fatalf("want failed indices [0], got %v", failed)
+	}
+}
+
+// TestBatchVerifyTorsion tests that signatures whose R or public key has a
+// small order component get the result of Verify, even with coefficients
+// that cancel the torsion out of the batch equation
+func TestBatchVerifyTorsion(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	// T = (0, -1) of order 2 vanishes from the batch equation under even
+	// coefficients.
+	tx, ty := new(big.Int), new(big.Int).Sub(curve.P, one)
+	even := bytes.Repeat([]byte{0x02}, 64*batchCoefficientSize)
+
+	pubs, msgs, sigs := sigListToSlices(mockUpSigList(curve, 4))
+	for i, sk := range mockUpSecKeysByBytes(curve, 8) {
+		pub := sk.PubKey()
+		a := sk.reducedScalar(curve)
+		msg := []byte{byte(i), 'm', 's', 'g'}
+
+		// R = rB + T, which Verify always rejects.
+		sig, _ := divergentSig(t, curve, a, pub.GetX(), pub.GetY(), tx, ty,
+			msg)
+		if Verify(pub, msg, sig.R, sig.S) {
+			t.Fatalf("test %d: Verify accepted a torsion R", i)
+		}
+		ok, failed := batchVerify(curve, bytes.NewReader(even),
+			append(pubs[:4:4], pub), append(msgs[:4:4], msg),
+			append(sigs[:4:4], sig))
+		if ok || len(failed) != 1 || failed[0] != 4 {
+			t.Fatalf("test %d: want failed indices [4] for a torsion R, "+
+				"got %v", i, failed)
+		}
+
+		// A = aB + T, which Verify accepts only if the challenge is even.
+		ax, ay := curve.Add(pub.GetX(), pub.GetY(), tx, ty)
+		mixedPub := NewPublicKey(curve, ax, ay)
+		sig, _ = divergentSig(t, curve, a, ax, ay, zero, one, msg)
+		want := Verify(mixedPub, msg, sig.R, sig.S)
+		ok, failed = batchVerify(curve, bytes.NewReader(even),
+			append(pubs[:4:4], mixedPub), append(msgs[:4:4], msg),
+			append(sigs[:4:4], sig))
+		if ok != want || (!ok && (len(failed) != 1 || failed[0] != 4)) {
+			t.Fatalf("test %d: got %v, %v for a torsion A, want %v", i, ok,
+				failed, want)
+		}
+	}
+}
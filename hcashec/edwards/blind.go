@@ -0,0 +1,183 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"fmt"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// BlindingFactors holds the secret state of the requester of a blind
+// signature between blinding the challenge and unblinding the signer's
+// response. It must not be shared with the signer.
+type BlindingFactors struct {
+	Alpha *big.Int
+	Beta  *big.Int
+
+	// R is the nonce of the unblinded signature, R = R' + alpha*G +
+	// beta*A.
+	R *PublicKey
+
+	pub       *PublicKey
+	pubNonce  *PublicKey
+	challenge *big.Int
+}
+
+// NewBlindNonce generates a random secret nonce k and its public nonce
+// R' = kG for the signer of a blind signature. The message is unknown to the
+// signer, so unlike GenerateNoncePair the nonce can't be derived from it.
+// The public nonce is sent to the requester, possibly after a commitment to
+// it with NonceCommitment, and the secret nonce is later passed to
+// BlindSign.
+func NewBlindNonce(curve *TwistedEdwardsCurve) (*PrivateKey, *PublicKey,
+	error) {
+	k, err := NewRandomScalar(curve, rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer k.SetInt64(0)
+	kBytes := BigIntToEncodedBytesNoReverse(k)
+	defer zeroSlice(kBytes[:])
+
+	return PrivKeyFromScalar(curve, kBytes[:])
+}
+
+// Blind blinds the message msg for a signature by the signer with public key
+// pub and public nonce pubNonce. It picks random alpha and beta, computes the
+// nonce R = R' + alpha*G + beta*A of the final signature and returns the
+// blinded challenge c' = hash512(R || A || M) + beta to send to the signer,
+// along with the blinding factors needed by Unblind. Neither R nor the
+// challenge seen by the signer can be linked to the final signature.
+func Blind(curve *TwistedEdwardsCurve, pub, pubNonce *PublicKey,
+	msg []byte) (*BlindingFactors, *big.Int, error) {
+	if pub == nil || pub.GetX() == nil || pub.GetY() == nil ||
+		pubNonce == nil || pubNonce.GetX() == nil || pubNonce.GetY() == nil ||
+		msg == nil {
+		return nil, nil, fmt.Errorf("nil input")
+	}
+	if !curve.IsOnCurve(pub.GetX(), pub.GetY()) {
+		return nil, nil, fmt.Errorf("public key is off curve")
+	}
+	if !curve.IsOnCurve(pubNonce.GetX(), pubNonce.GetY()) {
+		return nil, nil, fmt.Errorf("public nonce is off curve")
+	}
+
+	alpha, err := NewRandomScalar(curve, rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	beta, err := NewRandomScalar(curve, rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// R = R' + alpha*G + beta*A
+	aX, aY := curve.BaseMult(alpha.Bytes())
+	bX, bY := curve.ScalarMult(pub.GetX(), pub.GetY(), beta.Bytes())
+	rX, rY := curve.Add(pubNonce.GetX(), pubNonce.GetY(), aX, aY)
+	rX, rY = curve.Add(rX, rY, bX, bY)
+	if !curve.IsOnCurve(rX, rY) {
+		return nil, nil, fmt.Errorf("blinded nonce is off curve")
+	}
+	encodedR := BigIntPointToEncodedBytes(rX, rY)
+
+	// c = hash512(R || A || M)
+	var hramDigest [64]byte
+	h := sha512.New()
+	h.Write(encodedR[:])
+	h.Write(pub.Serialize())
+	h.Write(msg)
+	h.Sum(hramDigest[:0])
+	var hramDigestReduced [32]byte
+	edwards25519.ScReduce(&hramDigestReduced, &hramDigest)
+	c := EncodedBytesToBigInt(&hramDigestReduced)
+
+	blindedChallenge := scalarMulAdd(one, c, beta)
+
+	return &BlindingFactors{
+		Alpha:     alpha,
+		Beta:      beta,
+		R:         NewPublicKey(curve, rX, rY),
+		pub:       pub,
+		pubNonce:  pubNonce,
+		challenge: blindedChallenge,
+	}, blindedChallenge, nil
+}
+
+// BlindSign is the signer's side of a blind signature. It answers the
+// blinded challenge c' received from the requester with s' = k + c' * a,
+// where k is the secret nonce returned by NewBlindNonce, without learning
+// the message. The secret nonce is wiped afterwards, since answering two
+// challenges with the same nonce reveals the private key.
+//
+// The signer should not run many signing sessions concurrently, as opening
+// a large number of sessions at the same time allows a requester to forge an
+// extra signature (the ROS attack).
+func BlindSign(curve *TwistedEdwardsCurve, priv *PrivateKey,
+	privNonce *PrivateKey, blindedChallenge *big.Int) (*big.Int, error) {
+	if priv == nil || privNonce == nil || blindedChallenge == nil {
+		return nil, fmt.Errorf("nil input")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, err
+	}
+	if err := privNonce.signingErr(); err != nil {
+		return nil, err
+	}
+	defer privNonce.Wipe()
+	if blindedChallenge.Sign() < 0 || blindedChallenge.Cmp(curve.N) >= 0 {
+		return nil, fmt.Errorf("blinded challenge is out of range")
+	}
+
+	a := priv.reducedScalar(curve)
+	defer a.SetInt64(0)
+	k := privNonce.reducedScalar(curve)
+	defer k.SetInt64(0)
+
+	s := scalarMulAdd(blindedChallenge, a, k)
+	if s.Sign() == 0 {
+		return nil, fmt.Errorf("blind sig s is zero")
+	}
+
+	return s, nil
+}
+
+// Unblind turns the signer's response blindS to the blinded challenge into
+// a regular signature (R, s) of the original message, with s = s' + alpha,
+// which verifies with Verify under the signer's public key. The response is
+// checked first, s'G = R' + c'A, so that a bad response from the signer is
+// reported rather than producing an invalid signature.
+func Unblind(curve *TwistedEdwardsCurve, bf *BlindingFactors,
+	blindS *big.Int) (*Signature, error) {
+	if bf == nil || bf.Alpha == nil || bf.R == nil || bf.pub == nil ||
+		bf.pubNonce == nil || bf.challenge == nil || blindS == nil {
+		return nil, fmt.Errorf("nil input")
+	}
+	if blindS.Sign() <= 0 || blindS.Cmp(curve.N) >= 0 {
+		return nil, fmt.Errorf("blind sig s is out of range")
+	}
+
+	lX, lY := curve.BaseMult(blindS.Bytes())
+	cX, cY := curve.ScalarMult(bf.pub.GetX(), bf.pub.GetY(),
+		bf.challenge.Bytes())
+	rX, rY := curve.Add(bf.pubNonce.GetX(), bf.pubNonce.GetY(), cX, cY)
+	if lX.Cmp(rX) != 0 || lY.Cmp(rY) != 0 {
+		return nil, fmt.Errorf("blind sig s does not match the blinded " +
+			"challenge")
+	}
+
+	s := scalarMulAdd(one, blindS, bf.Alpha)
+	if s.Sign() == 0 {
+		return nil, fmt.Errorf("unblinded sig s is zero")
+	}
+	r := EncodedBytesToBigInt(BigIntPointToEncodedBytes(bf.R.GetX(),
+		bf.R.GetY()))
+
+	return NewSignature(r, s), nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"math/big"
+	"testing"
+)
+
+// TestBlindSignature tests that an unblinded blind signature verifies for the
+// original message under the signer's public key, and that the signer's view
+// of the session differs from the final signature
+func TestBlindSignature(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := []byte("token serial number in TestBlindSignature")
+
+	signer := mockUpSecKeysByBytes(curve, 1)[0]
+	pub := signer.PubKey()
+
+	privNonce, pubNonce, err := NewBlindNonce(curve)
+	if err != nil {
+		t.Fatalf("unexpected nonce generation error: %v", err)
+	}
+	bf, blindedChallenge, err := Blind(curve, pub, pubNonce, msg)
+	if err != nil {
+		t.Fatalf("unexpected blinding error: %v", err)
+	}
+	blindS, err := BlindSign(curve, signer, privNonce, blindedChallenge)
+	if err != nil {
+		t.Fatalf("unexpected blind signing error: %v", err)
+	}
+	sig, err := Unblind(curve, bf, blindS)
+	if err != nil {
+		t.Fatalf("unexpected unblinding error: %v", err)
+	}
+	if !Verify(pub, msg, sig.GetR(), sig.GetS()) {
+		t.Fatalf("unblinded signature failed to verify")
+	}
+	if Verify(pub, []byte("some other message"), sig.GetR(), sig.GetS()) {
+		t.Fatalf("unblinded signature verified for the wrong message")
+	}
+
+	// The signer only ever sees R', c' and s', none of which appear in
+	// the final signature.
+	signerR := EncodedBytesToBigInt(BigIntPointToEncodedBytes(
+		pubNonce.GetX(), pubNonce.GetY()))
+	if signerR.Cmp(sig.GetR()) == 0 || blindS.Cmp(sig.GetS()) == 0 {
+		t.Fatalf("final signature is linkable to the signing session")
+	}
+	if Verify(pub, msg, signerR, blindS) {
+		t.Fatalf("signer's view verified as a signature of the message")
+	}
+
+	// The nonce is wiped after use, so it can't answer a second challenge.
+	if !privNonce.IsWiped() {
+		t.Fatalf("secret nonce not wiped after signing")
+	}
+	_, err = BlindSign(curve, signer, privNonce, blindedChallenge)
+	if err != ErrWipedKey {
+		t.Fatalf("want %v reusing a nonce, got %v", ErrWipedKey, err)
+	}
+
+	// A bad response from the signer is caught when unblinding.
+	badS := new(big.Int).Add(blindS, one)
+	badS.Mod(badS, curve.N)
+	if _, err := Unblind(curve, bf, badS); err == nil {
+		t.Fatalf("expected error unblinding a bad response")
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// ChallengeHash selects the hash function used to compute the challenge
+// k = hash(R || A || M) of an Ed25519 signature.
+type ChallengeHash int
+
+// These constants define the available challenge hash functions.
+const (
+	// ChallengeSHA512 is the SHA512 challenge of RFC 8032, as used by Sign,
+	// SignDeterministic and Verify. It is the default and the only one
+	// accepted by consensus.
+	ChallengeSHA512 ChallengeHash = iota
+
+	// ChallengeSHA3 computes the challenge with SHA3-512, for protocols that
+	// standardize on SHA-3. SHA3-512 rather than Keccak-256 is used because
+	// the challenge must be 64 bytes long to be reduced mod N without bias.
+	// The signatures are in a separate signing domain and never verify as
+	// SHA512 ones, nor the other way around.
+	ChallengeSHA3
+)
+
+// String returns the ChallengeHash as a human-readable name.
+func (h ChallengeHash) String() string {
+	switch h {
+	case ChallengeSHA512:
+		return "ChallengeSHA512"
+	case ChallengeSHA3:
+		return "ChallengeSHA3"
+	}
+	return "Unknown ChallengeHash"
+}
+
+// domSHA3 is the domain separation prefix of ChallengeSHA3 signatures. It
+// is written in front of both the nonce and the challenge hash input, so the
+// nonces differ from those of SHA512 signatures of the same message and a
+// signature can't be reinterpreted under another hash.
+var domSHA3 = []byte("SigEd25519 SHA3-512 challenge")
+
+// SignWithChallengeHash deterministically signs msg as SignDeterministic
+// does, with the challenge computed by the hash function h. The nonce is
+// still derived with SHA512. ChallengeSHA512 gives the same signatures as
+// SignDeterministic, including on the Ed448 curve, while ChallengeSHA3 is
+// only defined for Ed25519. The private key must hold a secret seed.
+// R = rG
+// S = r + h(dom || R || A || M) * a
+func SignWithChallengeHash(curve *TwistedEdwardsCurve, priv *PrivateKey,
+	msg []byte, h ChallengeHash) (r, s *big.Int, err error) {
+	switch h {
+	case ChallengeSHA512:
+		sig, err := SignDeterministic(curve, priv, msg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return sig.GetR(), sig.GetS(), nil
+	case ChallengeSHA3:
+	default:
+		return nil, nil, fmt.Errorf("unknown challenge hash %v", h)
+	}
+
+	if priv == nil {
+		return nil, nil, fmt.Errorf("private key is nil")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, nil, err
+	}
+	if curve.isEd448() {
+		return nil, nil, fmt.Errorf("%v is not supported on Ed448", h)
+	}
+	if priv.secret == nil {
+		return nil, nil, fmt.Errorf("private key has no secret seed to " +
+			"derive the nonce from")
+	}
+
+	k := seedExpandedKey(curve, priv)
+	defer k.Wipe()
+	sig, err := k.signWithChallengeHash(sha3.New512, domSHA3, msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sig.GetR(), sig.GetS(), nil
+}
+
+// VerifyWithChallengeHash verifies the signature (r, s) over msg using the
+// public key pub, with the challenge computed by the hash function h.
+// ChallengeSHA512 is the same as Verify.
+func VerifyWithChallengeHash(pub *PublicKey, msg []byte, r, s *big.Int,
+	h ChallengeHash) bool {
+	switch h {
+	case ChallengeSHA512:
+		return Verify(pub, msg, r, s)
+	case ChallengeSHA3:
+	default:
+		return false
+	}
+
+	if pub == nil {
+		return false
+	}
+	if curve, ok := pub.Curve.(*TwistedEdwardsCurve); !ok || curve == nil ||
+		curve.isEd448() {
+		return false
+	}
+
+	return verifyWithChallengeHash(pub, sha3.New512, domSHA3, msg, r, s)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// TestChallengeHash tests signing the RFC 8032 test vector messages with
+// the SHA3-512 challenge against fixed vectors, that SHA512 challenge
+// signatures are those of SignDeterministic, and that signatures of one
+// challenge hash never verify under the other
+func TestChallengeHash(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	tests := []struct {
+		secret  string
+		msg     string
+		sha3Sig string
+	}{
+		{
+			"9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+			"",
+			"1fbb261de6369a3fbe2f9718ea4df9d3c6c3e19d2d8764ee8f63acb02dfdc8f5" +
+				"058cd42a18aeada5eb9aa0baa74e3c71818a04df43017b2cafc3f168ca639c05",
+		},
+		{
+			"4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+			"72",
+			"14e22202da8280fdac9f5987efcdb3ef5586999d4e5f6c3e782eef1e6689b1fa" +
+				"212747b87ad5e314eb54502bbc46803779738bf8c43e0bac9c4b81e2f6647b08",
+		},
+	}
+
+	for i, test := range tests {
+		secret, _ := hex.DecodeString(test.secret)
+		msg, _ := hex.DecodeString(test.msg)
+		want, _ := hex.DecodeString(test.sha3Sig)
+		priv, pub := PrivKeyFromSecret(curve, secret)
+
+		r, s, err := SignWithChallengeHash(curve, priv, msg, ChallengeSHA3)
+		if err != nil {
+			t.Fatalf("test %d: unexpected signing error: %v", i, err)
+		}
+		if got := (&Signature{r, s}).Serialize(); !bytes.Equal(got, want) {
+			t.Fatalf("test %d: want %x, got %x", i, want, got)
+		}
+		if !VerifyWithChallengeHash(pub, msg, r, s, ChallengeSHA3) {
+			t.Fatalf("test %d: SHA3 signature failed to verify", i)
+		}
+		if VerifyWithChallengeHash(pub, append(msg, 0), r, s, ChallengeSHA3) {
+			t.Fatalf("test %d: SHA3 signature verified for another message",
+				i)
+		}
+		if Verify(pub, msg, r, s) ||
+			VerifyWithChallengeHash(pub, msg, r, s, ChallengeSHA512) {
+			t.Fatalf("test %d: SHA3 signature verified as a SHA512 one", i)
+		}
+
+		// The default is plain deterministic Ed25519.
+		r, s, err = SignWithChallengeHash(curve, priv, msg, ChallengeSHA512)
+		if err != nil {
+			t.Fatalf("test %d: unexpected signing error: %v", i, err)
+		}
+		sig, _ := SignDeterministic(curve, priv, msg)
+		if !bytes.Equal((&Signature{r, s}).Serialize(), sig.Serialize()) {
+			t.Fatalf("test %d: SHA512 challenge signature differs from "+
+				"SignDeterministic", i)
+		}
+		if !VerifyWithChallengeHash(pub, msg, r, s, ChallengeSHA512) {
+			t.Fatalf("test %d: SHA512 signature failed to verify", i)
+		}
+		if VerifyWithChallengeHash(pub, msg, r, s, ChallengeSHA3) {
+			t.Fatalf("test %d: SHA512 signature verified as a SHA3 one", i)
+		}
+	}
+
+	priv := mockUpSecKeysByBytes(curve, 1)[0]
+	if _, _, err := SignWithChallengeHash(curve, priv, []byte{1},
+		ChallengeHash(2)); err == nil {
+		t.Fatalf("signed with an unknown challenge hash")
+	}
+	if VerifyWithChallengeHash(priv.PubKey(), []byte{1}, one, one,
+		ChallengeHash(2)) {
+		t.Fatalf("verified with an unknown challenge hash")
+	}
+	if VerifyWithChallengeHash(nil, []byte{1}, one, one, ChallengeSHA3) {
+		t.Fatalf("verified with a nil key")
+	}
+}
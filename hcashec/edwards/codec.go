@@ -0,0 +1,224 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"fmt"
+	"io"
+)
+
+// The kinds of private keys written by PrivateKey.WriteTo, which precede the
+// length prefixed key bytes in the stream.
+const (
+	// privKeyKindSeed is a key stored as its RFC 8032 secret seed, 32
+	// bytes for Ed25519 and 57 bytes for Ed448.
+	privKeyKindSeed byte = 0
+
+	// privKeyKindScalar is an Ed25519 key stored as its 32 byte big endian
+	// private scalar, for keys without a seed.
+	privKeyKindScalar byte = 1
+)
+
+// writePrefixed writes b to w preceded by its length as a single byte.
+func writePrefixed(w io.Writer, b []byte) (int64, error) {
+	n, err := w.Write(append([]byte{byte(len(b))}, b...))
+	return int64(n), err
+}
+
+// readFull reads exactly len(b) bytes from r into b, adding the number of
+// bytes read to *n. A stream that ends early is reported as
+// io.ErrUnexpectedEOF unless nothing at all was read.
+func readFull(r io.Reader, b []byte, n *int64) error {
+	m, err := io.ReadFull(r, b)
+	*n += int64(m)
+	if err == io.EOF && *n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// curveForPointSize returns the curve whose points are encoded in size
+// bytes, or nil for sizes that are neither Ed25519 nor Ed448.
+func curveForPointSize(size int) *TwistedEdwardsCurve {
+	switch size {
+	case PubKeyBytesLen:
+		return Edwards()
+	case Ed448PubKeyBytesLen:
+		curve := new(TwistedEdwardsCurve)
+		curve.InitParamEd448()
+		return curve
+	}
+	return nil
+}
+
+// WriteTo satisfies the io.WriterTo interface, writing the compressed public
+// key to w preceded by a length byte, so Ed25519 and Ed448 keys can be told
+// apart by ReadFrom.
+func (p PublicKey) WriteTo(w io.Writer) (int64, error) {
+	if p.Curve == nil || p.X == nil || p.Y == nil {
+		return 0, fmt.Errorf("cannot write incomplete public key")
+	}
+
+	return writePrefixed(w, p.Serialize())
+}
+
+// ReadFrom satisfies the io.ReaderFrom interface, reading a public key
+// written by WriteTo from r. The key is validated the same way as by
+// ParsePubKey. If p already has a curve, the key must belong to it,
+// otherwise the curve is chosen by the length of the key.
+func (p *PublicKey) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	var size [1]byte
+	if err := readFull(r, size[:], &n); err != nil {
+		return n, err
+	}
+	curve := curveForPointSize(int(size[0]))
+	if curve == nil {
+		return n, fmt.Errorf("bad public key size %v", size[0])
+	}
+	if c, ok := p.Curve.(*TwistedEdwardsCurve); ok && c != nil {
+		if c.byteSize != int(size[0]) {
+			return n, fmt.Errorf("bad public key size (got %v, want %v)",
+				size[0], c.byteSize)
+		}
+		curve = c
+	}
+
+	b := make([]byte, size[0])
+	if err := readFull(r, b, &n); err != nil {
+		return n, err
+	}
+	pub, err := ParsePubKey(curve, b)
+	if err != nil {
+		return n, err
+	}
+	*p = *pub
+
+	return n, nil
+}
+
+// WriteTo satisfies the io.WriterTo interface, writing the private key to w
+// as a kind byte followed by the length prefixed key. Keys holding a secret
+// seed are written as the seed, so ReadFrom restores a key that signs
+// exactly like the original, other Ed25519 keys as their private scalar.
+// Wiped keys can't be written.
+func (p PrivateKey) WriteTo(w io.Writer) (int64, error) {
+	if err := p.signingErr(); err != nil {
+		return 0, err
+	}
+
+	var kind byte
+	var b []byte
+	switch {
+	case p.ed448Seed != nil:
+		kind, b = privKeyKindSeed, p.ed448Seed[:]
+	case p.secret != nil:
+		kind, b = privKeyKindSeed, p.secret[:]
+	default:
+		if curve, ok := p.ecPk.Curve.(*TwistedEdwardsCurve); ok &&
+			curve.isEd448() {
+			return 0, fmt.Errorf("cannot write Ed448 private key without " +
+				"its seed")
+		}
+		kind, b = privKeyKindScalar, p.Serialize()
+		defer zeroSlice(b)
+	}
+
+	m, err := w.Write([]byte{kind})
+	if err != nil {
+		return int64(m), err
+	}
+	n, err := writePrefixed(w, b)
+	return int64(m) + n, err
+}
+
+// ReadFrom satisfies the io.ReaderFrom interface, reading a private key
+// written by WriteTo from r. Seeds are expanded with PrivKeyFromSecret on
+// the curve given by their length, and scalars are validated by
+// PrivKeyFromScalar, so a scalar that is zero or not below N is rejected.
+func (p *PrivateKey) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	var header [2]byte
+	if err := readFull(r, header[:], &n); err != nil {
+		return n, err
+	}
+	kind, size := header[0], int(header[1])
+
+	var curve *TwistedEdwardsCurve
+	switch kind {
+	case privKeyKindSeed:
+		if size == Ed448SeedSize {
+			curve = curveForPointSize(Ed448PubKeyBytesLen)
+		} else if size == PrivKeyBytesLen/2 {
+			curve = Edwards()
+		}
+	case privKeyKindScalar:
+		if size == PrivScalarSize {
+			curve = Edwards()
+		}
+	default:
+		return n, fmt.Errorf("unknown private key kind %v", kind)
+	}
+	if curve == nil {
+		return n, fmt.Errorf("bad private key size %v", size)
+	}
+
+	b := make([]byte, size)
+	defer zeroSlice(b)
+	if err := readFull(r, b, &n); err != nil {
+		return n, err
+	}
+
+	var priv *PrivateKey
+	if kind == privKeyKindSeed {
+		priv, _ = PrivKeyFromSecret(curve, b)
+		if priv == nil {
+			return n, fmt.Errorf("failed to expand private key seed")
+		}
+	} else {
+		var err error
+		priv, _, err = PrivKeyFromScalar(curve, b)
+		if err != nil {
+			return n, err
+		}
+	}
+	*p = *priv
+
+	return n, nil
+}
+
+// WriteTo satisfies the io.WriterTo interface, writing the signature to w in
+// the SignatureSize bytes of Serialize. Signatures have a fixed size, so no
+// length prefix is written, and only Ed25519 signatures can be written.
+func (sig Signature) WriteTo(w io.Writer) (int64, error) {
+	if sig.R == nil || sig.S == nil {
+		return 0, fmt.Errorf("cannot write incomplete signature")
+	}
+	if sig.isEd448() {
+		return 0, fmt.Errorf("cannot write Ed448 signature")
+	}
+
+	n, err := w.Write(sig.Serialize())
+	return int64(n), err
+}
+
+// ReadFrom satisfies the io.ReaderFrom interface, reading an Ed25519
+// signature of SignatureSize bytes from r. The signature is validated the
+// same way as by ParseSignature, so R must be a point and S must be in
+// [1, N).
+func (sig *Signature) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	b := make([]byte, SignatureSize)
+	if err := readFull(r, b, &n); err != nil {
+		return n, err
+	}
+	parsed, err := ParseSignature(Edwards(), b)
+	if err != nil {
+		return n, err
+	}
+	*sig = *parsed
+
+	return n, nil
+}
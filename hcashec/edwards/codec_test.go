@@ -0,0 +1,202 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"io"
+	"testing"
+)
+
+// TestStreamCodec tests that public keys, private keys and signatures of
+// both curves roundtrip through WriteTo and ReadFrom over a bytes.Buffer,
+// one after another in the same stream, with the byte counts of both sides
+// matching
+func TestStreamCodec(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	ed448 := new(TwistedEdwardsCurve)
+	ed448.InitParamEd448()
+
+	var privs []*PrivateKey
+	privs = append(privs, mockUpSecKeysByBytes(curve, 3)...)
+	privs = append(privs, mockUpSecKeysByScalars(curve, 3)...)
+	ed448Privs, err := GenerateKeys(ed448, crand.Reader, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	privs = append(privs, ed448Privs...)
+
+	var buf bytes.Buffer
+	var written int64
+	for i, priv := range privs {
+		n, err := priv.PubKey().WriteTo(&buf)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		written += n
+		n, err = priv.WriteTo(&buf)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		written += n
+	}
+	msg := []byte("stream codec")
+	var sigs []*Signature
+	for _, priv := range privs[:6] {
+		r, s, err := Sign(curve, priv, msg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		sig := NewSignature(r, s)
+		n, err := sig.WriteTo(&buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		written += n
+		sigs = append(sigs, sig)
+	}
+	if written != int64(buf.Len()) {
+		t.Fatalf("WriteTo reported %v bytes, wrote %v", written, buf.Len())
+	}
+
+	var read int64
+	for i, priv := range privs {
+		var pub PublicKey
+		n, err := pub.ReadFrom(&buf)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		read += n
+		if !pub.Equal(priv.PubKey()) {
+			t.Fatalf("key %d: public key changed in a roundtrip", i)
+		}
+
+		var readPriv PrivateKey
+		n, err = readPriv.ReadFrom(&buf)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		read += n
+		if readPriv.GetD().Cmp(priv.GetD()) != 0 ||
+			!readPriv.PubKey().Equal(priv.PubKey()) {
+			t.Fatalf("key %d: private key changed in a roundtrip", i)
+		}
+		if !bytes.Equal(readPriv.SerializeSecret(), priv.SerializeSecret()) {
+			t.Fatalf("key %d: secret seed changed in a roundtrip", i)
+		}
+	}
+	for i, sig := range sigs {
+		var readSig Signature
+		n, err := readSig.ReadFrom(&buf)
+		if err != nil {
+			t.Fatalf("signature %d: unexpected error: %v", i, err)
+		}
+		read += n
+		if !bytes.Equal(readSig.Serialize(), sig.Serialize()) {
+			t.Fatalf("signature %d: changed in a roundtrip", i)
+		}
+		if !Verify(privs[i].PubKey(), msg, readSig.R, readSig.S) {
+			t.Fatalf("signature %d: failed to verify after a roundtrip", i)
+		}
+	}
+	if read != written || buf.Len() != 0 {
+		t.Fatalf("read %v of %v bytes, %v left over", read, written,
+			buf.Len())
+	}
+
+	// An empty stream is a clean EOF.
+	var pub PublicKey
+	if _, err := pub.ReadFrom(&buf); err != io.EOF {
+		t.Fatalf("want io.EOF on an empty stream, got %v", err)
+	}
+}
+
+// TestStreamCodecRejects tests that ReadFrom rejects truncated streams,
+// bad sizes and kinds, points off the curve and scalars not below N, and
+// that incomplete or wiped keys aren't written
+func TestStreamCodecRejects(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	ed448 := new(TwistedEdwardsCurve)
+	ed448.InitParamEd448()
+
+	priv := mockUpSecKeysByBytes(curve, 1)[0]
+	r, s, err := Sign(curve, priv, []byte("reject"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var pubBuf, privBuf, sigBuf bytes.Buffer
+	priv.PubKey().WriteTo(&pubBuf)
+	priv.WriteTo(&privBuf)
+	NewSignature(r, s).WriteTo(&sigBuf)
+
+	for _, b := range [][]byte{pubBuf.Bytes(), privBuf.Bytes(),
+		sigBuf.Bytes()} {
+		for l := 1; l < len(b); l++ {
+			var pub PublicKey
+			var readPriv PrivateKey
+			var sig Signature
+			_, errPub := pub.ReadFrom(bytes.NewReader(b[:l]))
+			_, errPriv := readPriv.ReadFrom(bytes.NewReader(b[:l]))
+			_, errSig := sig.ReadFrom(bytes.NewReader(b[:l]))
+			if errPub == nil || errPriv == nil || errSig == nil {
+				t.Fatalf("accepted a stream truncated to %d bytes: %x", l,
+					b[:l])
+			}
+		}
+	}
+
+	// A key of the wrong curve for the receiver.
+	ed448Pub := PublicKey{Curve: ed448}
+	if _, err := ed448Pub.ReadFrom(bytes.NewReader(pubBuf.Bytes())); err ==
+		nil {
+		t.Fatalf("read an Ed25519 key into an Ed448 public key")
+	}
+
+	// y = 2 is not on the curve.
+	offCurve := append([]byte{PubKeyBytesLen, 2}, make([]byte, 31)...)
+	var pub PublicKey
+	if _, err := pub.ReadFrom(bytes.NewReader(offCurve)); err == nil {
+		t.Fatalf("read a point off the curve")
+	}
+	if _, err := pub.ReadFrom(bytes.NewReader([]byte{31})); err == nil {
+		t.Fatalf("read a public key of bad size")
+	}
+
+	// Scalars of zero and N, an unknown kind and a short seed.
+	nBytes := copyBytes(curve.N.Bytes())
+	for i, b := range [][]byte{
+		append([]byte{privKeyKindScalar, PrivScalarSize},
+			make([]byte, PrivScalarSize)...),
+		append([]byte{privKeyKindScalar, PrivScalarSize}, nBytes[:]...),
+		append([]byte{2, PrivScalarSize}, make([]byte, PrivScalarSize)...),
+		append([]byte{privKeyKindSeed, 31}, make([]byte, 31)...),
+	} {
+		var readPriv PrivateKey
+		if _, err := readPriv.ReadFrom(bytes.NewReader(b)); err == nil {
+			t.Fatalf("bad private key %d: accepted %x", i, b)
+		}
+	}
+
+	// S = N in a signature.
+	badSig := append(sigBuf.Bytes()[:32:32], BigIntToEncodedBytes(curve.N)[:]...)
+	var sig Signature
+	if _, err := sig.ReadFrom(bytes.NewReader(badSig)); err == nil {
+		t.Fatalf("read a signature with S not below N")
+	}
+
+	if _, err := (PublicKey{}).WriteTo(io.Discard); err == nil {
+		t.Fatalf("wrote an incomplete public key")
+	}
+	if _, err := (Signature{}).WriteTo(io.Discard); err == nil {
+		t.Fatalf("wrote an incomplete signature")
+	}
+	priv.Wipe()
+	if _, err := priv.WriteTo(io.Discard); err == nil {
+		t.Fatalf("wrote a wiped private key")
+	}
+}
@@ -19,8 +19,9 @@ type TwistedEdwardsCurve struct {
 
 	A, D, I *big.Int // Edwards curve equation parameter constants
 
-	// byteSize is simply the bit size / 8 and is provided for convenience
-	// since it is calculated repeatedly.
+	// byteSize is simply the bit size / 8 (one more byte for Ed448, which
+	// needs it for the sign of x) and is provided for convenience since it
+	// is calculated repeatedly.
 	byteSize int
 }
 
@@ -29,13 +30,44 @@ func (curve TwistedEdwardsCurve) Params() *elliptic.CurveParams {
 	return curve.CurveParams
 }
 
+// Order returns a copy of the order N of the base point, which is safe to
+// modify. The exported fields of the curve (and Params) are kept for
+// compatibility with elliptic.Curve and the existing callers, but they are
+// shared by every user of the curve and must never be modified.
+func (curve *TwistedEdwardsCurve) Order() *big.Int {
+	return new(big.Int).Set(curve.N)
+}
+
+// FieldPrime returns a copy of the prime P of the underlying field, which is
+// safe to modify.
+func (curve *TwistedEdwardsCurve) FieldPrime() *big.Int {
+	return new(big.Int).Set(curve.P)
+}
+
+// Cofactor returns the cofactor H of the curve, the ratio of the number of
+// points on the curve to the order of the base point.
+func (curve *TwistedEdwardsCurve) Cofactor() int {
+	return curve.H
+}
+
+// BasePoint returns copies of the affine coordinates of the base point G,
+// which are safe to modify.
+func (curve *TwistedEdwardsCurve) BasePoint() (x, y *big.Int) {
+	return new(big.Int).Set(curve.Gx), new(big.Int).Set(curve.Gy)
+}
+
 // Marshal converts a point into the 32 byte encoded Ed25519 form.
 func Marshal(curve TwistedEdwardsCurve, x, y *big.Int) []byte {
 	return BigIntPointToEncodedBytes(x, y)[:]
 }
 
-// Unmarshal converts a point into the 32 byte encoded Ed25519 form.
+// Unmarshal converts a point in the 32 byte encoded Ed25519 form back to
+// affine coordinates. The encoding is little endian, so input of any other
+// length is rejected rather than padded, which would shift its bytes.
 func Unmarshal(curve *TwistedEdwardsCurve, data []byte) (x, y *big.Int) {
+	if len(data) != PubKeyBytesLen {
+		return nil, nil
+	}
 	var err error
 	x, y, err = curve.EncodedBytesToBigIntPoint(copyBytes(data))
 	if err != nil {
@@ -106,7 +138,7 @@ func (curve *TwistedEdwardsCurve) RecoverXFieldElement(xIsNeg bool,
 	edwards25519.FeSquare(r, y)
 	edwards25519.FeMul(r, r, &fed)
 	edwards25519.FeAdd(r, r, &feOne)
-	edwards25519.FeInvert(r, r)
+	fieldInverse(r, r)
 
 	x2 := new(edwards25519.FieldElement)
 	edwards25519.FeMul(x2, r, l)
@@ -161,6 +193,10 @@ func (curve *TwistedEdwardsCurve) RecoverXFieldElement(xIsNeg bool,
 // IsOnCurve returns bool to say if the point (x,y) is on the curve by
 // checking (y^2 - x^2 - 1 - dx^2y^2) % P == 0.
 func (curve *TwistedEdwardsCurve) IsOnCurve(x *big.Int, y *big.Int) bool {
+	if curve.isEd448() {
+		return curve.ed448IsOnCurve(x, y)
+	}
+
 	// Convert to field elements.
 	xB := BigIntToEncodedBytes(x)
 	yB := BigIntToEncodedBytes(y)
@@ -213,9 +249,33 @@ func toCached(r *cachedGroupElement, p *edwards25519.ExtendedGroupElement) {
 	edwards25519.FeMul(&r.T2d, &p.T, &fed2)
 }
 
+// geAdd adds an extended group element p to a cached group element q,
+// storing the result in the completed group element r.
+func geAdd(r *edwards25519.CompletedGroupElement,
+	p *edwards25519.ExtendedGroupElement, q *cachedGroupElement) {
+	var t0 edwards25519.FieldElement
+
+	edwards25519.FeAdd(&r.X, &p.Y, &p.X)
+	edwards25519.FeSub(&r.Y, &p.Y, &p.X)
+	edwards25519.FeMul(&r.Z, &r.X, &q.yPlusX)
+	edwards25519.FeMul(&r.Y, &r.Y, &q.yMinusX)
+	edwards25519.FeMul(&r.T, &q.T2d, &p.T)
+	edwards25519.FeMul(&r.X, &p.Z, &q.Z)
+	edwards25519.FeAdd(&t0, &r.X, &r.X)
+	edwards25519.FeSub(&r.X, &r.Z, &r.Y)
+	edwards25519.FeAdd(&r.Y, &r.Z, &r.Y)
+	edwards25519.FeAdd(&r.Z, &t0, &r.T)
+	edwards25519.FeSub(&r.T, &t0, &r.T)
+}
+
 // Add adds two points represented by pairs of big integers on the elliptical
 // curve.
 func (curve *TwistedEdwardsCurve) Add(x1, y1, x2, y2 *big.Int) (x, y *big.Int) {
+	if curve.isEd448() {
+		return curve.ed448Affine(curve.ed448Add(ed448FromAffine(x1, y1),
+			ed448FromAffine(x2, y2)))
+	}
+
 	// Convert to extended from affine.
 	a := BigIntPointToEncodedBytes(x1, y1)
 	aEGE := new(edwards25519.ExtendedGroupElement)
@@ -229,26 +289,8 @@ func (curve *TwistedEdwardsCurve) Add(x1, y1, x2, y2 *big.Int) (x, y *big.Int) {
 	bCached := new(cachedGroupElement)
 	toCached(bCached, bEGE)
 
-	p := aEGE
-	q := bCached
-
-	// geAdd(r*CompletedGroupElement, p*ExtendedGroupElement,
-	//   q*CachedGroupElement)
-	// r is the result.
 	r := new(edwards25519.CompletedGroupElement)
-	var t0 edwards25519.FieldElement
-
-	edwards25519.FeAdd(&r.X, &p.Y, &p.X)
-	edwards25519.FeSub(&r.Y, &p.Y, &p.X)
-	edwards25519.FeMul(&r.Z, &r.X, &q.yPlusX)
-	edwards25519.FeMul(&r.Y, &r.Y, &q.yMinusX)
-	edwards25519.FeMul(&r.T, &q.T2d, &p.T)
-	edwards25519.FeMul(&r.X, &p.Z, &q.Z)
-	edwards25519.FeAdd(&t0, &r.X, &r.X)
-	edwards25519.FeSub(&r.X, &r.Z, &r.Y)
-	edwards25519.FeAdd(&r.Y, &r.Z, &r.Y)
-	edwards25519.FeAdd(&r.Z, &t0, &r.T)
-	edwards25519.FeSub(&r.T, &t0, &r.T)
+	geAdd(r, aEGE, bCached)
 
 	rEGE := new(edwards25519.ExtendedGroupElement)
 	r.ToExtended(rEGE)
@@ -264,6 +306,10 @@ func (curve *TwistedEdwardsCurve) Add(x1, y1, x2, y2 *big.Int) (x, y *big.Int) {
 // Double adds the same pair of big integer coordinates to itself on the
 // elliptical curve.
 func (curve *TwistedEdwardsCurve) Double(x1, y1 *big.Int) (x, y *big.Int) {
+	if curve.isEd448() {
+		return curve.Add(x1, y1, x1, y1)
+	}
+
 	// Convert to extended projective coordinates.
 	a := BigIntPointToEncodedBytes(x1, y1)
 	aEGE := new(edwards25519.ExtendedGroupElement)
@@ -281,6 +327,23 @@ func (curve *TwistedEdwardsCurve) Double(x1, y1 *big.Int) (x, y *big.Int) {
 	return
 }
 
+// Neg returns the negation -(x1,y1) = (-x1,y1) of a point on the curve, so
+// that (x1,y1) + (-x1,y1) is the identity (0,1).
+func (curve *TwistedEdwardsCurve) Neg(x1, y1 *big.Int) (x, y *big.Int) {
+	x = new(big.Int).Neg(x1)
+	x.Mod(x, curve.P)
+	y = new(big.Int).Set(y1)
+
+	return
+}
+
+// Sub subtracts the point (x2,y2) from (x1,y1) on the curve, that is it adds
+// the negation of (x2,y2) to (x1,y1).
+func (curve *TwistedEdwardsCurve) Sub(x1, y1, x2, y2 *big.Int) (x, y *big.Int) {
+	negX2, negY2 := curve.Neg(x2, y2)
+	return curve.Add(x1, y1, negX2, negY2)
+}
+
 // ScalarMult returns k*(Bx,By) where k is a number in big-endian form. This
 // uses the repeated doubling method, which is variable time.
 // TODO use a constant time method to prevent side channel attacks.
@@ -289,6 +352,11 @@ func (curve *TwistedEdwardsCurve) ScalarMult(x1, y1 *big.Int,
 	// Convert the scalar to a big int.
 	s := new(big.Int).SetBytes(k)
 
+	if curve.isEd448() {
+		return curve.ed448Affine(curve.ed448ScalarMult(
+			ed448FromAffine(x1, y1), s))
+	}
+
 	// Get a new group element to do cached doubling
 	// calculations in.
 	dEGE := new(edwards25519.ExtendedGroupElement)
@@ -336,11 +404,225 @@ func (curve *TwistedEdwardsCurve) ScalarMult(x1, y1 *big.Int,
 	return
 }
 
+// feCondSwap swaps the field elements a and b if swap is 1 and leaves them
+// untouched if swap is 0. The limbs are exchanged through a mask derived from
+// swap, so neither the branches taken nor the memory accessed depend on it.
+func feCondSwap(a, b *edwards25519.FieldElement, swap int32) {
+	mask := -swap
+	for i := range a {
+		t := mask & (a[i] ^ b[i])
+		a[i] ^= t
+		b[i] ^= t
+	}
+}
+
+// geCondSwap swaps the extended group elements a and b if swap is 1 and
+// leaves them untouched if swap is 0, without branching on swap.
+func geCondSwap(a, b *edwards25519.ExtendedGroupElement, swap int32) {
+	feCondSwap(&a.X, &b.X, swap)
+	feCondSwap(&a.Y, &b.Y, swap)
+	feCondSwap(&a.Z, &b.Z, swap)
+	feCondSwap(&a.T, &b.T, swap)
+}
+
+// ScalarMultConstantTime returns k*(Bx,By) where k is a number in big-endian
+// form. It uses a Montgomery ladder over the complete twisted Edwards
+// addition law: every bit of k, set or not, costs exactly one point addition
+// and one doubling, and the ladder registers are exchanged with conditional
+// moves instead of branches. The running time therefore only depends on the
+// length of k and not on the value of its bits, so it is the multiplication
+// to use with secret scalars. The point itself is treated as public.
+//
+// Note that the field arithmetic of the underlying edwards25519 library is
+// itself constant time, but the conversion of the inputs and the result from
+// and to big integers is not. Callers that need to hide the scalar length
+// should pad k to a fixed size. On Ed448 the multiplication is done with
+// fixed windows over a constant time field arithmetic of its own instead.
+func (curve *TwistedEdwardsCurve) ScalarMultConstantTime(Bx, By *big.Int,
+	k []byte) (x, y *big.Int) {
+	if curve.isEd448() {
+		kLE := make([]byte, len(k))
+		for i, b := range k {
+			kLE[len(k)-1-i] = b
+		}
+		defer zeroSlice(kLE)
+		return curve.ed448ScalarMultConstantTime(Bx, By, kLE)
+	}
+
+	p := new(edwards25519.ExtendedGroupElement)
+	if !p.FromBytes(BigIntPointToEncodedBytes(Bx, By)) {
+		return nil, nil
+	}
+
+	r0 := new(edwards25519.ExtendedGroupElement)
+	geScalarMultConstantTime(r0, p, k)
+
+	finalBytes := new([32]byte)
+	r0.ToBytes(finalBytes)
+
+	var err error
+	x, y, err = curve.EncodedBytesToBigIntPoint(finalBytes)
+	if err != nil {
+		return nil, nil
+	}
+
+	return
+}
+
+// geScalarMultConstantTime sets r0 = k*p for the big endian scalar k with the
+// Montgomery ladder of ScalarMultConstantTime.
+func geScalarMultConstantTime(r0, p *edwards25519.ExtendedGroupElement,
+	k []byte) {
+	// Ladder invariant: r1 - r0 = P.
+	r0.Zero()
+	r1 := new(edwards25519.ExtendedGroupElement)
+	*r1 = *p
+
+	var swap int32
+	for i := 0; i < len(k)*8; i++ {
+		bit := int32(k[i/8]>>uint(7-i%8)) & 1
+		swap ^= bit
+		geCondSwap(r0, r1, swap)
+		swap = bit
+
+		// r1 = r0 + r1, r0 = 2 * r0
+		var c edwards25519.CompletedGroupElement
+		var r1Cached cachedGroupElement
+		toCached(&r1Cached, r1)
+		geAdd(&c, r0, &r1Cached)
+		c.ToExtended(r1)
+		r0.Double(&c)
+		c.ToExtended(r0)
+	}
+	geCondSwap(r0, r1, swap)
+}
+
+// geIsIdentity returns whether or not the extended group element p is the
+// identity (neutral) element.
+func geIsIdentity(p *edwards25519.ExtendedGroupElement) bool {
+	var s [32]byte
+	p.ToBytes(&s)
+	return s == [32]byte{1}
+}
+
+// isPrimeOrderPoint returns whether or not the extended group element p
+// generates the prime order subgroup, that is [N]p is the identity while
+// [8]p is not. This rejects the identity, the other seven points of small
+// order, and every point with a small order component.
+func (curve *TwistedEdwardsCurve) isPrimeOrderPoint(
+	p *edwards25519.ExtendedGroupElement) bool {
+	return !isSmallOrderPoint(p) && curve.isTorsionFree(p)
+}
+
+// isSmallOrderPoint returns whether or not the extended group element p is
+// one of the eight points whose order divides the cofactor, that is whether
+// [8]p is the identity.
+func isSmallOrderPoint(p *edwards25519.ExtendedGroupElement) bool {
+	p8 := *p
+	for i := 0; i < 3; i++ {
+		var c edwards25519.CompletedGroupElement
+		p8.Double(&c)
+		c.ToExtended(&p8)
+	}
+	return geIsIdentity(&p8)
+}
+
+// montgomeryA is the constant A of v^2 = u^3 + A*u^2 + u, the Montgomery form
+// Curve25519 of the curve.
+var montgomeryA = big.NewInt(486662)
+
+// isTorsionFree returns whether or not the extended group element p has no
+// small order component. The group of the curve is the product of the
+// subgroup of order N and a cyclic group of order 8, so these are the
+// multiples [8]E of all points. Rather than computing [N]p, it uses the
+// 2-descent on the Montgomery form of the curve: a point with u != 0 is a
+// double of a point of the curve if and only if u is a square mod P.
+// Testing that three times while halving the point in between decides
+// membership of [8]E with a few field exponentiations, far less than a
+// scalar multiplication. This is variable time and must only be used with
+// public points.
+func (curve *TwistedEdwardsCurve) isTorsionFree(
+	p *edwards25519.ExtendedGroupElement) bool {
+	_, y, _ := curve.extendedToBigAffine(&p.X, &p.Y, &p.Z)
+
+	// u = (1 + y) / (1 - y), with y = 1 the identity and y = -1, u = 0 the
+	// point of order 2.
+	den := new(big.Int).Sub(one, y)
+	den.Mod(den, curve.P)
+	if den.Sign() == 0 {
+		return true
+	}
+	u := new(big.Int).Add(one, y)
+	u.Mul(u, curve.invert(den))
+	u.Mod(u, curve.P)
+
+	for i := 0; ; i++ {
+		if big.Jacobi(u, curve.P) != 1 {
+			return false
+		}
+		if i == 2 {
+			return true
+		}
+		// The two halves of a point differ by the point of order 2, which
+		// is in [4]E, so either of them serves for the next test.
+		if u = curve.montgomeryHalve(u); u == nil {
+			return false
+		}
+	}
+}
+
+// montgomeryHalve returns the u-coordinate of a point Q with 2Q = P from the
+// u-coordinate u != 0 of P, a double, or nil if there's none. The doubling
+// formula gives t = u_Q + 1/u_Q = 2u +- 2sqrt(u^2 + Au + 1), and for only one
+// of the signs the roots of u_Q^2 - t*u_Q + 1 = 0 are u-coordinates of
+// points on the curve rather than on its twist.
+func (curve *TwistedEdwardsCurve) montgomeryHalve(u *big.Int) *big.Int {
+	// u^2 + Au + 1
+	g := new(big.Int).Add(u, montgomeryA)
+	g.Mul(g, u)
+	g.Add(g, one)
+	g.Mod(g, curve.P)
+	s := new(big.Int).ModSqrt(g, curve.P)
+	if s == nil {
+		return nil
+	}
+
+	twoInv := curve.invert(two)
+	for _, sign := range []int64{1, -1} {
+		t := new(big.Int).Mul(s, big.NewInt(sign))
+		t.Add(t, u)
+		t.Lsh(t, 1)
+		t.Mod(t, curve.P)
+
+		disc := new(big.Int).Mul(t, t)
+		disc.Sub(disc, big.NewInt(4))
+		disc.Mod(disc, curve.P)
+		r := new(big.Int).ModSqrt(disc, curve.P)
+		if r == nil {
+			continue
+		}
+		uQ := new(big.Int).Add(t, r)
+		uQ.Mul(uQ, twoInv)
+		uQ.Mod(uQ, curve.P)
+
+		// v_Q^2 = u_Q^3 + A*u_Q^2 + u_Q must be a square.
+		v2 := new(big.Int).Add(uQ, montgomeryA)
+		v2.Mul(v2, uQ)
+		v2.Add(v2, one)
+		v2.Mul(v2, uQ)
+		if big.Jacobi(v2.Mod(v2, curve.P), curve.P) == 1 {
+			return uQ
+		}
+	}
+
+	return nil
+}
+
 // ScalarBaseMult returns k*G, where G is the base point of the group
-// and k is an integer in big-endian form.
-// TODO Optimize this with field elements
+// and k is an integer in big-endian form. It uses the precomputed table of
+// BaseMult.
 func (curve *TwistedEdwardsCurve) ScalarBaseMult(k []byte) (x, y *big.Int) {
-	return curve.ScalarMult(curve.Gx, curve.Gy, k)
+	return curve.BaseMult(k)
 }
 
 // ScalarAdd adds two scalars and returns the sum mod N.
@@ -401,6 +683,50 @@ func (curve *TwistedEdwardsCurve) InitParam25519() {
 	curve.byteSize = curve.BitSize / 8
 }
 
+// InitParamEd448 initializes an instance of the Ed448 (Goldilocks) curve of
+// RFC 8032, the untwisted Edwards curve x^2 + y^2 = 1 + dx^2y^2 with
+// d = -39081. The curve is recognized by the size of its field, and Sign,
+// Verify, the key parsing functions and the elliptic.Curve methods then use
+// the Ed448 arithmetic and encodings (57 byte points, SHAKE256 hashing). The
+// other protocols of the package (multisignatures, threshold signing, ring
+// signatures and so on) only support Ed25519.
+func (curve *TwistedEdwardsCurve) InitParamEd448() {
+	// The prime modulus of the field.
+	// P = 2^448 - 2^224 - 1
+	curve.CurveParams = new(elliptic.CurveParams)
+	curve.P = new(big.Int).SetBit(zero, 448, 1)
+	curve.P.Sub(curve.P, new(big.Int).SetBit(zero, 224, 1))
+	curve.P.Sub(curve.P, one)
+
+	// The prime order for the base point.
+	// N = 2^446 - 13818066809895115352007386748515426880336692474882178609894547503885
+	qs, _ := new(big.Int).SetString("1381806680989511535200738674851542688"+
+		"0336692474882178609894547503885", 10)
+	curve.N = new(big.Int).SetBit(zero, 446, 1)
+	curve.N.Sub(curve.N, qs)
+
+	curve.A = new(big.Int).Set(one)
+
+	// d = -39081
+	curve.D = new(big.Int).Sub(curve.P, big.NewInt(39081))
+
+	// The base point.
+	curve.Gx = new(big.Int)
+	curve.Gx.SetString("224580040295924300187604334099896036246789641632564"+
+		"134246125461686950415467406032909029192869357953282578032075146446"+
+		"173674602635247710", 10)
+	curve.Gy = new(big.Int)
+	curve.Gy.SetString("298819210078481492676017930443930673437544040154080"+
+		"242095928241372331506189835876003536878655418784733982303233503462"+
+		"500531545062832660", 10)
+
+	curve.BitSize = ed448FieldBits
+	curve.H = 4
+
+	// Points are encoded with an extra byte for the sign of x.
+	curve.byteSize = Ed448PubKeyBytesLen
+}
+
 // Edwards returns a Curve which implements Ed25519.
 func Edwards() *TwistedEdwardsCurve {
 	c := new(TwistedEdwardsCurve)
@@ -8,13 +8,29 @@
 // * TestRecoverXBigInt
 // * TestRecoverXFieldElement
 // * TestScalarMult
+// * TestScalarMultConstantTime
+// * TestScalarMultConstantTimeVariance
+// * TestVarBaseMult
+// * TestDoubleScalarMult
+// * TestBaseMult
+// * TestSelectCached
+// * TestCurveParamGetters
+// * TestFeCondSwap
+// * TestCurveNegSub
+// * TestUnmarshalSize
+// * TestIsTorsionFree
 
 package edwards
 
 import (
 	"bytes"
 	"encoding/hex"
+	"math/big"
+	"math/rand"
 	"testing"
+	"time"
+
+	"github.com/agl/ed25519/edwards25519"
 )
 
 // TestCurvePointAdd tests the addition on curve points
@@ -157,3 +173,490 @@ func TestScalarMult(t *testing.T) {
 		}
 	}
 }
+
+// TestScalarMultConstantTime tests the constant time multiplication
+//	against the known scalar multiplication vectors
+func TestScalarMultConstantTime(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	for _, vector := range mockUpScalarMultVec() {
+		x, y, _ := curve.EncodedBytesToBigIntPoint(vector.bIn)
+		sBig := EncodedBytesToBigInt(vector.s) // We need big endian
+		xMul, yMul := curve.ScalarMultConstantTime(x, y, sBig.Bytes())
+		finalPoint := BigIntPointToEncodedBytes(xMul, yMul)
+		if !bytes.Equal(vector.bRes[:], finalPoint[:]) {
+			t.Fatalf("want %s, got %s",
+				hex.EncodeToString(vector.bRes[:]), hex.EncodeToString(finalPoint[:]))
+		}
+
+		// Leading zero bytes must not change the result.
+		padded := BigIntToEncodedBytesNoReverse(sBig)
+		xPad, yPad := curve.ScalarMultConstantTime(x, y, padded[:])
+		if xPad.Cmp(xMul) != 0 || yPad.Cmp(yMul) != 0 {
+			t.Fatalf("padded scalar gave a different result")
+		}
+	}
+}
+
+// TestScalarMultConstantTimeVariance is a coarse statistical check that the
+//	running time of the constant time multiplication doesn't depend on the
+//	bits of the scalar, by comparing scalars of very low and very high
+//	hamming weight against random ones
+func TestScalarMultConstantTimeVariance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping timing test in short mode")
+	}
+
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+
+	genLow := func() []byte {
+		k := make([]byte, 32)
+		k[31] = 1
+		return k
+	}
+	genHigh := func() []byte {
+		k := make([]byte, 32)
+		for i := range k {
+			k[i] = 0xff
+		}
+		return k
+	}
+	genRandom := func() []byte {
+		k := make([]byte, 32)
+		r.Read(k)
+		return k
+	}
+
+	// Interleave the measurements so that any drift in the machine load
+	// affects all the scalars alike, and keep the fastest run of each
+	// since noise only ever makes a run slower.
+	const numSamples = 25
+	gens := []func() []byte{genLow, genHigh, genRandom}
+	fastest := make([]time.Duration, len(gens))
+	for i := 0; i < numSamples; i++ {
+		for j, gen := range gens {
+			k := gen()
+			start := time.Now()
+			curve.ScalarMultConstantTime(curve.Gx, curve.Gy, k)
+			d := time.Since(start)
+			if i == 0 || d < fastest[j] {
+				fastest[j] = d
+			}
+		}
+	}
+	low, high, random := fastest[0], fastest[1], fastest[2]
+
+	// The variable time multiplication differs by a factor of roughly
+	// two between these scalars, so a generous bound still catches a
+	// regression to a bit dependent algorithm.
+	for _, d := range []time.Duration{low, high} {
+		ratio := float64(d) / float64(random)
+		if ratio < 0.6 || ratio > 1.6 {
+			t.Fatalf("timing depends on the scalar: low %v, high %v, "+
+				"random %v", low, high, random)
+		}
+	}
+}
+
+// TestVarBaseMult tests the variable base NAF multiplication against the
+// known scalar multiplication vectors and the generic scalar multiplication,
+// for random points and scalars of many lengths, for points of small order
+// and for scalars around the order of the group
+func TestVarBaseMult(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+
+	for i, vector := range mockUpScalarMultVec() {
+		x, y, _ := curve.EncodedBytesToBigIntPoint(vector.bIn)
+		sBig := EncodedBytesToBigInt(vector.s)
+		xMul, yMul := curve.VarBaseMult(x, y, sBig.Bytes())
+		finalPoint := BigIntPointToEncodedBytes(xMul, yMul)
+		if !bytes.Equal(vector.bRes[:], finalPoint[:]) {
+			t.Fatalf("vector %d: want %x, got %x", i, vector.bRes[:],
+				finalPoint[:])
+		}
+	}
+
+	type point struct{ x, y *big.Int }
+	points := []point{{curve.Gx, curve.Gy}}
+	for _, str := range smallOrderPoints {
+		b, _ := hex.DecodeString(str)
+		if x, y, err := curve.EncodedBytesToBigIntPoint(copyBytes(b)); err ==
+			nil {
+			points = append(points, point{x, y})
+		}
+	}
+	for _, sk := range mockUpSecKeysByScalars(curve, 2) {
+		points = append(points, point{sk.PubKey().GetX(), sk.PubKey().GetY()})
+	}
+	// A point with a small order component.
+	tx, ty := curve.Add(points[len(points)-1].x, points[len(points)-1].y,
+		points[2].x, points[2].y)
+	points = append(points, point{tx, ty})
+
+	scalars := [][]byte{
+		{},
+		{0x01},
+		{0xff},
+		curve.N.Bytes(),
+		new(big.Int).Sub(curve.N, one).Bytes(),
+		new(big.Int).Add(curve.N, one).Bytes(),
+		bytes.Repeat([]byte{0xff}, 32),
+	}
+	for i := 0; i < 6; i++ {
+		k := make([]byte, 1+r.Intn(40))
+		r.Read(k)
+		scalars = append(scalars, k)
+	}
+
+	// The Montgomery ladder doesn't reduce k either, and is much faster
+	// than the generic ScalarMult.
+	for i, p := range points {
+		for _, k := range scalars {
+			wantX, wantY := curve.ScalarMultConstantTime(p.x, p.y, k)
+			x, y := curve.VarBaseMult(p.x, p.y, k)
+			if x == nil || x.Cmp(wantX) != 0 || y.Cmp(wantY) != 0 {
+				t.Fatalf("point %d, scalar %x: want (%v, %v), got (%v, %v)",
+					i, k, wantX, wantY, x, y)
+			}
+		}
+	}
+
+	if x, y := curve.VarBaseMult(one, one, []byte{0x01}); x != nil ||
+		y != nil {
+		t.Fatalf("multiplied a point off the curve")
+	}
+}
+
+// TestDoubleScalarMult tests the double scalar multiplication against
+// edwards25519.GeDoubleScalarMultVartime, that scalars of the point aren't
+// reduced, as with VarBaseMult, and that Verify agrees with it
+func TestDoubleScalarMult(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+
+	scalars := [][]byte{
+		{},
+		{0x01},
+		curve.N.Bytes(),
+		new(big.Int).Sub(curve.N, one).Bytes(),
+		bytes.Repeat([]byte{0xff}, 32),
+	}
+	for i := 0; i < 8; i++ {
+		k := make([]byte, 1+r.Intn(32))
+		r.Read(k)
+		scalars = append(scalars, k)
+	}
+
+	for i, sk := range mockUpSecKeysByScalars(curve, 3) {
+		px, py := sk.PubKey().GetX(), sk.PubKey().GetY()
+		var A edwards25519.ExtendedGroupElement
+		A.FromBytes(BigIntPointToEncodedBytes(px, py))
+		for j, k1 := range scalars {
+			k2 := scalars[(j+i+1)%len(scalars)]
+
+			// The key is of prime order, so the scalars can be reduced
+			// below 2^255 for GeDoubleScalarMultVartime.
+			k1LE := ScalarToBytesLE(new(big.Int).SetBytes(k1))
+			k2LE := ScalarToBytesLE(new(big.Int).SetBytes(k2))
+			var want edwards25519.ProjectiveGroupElement
+			edwards25519.GeDoubleScalarMultVartime(&want, &k2LE, &A, &k1LE)
+			var wantBytes [32]byte
+			want.ToBytes(&wantBytes)
+			wantX, wantY, _ := curve.EncodedBytesToBigIntPoint(&wantBytes)
+
+			x, y := curve.DoubleScalarMult(k1, k2, px, py)
+			if x == nil || x.Cmp(wantX) != 0 || y.Cmp(wantY) != 0 {
+				t.Fatalf("key %d: %x*G + %x*P: want (%v, %v), got (%v, %v)",
+					i, k1, k2, wantX, wantY, x, y)
+			}
+		}
+
+		// sG - hA is R for a valid signature.
+		msg := []byte{byte(i)}
+		sigR, sigS, err := Sign(curve, sk, msg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !Verify(sk.PubKey(), msg, sigR, sigS) {
+			t.Fatalf("key %d: signature failed to verify", i)
+		}
+		if Verify(sk.PubKey(), append(msg, 0x00), sigR, sigS) {
+			t.Fatalf("key %d: verified for another message", i)
+		}
+	}
+
+	// Scalars longer than 32 bytes multiply a point with a small order
+	// component like VarBaseMult does.
+	long := append([]byte{0x01}, bytes.Repeat([]byte{0x5a}, 32)...)
+	b, _ := hex.DecodeString(smallOrderPoints[4])
+	tx, ty, _ := curve.EncodedBytesToBigIntPoint(copyBytes(b))
+	tx, ty = curve.Add(curve.Gx, curve.Gy, tx, ty)
+	x, y := curve.DoubleScalarMult(long, long, tx, ty)
+	x1, y1 := curve.BaseMult(long)
+	x2, y2 := curve.VarBaseMult(tx, ty, long)
+	if wantX, wantY := curve.Add(x1, y1, x2, y2); x == nil ||
+		x.Cmp(wantX) != 0 || y.Cmp(wantY) != 0 {
+		t.Fatalf("long scalars: want (%v, %v), got (%v, %v)", wantX, wantY,
+			x, y)
+	}
+	if x, _ := curve.DoubleScalarMult(long, long, one, one); x != nil {
+		t.Fatalf("multiplied a point off the curve")
+	}
+}
+
+// TestBaseMult tests the table based base point multiplication against
+//	the generic scalar multiplication
+func TestBaseMult(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+
+	scalars := [][]byte{
+		{},
+		{0x00},
+		{0x01},
+		{0x10},
+		curve.N.Bytes(),
+		new(big.Int).Sub(curve.N, one).Bytes(),
+		new(big.Int).Add(curve.N, one).Bytes(),
+	}
+	for i := 0; i < 32; i++ {
+		// Also use scalars longer than 32 bytes.
+		k := make([]byte, 1+r.Intn(64))
+		r.Read(k)
+		scalars = append(scalars, k)
+	}
+
+	for _, k := range scalars {
+		wantX, wantY := curve.ScalarMult(curve.Gx, curve.Gy, k)
+		x, y := curve.BaseMult(k)
+		if x.Cmp(wantX) != 0 || y.Cmp(wantY) != 0 {
+			t.Fatalf("scalar %x: want (%v, %v), got (%v, %v)", k, wantX,
+				wantY, x, y)
+		}
+	}
+}
+
+// cachedBytes returns the encoded coordinates of a cached group element.
+func cachedBytes(c *cachedGroupElement) []byte {
+	var b []byte
+	for _, fe := range []*edwards25519.FieldElement{&c.yPlusX, &c.yMinusX,
+		&c.Z, &c.T2d} {
+		var feBytes [32]byte
+		edwards25519.FeToBytes(&feBytes, fe)
+		b = append(b, feBytes[:]...)
+	}
+	return b
+}
+
+// TestSelectCached tests that the constant time table lookup used by
+// BaseMult selects the same points as indexing the table directly, for every
+// window and value of the base point table and of the table of another point
+func TestSelectCached(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	var p edwards25519.ExtendedGroupElement
+	edwards25519.GeScalarMultBase(&p, BigIntToEncodedBytes(big.NewInt(12345)))
+	other := new([64][16]cachedGroupElement)
+	buildMultTable(other, p)
+
+	for _, table := range []*[64][16]cachedGroupElement{getBaseTable(curve),
+		other} {
+		for i := range table {
+			for j := byte(0); j < 16; j++ {
+				var got cachedGroupElement
+				selectCached(&got, &table[i], j)
+				if !bytes.Equal(cachedBytes(&got), cachedBytes(&table[i][j])) {
+					t.Fatalf("window %d, value %d: selected the wrong point",
+						i, j)
+				}
+			}
+		}
+	}
+}
+
+// TestCurveParamGetters tests that the curve parameter getters return copies
+// which can be modified without affecting the curve
+func TestCurveParamGetters(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	want := new(TwistedEdwardsCurve)
+	want.InitParam25519()
+
+	if curve.Order().Cmp(want.N) != 0 ||
+		curve.FieldPrime().Cmp(want.P) != 0 || curve.Cofactor() != 8 {
+		t.Fatalf("getters don't match the curve parameters")
+	}
+	gx, gy := curve.BasePoint()
+	if gx.Cmp(want.Gx) != 0 || gy.Cmp(want.Gy) != 0 {
+		t.Fatalf("base point doesn't match the curve parameters")
+	}
+
+	// Mutate everything the getters returned.
+	curve.Order().SetInt64(1)
+	curve.FieldPrime().SetInt64(1)
+	gx.SetInt64(1)
+	gy.SetInt64(1)
+
+	if curve.N.Cmp(want.N) != 0 || curve.P.Cmp(want.P) != 0 ||
+		curve.Gx.Cmp(want.Gx) != 0 || curve.Gy.Cmp(want.Gy) != 0 {
+		t.Fatalf("curve parameters modified through a getter")
+	}
+
+	// Signing and verifying still work.
+	priv := mockUpSecKeysByBytes(curve, 1)[0]
+	msg := []byte("Hello World in TestCurveParamGetters")
+	r, s, err := Sign(curve, priv, msg)
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+	if !Verify(priv.PubKey(), msg, r, s) {
+		t.Fatalf("signature failed to verify")
+	}
+}
+
+// TestFeCondSwap tests that the conditional swap of field elements exchanges
+// them for swap = 1 and leaves them alone for swap = 0
+func TestFeCondSwap(t *testing.T) {
+	r := rand.New(rand.NewSource(12345))
+
+	randFe := func() *edwards25519.FieldElement {
+		var b [32]byte
+		r.Read(b[:])
+		b[31] &= 0x7f
+		fe := new(edwards25519.FieldElement)
+		edwards25519.FeFromBytes(fe, &b)
+		return fe
+	}
+
+	for i := 0; i < 100; i++ {
+		a, b := randFe(), randFe()
+		wantA, wantB := *a, *b
+
+		feCondSwap(a, b, 0)
+		if *a != wantA || *b != wantB {
+			t.Fatalf("test %d: elements changed for swap = 0", i)
+		}
+
+		feCondSwap(a, b, 1)
+		if *a != wantB || *b != wantA {
+			t.Fatalf("test %d: elements not swapped for swap = 1", i)
+		}
+
+		// Swapping an element with itself leaves it untouched.
+		feCondSwap(a, a, 1)
+		if *a != wantB {
+			t.Fatalf("test %d: element changed swapping with itself", i)
+		}
+	}
+}
+
+// TestCurveNegSub tests that P + (-P) is the identity and that subtracting a
+// point undoes adding it, on both curves
+func TestCurveNegSub(t *testing.T) {
+	ed25519 := new(TwistedEdwardsCurve)
+	ed25519.InitParam25519()
+	ed448 := new(TwistedEdwardsCurve)
+	ed448.InitParamEd448()
+
+	for _, curve := range []*TwistedEdwardsCurve{ed25519, ed448} {
+		gx, gy := curve.Gx, curve.Gy
+		points := [][2]*big.Int{{gx, gy}}
+		for _, k := range []int64{2, 3, 1000, 123456789} {
+			x, y := curve.ScalarMult(gx, gy, big.NewInt(k).Bytes())
+			points = append(points, [2]*big.Int{x, y})
+		}
+		// The identity is its own negation.
+		points = append(points, [2]*big.Int{big.NewInt(0), big.NewInt(1)})
+
+		for i, p := range points {
+			negX, negY := curve.Neg(p[0], p[1])
+			if !curve.IsOnCurve(negX, negY) {
+				t.Fatalf("test %d: negation is off curve", i)
+			}
+			x, y := curve.Add(p[0], p[1], negX, negY)
+			if x.Sign() != 0 || y.Cmp(one) != 0 {
+				t.Fatalf("test %d: P + (-P) = (%v, %v), want the identity",
+					i, x, y)
+			}
+			x, y = curve.Sub(p[0], p[1], p[0], p[1])
+			if x.Sign() != 0 || y.Cmp(one) != 0 {
+				t.Fatalf("test %d: P - P = (%v, %v), want the identity", i,
+					x, y)
+			}
+
+			for j, q := range points {
+				sumX, sumY := curve.Add(p[0], p[1], q[0], q[1])
+				x, y := curve.Sub(sumX, sumY, q[0], q[1])
+				if x.Cmp(p[0]) != 0 || y.Cmp(p[1]) != 0 {
+					t.Fatalf("test %d, %d: (P + Q) - Q != P", i, j)
+				}
+			}
+		}
+	}
+}
+
+// TestUnmarshalSize tests that Unmarshal only accepts 32 byte encodings, as
+// padding the little endian encoding of a point would change it
+func TestUnmarshalSize(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	b := Marshal(*curve, curve.Gx, curve.Gy)
+	if x, y := Unmarshal(curve, b); x == nil || x.Cmp(curve.Gx) != 0 ||
+		y.Cmp(curve.Gy) != 0 {
+		t.Fatalf("base point changed in a roundtrip")
+	}
+	for _, data := range [][]byte{nil, b[:31], b[1:], append(b, 0x00)} {
+		if x, y := Unmarshal(curve, data); x != nil || y != nil {
+			t.Fatalf("unmarshaled %x of %d bytes", data, len(data))
+		}
+	}
+}
+
+// TestIsTorsionFree tests the torsion test against computing [N]p for points
+// in every coset of the prime order subgroup
+func TestIsTorsionFree(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	type point struct{ x, y *big.Int }
+	points := []point{{curve.Gx, curve.Gy}, {big.NewInt(0), big.NewInt(1)}}
+	for _, sk := range mockUpSecKeysByScalars(curve, 6) {
+		points = append(points, point{sk.PubKey().GetX(), sk.PubKey().GetY()})
+	}
+	var torsion []point
+	for _, str := range smallOrderPoints {
+		b, _ := hex.DecodeString(str)
+		if x, y, err := curve.EncodedBytesToBigIntPoint(copyBytes(b)); err ==
+			nil {
+			torsion = append(torsion, point{x, y})
+		}
+	}
+
+	nBytes := BigIntToEncodedBytes(curve.N)
+	for i, pt := range points {
+		for j, tp := range torsion {
+			x, y := curve.Add(pt.x, pt.y, tp.x, tp.y)
+			var p edwards25519.ExtendedGroupElement
+			if !p.FromBytes(BigIntPointToEncodedBytes(x, y)) {
+				t.Fatalf("point %d, torsion %d: failed to decode", i, j)
+			}
+			var pN edwards25519.ExtendedGroupElement
+			multiScalarMultVartime(&pN, []*[32]byte{nBytes},
+				[]*edwards25519.ExtendedGroupElement{&p})
+			if want, got := geIsIdentity(&pN), curve.isTorsionFree(&p); got !=
+				want {
+				t.Fatalf("point %d, torsion %d: want %v, got %v", i, j, want,
+					got)
+			}
+		}
+	}
+}
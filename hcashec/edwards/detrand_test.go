@@ -0,0 +1,80 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// TestDeterministicReader tests that readers with the same seed yield the
+// same stream however it is read and the same sequence of keys, and that
+// another seed yields other keys
+func TestDeterministicReader(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	ed448 := new(TwistedEdwardsCurve)
+	ed448.InitParamEd448()
+	seed := []byte("TestDeterministicReader")
+
+	// Reading in odd sized chunks gives the same bytes as a single read.
+	whole := make([]byte, 1000)
+	io.ReadFull(NewDeterministicReader(seed), whole)
+	var chunked []byte
+	r := NewDeterministicReader(seed)
+	for len(chunked) < len(whole) {
+		chunk := make([]byte, 1+len(chunked)%67)
+		if n, err := r.Read(chunk); err != nil || n != len(chunk) {
+			t.Fatalf("short read of %d bytes: %v", n, err)
+		}
+		chunked = append(chunked, chunk...)
+	}
+	if !bytes.Equal(whole, chunked[:len(whole)]) {
+		t.Fatalf("chunked reads differ from a single read")
+	}
+	if bytes.Equal(whole[:64], whole[64:128]) {
+		t.Fatalf("stream repeats its first block")
+	}
+
+	for _, c := range []*TwistedEdwardsCurve{curve, ed448} {
+		a, err := GenerateKeys(c, NewDeterministicReader(seed), 3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		b, err := GenerateKeys(c, NewDeterministicReader(seed), 3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		other, err := GenerateKeys(c, NewDeterministicReader([]byte("other")),
+			3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for i := range a {
+			if !a[i].PubKey().Equal(b[i].PubKey()) {
+				t.Fatalf("key %d differs for the same seed", i)
+			}
+			if a[i].PubKey().Equal(other[i].PubKey()) {
+				t.Fatalf("key %d is the same for another seed", i)
+			}
+		}
+		if a[0].PubKey().Equal(a[1].PubKey()) {
+			t.Fatalf("keys of a sequence are the same")
+		}
+	}
+
+	priv1, x1, y1, err := GenerateKey(curve, NewDeterministicReader(seed))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	priv2, x2, y2, err := GenerateKey(curve, NewDeterministicReader(seed))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(priv1, priv2) || x1.Cmp(x2) != 0 || y1.Cmp(y2) != 0 {
+		t.Fatalf("GenerateKey differs for the same seed")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// dlogProofTag is the domain separation tag of the challenges of proofs of
+// knowledge of a discrete log, which keeps them from ever being valid
+// Ed25519 signatures or proofs of another protocol.
+const dlogProofTag = "edwards25519 dlog proof"
+
+// DLogProof is a non-interactive proof of knowledge of the private key a of
+// a public key A = aG, a Schnorr identification made non-interactive with
+// the Fiat-Shamir transform. R is the encoded nonce point and S the
+// response, as in a Signature.
+type DLogProof struct {
+	R *big.Int
+	S *big.Int
+}
+
+// dlogChallenge computes the challenge
+// e = taggedHash(tag, len(context) || context || A || R) mod N of a proof of
+// knowledge for the encoded public key pub and nonce point r, as a 32 byte
+// little endian scalar.
+func dlogChallenge(context []byte, pub, r *[32]byte) *[32]byte {
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(context)))
+
+	return taggedScalar(dlogProofTag, size[:], context, pub[:], r[:])
+}
+
+// ProveDLog proves knowledge of the private key of priv with a random nonce,
+// R = kG and S = k + e*a, bound to context. The context should identify the
+// protocol and the session, e.g. the transcript of a handshake, since a
+// proof verifies for any verifier that uses the same context. Only Ed25519
+// keys are supported.
+func ProveDLog(priv *PrivateKey, context []byte) (*DLogProof, error) {
+	if priv == nil {
+		return nil, fmt.Errorf("private key is nil")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, err
+	}
+	curve, ok := priv.PubKey().Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil || curve.isEd448() {
+		return nil, fmt.Errorf("proofs of knowledge are only supported on " +
+			"Ed25519")
+	}
+
+	k, err := NewRandomScalar(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	defer k.SetInt64(0)
+	kLE := BigIntToEncodedBytes(k)
+	defer zeroSlice(kLE[:])
+
+	var r edwards25519.ExtendedGroupElement
+	edwards25519.GeScalarMultBase(&r, kLE)
+	var encodedR [32]byte
+	r.ToBytes(&encodedR)
+
+	pubX, pubY := priv.Public()
+	e := dlogChallenge(context, BigIntPointToEncodedBytes(pubX, pubY),
+		&encodedR)
+
+	a := priv.reducedScalar(curve)
+	defer a.SetInt64(0)
+	s := scalarMulAdd(EncodedBytesToBigInt(e), a, k)
+
+	return &DLogProof{EncodedBytesToBigInt(&encodedR), s}, nil
+}
+
+// VerifyDLog verifies the proof of knowledge of the private key of pub for
+// context, as produced by ProveDLog, by checking that SG - eA = R. Public
+// keys outside the prime order subgroup are rejected, since knowing the
+// private key of their prime order part would be enough to prove them, as
+// are non-canonical proofs.
+func VerifyDLog(pub *PublicKey, context []byte, proof *DLogProof) bool {
+	if pub == nil || pub.GetX() == nil || pub.GetY() == nil ||
+		proof == nil || proof.R == nil || proof.S == nil {
+		return false
+	}
+	curve, ok := pub.Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil || curve.isEd448() {
+		return false
+	}
+	if !IsCanonical(&Signature{proof.R, proof.S}) {
+		return false
+	}
+
+	encodedA := BigIntPointToEncodedBytes(pub.GetX(), pub.GetY())
+	var A edwards25519.ExtendedGroupElement
+	if !A.FromBytes(encodedA) || !curve.isPrimeOrderPoint(&A) {
+		return false
+	}
+	encodedR := BigIntToEncodedBytes(proof.R)
+	var r edwards25519.ExtendedGroupElement
+	if !r.FromBytes(encodedR) {
+		return false
+	}
+
+	e := dlogChallenge(context, encodedA, encodedR)
+	negE := BigIntToEncodedBytes(new(big.Int).Sub(curve.N,
+		EncodedBytesToBigInt(e)))
+	var check edwards25519.ExtendedGroupElement
+	doubleScalarMultVartime(&check, negE, &A, BigIntToEncodedBytes(proof.S))
+	var checkR [32]byte
+	check.ToBytes(&checkR)
+
+	return checkR == *encodedR
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// TestDLogProof tests that proofs of knowledge verify for the right key and
+// context only, and that tampered proofs, small order keys and Ed448 keys
+// are rejected
+func TestDLogProof(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	context := []byte("peer handshake 1")
+	var privs []*PrivateKey
+	privs = append(privs, mockUpSecKeysByBytes(curve, 2)...)
+	privs = append(privs, mockUpSecKeysByScalars(curve, 2)...)
+	for i, priv := range privs {
+		proof, err := ProveDLog(priv, context)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		if !VerifyDLog(priv.PubKey(), context, proof) {
+			t.Fatalf("key %d: proof failed to verify", i)
+		}
+
+		// Proofs are randomized but all verify.
+		again, err := ProveDLog(priv, context)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		if again.R.Cmp(proof.R) == 0 {
+			t.Fatalf("key %d: nonce reused", i)
+		}
+		if !VerifyDLog(priv.PubKey(), context, again) {
+			t.Fatalf("key %d: second proof failed to verify", i)
+		}
+
+		other := privs[(i+1)%len(privs)].PubKey()
+		if VerifyDLog(other, context, proof) {
+			t.Fatalf("key %d: proof verified for the wrong key", i)
+		}
+
+		// A proof can't be replayed in another context.
+		for _, ctx := range [][]byte{nil, []byte("peer handshake 2"),
+			context[:len(context)-1]} {
+			if VerifyDLog(priv.PubKey(), ctx, proof) {
+				t.Fatalf("key %d: proof replayed in context %q", i, ctx)
+			}
+		}
+
+		// Nor used as an Ed25519 signature of the context.
+		if Verify(priv.PubKey(), context, proof.R, proof.S) {
+			t.Fatalf("key %d: proof verified as a signature", i)
+		}
+
+		tampered := []*DLogProof{
+			{proof.R, new(big.Int).Add(proof.S, one)},
+			{proof.R, new(big.Int).Add(proof.S, curve.N)},
+			{new(big.Int).Add(proof.R, one), proof.S},
+			{nil, proof.S},
+			nil,
+		}
+		for j, bad := range tampered {
+			if VerifyDLog(priv.PubKey(), context, bad) {
+				t.Fatalf("key %d: tampered proof %d verified", i, j)
+			}
+		}
+	}
+
+	// A key of small order has no private key to know, and anyone could
+	// prove it with S = k and R = kG.
+	b, _ := hex.DecodeString(smallOrderPoints[len(smallOrderPoints)-1])
+	x, y, err := curve.EncodedBytesToBigIntPoint(copyBytes(b))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	small := NewPublicKey(curve, x, y)
+	k := mockUpSecKeysByScalars(curve, 1)[0]
+	kX, kY := k.Public()
+	forged := &DLogProof{EncodedBytesToBigInt(BigIntPointToEncodedBytes(kX,
+		kY)), k.GetD()}
+	if VerifyDLog(small, context, forged) {
+		t.Fatalf("proof for a small order key verified")
+	}
+
+	ed448 := new(TwistedEdwardsCurve)
+	ed448.InitParamEd448()
+	ed448Keys, err := GenerateKeys(ed448, NewDeterministicReader(context), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ProveDLog(ed448Keys[0], context); err == nil {
+		t.Fatalf("proved knowledge of an Ed448 key")
+	}
+	if _, err := ProveDLog(nil, context); err == nil {
+		t.Fatalf("proved knowledge of a nil key")
+	}
+}
@@ -37,12 +37,20 @@ var (
 
 // GenerateKey generates a key using a random number generator, returning
 // the private scalar and the corresponding public key points from a
-// random secret.
+// random secret. If rand is an EntropySource, no key is generated unless it
+// passes its health check.
 func GenerateKey(curve *TwistedEdwardsCurve, rand io.Reader) (priv []byte, x,
 	y *big.Int, err error) {
+	if err := checkEntropySource(rand); err != nil {
+		return nil, nil, nil, err
+	}
+
 	var pub *[PubKeyBytesLen]byte
 	var privArray *[PrivKeyBytesLen]byte
 	pub, privArray, err = ed25519.GenerateKey(rand)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	priv = privArray[:]
 
 	x, y, err = curve.EncodedBytesToBigIntPoint(pub)
@@ -53,33 +61,88 @@ func GenerateKey(curve *TwistedEdwardsCurve, rand io.Reader) (priv []byte, x,
 	return
 }
 
-// SignFromSecret signs a message 'hash' using the given private key priv. It doesn't
-// actually user the random reader (the lib is maybe deterministic???).
-func SignFromSecret(rand io.Reader, priv *PrivateKey, hash []byte) (r, s *big.Int,
+// GenerateKeys generates n private keys for curve, such as the keys of the
+// address gap a new wallet pre-creates. The entropy for all the keys is read
+// from rand at once and each key is derived from its part of it as by
+// PrivKeyFromSecret, so a slow reader is only waited on once. The public
+// keys are computed from the secrets rather than parsed, which also skips
+// the costly subgroup check ParsePubKey does on untrusted keys. A short read
+// fails the whole batch, reporting how much entropy was read, and no keys
+// are returned. If rand is an EntropySource, it must pass its health check
+// first.
+func GenerateKeys(curve *TwistedEdwardsCurve, rand io.Reader,
+	n int) ([]*PrivateKey, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid number of keys %v", n)
+	}
+	if err := checkEntropySource(rand); err != nil {
+		return nil, err
+	}
+	secretSize := PrivKeyBytesLen / 2
+	if curve.isEd448() {
+		secretSize = Ed448SeedSize
+	}
+
+	entropy := make([]byte, n*secretSize)
+	defer zeroSlice(entropy)
+	if read, err := io.ReadFull(rand, entropy); err != nil {
+		return nil, fmt.Errorf("failed to read entropy for %v keys: got "+
+			"%v of %v bytes: %v", n, read, len(entropy), err)
+	}
+
+	keys := make([]*PrivateKey, n)
+	for i := range keys {
+		secret := entropy[i*secretSize : (i+1)*secretSize]
+		if curve.isEd448() {
+			keys[i], _ = PrivKeyFromSecret(curve, secret)
+			if keys[i] == nil {
+				return nil, fmt.Errorf("failed to derive key %v", i)
+			}
+			continue
+		}
+
+		pubBytes, pk, err := ed25519.GenerateKey(bytes.NewReader(secret))
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key %v: %v", i, err)
+		}
+		x, y, err := curve.EncodedBytesToBigIntPoint(pubBytes)
+		if err != nil {
+			zeroSlice(pk[:])
+			return nil, fmt.Errorf("failed to derive key %v: %v", i, err)
+		}
+		keys[i] = privKeyFromParts(pk, NewPublicKey(curve, x, y))
+		zeroSlice(pk[:])
+	}
+
+	return keys, nil
+}
+
+// SignFromSecret signs a message 'hash' using the given signer, which is
+// usually a *PrivateKey but may be any Signer such as a hardware wallet. It
+// doesn't actually use the random reader, since the signatures are
+// deterministic.
+func SignFromSecret(rand io.Reader, priv Signer, hash []byte) (r, s *big.Int,
 	err error) {
 	r, s, err = SignFromSecretNoReader(priv, hash)
 
 	return
 }
 
-// SignFromSecretNoReader signs a message 'hash' using the given private key
-// priv. It doesn't actually user the random reader.
-func SignFromSecretNoReader(priv *PrivateKey, hash []byte) (r, s *big.Int,
+// SignFromSecretNoReader signs a message 'hash' using the given signer, which
+// is usually a *PrivateKey but may be any Signer such as a hardware wallet.
+// Private keys holding a secret seed produce the signatures of the reference
+// Ed25519 implementation.
+func SignFromSecretNoReader(priv Signer, hash []byte) (r, s *big.Int,
 	err error) {
-	privBytes := priv.SerializeSecret()
-	privArray := copyBytes64(privBytes)
-	sig := ed25519.Sign(privArray, hash)
-
-	// The signatures are encoded as
-	//   sig[0:32]  R, a point encoded as little endian
-	//   sig[32:64] S, scalar multiplication/addition results = (ab+c) mod l
-	//     encoded also as little endian
-	rBytes := copyBytes(sig[0:32])
-	r = EncodedBytesToBigInt(rBytes)
-	sBytes := copyBytes(sig[32:64])
-	s = EncodedBytesToBigInt(sBytes)
+	if priv == nil {
+		return nil, nil, fmt.Errorf("signer is nil")
+	}
+	sig, err := priv.Sign(hash)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return
+	return sig.GetR(), sig.GetS(), nil
 }
 
 // nonceRFC6979 is a local instatiation of deterministic nonce generation
@@ -106,7 +169,10 @@ func NonceRFC6979(curve *TwistedEdwardsCurve, privkey *big.Int, hash []byte,
 	qlen := q.BitLen()
 	holen := alg().Size()
 	rolen := (qlen + 7) >> 3
-	bx := append(int2octets(x, rolen), bits2octets(hash, curve, rolen)...)
+	xOctets := int2octets(x, rolen)
+	defer zeroSlice(xOctets)
+	bx := append(xOctets, bits2octets(hash, curve, rolen)...)
+	defer zeroSlice(bx)
 	if len(extra) == 32 {
 		bx = append(bx, extra...)
 	}
@@ -149,9 +215,13 @@ func NonceRFC6979(curve *TwistedEdwardsCurve, privkey *big.Int, hash []byte,
 
 		// Step H3
 		secret := hashToInt(t, curve)
+		zeroSlice(t)
 		if secret.Cmp(one) >= 0 && secret.Cmp(q) < 0 {
+			zeroSlice(k)
+			zeroSlice(v)
 			return secret
 		}
+		secret.SetInt64(0)
 		k = mac(alg, k, append(v, 0x00))
 		v = mac(alg, k, v)
 	}
@@ -219,22 +289,55 @@ func bits2octets(in []byte, curve *TwistedEdwardsCurve, rolen int) []byte {
 
 // SignFromScalar signs a message 'hash' using the given private scalar priv.
 // It uses RFC6979 to generate a deterministic nonce. Considered experimental.
+// An invalid nonce is rejected with a ScalarError matching ErrInvalidNonce.
 // r = kG, where k is the RFC6979 nonce
 // s = r + hash512(k || A || M) * a
 func SignFromScalar(curve *TwistedEdwardsCurve, priv *PrivateKey,
 	nonce []byte, hash []byte) (r, s *big.Int, err error) {
-	publicKey := new([PubKeyBytesLen]byte)
-	var A edwards25519.ExtendedGroupElement
-	privateScalar := copyBytes(priv.Serialize())
+	if err := priv.signingErr(); err != nil {
+		return nil, nil, err
+	}
+	if err := checkNonce(curve, nonce); err != nil {
+		return nil, nil, err
+	}
+
+	privBytes := priv.Serialize()
+	privateScalar := copyBytes(privBytes)
+	zeroSlice(privBytes)
 	reverse(privateScalar) // BE --> LE
-	edwards25519.GeScalarMultBase(&A, privateScalar)
-	A.ToBytes(publicKey)
 
 	// For signing from a scalar, r = nonce.
 	nonceLE := copyBytes(nonce)
 	reverse(nonceLE)
+
+	return signScalarLE(curve, privateScalar, nonceLE, hash)
+}
+
+// signScalarLE signs hash with the little endian private scalar a and
+// nonce k, which are zeroed before it returns, as are the other secret
+// intermediates it creates. Secrets held in big.Int values, such as the
+// D of a PrivateKey, can't be wiped reliably this way since math/big may
+// copy their words when it reallocates, so the point is only to keep the
+// copies made for signing from lingering on the heap.
+// R = kG
+// S = k + hash512(R || A || M) * a
+func signScalarLE(curve *TwistedEdwardsCurve, privateScalar, nonceLE *[32]byte,
+	hash []byte) (r, s *big.Int, err error) {
+	defer zeroSlice(privateScalar[:])
+	defer zeroSlice(nonceLE[:])
+
+	publicKey := new([PubKeyBytesLen]byte)
+	var A edwards25519.ExtendedGroupElement
+	edwards25519.GeScalarMultBase(&A, privateScalar)
+	A.ToBytes(publicKey)
+
 	var R edwards25519.ExtendedGroupElement
 	edwards25519.GeScalarMultBase(&R, nonceLE)
+	if geIsIdentity(&R) {
+		serr := scalarError(ScalarZero, "nonce point is the identity")
+		serr.nonce = true
+		return nil, nil, serr
+	}
 
 	var encodedR [32]byte
 	R.ToBytes(&encodedR)
@@ -278,9 +381,16 @@ func SignFromScalar(curve *TwistedEdwardsCurve, priv *PrivateKey,
 func SignThreshold(curve *TwistedEdwardsCurve, priv *PrivateKey,
 	groupPub *PublicKey, hash []byte, privNonce *PrivateKey,
 	pubNonceSum *PublicKey) (r, s *big.Int, err error) {
-	if priv == nil || hash == nil || privNonce == nil || pubNonceSum == nil {
+	if priv == nil || groupPub == nil || hash == nil || privNonce == nil ||
+		pubNonceSum == nil {
 		return nil, nil, fmt.Errorf("nil input")
 	}
+	if err := priv.signingErr(); err != nil {
+		return nil, nil, err
+	}
+	if err := privNonce.signingErr(); err != nil {
+		return nil, nil, err
+	}
 
 	privateScalar := copyBytes(priv.Serialize())
 	reverse(privateScalar) // BE --> LE
@@ -292,6 +402,9 @@ func SignThreshold(curve *TwistedEdwardsCurve, priv *PrivateKey,
 	// That is, R = k1G + ... + knG.
 	encodedGroupR := BigIntPointToEncodedBytes(pubNonceSum.GetX(),
 		pubNonceSum.GetY())
+	if *encodedGroupR == [32]byte{1} {
+		return nil, nil, fmt.Errorf("public nonce sum is the identity")
+	}
 
 	// h = hash512(k || A || M)
 	var hramDigest [64]byte
@@ -323,7 +436,11 @@ func SignThreshold(curve *TwistedEdwardsCurve, priv *PrivateKey,
 }
 
 // Sign is the generalized and exported version of Ed25519 signing, that
-// handles both standard private secrets and non-standard scalars.
+// handles both standard private secrets and non-standard scalars. Keys
+// holding a secret seed are signed with SignDeterministic, while bare
+// scalars (as used in threshold signing) fall back to an RFC6979 nonce. On
+// the Ed448 curve (see InitParamEd448) the message is signed with Ed448 and
+// the key must hold its 57 byte secret seed.
 func Sign(curve *TwistedEdwardsCurve, priv *PrivateKey, hash []byte) (r,
 	s *big.Int, err error) {
 	if priv == nil {
@@ -332,27 +449,218 @@ func Sign(curve *TwistedEdwardsCurve, priv *PrivateKey, hash []byte) (r,
 	if hash == nil {
 		return nil, nil, fmt.Errorf("message key is nil")
 	}
+	if err := priv.signingErr(); err != nil {
+		return nil, nil, err
+	}
+
+	if curve.isEd448() {
+		sig, err := curve.ed448Sign(priv, nil, hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		return sig.GetR(), sig.GetS(), nil
+	}
 
 	if priv.secret == nil {
-		privLE := copyBytes(priv.Serialize())
+		privBytes := priv.Serialize()
+		privLE := copyBytes(privBytes)
+		zeroSlice(privBytes)
 		reverse(privLE)
 		nonce := nonceRFC6979(curve, privLE[:], hash, nil, nil)
+		zeroSlice(privLE[:])
+		defer zeroSlice(nonce)
 		return SignFromScalar(curve, priv, nonce, hash)
 	}
 
-	return SignFromSecretNoReader(priv, hash)
+	sig, err := SignDeterministic(curve, priv, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sig.GetR(), sig.GetS(), nil
+}
+
+// SignDeterministic signs a message 'msg' using the given private key priv
+// following the Ed25519 algorithm of RFC 8032. The secret nonce is never
+// supplied by the caller and is instead derived deterministically as
+// r = hash512(prefix || M) mod N, where prefix is the upper half of
+// hash512(seed). The private key must therefore have been created from its
+// 32 byte secret seed (see PrivKeyFromSecret and PrivKeyFromBytes). The
+// resulting signatures are byte identical to those of the reference Ed25519
+// implementation. On the Ed448 curve msg is signed with Ed448 instead.
+// R = rG
+// S = r + hash512(R || A || M) * a
+func SignDeterministic(curve *TwistedEdwardsCurve, priv *PrivateKey,
+	msg []byte) (*Signature, error) {
+	if priv == nil {
+		return nil, fmt.Errorf("private key is nil")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, err
+	}
+	if curve.isEd448() {
+		return curve.ed448Sign(priv, nil, msg)
+	}
+	if priv.secret == nil {
+		return nil, fmt.Errorf("private key has no secret seed to derive " +
+			"the nonce from")
+	}
+
+	return signRFC8032(curve, priv, nil, msg)
+}
+
+// signRFC8032 implements RFC 8032 signing for the private key priv, which
+// must hold a secret seed, by signing with its expanded key. The domain
+// separation prefix dom is written in front of both the nonce and the
+// challenge hash input. It is empty for pure Ed25519 and
+// dom2(phflag, context) for the Ed25519ph and Ed25519ctx variants.
+func signRFC8032(curve *TwistedEdwardsCurve, priv *PrivateKey, dom []byte,
+	msg []byte) (*Signature, error) {
+	k := seedExpandedKey(curve, priv)
+	defer k.Wipe()
+
+	return k.signRFC8032(dom, msg)
+}
+
+// seedExpandedKey returns the expanded key of the private key priv, which
+// must hold a secret seed. The public key is already known, so there's no
+// need to compute it from the scalar again.
+func seedExpandedKey(curve *TwistedEdwardsCurve,
+	priv *PrivateKey) *ExpandedPrivateKey {
+	k := &ExpandedPrivateKey{curve: curve}
+	expandSeed(k, priv.secret[:])
+	pubX, pubY := priv.Public()
+	copy(k.pub[:], BigIntPointToEncodedBytes(pubX, pubY)[:])
+
+	return k
+}
+
+// validSigR returns whether or not the encoded signature point R is a point
+// of the curve that isn't of small order. An honest R = rB never is, so an R
+// which is off the curve or of small order can only come from a malformed
+// signature and is rejected before any further work. As with Verify, R
+// values with a small order component are left to the equation.
+func validSigR(pub *PublicKey, encodedR *[32]byte) bool {
+	curve, ok := pub.Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil {
+		return false
+	}
+
+	var R edwards25519.ExtendedGroupElement
+	return R.FromBytes(encodedR) && !isSmallOrderPoint(&R)
+}
+
+// verifyHram verifies a signature given the already computed challenge
+// digest hash512(R || A || M) by checking that R = SB - hA. This is the
+// second half of Ed25519 verification and lets callers compute the challenge
+// digest however they need to (e.g. incrementally).
+func verifyHram(pub *PublicKey, sig *[64]byte, hramDigest *[64]byte) bool {
+	// S must be < N.
+	if !scMinimal(sig[32:]) {
+		return false
+	}
+	var encodedR [32]byte
+	copy(encodedR[:], sig[:32])
+	if !validSigR(pub, &encodedR) {
+		return false
+	}
+
+	return checkHram(pub, sig, hramDigest)
+}
+
+// checkHram checks the verification equation R = SB - hA of the signature
+// sig with the challenge digest hash512(R || A || M) with a single double
+// scalar multiplication, assuming S and R have already been checked.
+func checkHram(pub *PublicKey, sig *[64]byte, hramDigest *[64]byte) bool {
+	var A edwards25519.ExtendedGroupElement
+	if !A.FromBytes(BigIntPointToEncodedBytes(pub.GetX(), pub.GetY())) {
+		return false
+	}
+	edwards25519.FeNeg(&A.X, &A.X)
+	edwards25519.FeNeg(&A.T, &A.T)
+
+	var hramDigestReduced [32]byte
+	edwards25519.ScReduce(&hramDigestReduced, hramDigest)
+
+	var sBytes [32]byte
+	copy(sBytes[:], sig[32:])
+	var R edwards25519.ExtendedGroupElement
+	doubleScalarMultVartime(&R, &hramDigestReduced, &A, &sBytes)
+
+	var checkR [32]byte
+	R.ToBytes(&checkR)
+	return bytes.Equal(sig[:32], checkR[:])
 }
 
 // Verify verifies a message 'hash' using the given public keys and signature.
+// Signatures which are not canonical (see IsCanonical) are rejected, as are
+// signatures whose R is one of the eight points of small order or not
+// encoded canonically, so only signatures that Normalize leaves unchanged
+// verify. An R with a small order component is not rejected by itself, the
+// equation is checked without the cofactor as the reference implementation
+// the consensus rules were built on does; VerifyWithMode with
+// VerifyPrimeOrder also rejects such an R. Public keys on the Ed448 curve are checked with Ed448
+// verification. VerifyWithReason tells why a signature was rejected.
 func Verify(pub *PublicKey, hash []byte, r, s *big.Int) bool {
-	if pub == nil || hash == nil || r == nil || s == nil {
-		return false
+	ok, _ := VerifyWithReason(pub, hash, r, s)
+	return ok
+}
+
+// VerifyWithReason verifies the signature (r, s) of the message 'hash' under
+// pub exactly like Verify, and when it is rejected also returns the reason,
+// one of ErrVerifyNilInput, ErrInvalidPubKey, ErrPointNotOnCurve,
+// ErrSmallOrderR, ErrNonCanonicalS and ErrChallengeMismatch. The checks are
+// made in that order, so the first problem found is reported. Ed448
+// signatures that are rejected for any reason but missing input are
+// reported as ErrChallengeMismatch.
+func VerifyWithReason(pub *PublicKey, hash []byte, r, s *big.Int) (bool,
+	error) {
+	if pub == nil || pub.GetX() == nil || pub.GetY() == nil || hash == nil ||
+		r == nil || s == nil {
+		return false, ErrVerifyNilInput
+	}
+	curve, ok := pub.Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil {
+		return false, ErrInvalidPubKey
+	}
+	if curve.isEd448() {
+		if !curve.ed448Verify(pub, nil, hash, r, s) {
+			return false, ErrChallengeMismatch
+		}
+		return true, nil
 	}
 
-	pubBytes := pub.Serialize()
+	var A edwards25519.ExtendedGroupElement
+	if !A.FromBytes(BigIntPointToEncodedBytes(pub.GetX(), pub.GetY())) {
+		return false, ErrInvalidPubKey
+	}
+	if r.Sign() < 0 || r.BitLen() > 256 {
+		return false, ErrPointNotOnCurve
+	}
+	encodedR := BigIntToEncodedBytes(r)
+	var R edwards25519.ExtendedGroupElement
+	if !R.FromBytes(encodedR) {
+		return false, ErrPointNotOnCurve
+	}
+	if isSmallOrderPoint(&R) {
+		return false, ErrSmallOrderR
+	}
 	sig := &Signature{r, s}
-	sigBytes := sig.Serialize()
-	pubArray := copyBytes(pubBytes)
-	sigArray := copyBytes64(sigBytes)
-	return ed25519.Verify(pubArray, hash, sigArray)
+	if !IsCanonical(sig) {
+		return false, ErrNonCanonicalS
+	}
+
+	// h = hash512(R || A || M)
+	var hramDigest [64]byte
+	h := sha512.New()
+	h.Write(encodedR[:])
+	h.Write(pub.Serialize())
+	h.Write(hash)
+	h.Sum(hramDigest[:0])
+
+	if !checkHram(pub, copyBytes64(sig.Serialize()), &hramDigest) {
+		return false, ErrChallengeMismatch
+	}
+
+	return true, nil
 }
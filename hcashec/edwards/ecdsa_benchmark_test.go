@@ -6,6 +6,8 @@
 package edwards
 
 import (
+	crand "crypto/rand"
+	"math/big"
 	"math/rand"
 	"testing"
 )
@@ -96,3 +98,248 @@ func BenchmarkVerification(b *testing.B) {
 		}
 	}
 }
+
+// benchmarkBatchVerification benchmarks verifying a batch of n signatures
+// at once with BatchVerify
+func benchmarkBatchVerification(b *testing.B, n int) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	pubs, msgs, sigs := sigListToSlices(mockUpSigList(curve, n))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if ok, _ := BatchVerify(curve, pubs, msgs, sigs); !ok {
+			b.Fatalf("batch verification failed")
+		}
+	}
+}
+
+// benchmarkSerialVerification benchmarks verifying n signatures one at a
+// time with Verify
+func benchmarkSerialVerification(b *testing.B, n int) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	pubs, msgs, sigs := sigListToSlices(mockUpSigList(curve, n))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range sigs {
+			if !Verify(pubs[j], msgs[j], sigs[j].R, sigs[j].S) {
+				b.Fatalf("verification failed")
+			}
+		}
+	}
+}
+
+// sameKeySigs signs n random messages with a single key, as a committee
+// member would, for the precomputed key benchmarks.
+func sameKeySigs(b *testing.B, curve *TwistedEdwardsCurve,
+	n int) (*PublicKey, [][]byte, []*Signature) {
+	priv := mockUpSecKeysByBytes(curve, 1)[0]
+	r := rand.New(rand.NewSource(54321))
+	msgs := make([][]byte, n)
+	sigs := make([]*Signature, n)
+	for i := range msgs {
+		msgs[i] = make([]byte, 32)
+		r.Read(msgs[i])
+		sig, err := SignDeterministic(curve, priv, msgs[i])
+		if err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+		sigs[i] = sig
+	}
+	return priv.PubKey(), msgs, sigs
+}
+
+// BenchmarkVerifySameKey benchmarks verifying signatures by a single key
+// with Verify, the baseline of BenchmarkVerifyPrecomputed
+func BenchmarkVerifySameKey(b *testing.B) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	pub, msgs, sigs := sameKeySigs(b, curve, 64)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		j := i % len(sigs)
+		if !Verify(pub, msgs[j], sigs[j].R, sigs[j].S) {
+			b.Fatalf("verification failed")
+		}
+	}
+}
+
+// BenchmarkVerifyPrecomputed benchmarks verifying signatures by a single key
+// with VerifyWithPrecomputed, the table being built once up front
+func BenchmarkVerifyPrecomputed(b *testing.B) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	pub, msgs, sigs := sameKeySigs(b, curve, 64)
+	precomputed, err := NewPrecomputedPublicKey(pub)
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		j := i % len(sigs)
+		if !VerifyWithPrecomputed(precomputed, msgs[j], sigs[j].R,
+			sigs[j].S) {
+			b.Fatalf("verification failed")
+		}
+	}
+}
+
+func BenchmarkBatchVerification1(b *testing.B)    { benchmarkBatchVerification(b, 1) }
+func BenchmarkBatchVerification16(b *testing.B)   { benchmarkBatchVerification(b, 16) }
+func BenchmarkBatchVerification128(b *testing.B)  { benchmarkBatchVerification(b, 128) }
+func BenchmarkSerialVerification1(b *testing.B)   { benchmarkSerialVerification(b, 1) }
+func BenchmarkSerialVerification16(b *testing.B)  { benchmarkSerialVerification(b, 16) }
+func BenchmarkSerialVerification128(b *testing.B) { benchmarkSerialVerification(b, 128) }
+
+// BenchmarkBatchVerifyOpenings benchmarks checking the openings of 64
+// Pedersen commitments with BatchVerifyOpenings
+func BenchmarkBatchVerifyOpenings(b *testing.B) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	commitments, openings := mockUpCommitments(b, curve, 64)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !BatchVerifyOpenings(curve, commitments, openings) {
+			b.Fatalf("verification failed")
+		}
+	}
+}
+
+// BenchmarkSerialVerifyOpenings benchmarks checking the openings of 64
+// Pedersen commitments by recomputing each commitment, the baseline of
+// BenchmarkBatchVerifyOpenings
+func BenchmarkSerialVerifyOpenings(b *testing.B) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	commitments, openings := mockUpCommitments(b, curve, 64)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, o := range openings {
+			c, err := Commit(curve, o.Value, o.Blinding)
+			if err != nil || !c.Equal(commitments[j]) {
+				b.Fatalf("verification failed")
+			}
+		}
+	}
+}
+
+// benchmarkBaseMult benchmarks the multiplication of the base point by a
+// random scalar with mult.
+func benchmarkBaseMult(b *testing.B, mult func(k []byte) (x, y *big.Int)) {
+	r := rand.New(rand.NewSource(54321))
+	k := make([]byte, PrivScalarSize)
+	r.Read(k)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		mult(k)
+	}
+}
+
+// BenchmarkBaseMult benchmarks the base point multiplication using the
+// precomputed table.
+func BenchmarkBaseMult(b *testing.B) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	curve.BaseMult(nil) // Build the table outside of the timing.
+
+	benchmarkBaseMult(b, curve.BaseMult)
+}
+
+// BenchmarkBaseMultGeneric benchmarks the base point multiplication using the
+// generic scalar multiplication.
+func BenchmarkBaseMultGeneric(b *testing.B) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	benchmarkBaseMult(b, func(k []byte) (x, y *big.Int) {
+		return curve.ScalarMult(curve.Gx, curve.Gy, k)
+	})
+}
+
+// benchmarkVarBaseMult benchmarks the multiplication of a public key by a
+// random scalar with mult.
+func benchmarkVarBaseMult(b *testing.B, mult func(x, y *big.Int,
+	k []byte) (*big.Int, *big.Int)) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	pub := mockUpSecKeysByScalars(curve, 1)[0].PubKey()
+
+	benchmarkBaseMult(b, func(k []byte) (x, y *big.Int) {
+		return mult(pub.GetX(), pub.GetY(), k)
+	})
+}
+
+// BenchmarkVarBaseMult benchmarks the variable base NAF multiplication.
+func BenchmarkVarBaseMult(b *testing.B) {
+	benchmarkVarBaseMult(b, Edwards().VarBaseMult)
+}
+
+// BenchmarkVarBaseMultGeneric benchmarks the generic scalar multiplication
+// of a point other than the base point.
+func BenchmarkVarBaseMultGeneric(b *testing.B) {
+	benchmarkVarBaseMult(b, Edwards().ScalarMult)
+}
+
+// BenchmarkDoubleScalarMult benchmarks computing sG + hP for random scalars
+// in a single pass.
+func BenchmarkDoubleScalarMult(b *testing.B) {
+	benchmarkVarBaseMult(b, func(x, y *big.Int, k []byte) (*big.Int,
+		*big.Int) {
+		return Edwards().DoubleScalarMult(k, k, x, y)
+	})
+}
+
+// BenchmarkDoubleScalarMultSeparate benchmarks computing sG + hP with two
+// scalar multiplications and an addition.
+func BenchmarkDoubleScalarMultSeparate(b *testing.B) {
+	curve := Edwards()
+	curve.BaseMult(nil) // Build the table outside of the timing.
+	benchmarkVarBaseMult(b, func(x, y *big.Int, k []byte) (*big.Int,
+		*big.Int) {
+		x1, y1 := curve.BaseMult(k)
+		x2, y2 := curve.VarBaseMult(x, y, k)
+		return curve.Add(x1, y1, x2, y2)
+	})
+}
+
+// benchmarkKeyGenCount is the number of keys generated per iteration of the
+// key generation benchmarks, about the size of a wallet's address gap.
+const benchmarkKeyGenCount = 20
+
+// BenchmarkGenerateKeys benchmarks generating a batch of keys with a single
+// read of entropy.
+func BenchmarkGenerateKeys(b *testing.B) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	for n := 0; n < b.N; n++ {
+		if _, err := GenerateKeys(curve, crand.Reader,
+			benchmarkKeyGenCount); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+// BenchmarkGenerateKeyLoop benchmarks generating the same number of keys as
+// BenchmarkGenerateKeys by calling GenerateKey for each.
+func BenchmarkGenerateKeyLoop(b *testing.B) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	for n := 0; n < b.N; n++ {
+		for i := 0; i < benchmarkKeyGenCount; i++ {
+			priv, _, _, err := GenerateKey(curve, crand.Reader)
+			if err != nil {
+				b.Fatalf("unexpected error: %v", err)
+			}
+			PrivKeyFromBytes(curve, priv)
+		}
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"io"
+	"math/big"
 	"math/rand"
 	"os"
 	"strings"
@@ -287,3 +290,728 @@ func TestStdSigning(t *testing.T) {
 		}
 	}
 }
+
+// TestSignDeterministic tests that deterministic signing reproduces the
+//	reference signatures from RFC 8032 section 7.1
+func TestSignDeterministic(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	tests := []struct {
+		secret string
+		msg    string
+		sig    string
+	}{
+		{
+			"9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+			"",
+			"e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e06522490155" +
+				"5fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b",
+		},
+		{
+			"4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+			"72",
+			"92a009a9f0d4cab8720e820b5f642540a2b27b5416503f8fb3762223ebdb69da" +
+				"085ac1e43e15996e458f3613d0f11d8c387b2eaeb4302aeeb00d291612bb0c00",
+		},
+		{
+			"c5aa8df43f9f837bedb7442f31dcb7b166d38535076f094b85ce3a2e0b4458f7",
+			"af82",
+			"6291d657deec24024827e69c3abe01a30ce548a284743a445e3680d7db5ac3ac" +
+				"18ff9b538d16f290ae67f760984dc6594a7c15e9716ed28dc027beceea1ec40a",
+		},
+	}
+
+	for i, test := range tests {
+		secret, _ := hex.DecodeString(test.secret)
+		msg, _ := hex.DecodeString(test.msg)
+		want, _ := hex.DecodeString(test.sig)
+
+		priv, pub := PrivKeyFromSecret(curve, secret)
+		sig, err := SignDeterministic(curve, priv, msg)
+		if err != nil {
+			t.Fatalf("test %d: unexpected signing error: %v", i, err)
+		}
+		if !bytes.Equal(sig.Serialize(), want) {
+			t.Fatalf("test %d: want %x, got %x", i, want, sig.Serialize())
+		}
+		if !Verify(pub, msg, sig.GetR(), sig.GetS()) {
+			t.Fatalf("test %d: signature failed to verify", i)
+		}
+	}
+
+	// Keys without a secret seed have no nonce prefix and must be
+	// rejected.
+	sks := mockUpSecKeysByScalars(curve, 1)
+	if _, err := SignDeterministic(curve, sks[0], []byte("msg")); err == nil {
+		t.Fatalf("expected error signing with a bare scalar")
+	}
+}
+
+// TestNonCanonicalS tests that signatures with an S value which isn't fully
+// reduced modulo N are rejected, using the RFC 8032 test vectors with N added
+// to S
+func TestNonCanonicalS(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	tests := []struct {
+		pub string
+		msg string
+		sig string
+	}{
+		{
+			"d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+			"",
+			"e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e06522490155" +
+				"5fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b",
+		},
+		{
+			"3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c",
+			"72",
+			"92a009a9f0d4cab8720e820b5f642540a2b27b5416503f8fb3762223ebdb69da" +
+				"085ac1e43e15996e458f3613d0f11d8c387b2eaeb4302aeeb00d291612bb0c00",
+		},
+	}
+
+	for i, test := range tests {
+		pubBytes, _ := hex.DecodeString(test.pub)
+		msg, _ := hex.DecodeString(test.msg)
+		sigBytes, _ := hex.DecodeString(test.sig)
+
+		pub, err := ParsePubKey(curve, pubBytes)
+		if err != nil {
+			t.Fatalf("test %d: unexpected pubkey error: %v", i, err)
+		}
+		sig, err := ParseSignature(curve, sigBytes)
+		if err != nil {
+			t.Fatalf("test %d: unexpected signature error: %v", i, err)
+		}
+		if !IsCanonical(sig) || !Verify(pub, msg, sig.R, sig.S) {
+			t.Fatalf("test %d: canonical signature rejected", i)
+		}
+
+		malleated := NewSignature(sig.R, new(big.Int).Add(sig.S, curve.N))
+		malleatedBytes := malleated.Serialize()
+		if malleatedBytes[63]&224 != 0 {
+			t.Fatalf("test %d: S+N should fit in 253 bits", i)
+		}
+
+		if IsCanonical(malleated) {
+			t.Fatalf("test %d: S+N reported as canonical", i)
+		}
+		if Verify(pub, msg, malleated.R, malleated.S) {
+			t.Fatalf("test %d: S+N signature verified", i)
+		}
+		sv := VerifyStream(curve, pub, malleated.R, malleated.S)
+		sv.Write(msg)
+		if sv.Verify() {
+			t.Fatalf("test %d: S+N signature verified as a stream", i)
+		}
+		if _, err := ParseSignature(curve, malleatedBytes); err == nil {
+			t.Fatalf("test %d: S+N signature parsed", i)
+		}
+	}
+}
+
+// TestSignatureNormalize tests that S+N and non-canonical encodings of R
+// normalize to the canonical bytes, which verify where the originals don't
+func TestSignatureNormalize(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	sk := mockUpSecKeysByBytes(curve, 1)[0]
+	msg := []byte("Hello World in TestNormalize!!!!")
+	r, s, err := Sign(curve, sk, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sig := NewSignature(r, s)
+
+	normalized, err := sig.Normalize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(normalized.Serialize(), sig.Serialize()) {
+		t.Fatalf("normalizing a canonical signature changed it")
+	}
+
+	malleated := NewSignature(r, new(big.Int).Add(s, curve.N))
+	if Verify(sk.PubKey(), msg, malleated.R, malleated.S) {
+		t.Fatalf("S+N signature verified")
+	}
+	normalized, err = malleated.Normalize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(normalized.Serialize(), sig.Serialize()) {
+		t.Fatalf("S+N normalized to %x, want %x", normalized.Serialize(),
+			sig.Serialize())
+	}
+	if !Verify(sk.PubKey(), msg, normalized.R, normalized.S) {
+		t.Fatalf("normalized signature failed to verify")
+	}
+
+	// The identity encoded with y = P+1 and with the sign bit of x = 0
+	// set normalize to the canonical encoding of y = 1.
+	canonicalR, _ := hex.DecodeString("0100000000000000000000000000000000" +
+		"000000000000000000000000000000")
+	for _, str := range []string{
+		"eeffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+		"0100000000000000000000000000000000000000000000000000000000000080",
+	} {
+		b, _ := hex.DecodeString(str)
+		nonCanonical := NewSignature(EncodedBytesToBigInt(copyBytes(b)), s)
+		normalized, err := nonCanonical.Normalize()
+		if err != nil {
+			t.Fatalf("R %v: unexpected error: %v", str, err)
+		}
+		if !bytes.Equal(normalized.Serialize()[:32], canonicalR) {
+			t.Fatalf("R %v normalized to %x", str, normalized.Serialize()[:32])
+		}
+	}
+
+	// y = 2 is not on the curve.
+	if _, err := NewSignature(big.NewInt(2), s).Normalize(); err == nil {
+		t.Fatalf("normalized R off the curve")
+	}
+	if _, err := NewSignature(r, curve.N).Normalize(); err == nil {
+		t.Fatalf("normalized S = N")
+	}
+	if _, err := (Signature{}).Normalize(); err == nil {
+		t.Fatalf("normalized an incomplete signature")
+	}
+}
+
+// TestRecoverPublicKey tests that public key recovery is reported as
+// unsupported rather than returning a bogus key
+func TestRecoverPublicKey(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	priv := mockUpSecKeysByBytes(curve, 1)[0]
+	msg := []byte("Hello World in TestRecoverPublicKey")
+	sig, err := SignDeterministic(curve, priv, msg)
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+
+	pub, err := RecoverPublicKey(curve, sig, msg)
+	if err != ErrKeyRecoveryUnsupported {
+		t.Fatalf("want ErrKeyRecoveryUnsupported, got %v", err)
+	}
+	if pub != nil {
+		t.Fatalf("want no public key, got %v", pub)
+	}
+}
+
+// TestSignatureJSON tests the JSON encoding of signatures
+func TestSignatureJSON(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	priv := mockUpSecKeysByBytes(curve, 1)[0]
+	msg := []byte("Hello World in TestSignatureJSON")
+	sig, err := SignDeterministic(curve, priv, msg)
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+
+	b, err := json.Marshal(sig)
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+	want := `"` + hex.EncodeToString(sig.Serialize()) + `"`
+	if string(b) != want {
+		t.Fatalf("marshaled to %s, want %s", b, want)
+	}
+
+	var decoded Signature
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unexpected unmarshal error: %v", err)
+	}
+	if decoded.R.Cmp(sig.R) != 0 || decoded.S.Cmp(sig.S) != 0 {
+		t.Fatalf("decoded signature %x, want %x", decoded.Serialize(),
+			sig.Serialize())
+	}
+
+	// Signatures embedded in other types must round trip as well.
+	type wrapper struct {
+		Sig *Signature `json:"sig"`
+	}
+	b, err = json.Marshal(wrapper{sig})
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+	var w wrapper
+	if err := json.Unmarshal(b, &w); err != nil {
+		t.Fatalf("unexpected unmarshal error: %v", err)
+	}
+	if w.Sig == nil || !bytes.Equal(w.Sig.Serialize(), sig.Serialize()) {
+		t.Fatalf("wrapped signature did not round trip: %s", b)
+	}
+
+	sHex := hex.EncodeToString(sig.Serialize()[32:])
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"not a string", `{}`, "not a JSON string"},
+		{"bad hex", `"` + strings.Repeat("g", 128) + `"`, "not valid hex"},
+		{"short", `"` + want[1:len(want)-3] + `"`, "bad signature size"},
+		{"bad R", `"02` + strings.Repeat("00", 31) + sHex + `"`,
+			"not on curve"},
+		{"zero S", `"` + want[1:65] + strings.Repeat("00", 32) + `"`,
+			"s scalar"},
+	}
+	for _, test := range tests {
+		var decoded Signature
+		err := json.Unmarshal([]byte(test.json), &decoded)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Fatalf("%s: got error %v, want one containing %q", test.name,
+				err, test.want)
+		}
+	}
+}
+
+// TestVerifyBadR tests that signatures whose R is off the curve or not in the
+// prime order subgroup are rejected
+func TestVerifyBadR(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	priv := mockUpSecKeysByBytes(curve, 1)[0]
+	pub := priv.PubKey()
+	msg := []byte("Hello World in TestVerifyBadR")
+	sig, err := SignDeterministic(curve, priv, msg)
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+	if !Verify(pub, msg, sig.R, sig.S) {
+		t.Fatalf("valid signature failed to verify")
+	}
+	rX, rY, err := curve.EncodedBytesToBigIntPoint(BigIntToEncodedBytes(sig.R))
+	if err != nil {
+		t.Fatalf("unexpected error decoding R: %v", err)
+	}
+
+	// y = 2 is not the y coordinate of any point.
+	offCurve := make([]byte, 32)
+	offCurve[0] = 0x02
+	badRs := [][]byte{offCurve}
+	for _, str := range smallOrderPoints {
+		lowOrder, _ := hex.DecodeString(str)
+		badRs = append(badRs, lowOrder)
+
+		// R plus a small order component.
+		tX, tY, err := curve.EncodedBytesToBigIntPoint(copyBytes(lowOrder))
+		if err != nil {
+			t.Fatalf("vector %v is not a point: %v", str, err)
+		}
+		if tX.Sign() == 0 && tY.Cmp(one) == 0 {
+			continue
+		}
+		mX, mY := curve.Add(rX, rY, tX, tY)
+		badRs = append(badRs, BigIntPointToEncodedBytes(mX, mY)[:])
+	}
+
+	for _, badR := range badRs {
+		r := EncodedBytesToBigInt(copyBytes(badR))
+		if Verify(pub, msg, r, sig.S) {
+			t.Fatalf("signature with R = %x verified", badR)
+		}
+		sv := VerifyStream(curve, pub, r, sig.S)
+		sv.Write(msg)
+		if sv.Verify() {
+			t.Fatalf("signature with R = %x verified as a stream", badR)
+		}
+	}
+}
+
+// TestVerifyWithReason tests that each class of bad signature input is
+// rejected with its own error, and that Verify agrees with VerifyWithReason
+func TestVerifyWithReason(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	ed448 := new(TwistedEdwardsCurve)
+	ed448.InitParamEd448()
+
+	priv := mockUpSecKeysByBytes(curve, 2)[0]
+	pub := priv.PubKey()
+	msg := []byte("Hello World in VerifyWithReason")
+	sig, err := SignDeterministic(curve, priv, msg)
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+	otherPub := mockUpSecKeysByBytes(curve, 2)[1].PubKey()
+	ed448Priv, err := GenerateKeys(ed448, NewDeterministicReader(msg), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ed448Sig, err := SignDeterministic(ed448, ed448Priv[0], msg)
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+
+	offCurve := make([]byte, 32)
+	offCurve[0] = 0x02
+	lowOrder, _ := hex.DecodeString(smallOrderPoints[len(smallOrderPoints)-1])
+	tests := []struct {
+		name string
+		pub  *PublicKey
+		msg  []byte
+		r, s *big.Int
+		want error
+	}{
+		{"valid", pub, msg, sig.R, sig.S, nil},
+		{"valid Ed448", ed448Priv[0].PubKey(), msg, ed448Sig.R, ed448Sig.S,
+			nil},
+		{"nil key", nil, msg, sig.R, sig.S, ErrVerifyNilInput},
+		{"incomplete key", &PublicKey{Curve: curve}, msg, sig.R, sig.S,
+			ErrVerifyNilInput},
+		{"nil message", pub, nil, sig.R, sig.S, ErrVerifyNilInput},
+		{"nil R", pub, msg, nil, sig.S, ErrVerifyNilInput},
+		{"nil S", pub, msg, sig.R, nil, ErrVerifyNilInput},
+		{"key without a curve", &PublicKey{X: pub.X, Y: pub.Y}, msg, sig.R,
+			sig.S, ErrInvalidPubKey},
+		{"key off the curve", NewPublicKey(curve, big.NewInt(1),
+			big.NewInt(2)), msg, sig.R, sig.S, ErrInvalidPubKey},
+		{"R off the curve", pub, msg,
+			EncodedBytesToBigInt(copyBytes(offCurve)), sig.S,
+			ErrPointNotOnCurve},
+		{"R too long", pub, msg, new(big.Int).Lsh(sig.R, 8), sig.S,
+			ErrPointNotOnCurve},
+		{"R of small order", pub, msg,
+			EncodedBytesToBigInt(copyBytes(lowOrder)), sig.S, ErrSmallOrderR},
+		{"S+N", pub, msg, sig.R, new(big.Int).Add(sig.S, curve.N),
+			ErrNonCanonicalS},
+		{"wrong message", pub, append([]byte{0}, msg...), sig.R, sig.S,
+			ErrChallengeMismatch},
+		{"wrong key", otherPub, msg, sig.R, sig.S, ErrChallengeMismatch},
+		{"wrong S", pub, msg, sig.R, new(big.Int).Add(sig.S, one),
+			ErrChallengeMismatch},
+		{"wrong Ed448 message", ed448Priv[0].PubKey(), msg[1:], ed448Sig.R,
+			ed448Sig.S, ErrChallengeMismatch},
+	}
+	for _, test := range tests {
+		ok, err := VerifyWithReason(test.pub, test.msg, test.r, test.s)
+		if err != test.want {
+			t.Fatalf("%v: want error %v, got %v", test.name, test.want, err)
+		}
+		if ok != (test.want == nil) {
+			t.Fatalf("%v: verified %v with error %v", test.name, ok, err)
+		}
+		if Verify(test.pub, test.msg, test.r, test.s) != ok {
+			t.Fatalf("%v: Verify disagrees with VerifyWithReason",
+				test.name)
+		}
+	}
+}
+
+// TestSignFromScalarBadNonce tests that zero and out of range nonces are
+// rejected with ErrInvalidNonce rather than producing a broken signature
+func TestSignFromScalarBadNonce(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	sk := mockUpSecKeysByScalars(curve, 1)[0]
+	msg := []byte("Hello World in TestBadNonce!!!!!")
+	badNonces := map[string][]byte{
+		"zero": make([]byte, PrivScalarSize),
+		"N":    BigIntToEncodedBytesNoReverse(curve.N)[:],
+	}
+	for name, nonce := range badNonces {
+		r, s, err := SignFromScalar(curve, sk, nonce, msg)
+		if !errors.Is(err, ErrInvalidNonce) {
+			t.Fatalf("%v: want ErrInvalidNonce, got %v", name, err)
+		}
+		if r != nil || s != nil {
+			t.Fatalf("%v: got a signature along with the error", name)
+		}
+
+		// The threshold flow validates the nonce the same way.
+		keyVec := mockUpSchnorrKeyVec(curve, 2, msg)
+		_, _, err = schnorrPartialSign(curve, msg, sk.Serialize(),
+			keyVec.pkVecSum.Serialize(), nonce,
+			keyVec.pubNonceVecSum.Serialize())
+		if !errors.Is(err, ErrInvalidNonce) {
+			t.Fatalf("%v: want ErrInvalidNonce from partial signing, got "+
+				"%v", name, err)
+		}
+	}
+
+	// Invalid private scalars are not nonces.
+	_, _, err := PrivKeyFromScalar(curve, make([]byte, PrivScalarSize))
+	if errors.Is(err, ErrInvalidNonce) {
+		t.Fatalf("invalid private scalar reported as an invalid nonce")
+	}
+
+	// A public nonce sum at the identity, as when the nonces cancel out,
+	// is rejected.
+	keyVec := mockUpSchnorrKeyVec(curve, 2, msg)
+	identity := NewPublicKey(curve, new(big.Int), new(big.Int).Set(one))
+	_, _, err = SignThreshold(curve, keyVec.skVec[0], keyVec.pkVecSum, msg,
+		keyVec.secNonceVec[0], identity)
+	if err == nil {
+		t.Fatalf("expected error signing with an identity nonce sum")
+	}
+}
+
+// TestSignZeroizes tests, as far as it can be observed, that the copies of
+// the private scalar and the nonce made for signing are zeroed once the
+// signature is made, without changing the signature or the caller's nonce
+func TestSignZeroizes(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	sk := mockUpSecKeysByScalars(curve, 1)[0]
+	msg := []byte("Hello World in TestSignZeroizes!")
+	privLE := copyBytes(sk.Serialize())
+	reverse(privLE)
+	nonce := nonceRFC6979(curve, privLE[:], msg, nil, nil)
+	nonceCopy := append([]byte(nil), nonce...)
+	wantR, wantS, err := SignFromScalar(curve, sk, nonce, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(nonce, nonceCopy) {
+		t.Fatalf("SignFromScalar changed the caller's nonce")
+	}
+
+	// Sentinel buffers standing in for the copies SignFromScalar makes.
+	privateScalar := copyBytes(sk.Serialize())
+	reverse(privateScalar)
+	nonceLE := copyBytes(nonce)
+	reverse(nonceLE)
+	r, s, err := signScalarLE(curve, privateScalar, nonceLE, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Cmp(wantR) != 0 || s.Cmp(wantS) != 0 {
+		t.Fatalf("signScalarLE made a different signature")
+	}
+	var zeroBuf [32]byte
+	if *privateScalar != zeroBuf {
+		t.Fatalf("private scalar wasn't zeroed: %x", privateScalar[:])
+	}
+	if *nonceLE != zeroBuf {
+		t.Fatalf("nonce wasn't zeroed: %x", nonceLE[:])
+	}
+
+	// Failing to sign zeroes them as well.
+	privateScalar = copyBytes(sk.Serialize())
+	reverse(privateScalar)
+	nonceLE = new([32]byte)
+	if _, _, err := signScalarLE(curve, privateScalar, nonceLE,
+		msg); err == nil {
+		t.Fatalf("signed with a zero nonce")
+	}
+	if *privateScalar != zeroBuf {
+		t.Fatalf("private scalar wasn't zeroed on error")
+	}
+
+	if !Verify(sk.PubKey(), msg, wantR, wantS) {
+		t.Fatalf("signature failed to verify")
+	}
+	if r, s, err := Sign(curve, sk, msg); err != nil || r.Cmp(wantR) != 0 ||
+		s.Cmp(wantS) != 0 {
+		t.Fatalf("Sign made a different signature: %v", err)
+	}
+}
+
+// TestSignatureWireFormat tests that serialized signatures are always
+// SignatureSize bytes and that parsing rejects other sizes and S >= N
+func TestSignatureWireFormat(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := []byte("message in TestSignatureWireFormat")
+
+	for i, sk := range mockUpSecKeysByBytes(curve, 10) {
+		r, s, err := Sign(curve, sk, msg)
+		if err != nil {
+			t.Fatalf("test %d: unexpected signing error: %v", i, err)
+		}
+		b := NewSignature(r, s).Serialize()
+		if len(b) != SignatureSize {
+			t.Fatalf("test %d: serialized %v bytes, want %v", i, len(b),
+				SignatureSize)
+		}
+		sig, err := ParseSignature(curve, b)
+		if err != nil {
+			t.Fatalf("test %d: unexpected parsing error: %v", i, err)
+		}
+		if sig.R.Cmp(r) != 0 || sig.S.Cmp(s) != 0 {
+			t.Fatalf("test %d: round trip mismatch", i)
+		}
+
+		if _, err := ParseSignature(curve, b[:SignatureSize-1]); err == nil {
+			t.Fatalf("test %d: parsed a 63 byte signature", i)
+		}
+		if _, err := ParseSignature(curve, append(b, 0x00)); err == nil {
+			t.Fatalf("test %d: parsed a 65 byte signature", i)
+		}
+
+		// S = N and the largest 32 byte S.
+		for _, bigS := range []*big.Int{curve.N,
+			new(big.Int).Sub(new(big.Int).Lsh(one, 256), one)} {
+			oversized := NewSignature(r, bigS).Serialize()
+			if _, err := ParseSignature(curve, oversized); err == nil {
+				t.Fatalf("test %d: parsed a signature with S = %x", i, bigS)
+			}
+		}
+	}
+
+	// Small values and the zero value still take the full size.
+	for _, sig := range []*Signature{NewSignature(one, one), {}} {
+		if b := sig.Serialize(); len(b) != SignatureSize {
+			t.Fatalf("serialized %v bytes, want %v", len(b), SignatureSize)
+		}
+	}
+	if _, err := ParseSignature(curve, nil); err == nil {
+		t.Fatalf("parsed an empty signature")
+	}
+}
+
+// TestSignatureExpanded tests that the expanded and compressed forms of the
+// same signature parse to the same signature and verify identically, and
+// that expanded forms with R off the curve or unreduced are rejected
+func TestSignatureExpanded(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := []byte("message in TestSignatureExpanded")
+
+	for i, sk := range mockUpSecKeysByBytes(curve, 5) {
+		r, s, err := Sign(curve, sk, msg)
+		if err != nil {
+			t.Fatalf("test %d: unexpected signing error: %v", i, err)
+		}
+		compressed := NewSignature(r, s).Serialize()
+		expanded, err := NewSignature(r, s).SerializeExpanded()
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if len(expanded) != ExpandedSignatureSize {
+			t.Fatalf("test %d: serialized %v bytes, want %v", i,
+				len(expanded), ExpandedSignatureSize)
+		}
+		rX, rY, err := curve.EncodedBytesToBigIntPoint(copyBytes(compressed[:32]))
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(expanded[:32], BigIntToEncodedBytes(rX)[:]) ||
+			!bytes.Equal(expanded[32:64], BigIntToEncodedBytes(rY)[:]) ||
+			!bytes.Equal(expanded[64:], compressed[32:]) {
+			t.Fatalf("test %d: expanded form isn't Rx || Ry || S", i)
+		}
+
+		fromCompressed, err := ParseSignature(curve, compressed)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		fromExpanded, err := ParseExpandedSignature(curve, expanded)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(fromExpanded.Serialize(), compressed) {
+			t.Fatalf("test %d: expanded signature compressed differently", i)
+		}
+		for _, m := range [][]byte{msg, msg[1:]} {
+			if Verify(sk.PubKey(), m, fromExpanded.R, fromExpanded.S) !=
+				Verify(sk.PubKey(), m, fromCompressed.R, fromCompressed.S) {
+				t.Fatalf("test %d: forms verify differently for %q", i, m)
+			}
+		}
+		if !Verify(sk.PubKey(), msg, fromExpanded.R, fromExpanded.S) {
+			t.Fatalf("test %d: expanded signature failed to verify", i)
+		}
+
+		// Negating x gives another point, the signature of which doesn't
+		// verify.
+		negated := append([]byte(nil), expanded...)
+		copy(negated, BigIntToEncodedBytes(new(big.Int).Sub(curve.P, rX))[:])
+		sig, err := ParseExpandedSignature(curve, negated)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if Verify(sk.PubKey(), msg, sig.R, sig.S) {
+			t.Fatalf("test %d: signature with -R verified", i)
+		}
+
+		bad := map[string][]byte{
+			"short":   expanded[:ExpandedSignatureSize-1],
+			"compact": compressed,
+			"off curve": append(append(append([]byte(nil), expanded[:32]...),
+				BigIntToEncodedBytes(new(big.Int).Add(rY, one))[:]...),
+				expanded[64:]...),
+			"unreduced": append(append(append([]byte(nil), expanded[:32]...),
+				BigIntToEncodedBytes(new(big.Int).Add(rY, curve.P))[:]...),
+				expanded[64:]...),
+			"S = N": append(append([]byte(nil), expanded[:64]...),
+				BigIntToEncodedBytes(curve.N)[:]...),
+		}
+		for name, b := range bad {
+			if _, err := ParseExpandedSignature(curve, b); err == nil {
+				t.Fatalf("test %d: parsed %v expanded signature", i, name)
+			}
+		}
+	}
+
+	if _, err := (Signature{}).SerializeExpanded(); err == nil {
+		t.Fatalf("serialized an incomplete signature")
+	}
+	if _, err := NewSignature(big.NewInt(2), one).SerializeExpanded(); err ==
+		nil {
+		t.Fatalf("serialized a signature with R off the curve")
+	}
+}
+
+// TestGenerateKeys tests that batch generated keys are derived from
+// consecutive chunks of the entropy, and that a short read fails the batch
+func TestGenerateKeys(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := []byte("Hello World in TestGenerateKeys")
+
+	const n = 20
+	entropy := make([]byte, n*PrivKeyBytesLen/2)
+	rand.New(rand.NewSource(54321)).Read(entropy)
+	keys, err := GenerateKeys(curve, bytes.NewReader(entropy), n)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != n {
+		t.Fatalf("got %v keys, want %v", len(keys), n)
+	}
+	for i, priv := range keys {
+		secret := entropy[i*PrivKeyBytesLen/2 : (i+1)*PrivKeyBytesLen/2]
+		want, _ := PrivKeyFromSecret(curve, secret)
+		if !priv.Equal(want) || !priv.PubKey().Equal(want.PubKey()) {
+			t.Fatalf("key %d doesn't match its secret", i)
+		}
+		if i > 0 && priv.Equal(keys[i-1]) {
+			t.Fatalf("key %d repeats the previous key", i)
+		}
+
+		r, s, err := Sign(curve, priv, msg)
+		if err != nil {
+			t.Fatalf("key %d: unexpected signing error: %v", i, err)
+		}
+		if !Verify(priv.PubKey(), msg, r, s) {
+			t.Fatalf("key %d: signature failed to verify", i)
+		}
+	}
+
+	// One byte short of the last key.
+	keys, err = GenerateKeys(curve, bytes.NewReader(entropy[:len(entropy)-1]),
+		n)
+	if err == nil || keys != nil {
+		t.Fatalf("expected an error and no keys from a short read")
+	}
+	if !strings.Contains(err.Error(), "got 639 of 640 bytes") {
+		t.Fatalf("short read error doesn't report the read size: %v", err)
+	}
+	if _, err := GenerateKeys(curve, bytes.NewReader(entropy), 0); err == nil {
+		t.Fatalf("expected an error generating no keys")
+	}
+}
@@ -0,0 +1,455 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// These constants define the sizes of the Ed448 keys and signatures of RFC
+// 8032.
+const (
+	// Ed448SeedSize is the size of an Ed448 secret seed.
+	Ed448SeedSize = 57
+
+	// Ed448PubKeyBytesLen is the size of an encoded Ed448 public key.
+	Ed448PubKeyBytesLen = 57
+
+	// Ed448SignatureSize is the size of an encoded Ed448 signature, R || S.
+	Ed448SignatureSize = 2 * Ed448PubKeyBytesLen
+
+	// ed448FieldBits is the bit length of the Ed448 field prime, which is
+	// used to tell the Ed448 curve apart from Ed25519.
+	ed448FieldBits = 448
+)
+
+// dom4Prefix is the constant prefix of the RFC 8032 dom4 domain separation
+// string, which Ed448 always uses.
+var dom4Prefix = []byte("SigEd448")
+
+// ed448Point is a point of the Ed448 curve in projective coordinates,
+// x = X/Z and y = Y/Z.
+type ed448Point struct {
+	X, Y, Z *big.Int
+}
+
+// isEd448 returns whether or not the curve was initialized with
+// InitParamEd448. The curve is told apart by the size of its field, so the
+// routines shared by both curves can pick the arithmetic to use.
+func (curve *TwistedEdwardsCurve) isEd448() bool {
+	return curve.P.BitLen() == ed448FieldBits
+}
+
+// ed448Identity returns the neutral element (0, 1).
+func ed448Identity() *ed448Point {
+	return &ed448Point{new(big.Int), big.NewInt(1), big.NewInt(1)}
+}
+
+// ed448FromAffine converts the affine point (x, y) to projective
+// coordinates.
+func ed448FromAffine(x, y *big.Int) *ed448Point {
+	return &ed448Point{new(big.Int).Set(x), new(big.Int).Set(y),
+		big.NewInt(1)}
+}
+
+// ed448Add returns p + q using the formulas of RFC 8032 section 5.2.4,
+// which are complete on Ed448 and so also double a point.
+func (curve *TwistedEdwardsCurve) ed448Add(p, q *ed448Point) *ed448Point {
+	mul := func(a, b *big.Int) *big.Int {
+		r := new(big.Int).Mul(a, b)
+		return r.Mod(r, curve.P)
+	}
+
+	a := mul(p.Z, q.Z)
+	b := mul(a, a)
+	c := mul(p.X, q.X)
+	d := mul(p.Y, q.Y)
+	e := mul(mul(curve.D, c), d)
+	f := new(big.Int).Sub(b, e)
+	g := new(big.Int).Add(b, e)
+	h := mul(new(big.Int).Add(p.X, p.Y), new(big.Int).Add(q.X, q.Y))
+
+	// X3 = A*F*(H-C-D), Y3 = A*G*(D-C), Z3 = F*G
+	hcd := new(big.Int).Sub(h, c)
+	hcd.Sub(hcd, d)
+	dc := new(big.Int).Sub(d, c)
+
+	return &ed448Point{
+		X: mul(mul(a, f), hcd),
+		Y: mul(mul(a, g), dc),
+		Z: mul(f, g),
+	}
+}
+
+// ed448ScalarMult returns k*p for the non-negative integer k, using the
+// repeated doubling method, which is variable time. It must only be used
+// with public scalars, secret ones go through ed448BaseMultSecret.
+func (curve *TwistedEdwardsCurve) ed448ScalarMult(p *ed448Point,
+	k *big.Int) *ed448Point {
+	q := ed448Identity()
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		q = curve.ed448Add(q, q)
+		if k.Bit(i) == 1 {
+			q = curve.ed448Add(q, p)
+		}
+	}
+
+	return q
+}
+
+// ed448Equal returns whether or not p and q are the same point.
+func (curve *TwistedEdwardsCurve) ed448Equal(p, q *ed448Point) bool {
+	l := new(big.Int).Mul(p.X, q.Z)
+	r := new(big.Int).Mul(q.X, p.Z)
+	if l.Sub(l, r).Mod(l, curve.P).Sign() != 0 {
+		return false
+	}
+	l.Mul(p.Y, q.Z)
+	r.Mul(q.Y, p.Z)
+	return l.Sub(l, r).Mod(l, curve.P).Sign() == 0
+}
+
+// ed448Affine converts p to affine coordinates.
+func (curve *TwistedEdwardsCurve) ed448Affine(p *ed448Point) (x,
+	y *big.Int) {
+	zi := new(big.Int).ModInverse(p.Z, curve.P)
+	x = new(big.Int).Mul(p.X, zi)
+	x.Mod(x, curve.P)
+	y = new(big.Int).Mul(p.Y, zi)
+	y.Mod(y, curve.P)
+	return
+}
+
+// ed448BaseMultSecret returns the affine point k*G for the secret scalar k,
+// such as a private scalar or a nonce, with the constant time
+// ed448ScalarMultConstantTime over the 57 byte encoding of k.
+func (curve *TwistedEdwardsCurve) ed448BaseMultSecret(k *big.Int) (x,
+	y *big.Int) {
+	kLE := bigIntToLEBytes(k, Ed448SeedSize)
+	defer zeroSlice(kLE)
+
+	return curve.ed448ScalarMultConstantTime(curve.Gx, curve.Gy, kLE)
+}
+
+// ed448IsOnCurve returns whether or not the affine point (x, y) is on the
+// Ed448 curve x^2 + y^2 = 1 + dx^2y^2.
+func (curve *TwistedEdwardsCurve) ed448IsOnCurve(x, y *big.Int) bool {
+	if x.Sign() < 0 || x.Cmp(curve.P) >= 0 || y.Sign() < 0 ||
+		y.Cmp(curve.P) >= 0 {
+		return false
+	}
+
+	x2 := new(big.Int).Mul(x, x)
+	y2 := new(big.Int).Mul(y, y)
+	l := new(big.Int).Add(x2, y2)
+	r := new(big.Int).Mul(x2, y2)
+	r.Mul(r, curve.D)
+	r.Add(r, one)
+
+	return l.Sub(l, r).Mod(l, curve.P).Sign() == 0
+}
+
+// ed448IsPrimeOrderPoint returns whether or not p generates the prime order
+// subgroup, that is [N]p is the identity while [4]p is not.
+func (curve *TwistedEdwardsCurve) ed448IsPrimeOrderPoint(p *ed448Point) bool {
+	p4 := curve.ed448Add(p, p)
+	p4 = curve.ed448Add(p4, p4)
+	if curve.ed448Equal(p4, ed448Identity()) {
+		return false
+	}
+
+	return curve.ed448Equal(curve.ed448ScalarMult(p, curve.N),
+		ed448Identity())
+}
+
+// leBytesToBigInt reads the little endian integer b.
+func leBytesToBigInt(b []byte) *big.Int {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+// bigIntToLEBytes encodes the non-negative integer v as a size byte little
+// endian integer.
+func bigIntToLEBytes(v *big.Int, size int) []byte {
+	be := v.Bytes()
+	le := make([]byte, size)
+	for i := range be {
+		le[i] = be[len(be)-1-i]
+	}
+	return le
+}
+
+// ed448Encode encodes the affine point (x, y) as 57 bytes, the little
+// endian y with the low bit of x in the highest bit of the last byte.
+func ed448Encode(x, y *big.Int) []byte {
+	b := bigIntToLEBytes(y, Ed448PubKeyBytesLen)
+	b[Ed448PubKeyBytesLen-1] |= byte(x.Bit(0)) << 7
+	return b
+}
+
+// ed448Decode decodes a 57 byte encoded point, following RFC 8032 section
+// 5.2.3.
+func (curve *TwistedEdwardsCurve) ed448Decode(b []byte) (x, y *big.Int,
+	err error) {
+	if len(b) != Ed448PubKeyBytesLen {
+		return nil, nil, fmt.Errorf("bad point size; have %v, want %v",
+			len(b), Ed448PubKeyBytesLen)
+	}
+	if b[Ed448PubKeyBytesLen-1]&0x7f != 0 {
+		return nil, nil, fmt.Errorf("invalid point encoding")
+	}
+	xIsOdd := b[Ed448PubKeyBytesLen-1]>>7 == 1
+
+	y = leBytesToBigInt(b[:Ed448PubKeyBytesLen-1])
+	if y.Cmp(curve.P) >= 0 {
+		return nil, nil, fmt.Errorf("point y is >= to P")
+	}
+
+	// x^2 = (y^2 - 1) / (dy^2 - 1)
+	y2 := new(big.Int).Mul(y, y)
+	u := new(big.Int).Sub(y2, one)
+	v := new(big.Int).Mul(curve.D, y2)
+	v.Sub(v, one).Mod(v, curve.P)
+	if v.Sign() == 0 {
+		return nil, nil, fmt.Errorf("point not on curve")
+	}
+	x2 := new(big.Int).ModInverse(v, curve.P)
+	x2.Mul(x2, u).Mod(x2, curve.P)
+
+	// x = (x^2)^((P+1)/4), as P = 3 mod 4.
+	e := new(big.Int).Add(curve.P, one)
+	e.Rsh(e, 2)
+	x = new(big.Int).Exp(x2, e, curve.P)
+	check := new(big.Int).Mul(x, x)
+	if check.Mod(check, curve.P).Cmp(x2) != 0 {
+		return nil, nil, fmt.Errorf("point not on curve")
+	}
+
+	if x.Sign() == 0 && xIsOdd {
+		return nil, nil, fmt.Errorf("invalid point encoding")
+	}
+	if (x.Bit(0) == 1) != xIsOdd {
+		x.Sub(curve.P, x)
+	}
+
+	return x, y, nil
+}
+
+// dom4 builds the RFC 8032 domain separation string
+// dom4(phflag, context) = "SigEd448" || octet(phflag) ||
+// octet(len(context)) || context.
+func dom4(phflag byte, context []byte) []byte {
+	dom := make([]byte, 0, len(dom4Prefix)+2+len(context))
+	dom = append(dom, dom4Prefix...)
+	dom = append(dom, phflag, byte(len(context)))
+	dom = append(dom, context...)
+	return dom
+}
+
+// shake256 returns the 114 byte SHAKE256 digest of the concatenation of
+// parts, the hash function of Ed448.
+func shake256(parts ...[]byte) []byte {
+	h := sha3.NewShake256()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	digest := make([]byte, Ed448SignatureSize)
+	h.Read(digest)
+	return digest
+}
+
+// ed448ExpandSeed derives the clamped private scalar and the nonce prefix
+// from an Ed448 secret seed. The lower half of SHAKE256(seed) is clamped by
+// clearing the two lowest bits (making it a multiple of the cofactor 4),
+// clearing the last byte and setting the highest bit of the second to last
+// byte.
+func ed448ExpandSeed(seed []byte) (a *big.Int, prefix []byte) {
+	digest := shake256(seed)
+	defer zeroSlice(digest)
+
+	scalar := make([]byte, Ed448SeedSize)
+	defer zeroSlice(scalar)
+	copy(scalar, digest[:Ed448SeedSize])
+	scalar[0] &= 252
+	scalar[Ed448SeedSize-1] = 0
+	scalar[Ed448SeedSize-2] |= 128
+
+	prefix = make([]byte, Ed448SeedSize)
+	copy(prefix, digest[Ed448SeedSize:])
+
+	return leBytesToBigInt(scalar), prefix
+}
+
+// ed448PrivKeyFromSecret returns a private and public key for the Ed448
+// curve based on the 57 byte secret seed s.
+func ed448PrivKeyFromSecret(curve *TwistedEdwardsCurve,
+	s []byte) (*PrivateKey, *PublicKey) {
+	if len(s) != Ed448SeedSize {
+		return nil, nil
+	}
+
+	a, prefix := ed448ExpandSeed(s)
+	zeroSlice(prefix)
+
+	priv := new(PrivateKey)
+	priv.ecPk = new(ecdsa.PrivateKey)
+	priv.ecPk.Curve = curve
+	priv.ecPk.D = a
+	priv.ecPk.PublicKey.X, priv.ecPk.PublicKey.Y =
+		curve.ed448BaseMultSecret(a)
+	priv.ed448Seed = new([Ed448SeedSize]byte)
+	copy(priv.ed448Seed[:], s)
+	priv.pubKey = new(pubKeyCache)
+
+	return priv, (*PublicKey)(&priv.ecPk.PublicKey)
+}
+
+// ed448ParsePubKey parses a 57 byte encoded Ed448 public key, rejecting
+// points outside of the prime order subgroup.
+func ed448ParsePubKey(curve *TwistedEdwardsCurve, pubKeyStr []byte) (*PublicKey,
+	error) {
+	if len(pubKeyStr) == 0 {
+		return nil, errors.New("pubkey string is empty")
+	}
+	x, y, err := curve.ed448Decode(pubKeyStr)
+	if err != nil {
+		return nil, err
+	}
+	if !curve.ed448IsPrimeOrderPoint(ed448FromAffine(x, y)) {
+		return nil, fmt.Errorf("pubkey is not in the prime order subgroup")
+	}
+
+	return NewPublicKey(curve, x, y), nil
+}
+
+// ed448ParseSig parses a 114 byte Ed448 signature R || S.
+func ed448ParseSig(curve *TwistedEdwardsCurve, sigStr []byte) (*Signature,
+	error) {
+	if len(sigStr) != Ed448SignatureSize {
+		return nil, fmt.Errorf("bad signature size; have %v, want %v",
+			len(sigStr), Ed448SignatureSize)
+	}
+
+	rBytes := sigStr[:Ed448PubKeyBytesLen]
+	if _, _, err := curve.ed448Decode(rBytes); err != nil {
+		return nil, err
+	}
+
+	s := leBytesToBigInt(sigStr[Ed448PubKeyBytesLen:])
+	if s.Cmp(curve.N) >= 0 || s.Sign() == 0 {
+		return nil, fmt.Errorf("s scalar is empty or larger than the order of " +
+			"the curve")
+	}
+
+	return &Signature{leBytesToBigInt(rBytes), s}, nil
+}
+
+// SerializeEd448 returns the Ed448 signature as 114 bytes, the encoded R
+// followed by S, both little endian. A signature with R or S missing or
+// longer than 57 bytes serializes to nil.
+func (sig Signature) SerializeEd448() []byte {
+	if sig.R == nil || sig.S == nil || sig.R.Sign() < 0 || sig.S.Sign() < 0 ||
+		sig.R.BitLen() > 8*Ed448PubKeyBytesLen ||
+		sig.S.BitLen() > 8*Ed448PubKeyBytesLen {
+		return nil
+	}
+
+	return append(bigIntToLEBytes(sig.R, Ed448PubKeyBytesLen),
+		bigIntToLEBytes(sig.S, Ed448PubKeyBytesLen)...)
+}
+
+// isEd448 returns whether or not R or S of the signature is longer than the
+// 32 bytes of Ed25519, in which case it can only be an Ed448 signature. The
+// R and S of an Ed448 signature are both shorter only with negligible
+// probability.
+func (sig Signature) isEd448() bool {
+	return (sig.R != nil && sig.R.BitLen() > 256) ||
+		(sig.S != nil && sig.S.BitLen() > 256)
+}
+
+// ed448Sign implements Ed448 signing of RFC 8032 section 5.2.6 with the
+// given context, for a private key holding its secret seed.
+// R = rG
+// S = r + SHAKE256(dom4 || R || A || M) * a
+func (curve *TwistedEdwardsCurve) ed448Sign(priv *PrivateKey, context,
+	msg []byte) (*Signature, error) {
+	if priv.ed448Seed == nil {
+		return nil, fmt.Errorf("private key has no Ed448 secret seed to " +
+			"derive the nonce from")
+	}
+	if len(context) > 255 {
+		return nil, fmt.Errorf("context too long (got %v, want at most 255)",
+			len(context))
+	}
+	dom := dom4(0, context)
+
+	a, prefix := ed448ExpandSeed(priv.ed448Seed[:])
+	defer a.SetInt64(0)
+	defer zeroSlice(prefix)
+	pubX, pubY := priv.Public()
+	encodedA := ed448Encode(pubX, pubY)
+
+	// r = SHAKE256(dom4 || prefix || M) mod N
+	rDigest := shake256(dom, prefix, msg)
+	defer zeroSlice(rDigest)
+	r := leBytesToBigInt(rDigest)
+	r.Mod(r, curve.N)
+	defer r.SetInt64(0)
+
+	rX, rY := curve.ed448BaseMultSecret(r)
+	encodedR := ed448Encode(rX, rY)
+
+	// k = SHAKE256(dom4 || R || A || M) mod N
+	k := leBytesToBigInt(shake256(dom, encodedR, encodedA, msg))
+	k.Mod(k, curve.N)
+
+	// S = r + k * a
+	s := new(big.Int).Mul(k, a)
+	s.Add(s, r).Mod(s, curve.N)
+
+	return &Signature{leBytesToBigInt(encodedR), s}, nil
+}
+
+// ed448Verify implements Ed448 verification of RFC 8032 section 5.2.7 with
+// the given context, checking [4][S]G = [4]R + [4][k]A.
+func (curve *TwistedEdwardsCurve) ed448Verify(pub *PublicKey, context, msg []byte,
+	r, s *big.Int) bool {
+	if len(context) > 255 {
+		return false
+	}
+	if r.Sign() < 0 || r.BitLen() > 8*Ed448PubKeyBytesLen ||
+		s.Sign() < 0 || s.Cmp(curve.N) >= 0 {
+		return false
+	}
+	if !curve.ed448IsOnCurve(pub.GetX(), pub.GetY()) {
+		return false
+	}
+
+	encodedR := bigIntToLEBytes(r, Ed448PubKeyBytesLen)
+	rX, rY, err := curve.ed448Decode(encodedR)
+	if err != nil {
+		return false
+	}
+	encodedA := ed448Encode(pub.GetX(), pub.GetY())
+
+	k := leBytesToBigInt(shake256(dom4(0, context), encodedR, encodedA, msg))
+	k.Mod(k, curve.N)
+
+	lhs := curve.ed448ScalarMult(ed448FromAffine(curve.Gx, curve.Gy), s)
+	lhs = curve.ed448ScalarMult(lhs, four)
+	rhs := curve.ed448ScalarMult(ed448FromAffine(pub.GetX(), pub.GetY()), k)
+	rhs = curve.ed448Add(rhs, ed448FromAffine(rX, rY))
+	rhs = curve.ed448ScalarMult(rhs, four)
+
+	return curve.ed448Equal(lhs, rhs)
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+// ed448TestVectors are the Ed448 test vectors of RFC 8032 section 7.4.
+var ed448TestVectors = []struct {
+	sk      string
+	pk      string
+	msg     string
+	context string
+	sig     string
+}{
+	{
+		sk: "6c82a562cb808d10d632be89c8513ebf6c929f34ddfa8c9f63c9960ef6e348a3" +
+			"528c8a3fcc2f044e39a3fc5b94492f8f032e7549a20098f95b",
+		pk: "5fd7449b59b461fd2ce787ec616ad46a1da1342485a70e1f8a0ea75d80e96778" +
+			"edf124769b46c7061bd6783df1e50f6cd1fa1abeafe8256180",
+		msg: "",
+		sig: "533a37f6bbe457251f023c0d88f976ae2dfb504a843e34d2074fd823d41a591f" +
+			"2b233f034f628281f2fd7a22ddd47d7828c59bd0a21bfd3980ff0d2028d4b18a" +
+			"9df63e006c5d1c2d345b925d8dc00b4104852db99ac5c7cdda8530a113a0f4db" +
+			"b61149f05a7363268c71d95808ff2e652600",
+	},
+	{
+		sk: "c4eab05d357007c632f3dbb48489924d552b08fe0c353a0d4a1f00acda2c463a" +
+			"fbea67c5e8d2877c5e3bc397a659949ef8021e954e0a12274e",
+		pk: "43ba28f430cdff456ae531545f7ecd0ac834a55d9358c0372bfa0c6c6798c086" +
+			"6aea01eb00742802b8438ea4cb82169c235160627b4c3a9480",
+		msg: "03",
+		sig: "26b8f91727bd62897af15e41eb43c377efb9c610d48f2335cb0bd0087810f435" +
+			"2541b143c4b981b7e18f62de8ccdf633fc1bf037ab7cd779805e0dbcc0aae1cb" +
+			"cee1afb2e027df36bc04dcecbf154336c19f0af7e0a6472905e799f1953d2a0f" +
+			"f3348ab21aa4adafd1d234441cf807c03a00",
+	},
+	{
+		sk: "c4eab05d357007c632f3dbb48489924d552b08fe0c353a0d4a1f00acda2c463a" +
+			"fbea67c5e8d2877c5e3bc397a659949ef8021e954e0a12274e",
+		pk: "43ba28f430cdff456ae531545f7ecd0ac834a55d9358c0372bfa0c6c6798c086" +
+			"6aea01eb00742802b8438ea4cb82169c235160627b4c3a9480",
+		msg:     "03",
+		context: "666f6f",
+		sig: "d4f8f6131770dd46f40867d6fd5d5055de43541f8c5e35abbcd001b32a89f7d2" +
+			"151f7647f11d8ca2ae279fb842d607217fce6e042f6815ea000c85741de5c8da" +
+			"1144a6a1aba7f96de42505d7a7298524fda538fccbbb754f578c1cad10d54d0d" +
+			"5428407e85dcbc98a49155c13764e66c3c00",
+	},
+	{
+		sk: "cd23d24f714274e744343237b93290f511f6425f98e64459ff203e8985083ffd" +
+			"f60500553abc0e05cd02184bdb89c4ccd67e187951267eb328",
+		pk: "dcea9e78f35a1bf3499a831b10b86c90aac01cd84b67a0109b55a36e9328b1e3" +
+			"65fce161d71ce7131a543ea4cb5f7e9f1d8b00696447001400",
+		msg: "0c3e544074ec63b0265e0c",
+		sig: "1f0a8888ce25e8d458a21130879b840a9089d999aaba039eaf3e3afa090a09d3" +
+			"89dba82c4ff2ae8ac5cdfb7c55e94d5d961a29fe0109941e00b8dbdeea6d3b05" +
+			"1068df7254c0cdc129cbe62db2dc957dbb47b51fd3f213fb8698f064774250a5" +
+			"028961c9bf8ffd973fe5d5c206492b140e00",
+	},
+}
+
+// TestEd448 tests signing and verifying against the RFC 8032 Ed448 test
+// vectors through the same Sign and Verify used for Ed25519
+func TestEd448(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParamEd448()
+
+	if !curve.IsOnCurve(curve.Gx, curve.Gy) {
+		t.Fatalf("base point is not on the curve")
+	}
+	nX, nY := curve.ScalarBaseMult(curve.N.Bytes())
+	if nX.Sign() != 0 || nY.Cmp(one) != 0 {
+		t.Fatalf("base point does not have order N")
+	}
+
+	for i, v := range ed448TestVectors {
+		sk, _ := hex.DecodeString(v.sk)
+		wantPk, _ := hex.DecodeString(v.pk)
+		msg, _ := hex.DecodeString(v.msg)
+		context, _ := hex.DecodeString(v.context)
+		wantSig, _ := hex.DecodeString(v.sig)
+
+		priv, pub := PrivKeyFromSecret(curve, sk)
+		if priv == nil {
+			t.Fatalf("test %d: failed to derive the key", i)
+		}
+		if !bytes.Equal(pub.Serialize(), wantPk) {
+			t.Fatalf("test %d: got public key %x, want %x", i,
+				pub.Serialize(), wantPk)
+		}
+		parsedPub, err := ParsePubKey(curve, wantPk)
+		if err != nil {
+			t.Fatalf("test %d: unexpected public key parsing error: %v", i,
+				err)
+		}
+		if !parsedPub.Equal(pub) {
+			t.Fatalf("test %d: parsed public key differs", i)
+		}
+
+		var sig *Signature
+		if len(context) == 0 {
+			r, s, err := Sign(curve, priv, msg)
+			if err != nil {
+				t.Fatalf("test %d: unexpected signing error: %v", i, err)
+			}
+			sig = NewSignature(r, s)
+		} else {
+			sig, err = curve.ed448Sign(priv, context, msg)
+			if err != nil {
+				t.Fatalf("test %d: unexpected signing error: %v", i, err)
+			}
+		}
+		if !bytes.Equal(sig.SerializeEd448(), wantSig) {
+			t.Fatalf("test %d: got signature %x, want %x", i,
+				sig.SerializeEd448(), wantSig)
+		}
+		parsedSig, err := ParseSignature(curve, wantSig)
+		if err != nil {
+			t.Fatalf("test %d: unexpected signature parsing error: %v", i,
+				err)
+		}
+		if parsedSig.R.Cmp(sig.R) != 0 || parsedSig.S.Cmp(sig.S) != 0 {
+			t.Fatalf("test %d: parsed signature differs", i)
+		}
+
+		if len(context) == 0 {
+			if !Verify(pub, msg, sig.R, sig.S) {
+				t.Fatalf("test %d: signature failed to verify", i)
+			}
+			if Verify(pub, []byte("other"), sig.R, sig.S) {
+				t.Fatalf("test %d: signature verified for the wrong "+
+					"message", i)
+			}
+		} else {
+			if !curve.ed448Verify(pub, context, msg, sig.R, sig.S) {
+				t.Fatalf("test %d: signature failed to verify", i)
+			}
+			if curve.ed448Verify(pub, nil, msg, sig.R, sig.S) {
+				t.Fatalf("test %d: signature verified without its "+
+					"context", i)
+			}
+		}
+	}
+
+	// A bad S, a bad R and a secret of the wrong size are all rejected.
+	sk, _ := hex.DecodeString(ed448TestVectors[0].sk)
+	priv, pub := PrivKeyFromSecret(curve, sk)
+	msg := []byte("message in TestEd448")
+	r, s, err := Sign(curve, priv, msg)
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+	if !Verify(pub, msg, r, s) {
+		t.Fatalf("signature failed to verify")
+	}
+	if Verify(pub, msg, r, new(big.Int).Add(s, curve.N)) {
+		t.Fatalf("signature with S >= N verified")
+	}
+	if Verify(pub, msg, new(big.Int).Add(r, one), s) {
+		t.Fatalf("signature with a modified R verified")
+	}
+	if priv, _ := PrivKeyFromSecret(curve, sk[:32]); priv != nil {
+		t.Fatalf("expected failure deriving a key from a 32 byte secret")
+	}
+}
+
+// TestEd448SignatureEncoding tests that Ed448 signatures serialize to their
+// full 114 bytes wherever the generic Serialize is used, and that the
+// fixed size Ed25519 formats refuse them
+func TestEd448SignatureEncoding(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParamEd448()
+
+	sk, _ := hex.DecodeString(ed448TestVectors[0].sk)
+	wantSig, _ := hex.DecodeString(ed448TestVectors[0].sig)
+	priv, pub := PrivKeyFromSecret(curve, sk)
+	sig, err := ParseSignature(curve, wantSig)
+	if err != nil {
+		t.Fatalf("unexpected parsing error: %v", err)
+	}
+	if !bytes.Equal(sig.Serialize(), wantSig) {
+		t.Fatalf("got signature %x, want %x", sig.Serialize(), wantSig)
+	}
+
+	b, err := json.Marshal(sig)
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+	var decoded Signature
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unexpected unmarshal error: %v", err)
+	}
+	if decoded.R.Cmp(sig.R) != 0 || decoded.S.Cmp(sig.S) != 0 {
+		t.Fatalf("decoded signature %x, want %x", decoded.Serialize(),
+			wantSig)
+	}
+
+	if _, err := sig.WriteTo(new(bytes.Buffer)); err == nil {
+		t.Fatalf("wrote an Ed448 signature in the Ed25519 format")
+	}
+	resp := SigningResponse{PubKey: pub, Sig: sig}
+	if _, err := resp.Serialize(); err == nil {
+		t.Fatalf("serialized an Ed448 signing response")
+	}
+	if priv == nil || !Verify(pub, []byte{}, decoded.R, decoded.S) {
+		t.Fatalf("decoded signature failed to verify")
+	}
+
+	if (Signature{new(big.Int).Lsh(one, 8*Ed448PubKeyBytesLen), one}).
+		Serialize() != nil {
+		t.Fatalf("serialized a signature too large for Ed448")
+	}
+}
+
+// TestEd448ScalarMultConstantTime tests the constant time Ed448 scalar
+// multiplication against the variable time one, on the base point and
+// other points, for random scalars and the edges around 0 and N
+func TestEd448ScalarMultConstantTime(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParamEd448()
+
+	scalars := []*big.Int{new(big.Int), big.NewInt(1), big.NewInt(15),
+		big.NewInt(16), new(big.Int).Sub(curve.N, one), curve.N,
+		new(big.Int).Add(curve.N, one),
+		new(big.Int).Sub(new(big.Int).Lsh(one, 8*Ed448SeedSize), one)}
+	for i := 0; i < 8; i++ {
+		k, err := rand.Int(rand.Reader, curve.N)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		scalars = append(scalars, k)
+	}
+
+	px, py := curve.Gx, curve.Gy
+	for i := 0; i < 3; i++ {
+		for j, k := range scalars {
+			wantX, wantY := curve.ed448Affine(curve.ed448ScalarMult(
+				ed448FromAffine(px, py), k))
+			kBytes := bigIntToLEBytes(k, Ed448SeedSize)
+			x, y := curve.ed448ScalarMultConstantTime(px, py, kBytes)
+			if x.Cmp(wantX) != 0 || y.Cmp(wantY) != 0 {
+				t.Fatalf("point %d, scalar %d: got (%v, %v), want (%v, %v)",
+					i, j, x, y, wantX, wantY)
+			}
+			x, y = curve.ScalarMultConstantTime(px, py, k.Bytes())
+			if x.Cmp(wantX) != 0 || y.Cmp(wantY) != 0 {
+				t.Fatalf("point %d, scalar %d: ScalarMultConstantTime "+
+					"differs", i, j)
+			}
+			if i == 0 {
+				x, y = curve.BaseMult(k.Bytes())
+				if x.Cmp(wantX) != 0 || y.Cmp(wantY) != 0 {
+					t.Fatalf("scalar %d: BaseMult differs", j)
+				}
+			}
+		}
+		px, py = curve.ed448Affine(curve.ed448ScalarMult(
+			ed448FromAffine(px, py), scalars[len(scalars)-1]))
+	}
+}
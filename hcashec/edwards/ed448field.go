@@ -0,0 +1,252 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/subtle"
+	"math/big"
+)
+
+// ed448LimbBits is the size in bits of the limbs of an ed448FieldElement.
+const ed448LimbBits = 28
+
+// ed448LimbMask masks the low ed448LimbBits bits of a limb.
+const ed448LimbMask = 1<<ed448LimbBits - 1
+
+// ed448FieldElement is an element of the Ed448 field of integers mod
+// P = 2^448 - 2^224 - 1 as 16 little endian limbs of 28 bits, so that
+// products of limbs and their sums fit in 64 bits. Unlike big integers, the
+// arithmetic on it runs in the same time whatever the values, which is what
+// ed448ScalarMultConstantTime relies on. After every operation the limbs
+// are at most 2^28, but the value isn't necessarily reduced below P.
+type ed448FieldElement [16]uint64
+
+// ed448TwoP is 2P in limbs which are all larger than those of any
+// ed448FieldElement, so that subtracting from it never underflows.
+var ed448TwoP = ed448FieldElement{
+	0x1ffffffe, 0x1ffffffe, 0x1ffffffe, 0x1ffffffe,
+	0x1ffffffe, 0x1ffffffe, 0x1ffffffe, 0x1ffffffe,
+	0x1ffffffc, 0x1ffffffe, 0x1ffffffe, 0x1ffffffe,
+	0x1ffffffe, 0x1ffffffe, 0x1ffffffe, 0x1ffffffe,
+}
+
+// ed448P is P in limbs.
+var ed448P = ed448FieldElement{
+	0xfffffff, 0xfffffff, 0xfffffff, 0xfffffff,
+	0xfffffff, 0xfffffff, 0xfffffff, 0xfffffff,
+	0xffffffe, 0xfffffff, 0xfffffff, 0xfffffff,
+	0xfffffff, 0xfffffff, 0xfffffff, 0xfffffff,
+}
+
+// fe448Carry brings the limbs of h, each of them less than 2^63, back to at
+// most 2^28. The carry out of the top limb has the weight 2^448, which is
+// 2^224 + 1 mod P, so it is added back to limbs 0 and 8. A second pass
+// absorbs the carries that adds.
+func fe448Carry(h *ed448FieldElement) {
+	for pass := 0; pass < 2; pass++ {
+		for i := 0; i < 15; i++ {
+			h[i+1] += h[i] >> ed448LimbBits
+			h[i] &= ed448LimbMask
+		}
+		top := h[15] >> ed448LimbBits
+		h[15] &= ed448LimbMask
+		h[0] += top
+		h[8] += top
+	}
+}
+
+// fe448Add sets h = f + g.
+func fe448Add(h, f, g *ed448FieldElement) {
+	for i := range h {
+		h[i] = f[i] + g[i]
+	}
+	fe448Carry(h)
+}
+
+// fe448Sub sets h = f - g, computed as f + 2P - g.
+func fe448Sub(h, f, g *ed448FieldElement) {
+	for i := range h {
+		h[i] = f[i] + ed448TwoP[i] - g[i]
+	}
+	fe448Carry(h)
+}
+
+// fe448Mul sets h = f * g. The product is computed in 31 limbs, which are
+// then folded back onto the low 16 with 2^448 = 2^224 + 1 mod P, from the
+// top down so that limbs folded onto other high limbs are folded again.
+func fe448Mul(h, f, g *ed448FieldElement) {
+	var c [31]uint64
+	for i := 0; i < 16; i++ {
+		for j := 0; j < 16; j++ {
+			c[i+j] += f[i] * g[j]
+		}
+	}
+	for i := 30; i >= 16; i-- {
+		c[i-16] += c[i]
+		c[i-8] += c[i]
+	}
+
+	copy(h[:], c[:16])
+	fe448Carry(h)
+}
+
+// fe448CMove sets f = g if b is 1 and leaves it unchanged if b is 0,
+// without branching on b.
+func fe448CMove(f, g *ed448FieldElement, b uint64) {
+	mask := -b
+	for i := range f {
+		f[i] ^= mask & (f[i] ^ g[i])
+	}
+}
+
+// fe448Invert sets out = z^(P-2), the inverse of z for non-zero z. The
+// exponent is public, so the square and multiply runs in the same time for
+// every z.
+func fe448Invert(out, z *ed448FieldElement, curve *TwistedEdwardsCurve) {
+	e := new(big.Int).Sub(curve.P, two)
+	r := ed448FieldElement{1}
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		fe448Mul(&r, &r, &r)
+		if e.Bit(i) == 1 {
+			fe448Mul(&r, &r, z)
+		}
+	}
+	*out = r
+}
+
+// fe448Reduce returns f fully reduced mod P, with limbs below 2^28. It
+// subtracts P and keeps the difference unless that borrowed, with a mask
+// instead of a branch.
+func fe448Reduce(f *ed448FieldElement) ed448FieldElement {
+	h := *f
+	fe448Carry(&h)
+
+	var d ed448FieldElement
+	var borrow int64
+	for i := range d {
+		v := int64(h[i]) - int64(ed448P[i]) + borrow
+		d[i] = uint64(v) & ed448LimbMask
+		borrow = v >> ed448LimbBits
+	}
+	fe448CMove(&h, &d, uint64(borrow+1))
+
+	// If the difference borrowed, limbs 0 and 8 of h may still be 2^28.
+	for i := 0; i < 15; i++ {
+		h[i+1] += h[i] >> ed448LimbBits
+		h[i] &= ed448LimbMask
+	}
+
+	return h
+}
+
+// fe448FromBig returns the field element of the integer x mod P.
+func fe448FromBig(x *big.Int, curve *TwistedEdwardsCurve) ed448FieldElement {
+	b := bigIntToLEBytes(new(big.Int).Mod(x, curve.P), 56)
+
+	var h ed448FieldElement
+	for i := 0; i < 8; i++ {
+		var w uint64
+		for j := 6; j >= 0; j-- {
+			w = w<<8 | uint64(b[7*i+j])
+		}
+		h[2*i] = w & ed448LimbMask
+		h[2*i+1] = w >> ed448LimbBits
+	}
+
+	return h
+}
+
+// fe448ToBig returns f reduced mod P as a big integer.
+func fe448ToBig(f *ed448FieldElement) *big.Int {
+	h := fe448Reduce(f)
+
+	b := make([]byte, 56)
+	for i := 0; i < 8; i++ {
+		w := h[2*i] | h[2*i+1]<<ed448LimbBits
+		for j := 0; j < 7; j++ {
+			b[7*i+j] = byte(w >> (8 * uint(j)))
+		}
+	}
+
+	return leBytesToBigInt(b)
+}
+
+// ed448CTPoint is a point of the Ed448 curve in projective coordinates over
+// ed448FieldElement, x = X/Z and y = Y/Z.
+type ed448CTPoint struct {
+	X, Y, Z ed448FieldElement
+}
+
+// ed448CTAdd sets r = p + q with the complete formulas of ed448Add, which
+// double a point as well, so every addition costs the same.
+func ed448CTAdd(r, p, q *ed448CTPoint, d *ed448FieldElement) {
+	var a, b, c, dd, e, f, g, h, t ed448FieldElement
+	fe448Mul(&a, &p.Z, &q.Z)
+	fe448Mul(&b, &a, &a)
+	fe448Mul(&c, &p.X, &q.X)
+	fe448Mul(&dd, &p.Y, &q.Y)
+	fe448Mul(&e, d, &c)
+	fe448Mul(&e, &e, &dd)
+	fe448Sub(&f, &b, &e)
+	fe448Add(&g, &b, &e)
+	fe448Add(&h, &p.X, &p.Y)
+	fe448Add(&t, &q.X, &q.Y)
+	fe448Mul(&h, &h, &t)
+
+	// X3 = A*F*(H-C-D), Y3 = A*G*(D-C), Z3 = F*G
+	fe448Sub(&h, &h, &c)
+	fe448Sub(&h, &h, &dd)
+	fe448Sub(&t, &dd, &c)
+	fe448Mul(&r.X, &a, &f)
+	fe448Mul(&r.X, &r.X, &h)
+	fe448Mul(&r.Y, &a, &g)
+	fe448Mul(&r.Y, &r.Y, &t)
+	fe448Mul(&r.Z, &f, &g)
+}
+
+// ed448ScalarMultConstantTime returns k*(x1, y1) for the little endian
+// scalar k. It uses fixed 4-bit windows over every nibble of k, each costing
+// four doublings and the addition of a multiple of the point selected from
+// a table with conditional moves, so the running time only depends on the
+// length of k and not on its value. It is the Ed448 multiplication to use
+// with secret scalars. The point itself is treated as public.
+func (curve *TwistedEdwardsCurve) ed448ScalarMultConstantTime(x1, y1 *big.Int,
+	k []byte) (x, y *big.Int) {
+	d := fe448FromBig(curve.D, curve)
+
+	// 0P, 1P, ..., 15P
+	var table [16]ed448CTPoint
+	table[0].Y[0], table[0].Z[0] = 1, 1
+	table[1].X = fe448FromBig(x1, curve)
+	table[1].Y = fe448FromBig(y1, curve)
+	table[1].Z[0] = 1
+	for j := 2; j < 16; j++ {
+		ed448CTAdd(&table[j], &table[j-1], &table[1], &d)
+	}
+
+	q := table[0]
+	for i := 2*len(k) - 1; i >= 0; i-- {
+		for j := 0; j < 4; j++ {
+			ed448CTAdd(&q, &q, &q, &d)
+		}
+
+		nibble := (k[i/2] >> (uint(i%2) * 4)) & 0x0f
+		var sel ed448CTPoint
+		for j := range table {
+			b := uint64(subtle.ConstantTimeByteEq(uint8(j), nibble))
+			fe448CMove(&sel.X, &table[j].X, b)
+			fe448CMove(&sel.Y, &table[j].Y, b)
+			fe448CMove(&sel.Z, &table[j].Z, b)
+		}
+		ed448CTAdd(&q, &q, &sel, &d)
+	}
+
+	var zInv ed448FieldElement
+	fe448Invert(&zInv, &q.Z, curve)
+	fe448Mul(&q.X, &q.X, &zInv)
+	fe448Mul(&q.Y, &q.Y, &zInv)
+
+	return fe448ToBig(&q.X), fe448ToBig(&q.Y)
+}
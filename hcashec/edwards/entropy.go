@@ -0,0 +1,124 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrEntropyHealth is returned when an entropy source fails its health
+// tests, so keys aren't generated from output that may be predictable.
+var ErrEntropyHealth = errors.New("entropy source failed its health test")
+
+// EntropySource is a source of randomness which can test its own health,
+// such as a hardware RNG with continuous self tests. GenerateKey and
+// GenerateKeys run HealthCheck before reading from a reader which is an
+// EntropySource and refuse to generate keys if it fails.
+type EntropySource interface {
+	io.Reader
+
+	// HealthCheck returns an error if the source is known or found to be
+	// degraded.
+	HealthCheck() error
+}
+
+// checkEntropySource runs the health check of rand if it is an
+// EntropySource.
+func checkEntropySource(rand io.Reader) error {
+	src, ok := rand.(EntropySource)
+	if !ok {
+		return nil
+	}
+
+	return src.HealthCheck()
+}
+
+const (
+	// repetitionCutoff is the number of identical consecutive bytes at
+	// which the repetition count test of NIST SP 800-90B fails. With the
+	// cutoff of 1 + ceil(20/H) for a false positive rate of 2^-20, six
+	// bytes hold for a source of at least H = 4 bits of min-entropy per
+	// byte. Six equal bytes only come out of a good source with chance
+	// 2^-40 at any position.
+	repetitionCutoff = 6
+
+	// healthCheckSampleSize is the number of bytes HealthCheck tests.
+	healthCheckSampleSize = 256
+)
+
+// healthCheckedSource is the EntropySource of NewHealthCheckedSource.
+type healthCheckedSource struct {
+	r      io.Reader
+	last   byte
+	run    int
+	failed bool
+}
+
+// NewHealthCheckedSource wraps r in an EntropySource which runs the
+// repetition count test of NIST SP 800-90B continuously on everything read
+// through it, as well as on a fresh sample of its output when HealthCheck is
+// called. The test catches a source that got stuck, such as one returning
+// all zeros, not one that is merely biased or predictable. Once the test
+// fails, every read and health check fails with ErrEntropyHealth and the
+// data that failed is wiped rather than returned. The source isn't safe for
+// concurrent use.
+func NewHealthCheckedSource(r io.Reader) EntropySource {
+	return &healthCheckedSource{r: r}
+}
+
+// test runs the repetition count test on b, continuing the run of the
+// previous bytes, and returns whether it passed.
+func (s *healthCheckedSource) test(b []byte) bool {
+	for _, c := range b {
+		if s.run > 0 && c == s.last {
+			s.run++
+		} else {
+			s.last, s.run = c, 1
+		}
+		if s.run >= repetitionCutoff {
+			s.failed = true
+		}
+	}
+
+	return !s.failed
+}
+
+// Read satisfies the io.Reader interface, reading from the wrapped reader
+// and failing with ErrEntropyHealth if the output fails the test.
+func (s *healthCheckedSource) Read(p []byte) (int, error) {
+	if s.failed {
+		return 0, ErrEntropyHealth
+	}
+
+	n, err := s.r.Read(p)
+	if !s.test(p[:n]) {
+		zeroSlice(p[:n])
+		return 0, ErrEntropyHealth
+	}
+
+	return n, err
+}
+
+// HealthCheck reads a sample of healthCheckSampleSize bytes from the wrapped
+// reader and tests it, returning ErrEntropyHealth if it or any earlier read
+// failed the test.
+func (s *healthCheckedSource) HealthCheck() error {
+	if s.failed {
+		return ErrEntropyHealth
+	}
+
+	sample := make([]byte, healthCheckSampleSize)
+	defer zeroSlice(sample)
+	if _, err := io.ReadFull(s.r, sample); err != nil {
+		return fmt.Errorf("failed to read health check sample: %v", err)
+	}
+	if !s.test(sample) {
+		return ErrEntropyHealth
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+// zeroReader is a broken entropy source which only returns zeros.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// unhealthySource is an EntropySource which reports itself as degraded and
+// counts the bytes read from it.
+type unhealthySource struct {
+	read int
+}
+
+func (s *unhealthySource) Read(p []byte) (int, error) {
+	s.read += len(p)
+	return crand.Read(p)
+}
+
+func (s *unhealthySource) HealthCheck() error {
+	return errors.New("self test failed")
+}
+
+// TestHealthCheckedSource tests that an all zero source fails its health
+// check and is refused by GenerateKey and GenerateKeys, that a source which
+// gets stuck is caught while it is read, that a good source passes, and
+// that a failing EntropySource isn't read from
+func TestHealthCheckedSource(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	bad := NewHealthCheckedSource(zeroReader{})
+	if err := bad.HealthCheck(); err != ErrEntropyHealth {
+		t.Fatalf("want ErrEntropyHealth for an all zero source, got %v", err)
+	}
+	if _, _, _, err := GenerateKey(curve,
+		NewHealthCheckedSource(zeroReader{})); err != ErrEntropyHealth {
+		t.Fatalf("want ErrEntropyHealth from GenerateKey, got %v", err)
+	}
+	if _, err := GenerateKeys(curve, NewHealthCheckedSource(zeroReader{}),
+		3); err != ErrEntropyHealth {
+		t.Fatalf("want ErrEntropyHealth from GenerateKeys, got %v", err)
+	}
+
+	// Good output followed by a stuck source fails on read, without
+	// handing out the stuck bytes, and stays failed.
+	good := make([]byte, 64)
+	if _, err := crand.Read(good); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stuck := NewHealthCheckedSource(io.MultiReader(bytes.NewReader(good),
+		zeroReader{}))
+	buf := make([]byte, 64)
+	if _, err := io.ReadFull(stuck, buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	buf[0] = 0xff
+	if n, err := stuck.Read(buf); n != 0 || err != ErrEntropyHealth {
+		t.Fatalf("want ErrEntropyHealth from a stuck source, got %v, %v", n,
+			err)
+	}
+	if buf[0] != 0 {
+		t.Fatalf("failed read wasn't wiped")
+	}
+	if err := stuck.HealthCheck(); err != ErrEntropyHealth {
+		t.Fatalf("want a failed source to stay failed, got %v", err)
+	}
+
+	src := NewHealthCheckedSource(crand.Reader)
+	for i := 0; i < 10; i++ {
+		if err := src.HealthCheck(); err != nil {
+			t.Fatalf("good source failed its health check: %v", err)
+		}
+	}
+	priv, x, y, err := GenerateKey(curve, src)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pk, _ := PrivKeyFromBytes(curve, priv)
+	if pk == nil || !pk.PubKey().Equal(NewPublicKey(curve, x, y)) {
+		t.Fatalf("generated key doesn't match its public key")
+	}
+	if _, err := GenerateKeys(curve, src, 5); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	unhealthy := &unhealthySource{}
+	if _, _, _, err := GenerateKey(curve, unhealthy); err == nil {
+		t.Fatalf("generated a key from an unhealthy source")
+	}
+	if _, err := GenerateKeys(curve, unhealthy, 2); err == nil {
+		t.Fatalf("generated keys from an unhealthy source")
+	}
+	if unhealthy.read != 0 {
+		t.Fatalf("read %v bytes from an unhealthy source", unhealthy.read)
+	}
+}
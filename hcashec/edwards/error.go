@@ -0,0 +1,148 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ScalarErrorReason identifies why a scalar was rejected.
+type ScalarErrorReason int
+
+// These constants are used to identify a specific ScalarError.
+const (
+	// ScalarWrongLength indicates that the encoded scalar was not
+	// PrivScalarSize bytes long.
+	ScalarWrongLength ScalarErrorReason = iota
+
+	// ScalarZero indicates that the scalar was zero (or negative).
+	ScalarZero
+
+	// ScalarOutOfRange indicates that the scalar was not below the order
+	// of the curve.
+	ScalarOutOfRange
+)
+
+// Map of ScalarErrorReason values back to their constant names for pretty
+// printing.
+var scalarErrorReasonStrings = map[ScalarErrorReason]string{
+	ScalarWrongLength: "ScalarWrongLength",
+	ScalarZero:        "ScalarZero",
+	ScalarOutOfRange:  "ScalarOutOfRange",
+}
+
+// String returns the ScalarErrorReason as a human-readable name.
+func (r ScalarErrorReason) String() string {
+	if s := scalarErrorReasonStrings[r]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown ScalarErrorReason (%d)", int(r))
+}
+
+// ErrInvalidScalar is the error every ScalarError matches with errors.Is,
+// whatever its reason.
+var ErrInvalidScalar = errors.New("invalid scalar")
+
+// ErrInvalidNonce is the error a ScalarError for a rejected nonce matches
+// with errors.Is, on top of ErrInvalidScalar.
+var ErrInvalidNonce = errors.New("invalid nonce")
+
+// ScalarError is returned when a private scalar or nonce is rejected. Use
+// errors.Is with ErrInvalidScalar to check for any invalid scalar, with
+// ErrInvalidNonce to check for an invalid nonce, or with a ScalarError holding
+// the same Reason to check for a specific one.
+type ScalarError struct {
+	Reason      ScalarErrorReason // Describes why the scalar was rejected
+	Description string            // Human readable description of the issue
+
+	nonce bool
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e ScalarError) Error() string {
+	return e.Description
+}
+
+// Is makes every ScalarError match ErrInvalidScalar, the ones for nonces
+// match ErrInvalidNonce as well, along with any ScalarError of the same
+// reason.
+func (e ScalarError) Is(target error) bool {
+	switch target {
+	case ErrInvalidScalar:
+		return true
+	case ErrInvalidNonce:
+		return e.nonce
+	}
+	t, ok := target.(ScalarError)
+	return ok && t.Reason == e.Reason
+}
+
+// scalarError creates a ScalarError given a set of arguments.
+func scalarError(r ScalarErrorReason, desc string) ScalarError {
+	return ScalarError{Reason: r, Description: desc}
+}
+
+// checkScalar validates a 32 byte big endian scalar, which must be in the
+// range [1, N-1].
+func checkScalar(curve *TwistedEdwardsCurve, k []byte, name string) error {
+	if len(k) != PrivScalarSize {
+		return scalarError(ScalarWrongLength, fmt.Sprintf("wrong size for "+
+			"%v (got %v, want %v)", name, len(k), PrivScalarSize))
+	}
+
+	kBig := new(big.Int).SetBytes(k)
+	defer kBig.SetInt64(0)
+	if kBig.Sign() == 0 {
+		return scalarError(ScalarZero, fmt.Sprintf("%v is zero", name))
+	}
+	if kBig.Cmp(curve.N) >= 0 {
+		return scalarError(ScalarOutOfRange, fmt.Sprintf("%v is not below "+
+			"the curve order", name))
+	}
+
+	return nil
+}
+
+// checkNonce validates a 32 byte big endian nonce like checkScalar, marking
+// the error as one for a nonce.
+func checkNonce(curve *TwistedEdwardsCurve, k []byte) error {
+	err := checkScalar(curve, k, "nonce")
+	if serr, ok := err.(ScalarError); ok {
+		serr.nonce = true
+		return serr
+	}
+
+	return err
+}
+
+// These errors are returned by VerifyWithReason to tell why a signature was
+// rejected.
+var (
+	// ErrVerifyNilInput is returned when the public key, the message or
+	// either half of the signature is missing.
+	ErrVerifyNilInput = errors.New("nil public key, message or signature")
+
+	// ErrInvalidPubKey is returned when the public key has no Edwards
+	// curve or isn't a point on it.
+	ErrInvalidPubKey = errors.New("public key is not a point on the curve")
+
+	// ErrPointNotOnCurve is returned when R doesn't decode to a point.
+	ErrPointNotOnCurve = errors.New("signature R is not a point on the curve")
+
+	// ErrSmallOrderR is returned when R is one of the eight points of small
+	// order, which no honest signer produces.
+	ErrSmallOrderR = errors.New("signature R is of small order")
+
+	// ErrNonCanonicalS is returned when S is not fully reduced mod N.
+	ErrNonCanonicalS = errors.New("signature S is not canonical")
+
+	// ErrChallengeMismatch is returned when a well formed signature doesn't
+	// satisfy the verification equation, e.g. because it is for another
+	// message or key.
+	ErrChallengeMismatch = errors.New("signature doesn't match the " +
+		"challenge")
+)
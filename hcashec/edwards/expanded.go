@@ -0,0 +1,256 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// ExpandedPrivateKeySize is the size of a serialized expanded private key,
+// the clamped scalar followed by the nonce prefix.
+const ExpandedPrivateKeySize = 64
+
+// ExpandedPrivateKey is an Ed25519 private key in the expanded form of
+// RFC 8032: hash512(seed) split into the clamped signing scalar a and the
+// prefix that deterministic nonces r = hash512(prefix || M) are derived
+// from. Signing only needs these two halves, so keys from systems which
+// store expanded keys rather than seeds can sign messages with them, and
+// the signatures are identical to those of the seed they came from.
+type ExpandedPrivateKey struct {
+	curve  *TwistedEdwardsCurve
+	scalar [PrivScalarSize]byte
+	prefix [32]byte
+	pub    [PubKeyBytesLen]byte
+	wiped  bool
+}
+
+// expandSeed expands the 32 byte secret seed into the clamped scalar, the
+// lower half of hash512(seed), and the nonce prefix, its upper half.
+func expandSeed(k *ExpandedPrivateKey, seed []byte) {
+	var digest [64]byte
+	h := sha512.New()
+	h.Write(seed)
+	h.Sum(digest[:0])
+	defer zeroSlice(digest[:])
+
+	copy(k.scalar[:], digest[:32])
+	k.scalar[0] &= 248
+	k.scalar[31] &= 63
+	k.scalar[31] |= 64
+	copy(k.prefix[:], digest[32:])
+}
+
+// setPublicKey computes and stores the encoded public key aB of k.
+func (k *ExpandedPrivateKey) setPublicKey() {
+	var A edwards25519.ExtendedGroupElement
+	edwards25519.GeScalarMultBase(&A, &k.scalar)
+	A.ToBytes(&k.pub)
+}
+
+// NewExpandedPrivateKey expands the 32 byte Ed25519 secret seed into an
+// ExpandedPrivateKey for curve.
+func NewExpandedPrivateKey(curve *TwistedEdwardsCurve,
+	seed []byte) (*ExpandedPrivateKey, error) {
+	if curve == nil || curve.isEd448() {
+		return nil, fmt.Errorf("expanded keys are only supported on Ed25519")
+	}
+	if len(seed) != PrivKeyBytesLen/2 {
+		return nil, fmt.Errorf("bad seed size; have %v, want %v", len(seed),
+			PrivKeyBytesLen/2)
+	}
+
+	k := &ExpandedPrivateKey{curve: curve}
+	expandSeed(k, seed)
+	k.setPublicKey()
+
+	return k, nil
+}
+
+// ParseExpandedPrivateKey parses a 64 byte expanded private key, the little
+// endian scalar followed by the nonce prefix, as returned by Serialize. The
+// public key is computed from the scalar, which must be below 2^255 and
+// non-zero mod N. Since the seed can't be recovered, keys parsed this way
+// can only be used through ExpandedPrivateKey.
+func ParseExpandedPrivateKey(curve *TwistedEdwardsCurve,
+	b []byte) (*ExpandedPrivateKey, error) {
+	if curve == nil || curve.isEd448() {
+		return nil, fmt.Errorf("expanded keys are only supported on Ed25519")
+	}
+	if len(b) != ExpandedPrivateKeySize {
+		return nil, fmt.Errorf("bad expanded key size; have %v, want %v",
+			len(b), ExpandedPrivateKeySize)
+	}
+	if b[31]&0x80 != 0 {
+		return nil, fmt.Errorf("expanded key scalar is too large")
+	}
+	reduced := ScalarReduce(b[:32])
+	isZero := reduced == [32]byte{}
+	zeroSlice(reduced[:])
+	if isZero {
+		return nil, fmt.Errorf("expanded key scalar is zero mod N")
+	}
+
+	k := &ExpandedPrivateKey{curve: curve}
+	copy(k.scalar[:], b[:32])
+	copy(k.prefix[:], b[32:])
+	k.setPublicKey()
+
+	return k, nil
+}
+
+// Expand returns the expanded form of the private key, which must hold its
+// 32 byte secret seed.
+func (p *PrivateKey) Expand() (*ExpandedPrivateKey, error) {
+	if err := p.signingErr(); err != nil {
+		return nil, err
+	}
+	curve, ok := p.ecPk.Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil {
+		return nil, fmt.Errorf("private key has no curve")
+	}
+	if p.secret == nil {
+		return nil, fmt.Errorf("private key has no secret seed to expand")
+	}
+
+	return NewExpandedPrivateKey(curve, p.secret[:])
+}
+
+// Scalar returns a copy of the clamped signing scalar as a 32 byte little
+// endian integer.
+func (k *ExpandedPrivateKey) Scalar() []byte {
+	return append([]byte(nil), k.scalar[:]...)
+}
+
+// Prefix returns a copy of the 32 byte nonce prefix.
+func (k *ExpandedPrivateKey) Prefix() []byte {
+	return append([]byte(nil), k.prefix[:]...)
+}
+
+// RotatePrefix replaces the nonce prefix of the key with 32 fresh bytes
+// read from rand, leaving the scalar, and so the public key, as is. Nonces
+// are still derived deterministically, but from the new prefix, so the
+// signature of a message changes and no longer matches the one of the seed
+// the key came from, while still verifying under the same public key. That
+// re-randomizes the nonces of a long lived key, such as after a suspected
+// fault, without replacing it. The serialized key changes as well and must
+// be stored again, since the seed no longer reproduces it. On a short read
+// the prefix is left unchanged.
+func (k *ExpandedPrivateKey) RotatePrefix(rand io.Reader) error {
+	if k.wiped {
+		return ErrWipedKey
+	}
+
+	var prefix [32]byte
+	defer zeroSlice(prefix[:])
+	if _, err := io.ReadFull(rand, prefix[:]); err != nil {
+		return fmt.Errorf("failed to read nonce prefix: %v", err)
+	}
+	copy(k.prefix[:], prefix[:])
+
+	return nil
+}
+
+// Serialize returns the 64 byte expanded key, the scalar followed by the
+// nonce prefix.
+func (k *ExpandedPrivateKey) Serialize() []byte {
+	return append(k.Scalar(), k.prefix[:]...)
+}
+
+// PublicKey returns the public key of the expanded key, satisfying the
+// Signer interface.
+func (k *ExpandedPrivateKey) PublicKey() *PublicKey {
+	// The key is computed from the scalar, so it needs none of the checks
+	// ParsePubKey does on untrusted keys.
+	x, y, err := k.curve.EncodedBytesToBigIntPoint(&k.pub)
+	if err != nil {
+		return nil
+	}
+
+	return NewPublicKey(k.curve, x, y)
+}
+
+// Sign signs msg following the Ed25519 algorithm of RFC 8032, satisfying
+// the Signer interface. The signature is the same as SignDeterministic
+// produces with the seed of the key.
+func (k *ExpandedPrivateKey) Sign(msg []byte) (*Signature, error) {
+	if k.wiped {
+		return nil, ErrWipedKey
+	}
+
+	return k.signRFC8032(nil, msg)
+}
+
+// signRFC8032 implements RFC 8032 signing with the expanded key. The domain
+// separation prefix dom is written in front of both the nonce and the
+// challenge hash input. It is empty for pure Ed25519 and
+// dom2(phflag, context) for the Ed25519ph and Ed25519ctx variants.
+// R = rG
+// S = r + hash512(dom || R || A || M) * a
+func (k *ExpandedPrivateKey) signRFC8032(dom []byte,
+	msg []byte) (*Signature, error) {
+	return k.signWithChallengeHash(sha512.New, dom, msg)
+}
+
+// signWithChallengeHash is signRFC8032 with the challenge computed with the
+// 64 byte hash function newHash instead of SHA512. The nonce is still
+// derived with SHA512.
+func (k *ExpandedPrivateKey) signWithChallengeHash(newHash func() hash.Hash,
+	dom []byte, msg []byte) (*Signature, error) {
+	// r = hash512(dom || prefix || M)
+	var messageDigest [64]byte
+	h := sha512.New()
+	h.Write(dom)
+	h.Write(k.prefix[:])
+	h.Write(msg)
+	h.Sum(messageDigest[:0])
+	var messageDigestReduced [32]byte
+	edwards25519.ScReduce(&messageDigestReduced, &messageDigest)
+	defer zeroSlice(messageDigestReduced[:])
+	zeroSlice(messageDigest[:])
+
+	var R edwards25519.ExtendedGroupElement
+	edwards25519.GeScalarMultBase(&R, &messageDigestReduced)
+	var encodedR [32]byte
+	R.ToBytes(&encodedR)
+
+	// h = hash512(dom || R || A || M)
+	var hramDigest [64]byte
+	h = newHash()
+	h.Write(dom)
+	h.Write(encodedR[:])
+	h.Write(k.pub[:])
+	h.Write(msg)
+	h.Sum(hramDigest[:0])
+	var hramDigestReduced [32]byte
+	edwards25519.ScReduce(&hramDigestReduced, &hramDigest)
+
+	// s = r + h * a
+	var localS [32]byte
+	edwards25519.ScMulAdd(&localS, &hramDigestReduced, &k.scalar,
+		&messageDigestReduced)
+
+	signature := new([64]byte)
+	copy(signature[:], encodedR[:])
+	copy(signature[32:], localS[:])
+
+	return ParseSignature(k.curve, signature[:])
+}
+
+// Wipe overwrites the scalar and the nonce prefix of the key with zeros.
+// Any later attempt to sign with it fails with ErrWipedKey.
+func (k *ExpandedPrivateKey) Wipe() {
+	if k == nil {
+		return
+	}
+
+	zeroSlice(k.scalar[:])
+	zeroSlice(k.prefix[:])
+	k.wiped = true
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+// TestExpandedPrivateKey tests deriving expanded keys from the seeds of the
+// RFC 8032 test vectors and signing the reference messages with them, from
+// the seed, from a PrivateKey and after a serialization roundtrip
+func TestExpandedPrivateKey(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	tests := []struct {
+		secret string
+		pub    string
+		msg    string
+		sig    string
+	}{
+		{
+			"9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+			"d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+			"",
+			"e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e06522490155" +
+				"5fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b",
+		},
+		{
+			"4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+			"3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c",
+			"72",
+			"92a009a9f0d4cab8720e820b5f642540a2b27b5416503f8fb3762223ebdb69da" +
+				"085ac1e43e15996e458f3613d0f11d8c387b2eaeb4302aeeb00d291612bb0c00",
+		},
+	}
+
+	for i, test := range tests {
+		secret, _ := hex.DecodeString(test.secret)
+		wantPub, _ := hex.DecodeString(test.pub)
+		msg, _ := hex.DecodeString(test.msg)
+		want, _ := hex.DecodeString(test.sig)
+
+		fromSeed, err := NewExpandedPrivateKey(curve, secret)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		priv, _ := PrivKeyFromSecret(curve, secret)
+		fromPriv, err := priv.Expand()
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		parsed, err := ParseExpandedPrivateKey(curve, fromSeed.Serialize())
+		if err != nil {
+			t.Fatalf("test %d: unexpected parsing error: %v", i, err)
+		}
+
+		for j, k := range []Signer{fromSeed, fromPriv, parsed} {
+			if !bytes.Equal(k.PublicKey().Serialize(), wantPub) {
+				t.Fatalf("test %d, key %d: public key %x, want %x", i, j,
+					k.PublicKey().Serialize(), wantPub)
+			}
+			sig, err := k.Sign(msg)
+			if err != nil {
+				t.Fatalf("test %d, key %d: unexpected signing error: %v", i,
+					j, err)
+			}
+			if !bytes.Equal(sig.Serialize(), want) {
+				t.Fatalf("test %d, key %d: want %x, got %x", i, j, want,
+					sig.Serialize())
+			}
+		}
+
+		// The halves are the clamped scalar and the prefix.
+		scalar := fromSeed.Scalar()
+		if scalar[0]&7 != 0 || scalar[31]&0xc0 != 0x40 {
+			t.Fatalf("test %d: scalar %x is not clamped", i, scalar)
+		}
+		if !bytes.Equal(fromSeed.Serialize(), append(scalar,
+			fromSeed.Prefix()...)) {
+			t.Fatalf("test %d: serialization is not scalar || prefix", i)
+		}
+
+		fromSeed.Wipe()
+		if _, err := fromSeed.Sign(msg); err != ErrWipedKey {
+			t.Fatalf("test %d: want %v, got %v", i, ErrWipedKey, err)
+		}
+	}
+
+	if _, err := NewExpandedPrivateKey(curve, make([]byte, 31)); err == nil {
+		t.Fatalf("expanded a short seed")
+	}
+	bad := make([]byte, ExpandedPrivateKeySize)
+	if _, err := ParseExpandedPrivateKey(curve, bad); err == nil {
+		t.Fatalf("parsed a zero scalar")
+	}
+	bad[31] = 0x80
+	if _, err := ParseExpandedPrivateKey(curve, bad); err == nil {
+		t.Fatalf("parsed a scalar above 2^255")
+	}
+	if _, err := ParseExpandedPrivateKey(curve, bad[:63]); err == nil {
+		t.Fatalf("parsed a short expanded key")
+	}
+	if _, err := mockUpSecKeysByScalars(curve, 1)[0].Expand(); err == nil {
+		t.Fatalf("expanded a key without a seed")
+	}
+}
+
+// TestExpandedPrivateKeyRotatePrefix tests that rotating the nonce prefix
+// keeps the scalar and public key, that signatures still verify but differ
+// from the ones made before, and that failed reads and wiped keys leave the
+// prefix alone
+func TestExpandedPrivateKeyRotatePrefix(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := []byte("Hello World in TestRotatePrefix")
+
+	for i, priv := range mockUpSecKeysByBytes(curve, 3) {
+		k, err := priv.Expand()
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		scalar, prefix := k.Scalar(), k.Prefix()
+		pub := k.PublicKey()
+		before, err := k.Sign(msg)
+		if err != nil {
+			t.Fatalf("key %d: unexpected signing error: %v", i, err)
+		}
+
+		if err := k.RotatePrefix(crand.Reader); err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		if bytes.Equal(k.Prefix(), prefix) {
+			t.Fatalf("key %d: prefix didn't change", i)
+		}
+		if !bytes.Equal(k.Scalar(), scalar) {
+			t.Fatalf("key %d: scalar changed", i)
+		}
+		if !k.PublicKey().Equal(pub) || !pub.Equal(priv.PubKey()) {
+			t.Fatalf("key %d: public key changed", i)
+		}
+
+		after, err := k.Sign(msg)
+		if err != nil {
+			t.Fatalf("key %d: unexpected signing error: %v", i, err)
+		}
+		if !Verify(pub, msg, after.GetR(), after.GetS()) {
+			t.Fatalf("key %d: signature failed to verify after rotation", i)
+		}
+		if bytes.Equal(after.Serialize(), before.Serialize()) {
+			t.Fatalf("key %d: signature didn't change with the prefix", i)
+		}
+		again, err := k.Sign(msg)
+		if err != nil {
+			t.Fatalf("key %d: unexpected signing error: %v", i, err)
+		}
+		if !bytes.Equal(again.Serialize(), after.Serialize()) {
+			t.Fatalf("key %d: signing isn't deterministic after rotation", i)
+		}
+
+		// A rotated key roundtrips with its new prefix.
+		parsed, err := ParseExpandedPrivateKey(curve, k.Serialize())
+		if err != nil {
+			t.Fatalf("key %d: unexpected parsing error: %v", i, err)
+		}
+		if !bytes.Equal(parsed.Prefix(), k.Prefix()) {
+			t.Fatalf("key %d: rotated prefix lost in a roundtrip", i)
+		}
+
+		rotated := k.Prefix()
+		if err := k.RotatePrefix(bytes.NewReader(make([]byte, 31))); err ==
+			nil {
+			t.Fatalf("key %d: rotated the prefix from a short read", i)
+		}
+		if !bytes.Equal(k.Prefix(), rotated) {
+			t.Fatalf("key %d: failed rotation changed the prefix", i)
+		}
+
+		k.Wipe()
+		if err := k.RotatePrefix(crand.Reader); err != ErrWipedKey {
+			t.Fatalf("key %d: want %v, got %v", i, ErrWipedKey, err)
+		}
+	}
+}
@@ -0,0 +1,433 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// frostBindingTag is the domain separation tag for the FROST binding factors.
+var frostBindingTag = []byte("FROST-edwards25519-SHA512 rho")
+
+// FROSTShare is the Shamir share of the group secret held by a single
+// participant of a t-of-n FROST setup.
+type FROSTShare struct {
+	// Index is the non-zero evaluation point of the share.
+	Index uint32
+
+	// Threshold is the number of participants required to sign.
+	Threshold int
+
+	// Secret is the share of the group secret, p(Index).
+	Secret *PrivateKey
+
+	// PublicShare is Secret*G, used to verify the participant's partial
+	// signatures.
+	PublicShare *PublicKey
+
+	// GroupPub is the public key the combined signatures verify against.
+	GroupPub *PublicKey
+}
+
+// FROSTNonce holds the secret hiding and binding nonces of a participant for
+// a single signing round. It must never be used for more than one signature.
+type FROSTNonce struct {
+	Index   uint32
+	Hiding  *PrivateKey
+	Binding *PrivateKey
+}
+
+// FROSTCommitment is the public commitment to a FROSTNonce which is sent to
+// the other participants of a signing round.
+type FROSTCommitment struct {
+	Index   uint32
+	Hiding  *PublicKey
+	Binding *PublicKey
+}
+
+// FROSTPartialSig is a participant's share of a FROST signature.
+type FROSTPartialSig struct {
+	Index uint32
+	Z     *big.Int
+}
+
+// scalarToPrivKey wraps a non-zero scalar mod N in a PrivateKey.
+func scalarToPrivKey(curve *TwistedEdwardsCurve, k *big.Int) (*PrivateKey,
+	*PublicKey, error) {
+	kBytes := BigIntToEncodedBytesNoReverse(k)
+	defer zeroSlice(kBytes[:])
+	return PrivKeyFromScalar(curve, kBytes[:])
+}
+
+// GenerateSharesFROST splits a freshly generated group secret into n Shamir
+// shares, any t of which can produce a signature for the group public key.
+// The shares are the evaluations p(1), ..., p(n) of a random polynomial of
+// degree t-1 with p(0) being the group secret, which is never used again
+// after this returns.
+func GenerateSharesFROST(curve *TwistedEdwardsCurve, t, n int) ([]*FROSTShare,
+	*PublicKey, error) {
+	return generateSharesFROST(curve, rand.Reader, t, n)
+}
+
+// generateSharesFROST is the implementation of GenerateSharesFROST, taking
+// the source of randomness for the polynomial as an argument.
+func generateSharesFROST(curve *TwistedEdwardsCurve, rand io.Reader, t,
+	n int) ([]*FROSTShare, *PublicKey, error) {
+	if t < 1 || t > n {
+		return nil, nil, fmt.Errorf("invalid threshold %v for %v "+
+			"participants", t, n)
+	}
+	if uint64(n) > uint64(^uint32(0)) {
+		return nil, nil, fmt.Errorf("too many participants")
+	}
+
+	coefficients := make([]*big.Int, t)
+	defer func() {
+		for _, c := range coefficients {
+			if c != nil {
+				c.SetInt64(0)
+			}
+		}
+	}()
+	for i := range coefficients {
+		c, err := NewRandomScalar(curve, rand)
+		if err != nil {
+			return nil, nil, err
+		}
+		coefficients[i] = c
+	}
+
+	_, groupPub, err := scalarToPrivKey(curve, coefficients[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	shares := make([]*FROSTShare, n)
+	for i := range shares {
+		index := uint32(i + 1)
+		x := new(big.Int).SetUint64(uint64(index))
+
+		secret := evalPolynomial(coefficients, x)
+		if secret.Sign() == 0 {
+			return nil, nil, fmt.Errorf("share %v is zero", index)
+		}
+
+		priv, pub, err := scalarToPrivKey(curve, secret)
+		secret.SetInt64(0)
+		if err != nil {
+			return nil, nil, err
+		}
+		shares[i] = &FROSTShare{
+			Index:       index,
+			Threshold:   t,
+			Secret:      priv,
+			PublicShare: pub,
+			GroupPub:    groupPub,
+		}
+	}
+
+	return shares, groupPub, nil
+}
+
+// GenerateNoncesFROST generates the secret nonces of the participant holding
+// share for one signing round, along with the commitment to publish to the
+// other participants.
+func GenerateNoncesFROST(curve *TwistedEdwardsCurve,
+	share *FROSTShare) (*FROSTNonce, *FROSTCommitment, error) {
+	if share == nil {
+		return nil, nil, fmt.Errorf("nil share")
+	}
+
+	hiding, err := NewRandomScalar(curve, rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer hiding.SetInt64(0)
+	binding, err := NewRandomScalar(curve, rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer binding.SetInt64(0)
+
+	hidingPriv, hidingPub, err := scalarToPrivKey(curve, hiding)
+	if err != nil {
+		return nil, nil, err
+	}
+	bindingPriv, bindingPub, err := scalarToPrivKey(curve, binding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := &FROSTNonce{
+		Index:   share.Index,
+		Hiding:  hidingPriv,
+		Binding: bindingPriv,
+	}
+	commitment := &FROSTCommitment{
+		Index:   share.Index,
+		Hiding:  hidingPub,
+		Binding: bindingPub,
+	}
+	return nonce, commitment, nil
+}
+
+// checkCommitments makes sure the commitment list of a signing round is
+// well formed, that is non-empty, sorted by strictly increasing indices and
+// made of points on the curve.
+func checkCommitments(curve *TwistedEdwardsCurve,
+	commitments []*FROSTCommitment) error {
+	if len(commitments) == 0 {
+		return fmt.Errorf("no commitments")
+	}
+	for i, c := range commitments {
+		if c == nil || c.Hiding == nil || c.Binding == nil ||
+			c.Hiding.GetX() == nil || c.Binding.GetX() == nil {
+			return fmt.Errorf("commitment %v is nil", i)
+		}
+		if c.Index == 0 {
+			return fmt.Errorf("commitment %v has index zero", i)
+		}
+		if i > 0 && commitments[i-1].Index >= c.Index {
+			return fmt.Errorf("commitments are not sorted by unique index")
+		}
+		if !curve.IsOnCurve(c.Hiding.GetX(), c.Hiding.GetY()) ||
+			!curve.IsOnCurve(c.Binding.GetX(), c.Binding.GetY()) {
+			return fmt.Errorf("commitment %v is off curve", i)
+		}
+	}
+
+	return nil
+}
+
+// frostBindingFactors computes the binding factor of every participant,
+// rho_i = hash512(tag || i || M || B) mod N, where B is the encoded list of
+// all the commitments of the round.
+func frostBindingFactors(msg []byte,
+	commitments []*FROSTCommitment) []*big.Int {
+	var encoded []byte
+	var index [4]byte
+	for _, c := range commitments {
+		binary.BigEndian.PutUint32(index[:], c.Index)
+		encoded = append(encoded, index[:]...)
+		encoded = append(encoded, c.Hiding.Serialize()...)
+		encoded = append(encoded, c.Binding.Serialize()...)
+	}
+
+	factors := make([]*big.Int, len(commitments))
+	for i, c := range commitments {
+		binary.BigEndian.PutUint32(index[:], c.Index)
+
+		var digest [64]byte
+		h := sha512.New()
+		h.Write(frostBindingTag)
+		h.Write(index[:])
+		h.Write(msg)
+		h.Write(encoded)
+		h.Sum(digest[:0])
+		var digestReduced [32]byte
+		edwards25519.ScReduce(&digestReduced, &digest)
+		factors[i] = EncodedBytesToBigInt(&digestReduced)
+	}
+
+	return factors
+}
+
+// frostGroupCommitment computes the commitments D_i + rho_i*E_i of every
+// participant along with their sum R, the nonce of the signature.
+func frostGroupCommitment(curve *TwistedEdwardsCurve,
+	commitments []*FROSTCommitment, factors []*big.Int) ([]*PublicKey,
+	*PublicKey) {
+	shares := make([]*PublicKey, len(commitments))
+	var rX, rY *big.Int
+	for i, c := range commitments {
+		x, y := curve.ScalarMult(c.Binding.GetX(), c.Binding.GetY(),
+			factors[i].Bytes())
+		x, y = curve.Add(c.Hiding.GetX(), c.Hiding.GetY(), x, y)
+		shares[i] = NewPublicKey(curve, x, y)
+		if i == 0 {
+			rX, rY = x, y
+			continue
+		}
+		rX, rY = curve.Add(rX, rY, x, y)
+	}
+
+	return shares, NewPublicKey(curve, rX, rY)
+}
+
+// frostChallenge computes the Ed25519 challenge hash512(R || A || M) mod N.
+func frostChallenge(groupPub *PublicKey, groupR *PublicKey,
+	msg []byte) *big.Int {
+	var hramDigest [64]byte
+	h := sha512.New()
+	h.Write(groupR.Serialize())
+	h.Write(groupPub.Serialize())
+	h.Write(msg)
+	h.Sum(hramDigest[:0])
+	var hramDigestReduced [32]byte
+	edwards25519.ScReduce(&hramDigestReduced, &hramDigest)
+
+	return EncodedBytesToBigInt(&hramDigestReduced)
+}
+
+// lagrangeCoefficient computes the Lagrange coefficient at zero of the
+// participant index over the participating subset given by commitments.
+func lagrangeCoefficient(index uint32,
+	commitments []*FROSTCommitment) (*big.Int, error) {
+	indices := make([]*big.Int, len(commitments))
+	for i, c := range commitments {
+		indices[i] = new(big.Int).SetUint64(uint64(c.Index))
+	}
+
+	return LagrangeCoefficient(indices,
+		new(big.Int).SetUint64(uint64(index)))
+}
+
+// commitmentIndex returns the position of the commitment of the participant
+// index in commitments, or -1 if it isn't participating.
+func commitmentIndex(index uint32, commitments []*FROSTCommitment) int {
+	for i, c := range commitments {
+		if c.Index == index {
+			return i
+		}
+	}
+	return -1
+}
+
+// SignFROST creates the partial signature of msg for the participant holding
+// share, using the nonces it committed to in this round. The commitments of
+// all the participants of the round, which must be at least the threshold of
+// the share, are passed sorted by index. The partial signature is
+// z_i = d_i + e_i*rho_i + lambda_i*s_i*c, where lambda_i is the Lagrange
+// coefficient of the participant over the signing subset. The nonce is wiped
+// once used, so it can't be reused by accident.
+func SignFROST(curve *TwistedEdwardsCurve, share *FROSTShare,
+	nonce *FROSTNonce, msg []byte,
+	commitments []*FROSTCommitment) (*FROSTPartialSig, error) {
+	if share == nil || share.Secret == nil || share.GroupPub == nil ||
+		nonce == nil || nonce.Hiding == nil || nonce.Binding == nil ||
+		msg == nil {
+		return nil, fmt.Errorf("nil input")
+	}
+	for _, k := range []*PrivateKey{share.Secret, nonce.Hiding,
+		nonce.Binding} {
+		if err := k.signingErr(); err != nil {
+			return nil, err
+		}
+	}
+	if nonce.Index != share.Index {
+		return nil, fmt.Errorf("nonce does not belong to share %v",
+			share.Index)
+	}
+	if err := checkCommitments(curve, commitments); err != nil {
+		return nil, err
+	}
+	if len(commitments) < share.Threshold {
+		return nil, fmt.Errorf("not enough participants (got %v, want %v)",
+			len(commitments), share.Threshold)
+	}
+	pos := commitmentIndex(share.Index, commitments)
+	if pos < 0 {
+		return nil, fmt.Errorf("share %v is not participating", share.Index)
+	}
+
+	// Make sure the commitment in the list really is ours.
+	own := commitments[pos]
+	if !nonce.Hiding.PubKey().ConstantTimeEqual(own.Hiding) ||
+		!nonce.Binding.PubKey().ConstantTimeEqual(own.Binding) {
+		return nil, fmt.Errorf("commitment does not match the nonce")
+	}
+
+	factors := frostBindingFactors(msg, commitments)
+	_, groupR := frostGroupCommitment(curve, commitments, factors)
+	c := frostChallenge(share.GroupPub, groupR, msg)
+	lambda, err := lagrangeCoefficient(share.Index, commitments)
+	if err != nil {
+		return nil, err
+	}
+
+	lc := new(big.Int).Mul(lambda, c)
+	lc.Mod(lc, curve.N)
+	nonces := scalarMulAdd(nonce.Binding.GetD(), factors[pos],
+		nonce.Hiding.GetD())
+	z := scalarMulAdd(lc, share.Secret.GetD(), nonces)
+	nonces.SetInt64(0)
+
+	nonce.Hiding.Wipe()
+	nonce.Binding.Wipe()
+
+	return &FROSTPartialSig{Index: share.Index, Z: z}, nil
+}
+
+// CombineFROST verifies the partial signatures of a signing round and combines
+// them into a signature of msg valid for groupPub. The public shares of the
+// participants are passed in the same order as their sorted commitments, and
+// each partial signature is checked with the participant's Lagrange
+// coefficient over the signing subset,
+// z_i*G = D_i + rho_i*E_i + lambda_i*c*Y_i, so a misbehaving signer is caught
+// before it can spoil the signature.
+func CombineFROST(curve *TwistedEdwardsCurve, groupPub *PublicKey, msg []byte,
+	commitments []*FROSTCommitment, publicShares []*PublicKey,
+	partials []*FROSTPartialSig) (*Signature, error) {
+	if groupPub == nil || msg == nil {
+		return nil, fmt.Errorf("nil input")
+	}
+	if err := checkCommitments(curve, commitments); err != nil {
+		return nil, err
+	}
+	if len(publicShares) != len(commitments) ||
+		len(partials) != len(commitments) {
+		return nil, fmt.Errorf("mismatched number of commitments, public " +
+			"shares and partial signatures")
+	}
+
+	factors := frostBindingFactors(msg, commitments)
+	nonceShares, groupR := frostGroupCommitment(curve, commitments, factors)
+	c := frostChallenge(groupPub, groupR, msg)
+
+	z := new(big.Int)
+	for i, partial := range partials {
+		if partial == nil || partial.Z == nil || publicShares[i] == nil {
+			return nil, fmt.Errorf("partial signature %v is nil", i)
+		}
+		if partial.Index != commitments[i].Index {
+			return nil, fmt.Errorf("partial signature %v is out of order", i)
+		}
+		if partial.Z.Sign() < 0 || partial.Z.Cmp(curve.N) >= 0 {
+			return nil, fmt.Errorf("partial signature %v is out of bounds",
+				partial.Index)
+		}
+
+		lambda, err := lagrangeCoefficient(partial.Index, commitments)
+		if err != nil {
+			return nil, err
+		}
+		lc := new(big.Int).Mul(lambda, c)
+		lc.Mod(lc, curve.N)
+
+		lX, lY := curve.ScalarBaseMult(partial.Z.Bytes())
+		yX, yY := curve.ScalarMult(publicShares[i].GetX(),
+			publicShares[i].GetY(), lc.Bytes())
+		rX, rY := curve.Add(nonceShares[i].GetX(), nonceShares[i].GetY(),
+			yX, yY)
+		if lX.Cmp(rX) != 0 || lY.Cmp(rY) != 0 {
+			return nil, fmt.Errorf("partial signature %v is invalid",
+				partial.Index)
+		}
+
+		z.Add(z, partial.Z)
+		z.Mod(z, curve.N)
+	}
+
+	r := EncodedBytesToBigInt(BigIntPointToEncodedBytes(groupR.GetX(),
+		groupR.GetY()))
+	return NewSignature(r, z), nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// frostSign runs a complete FROST signing round with the given subset of
+// shares, returning the combined signature.
+func frostSign(t *testing.T, curve *TwistedEdwardsCurve,
+	shares []*FROSTShare, msg []byte) *Signature {
+	nonces := make([]*FROSTNonce, len(shares))
+	commitments := make([]*FROSTCommitment, len(shares))
+	publicShares := make([]*PublicKey, len(shares))
+	for i, share := range shares {
+		var err error
+		nonces[i], commitments[i], err = GenerateNoncesFROST(curve, share)
+		if err != nil {
+			t.Fatalf("unexpected nonce generation error: %v", err)
+		}
+		publicShares[i] = share.PublicShare
+	}
+
+	partials := make([]*FROSTPartialSig, len(shares))
+	for i, share := range shares {
+		var err error
+		partials[i], err = SignFROST(curve, share, nonces[i], msg,
+			commitments)
+		if err != nil {
+			t.Fatalf("unexpected partial signing error: %v", err)
+		}
+	}
+
+	sig, err := CombineFROST(curve, shares[0].GroupPub, msg, commitments,
+		publicShares, partials)
+	if err != nil {
+		t.Fatalf("unexpected combining error: %v", err)
+	}
+	return sig
+}
+
+// TestFROST tests t-of-n signing with various subsets of participants
+func TestFROST(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+	msg := []byte("Hello World in TestFROST")
+
+	tests := []struct {
+		t, n    int
+		subsets [][]int
+	}{
+		{2, 3, [][]int{{0, 1}, {0, 2}, {1, 2}, {0, 1, 2}}},
+		{3, 5, [][]int{{0, 1, 2}, {0, 2, 4}, {1, 3, 4}, {0, 1, 2, 3, 4}}},
+	}
+
+	for _, test := range tests {
+		shares, groupPub, err := generateSharesFROST(curve, r, test.t,
+			test.n)
+		if err != nil {
+			t.Fatalf("unexpected share generation error: %v", err)
+		}
+		if len(shares) != test.n {
+			t.Fatalf("want %v shares, got %v", test.n, len(shares))
+		}
+
+		for _, subset := range test.subsets {
+			signers := make([]*FROSTShare, len(subset))
+			for i, idx := range subset {
+				signers[i] = shares[idx]
+			}
+
+			sig := frostSign(t, curve, signers, msg)
+			if !Verify(groupPub, msg, sig.GetR(), sig.GetS()) {
+				t.Fatalf("%v-of-%v signature by %v failed to verify",
+					test.t, test.n, subset)
+			}
+		}
+
+		// Fewer participants than the threshold can't sign.
+		nonce, commitment, err := GenerateNoncesFROST(curve, shares[0])
+		if err != nil {
+			t.Fatalf("unexpected nonce generation error: %v", err)
+		}
+		_, err = SignFROST(curve, shares[0], nonce, msg,
+			[]*FROSTCommitment{commitment})
+		if err == nil {
+			t.Fatalf("signed with fewer participants than the threshold")
+		}
+	}
+}
+
+// TestFROSTBadPartial tests that partial signatures which do not verify
+// against the public share of their signer are rejected when combining
+func TestFROSTBadPartial(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+	msg := []byte("Hello World in TestFROSTBadPartial")
+
+	shares, _, err := generateSharesFROST(curve, r, 2, 3)
+	if err != nil {
+		t.Fatalf("unexpected share generation error: %v", err)
+	}
+	signers := shares[1:]
+
+	nonces := make([]*FROSTNonce, len(signers))
+	commitments := make([]*FROSTCommitment, len(signers))
+	publicShares := make([]*PublicKey, len(signers))
+	partials := make([]*FROSTPartialSig, len(signers))
+	for i, share := range signers {
+		nonces[i], commitments[i], err = GenerateNoncesFROST(curve, share)
+		if err != nil {
+			t.Fatalf("unexpected nonce generation error: %v", err)
+		}
+		publicShares[i] = share.PublicShare
+	}
+	for i, share := range signers {
+		partials[i], err = SignFROST(curve, share, nonces[i], msg,
+			commitments)
+		if err != nil {
+			t.Fatalf("unexpected partial signing error: %v", err)
+		}
+	}
+
+	// Nonces are single use.
+	_, err = SignFROST(curve, signers[0], nonces[0], msg, commitments)
+	if err != ErrWipedKey {
+		t.Fatalf("want ErrWipedKey reusing a nonce, got %v", err)
+	}
+
+	partials[1].Z = new(big.Int).Add(partials[1].Z, big.NewInt(1))
+	_, err = CombineFROST(curve, signers[0].GroupPub, msg, commitments,
+		publicShares, partials)
+	if err == nil {
+		t.Fatalf("combined an invalid partial signature")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// fuzzSeeds returns the seed corpus shared by the parser fuzz targets: the
+// valid encoding b of correct size, truncated and oversized copies of it,
+// and inputs of every interesting size filled with zeros and with 0xff.
+func fuzzSeeds(b []byte, size int) [][]byte {
+	seeds := [][]byte{nil, {}, b, b[:1], b[:size-1], append(b, 0x00),
+		append(b, b...)}
+	for _, n := range []int{1, size - 1, size, size + 1, 2 * size} {
+		seeds = append(seeds, make([]byte, n), bytes.Repeat([]byte{0xff}, n))
+	}
+	return seeds
+}
+
+// FuzzParseSignature checks that ParseSignature never panics on arbitrary
+// input for either curve, and that a parsed signature serializes back to
+// its input
+func FuzzParseSignature(f *testing.F) {
+	sig, _ := hex.DecodeString(
+		"e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e06522490155" +
+			"5fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b")
+	for _, seed := range fuzzSeeds(sig, SignatureSize) {
+		f.Add(seed)
+	}
+	for _, seed := range fuzzSeeds(make([]byte, Ed448SignatureSize),
+		Ed448SignatureSize) {
+		f.Add(seed)
+	}
+
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	ed448 := new(TwistedEdwardsCurve)
+	ed448.InitParamEd448()
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if parsed, err := ParseSignature(curve, b); err == nil {
+			if !bytes.Equal(parsed.Serialize(), b) {
+				t.Fatalf("signature %x serialized to %x", b,
+					parsed.Serialize())
+			}
+		}
+		if parsed, err := ParseSignature(ed448, b); err == nil {
+			if !bytes.Equal(parsed.SerializeEd448(), b) {
+				t.Fatalf("Ed448 signature %x serialized to %x", b,
+					parsed.SerializeEd448())
+			}
+		}
+	})
+}
+
+// FuzzParsePubKey checks that ParsePubKey never panics on arbitrary input
+// for either curve, and that a parsed public key survives a serialization
+// roundtrip
+func FuzzParsePubKey(f *testing.F) {
+	pub, _ := hex.DecodeString(
+		"d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")
+	for _, seed := range fuzzSeeds(pub, PubKeyBytesLen) {
+		f.Add(seed)
+	}
+	for _, str := range smallOrderPoints {
+		b, _ := hex.DecodeString(str)
+		f.Add(b)
+	}
+	for _, seed := range fuzzSeeds(make([]byte, Ed448PubKeyBytesLen),
+		Ed448PubKeyBytesLen) {
+		f.Add(seed)
+	}
+
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	ed448 := new(TwistedEdwardsCurve)
+	ed448.InitParamEd448()
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, c := range []*TwistedEdwardsCurve{curve, ed448} {
+			parsed, err := ParsePubKey(c, b)
+			if err != nil {
+				continue
+			}
+			again, err := ParsePubKey(c, parsed.Serialize())
+			if err != nil {
+				t.Fatalf("serialization %x of key %x failed to parse: %v",
+					parsed.Serialize(), b, err)
+			}
+			if !parsed.Equal(again) {
+				t.Fatalf("key %x changed in a roundtrip", b)
+			}
+		}
+	})
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// VerifyItem is a signature along with the public key and the message it
+// signs, one of the independent signatures combined by HalfAggregate.
+type VerifyItem struct {
+	PubKey *PublicKey
+	Msg    []byte
+	Sig    *Signature
+}
+
+// HalfAggSig is a half-aggregated signature, which combines the Ed25519
+// signatures (R_i, s_i) of any number of independent keys and messages into
+// their R values and a single scalar s. It's about half the size of the
+// signatures it replaces.
+type HalfAggSig struct {
+	R []*big.Int
+	S *big.Int
+}
+
+// halfAggListTag is the domain separation tag of the commitment to the
+// signatures, keys and messages of a half-aggregated signature.
+const halfAggListTag = "edwards25519 half aggregation list"
+
+// halfAggCoefficientTag is the domain separation tag of the coefficients of
+// half aggregation.
+const halfAggCoefficientTag = "edwards25519 half aggregation coefficient"
+
+// halfAggCoefficients computes the coefficients z_i = taggedHash(tag, L ||
+// i) mod N of a half-aggregated signature, where L = taggedHash(tag, R_1 ||
+// pk_1 || len(m_1) || m_1 || ... || R_n || pk_n || len(m_n) || m_n)
+// commits to every signature, key and message, so no R can be chosen after
+// the coefficients are known.
+func halfAggCoefficients(encodedRs []*[32]byte, pubs []*PublicKey,
+	msgs [][]byte) []*big.Int {
+	data := make([][]byte, 0, 4*len(pubs))
+	for i := range pubs {
+		size := make([]byte, 4)
+		binary.BigEndian.PutUint32(size, uint32(len(msgs[i])))
+		data = append(data, encodedRs[i][:], pubs[i].Serialize(), size,
+			msgs[i])
+	}
+	l := taggedHash(halfAggListTag, data...)
+
+	var index [4]byte
+	zs := make([]*big.Int, len(pubs))
+	for i := range zs {
+		binary.BigEndian.PutUint32(index[:], uint32(i))
+		zs[i] = EncodedBytesToBigInt(taggedScalar(halfAggCoefficientTag, l,
+			index[:]))
+	}
+
+	return zs
+}
+
+// HalfAggregate combines the signatures of items, each by its own key over
+// its own message, into a half-aggregated signature with s = sum z_i * s_i,
+// for VerifyHalfAggregate to check all of them at once. Unlike an n-of-n
+// multisignature the signers don't interact, so signatures can be
+// aggregated by anyone, such as a block producer. The signatures are only
+// checked to be canonical and to have R on the curve, not verified, so the
+// aggregate of an invalid signature fails to verify. At most MaxSigners
+// signatures can be aggregated.
+func HalfAggregate(items []VerifyItem) (*HalfAggSig, error) {
+	return HalfAggregateWithMaxSigners(items, MaxSigners)
+}
+
+// HalfAggregateWithMaxSigners is HalfAggregate with a limit of maxSigners
+// signatures instead of MaxSigners.
+func HalfAggregateWithMaxSigners(items []VerifyItem,
+	maxSigners int) (*HalfAggSig, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no signatures to aggregate")
+	}
+	if len(items) > maxSigners {
+		return nil, ErrTooManySigners
+	}
+
+	pubs := make([]*PublicKey, len(items))
+	msgs := make([][]byte, len(items))
+	for i, item := range items {
+		pubs[i], msgs[i] = item.PubKey, item.Msg
+	}
+	if err := checkAggregateInput(pubs, msgs); err != nil {
+		return nil, err
+	}
+	curve, ok := pubs[0].Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil || curve.isEd448() {
+		return nil, fmt.Errorf("half aggregation is only supported on " +
+			"Ed25519")
+	}
+
+	encodedRs := make([]*[32]byte, len(items))
+	for i, item := range items {
+		sig := item.Sig
+		if sig == nil || sig.GetR() == nil || sig.GetS() == nil {
+			return nil, fmt.Errorf("signature %v is nil", i)
+		}
+		if !IsCanonical(sig) {
+			return nil, fmt.Errorf("signature %v is not canonical", i)
+		}
+		encodedRs[i] = BigIntToEncodedBytes(sig.GetR())
+		var r edwards25519.ExtendedGroupElement
+		if !r.FromBytes(encodedRs[i]) {
+			return nil, fmt.Errorf("R of signature %v is not on the curve", i)
+		}
+	}
+
+	zs := halfAggCoefficients(encodedRs, pubs, msgs)
+	rs := make([]*big.Int, len(items))
+	s := new(big.Int)
+	for i, item := range items {
+		rs[i] = new(big.Int).Set(item.Sig.GetR())
+		s = scalarMulAdd(zs[i], item.Sig.GetS(), s)
+	}
+
+	return &HalfAggSig{rs, s}, nil
+}
+
+// VerifyHalfAggregate verifies the half-aggregated signature agg of the
+// messages msgs by the public keys pubs at the same indices, checking
+// sG = sum z_i * R_i + sum z_i * h_i * pk_i with h_i = hash512(R_i || pk_i ||
+// m_i) with a single multiscalar multiplication. Except with negligible
+// probability, it accepts exactly when every signature would verify on its
+// own for R values in the prime order subgroup. Unlike with Verify, R
+// values of small order aren't rejected, and the result for R values with
+// a small order component may depend on the coefficients. Aggregates of
+// more than MaxSigners signatures are rejected.
+func VerifyHalfAggregate(curve *TwistedEdwardsCurve, pubs []*PublicKey,
+	msgs [][]byte, agg *HalfAggSig) bool {
+	return VerifyHalfAggregateWithMaxSigners(curve, pubs, msgs, agg,
+		MaxSigners)
+}
+
+// VerifyHalfAggregateWithMaxSigners is VerifyHalfAggregate with a limit of
+// maxSigners signatures instead of MaxSigners.
+func VerifyHalfAggregateWithMaxSigners(curve *TwistedEdwardsCurve,
+	pubs []*PublicKey, msgs [][]byte, agg *HalfAggSig, maxSigners int) bool {
+	if agg == nil || agg.S == nil || len(agg.R) != len(pubs) ||
+		len(pubs) > maxSigners || curve == nil || curve.isEd448() {
+		return false
+	}
+	if checkAggregateInput(pubs, msgs) != nil {
+		return false
+	}
+	if agg.S.Sign() < 0 || agg.S.Cmp(curve.N) >= 0 {
+		return false
+	}
+
+	encodedRs := make([]*[32]byte, len(pubs))
+	scalars := make([]*[32]byte, 0, 2*len(pubs)+1)
+	points := make([]*edwards25519.ExtendedGroupElement, 0, 2*len(pubs)+1)
+	for i := range pubs {
+		if agg.R[i] == nil || agg.R[i].Sign() < 0 || agg.R[i].BitLen() > 256 {
+			return false
+		}
+		encodedRs[i] = BigIntToEncodedBytes(agg.R[i])
+	}
+	zs := halfAggCoefficients(encodedRs, pubs, msgs)
+
+	// Compute sG - sum z_i * R_i - sum z_i * h_i * pk_i, which must be the
+	// identity.
+	var g edwards25519.ExtendedGroupElement
+	g.FromBytes(BigIntPointToEncodedBytes(curve.Gx, curve.Gy))
+	scalars = append(scalars, BigIntToEncodedBytes(agg.S))
+	points = append(points, &g)
+	for i := range pubs {
+		r := new(edwards25519.ExtendedGroupElement)
+		a := new(edwards25519.ExtendedGroupElement)
+		encodedA := BigIntPointToEncodedBytes(pubs[i].GetX(), pubs[i].GetY())
+		if !r.FromBytes(encodedRs[i]) || !a.FromBytes(encodedA) {
+			return false
+		}
+
+		// h_i = hash512(R_i || A_i || M_i)
+		var hramDigest [64]byte
+		h := sha512.New()
+		h.Write(encodedRs[i][:])
+		h.Write(encodedA[:])
+		h.Write(msgs[i])
+		h.Sum(hramDigest[:0])
+		var hramDigestReduced [32]byte
+		edwards25519.ScReduce(&hramDigestReduced, &hramDigest)
+		hram := EncodedBytesToBigInt(&hramDigestReduced)
+
+		zh := new(big.Int).Mul(zs[i], hram)
+		zh.Mod(zh, curve.N)
+		scalars = append(scalars,
+			BigIntToEncodedBytes(new(big.Int).Sub(curve.N, zs[i])),
+			BigIntToEncodedBytes(new(big.Int).Sub(curve.N, zh)))
+		points = append(points, r, a)
+	}
+
+	var check edwards25519.ExtendedGroupElement
+	multiScalarMultVartime(&check, scalars, points)
+	return geIsIdentity(&check)
+}
+
+// Serialize returns the half-aggregated signature as the 32 byte encoded R
+// values followed by the 32 byte little endian s.
+func (agg HalfAggSig) Serialize() []byte {
+	b := make([]byte, 0, PubKeyBytesLen*len(agg.R)+PrivScalarSize)
+	for _, r := range agg.R {
+		b = append(b, BigIntToEncodedBytes(r)[:]...)
+	}
+	return append(b, BigIntToEncodedBytes(agg.S)[:]...)
+}
+
+// ParseHalfAggSig parses a half-aggregated signature serialized with
+// HalfAggSig.Serialize. Every R must be a point on the curve and s must be
+// below N. Aggregates of more than MaxSigners signatures are rejected.
+func ParseHalfAggSig(curve *TwistedEdwardsCurve, b []byte) (*HalfAggSig,
+	error) {
+	return ParseHalfAggSigWithMaxSigners(curve, b, MaxSigners)
+}
+
+// ParseHalfAggSigWithMaxSigners is ParseHalfAggSig with a limit of
+// maxSigners signatures instead of MaxSigners.
+func ParseHalfAggSigWithMaxSigners(curve *TwistedEdwardsCurve, b []byte,
+	maxSigners int) (*HalfAggSig, error) {
+	if len(b) < PubKeyBytesLen+PrivScalarSize ||
+		len(b)%PubKeyBytesLen != 0 {
+		return nil, fmt.Errorf("bad half-aggregated signature size %v",
+			len(b))
+	}
+	numSigs := len(b)/PubKeyBytesLen - 1
+	if numSigs > maxSigners {
+		return nil, ErrTooManySigners
+	}
+
+	rs := make([]*big.Int, numSigs)
+	for i := range rs {
+		encodedR := copyBytes(b[i*PubKeyBytesLen : (i+1)*PubKeyBytesLen])
+		if _, _, err := curve.EncodedBytesToBigIntPoint(encodedR); err != nil {
+			return nil, err
+		}
+		rs[i] = EncodedBytesToBigInt(encodedR)
+	}
+	s, err := ScalarFromBytesLE(b[numSigs*PubKeyBytesLen:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &HalfAggSig{rs, s}, nil
+}
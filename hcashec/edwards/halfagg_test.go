@@ -0,0 +1,154 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"math/big"
+	"testing"
+)
+
+// mockUpVerifyItems signs a different message of a different length with
+// each of n keys, half of them derived from a secret and half from a scalar.
+func mockUpVerifyItems(t *testing.T, curve *TwistedEdwardsCurve,
+	n int) []VerifyItem {
+	privs := append(mockUpSecKeysByBytes(curve, n/2),
+		mockUpSecKeysByScalars(curve, n-n/2)...)
+
+	items := make([]VerifyItem, n)
+	for i, priv := range privs {
+		msg := make([]byte, i*7)
+		for j := range msg {
+			msg[j] = byte(i + j)
+		}
+		r, s, err := Sign(curve, priv, msg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		items[i] = VerifyItem{priv.PubKey(), msg, NewSignature(r, s)}
+	}
+
+	return items
+}
+
+// splitVerifyItems returns the public keys and messages of items.
+func splitVerifyItems(items []VerifyItem) ([]*PublicKey, [][]byte) {
+	pubs := make([]*PublicKey, len(items))
+	msgs := make([][]byte, len(items))
+	for i, item := range items {
+		pubs[i], msgs[i] = item.PubKey, item.Msg
+	}
+	return pubs, msgs
+}
+
+// TestHalfAggregate tests that half-aggregated signatures of different keys
+// over different messages verify and survive a serialization roundtrip, and
+// that they don't verify for other keys, messages or signatures
+func TestHalfAggregate(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	for _, n := range []int{1, 2, 7} {
+		items := mockUpVerifyItems(t, curve, n)
+		pubs, msgs := splitVerifyItems(items)
+
+		agg, err := HalfAggregate(items)
+		if err != nil {
+			t.Fatalf("%d signatures: unexpected error: %v", n, err)
+		}
+		if !VerifyHalfAggregate(curve, pubs, msgs, agg) {
+			t.Fatalf("%d signatures: failed to verify", n)
+		}
+		if len(agg.Serialize()) != 32*(n+1) {
+			t.Fatalf("%d signatures: serialized to %d bytes", n,
+				len(agg.Serialize()))
+		}
+		parsed, err := ParseHalfAggSig(curve, agg.Serialize())
+		if err != nil {
+			t.Fatalf("%d signatures: unexpected error: %v", n, err)
+		}
+		if !VerifyHalfAggregate(curve, pubs, msgs, parsed) {
+			t.Fatalf("%d signatures: failed to verify after a roundtrip", n)
+		}
+
+		badS := &HalfAggSig{agg.R, new(big.Int).Add(agg.S, one)}
+		if VerifyHalfAggregate(curve, pubs, msgs, badS) {
+			t.Fatalf("%d signatures: verified with a changed s", n)
+		}
+		if VerifyHalfAggregate(curve, pubs[:n-1], msgs[:n-1], agg) {
+			t.Fatalf("%d signatures: verified with a signature missing", n)
+		}
+		badMsgs := append([][]byte{}, msgs...)
+		badMsgs[n-1] = append([]byte{0x01}, msgs[n-1]...)
+		if VerifyHalfAggregate(curve, pubs, badMsgs, agg) {
+			t.Fatalf("%d signatures: verified for another message", n)
+		}
+		badPubs := append([]*PublicKey{}, pubs...)
+		badPubs[0] = mockUpSecKeysByScalars(curve, n+1)[n].PubKey()
+		if VerifyHalfAggregate(curve, badPubs, msgs, agg) {
+			t.Fatalf("%d signatures: verified for another key", n)
+		}
+		if n > 1 {
+			swapped := append([]VerifyItem{}, items...)
+			swapped[0].Sig, swapped[1].Sig = items[1].Sig, items[0].Sig
+			bad, err := HalfAggregate(swapped)
+			if err != nil {
+				t.Fatalf("%d signatures: unexpected error: %v", n, err)
+			}
+			if VerifyHalfAggregate(curve, pubs, msgs, bad) {
+				t.Fatalf("%d signatures: verified with swapped signatures",
+					n)
+			}
+			badR := &HalfAggSig{append([]*big.Int{agg.R[1]}, agg.R[1:]...),
+				agg.S}
+			if VerifyHalfAggregate(curve, pubs, msgs, badR) {
+				t.Fatalf("%d signatures: verified with a changed R", n)
+			}
+		}
+	}
+
+	items := mockUpVerifyItems(t, curve, 3)
+	if _, err := HalfAggregate(nil); err == nil {
+		t.Fatalf("aggregated no signatures")
+	}
+	nonCanonical := append([]VerifyItem{}, items...)
+	nonCanonical[0].Sig = NewSignature(items[0].Sig.R,
+		new(big.Int).Add(items[0].Sig.S, curve.N))
+	if _, err := HalfAggregate(nonCanonical); err == nil {
+		t.Fatalf("aggregated a non-canonical signature")
+	}
+	missing := append([]VerifyItem{}, items...)
+	missing[1].Sig = nil
+	if _, err := HalfAggregate(missing); err == nil {
+		t.Fatalf("aggregated a nil signature")
+	}
+
+	agg, _ := HalfAggregate(items)
+	b := agg.Serialize()
+	for _, bad := range [][]byte{nil, b[:32], b[:len(b)-1],
+		append(append([]byte{}, b[:len(b)-32]...),
+			BigIntToEncodedBytes(curve.N)[:]...)} {
+		if _, err := ParseHalfAggSig(curve, bad); err == nil {
+			t.Fatalf("parsed a bad half-aggregated signature %x", bad)
+		}
+	}
+
+	// Lower limits reject the aggregate of three signatures.
+	pubs, msgs := splitVerifyItems(items)
+	if _, err := HalfAggregateWithMaxSigners(items, 2); err != ErrTooManySigners {
+		t.Fatalf("want %v aggregating above the limit, got %v",
+			ErrTooManySigners, err)
+	}
+	if _, err := ParseHalfAggSigWithMaxSigners(curve, b,
+		2); err != ErrTooManySigners {
+		t.Fatalf("want %v parsing above the limit, got %v",
+			ErrTooManySigners, err)
+	}
+	if VerifyHalfAggregateWithMaxSigners(curve, pubs, msgs, agg, 2) {
+		t.Fatalf("verified above the limit")
+	}
+	if !VerifyHalfAggregateWithMaxSigners(curve, pubs, msgs, agg, 3) {
+		t.Fatalf("failed to verify at the limit")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// hashToPointTag is the domain separation tag of hashToPoint, so that its
+// outputs are unrelated to any other use of SHA512 over the same data.
+const hashToPointTag = "edwards25519 hash to point"
+
+// clearCofactor sets p to [8]p, its component in the prime order subgroup
+// scaled by the cofactor.
+func clearCofactor(p *edwards25519.ExtendedGroupElement) {
+	for i := 0; i < 3; i++ {
+		var c edwards25519.CompletedGroupElement
+		p.Double(&c)
+		c.ToExtended(p)
+	}
+}
+
+// hashToPoint deterministically maps data to a point of the prime order
+// subgroup whose discrete log is unknown, by hashing the data with an
+// incrementing counter until the digest decodes to a point and multiplying
+// that point by the cofactor (try-and-increment). About half of the digests
+// decode, so this is variable time and must only be used with public data.
+func hashToPoint(data []byte) *edwards25519.ExtendedGroupElement {
+	var counter [4]byte
+	for ctr := uint32(0); ; ctr++ {
+		binary.BigEndian.PutUint32(counter[:], ctr)
+		var s [32]byte
+		copy(s[:], taggedHash(hashToPointTag, data, counter[:]))
+		q := new(edwards25519.ExtendedGroupElement)
+		if !q.FromBytes(&s) {
+			continue
+		}
+		clearCofactor(q)
+		if geIsIdentity(q) {
+			continue
+		}
+
+		return q
+	}
+}
+
+// HashToPoint deterministically maps arbitrary data to a point of the prime
+// order subgroup, such that nobody knows its discrete log with respect to
+// the base point. It's used for the key images of ring signatures and is
+// suitable for commitment schemes needing independent generators. The data
+// is public, since the map is variable time.
+func HashToPoint(curve *TwistedEdwardsCurve, data []byte) (x, y *big.Int) {
+	var s [32]byte
+	hashToPoint(data).ToBytes(&s)
+
+	x, y, err := curve.EncodedBytesToBigIntPoint(&s)
+	if err != nil {
+		return nil, nil
+	}
+
+	return x, y
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// TestHashToPoint tests that hashing to the curve is deterministic and
+// always gives distinct points of the prime order subgroup
+func TestHashToPoint(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	seen := make(map[string]int)
+	for i := 0; i < 64; i++ {
+		data := []byte(fmt.Sprintf("input %v", i))
+		if i == 0 {
+			data = nil
+		}
+
+		x, y := HashToPoint(curve, data)
+		if x == nil || y == nil {
+			t.Fatalf("input %v: no point", i)
+		}
+		if !curve.IsOnCurve(x, y) {
+			t.Fatalf("input %v: point is off curve", i)
+		}
+		var p edwards25519.ExtendedGroupElement
+		encoded := BigIntPointToEncodedBytes(x, y)
+		if !p.FromBytes(encoded) || !curve.isPrimeOrderPoint(&p) {
+			t.Fatalf("input %v: point %x is not in the prime order "+
+				"subgroup", i, encoded[:])
+		}
+
+		x2, y2 := HashToPoint(curve, data)
+		if x.Cmp(x2) != 0 || y.Cmp(y2) != 0 {
+			t.Fatalf("input %v: hashing is not deterministic", i)
+		}
+
+		if j, ok := seen[string(encoded[:])]; ok {
+			t.Fatalf("inputs %v and %v map to the same point", j, i)
+		}
+		seen[string(encoded[:])] = i
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// EdwardsToMontgomery converts the point (x, y) on the Ed25519 curve to the
+// u-coordinate of the corresponding point on Curve25519, the Montgomery form
+// used by X25519, with the birational map u = (1 + y) / (1 - y) of RFC 7748.
+// The sign of x is lost, since u only determines a point up to its negation,
+// which X25519 doesn't need. Nil is returned for the identity (y = 1), which
+// maps to the point at infinity and has no u-coordinate, or for points that
+// are not on the curve.
+func EdwardsToMontgomery(x, y *big.Int) (u *big.Int) {
+	curve := Edwards()
+	if x == nil || y == nil || !curve.IsOnCurve(x, y) {
+		return nil
+	}
+
+	den := new(big.Int).Sub(one, y)
+	den.Mod(den, curve.P)
+	if den.Sign() == 0 {
+		return nil
+	}
+	u = new(big.Int).Add(one, y)
+	u.Mul(u, curve.invert(den))
+	return u.Mod(u, curve.P)
+}
+
+// MontgomeryToEdwards converts the Curve25519 u-coordinate u back to a point
+// on the Ed25519 curve with the inverse map y = (u - 1) / (u + 1) of RFC 7748.
+// The sign of x is not determined by u, so it is taken from negative, which
+// selects the x with its lowest bit set like the sign bit of a compressed
+// point. Converting the u of an Ed25519 public key with the sign bit of its
+// encoding gives back the same key. An error is returned for u = -1, which
+// has no Edwards counterpart, for u not reduced mod P, and for u on the
+// quadratic twist rather than on Curve25519.
+func MontgomeryToEdwards(u *big.Int, negative bool) (x, y *big.Int,
+	err error) {
+	curve := Edwards()
+	if u == nil {
+		return nil, nil, fmt.Errorf("u-coordinate is nil")
+	}
+	if u.Sign() < 0 || u.Cmp(curve.P) >= 0 {
+		return nil, nil, fmt.Errorf("u-coordinate is not reduced")
+	}
+
+	den := new(big.Int).Add(u, one)
+	den.Mod(den, curve.P)
+	if den.Sign() == 0 {
+		return nil, nil, fmt.Errorf("u-coordinate -1 has no Edwards point")
+	}
+	y = new(big.Int).Sub(u, one)
+	y.Mul(y, curve.invert(den))
+	y.Mod(y, curve.P)
+
+	b := BigIntToEncodedBytes(y)
+	if negative {
+		b[31] |= 1 << 7
+	}
+	x, y, err = DecompressPoint(curve, b[:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("u-coordinate is not on Curve25519: %v",
+			err)
+	}
+
+	return x, y, nil
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// TestMontgomeryConversion tests the birational map between Ed25519 points
+// and Curve25519 u-coordinates against the base point and the public keys of
+// the RFC 8032 test vectors, whose u-coordinates are the X25519 public keys
+// of the same secrets, and that conversions roundtrip with the sign of x
+func TestMontgomeryConversion(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	tests := []struct {
+		point string // compressed Edwards point
+		u     string // little endian u-coordinate
+	}{
+		{
+			// The base points.
+			"5866666666666666666666666666666666666666666666666666666666666666",
+			"0900000000000000000000000000000000000000000000000000000000000000",
+		},
+		{
+			"d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+			"d85e07ec22b0ad881537c2f44d662d1a143cf830c57aca4305d85c7a90f6b62e",
+		},
+		{
+			"3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c",
+			"25c704c594b88afc00a76b69d1ed2b984d7e22550f3ed0802d04fbcd07d38d47",
+		},
+		{
+			// The negation of the previous point has the same u.
+			"3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4668c",
+			"25c704c594b88afc00a76b69d1ed2b984d7e22550f3ed0802d04fbcd07d38d47",
+		},
+	}
+
+	for i, test := range tests {
+		b, _ := hex.DecodeString(test.point)
+		x, y, err := DecompressPoint(curve, b)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		u := EdwardsToMontgomery(x, y)
+		if u == nil {
+			t.Fatalf("test %d: failed to convert the point", i)
+		}
+		if got := hex.EncodeToString(BigIntToEncodedBytes(u)[:]); got !=
+			test.u {
+			t.Fatalf("test %d: want u %v, got %v", i, test.u, got)
+		}
+
+		negative := b[31]>>7 == 1
+		backX, backY, err := MontgomeryToEdwards(u, negative)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if backX.Cmp(x) != 0 || backY.Cmp(y) != 0 {
+			t.Fatalf("test %d: point changed in a roundtrip", i)
+		}
+		negX, negY, _ := MontgomeryToEdwards(u, !negative)
+		if wantX, _ := curve.Neg(x, y); negX.Cmp(wantX) != 0 ||
+			negY.Cmp(y) != 0 {
+			t.Fatalf("test %d: flipping the sign didn't negate the point", i)
+		}
+	}
+
+	for i, sk := range mockUpSecKeysByBytes(curve, 10) {
+		pub := sk.PubKey()
+		negative := pub.Serialize()[31]>>7 == 1
+		x, y, err := MontgomeryToEdwards(EdwardsToMontgomery(pub.GetX(),
+			pub.GetY()), negative)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		if x.Cmp(pub.GetX()) != 0 || y.Cmp(pub.GetY()) != 0 {
+			t.Fatalf("key %d: public key changed in a roundtrip", i)
+		}
+	}
+
+	// The identity and u = -1 have no counterpart, and u = 2 is on the
+	// twist.
+	if EdwardsToMontgomery(zero, one) != nil {
+		t.Fatalf("converted the identity")
+	}
+	if EdwardsToMontgomery(one, one) != nil {
+		t.Fatalf("converted an off curve point")
+	}
+	badU := []*big.Int{
+		nil,
+		new(big.Int).Sub(curve.P, one),
+		curve.P,
+		big.NewInt(2),
+	}
+	for i, u := range badU {
+		if _, _, err := MontgomeryToEdwards(u, false); err == nil {
+			t.Fatalf("bad u %d: converted %v", i, u)
+		}
+	}
+}
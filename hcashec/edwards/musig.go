@@ -0,0 +1,247 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// musigKeyListTag is the domain separation tag of the commitment to the
+// list of signers of a MuSig key.
+const musigKeyListTag = "edwards25519 musig key list"
+
+// musigCoefficientTag is the domain separation tag of the MuSig aggregation
+// coefficients.
+const musigCoefficientTag = "edwards25519 musig coefficient"
+
+// musigKeyListHash computes L = taggedHash(tag, pk_1 || ... || pk_n), the
+// commitment to the full list of signers that every aggregation coefficient
+// depends on.
+func musigKeyListHash(pks []*PublicKey) []byte {
+	data := make([][]byte, len(pks))
+	for i, pk := range pks {
+		data[i] = pk.Serialize()
+	}
+	return taggedHash(musigKeyListTag, data...)
+}
+
+// musigCoefficient computes the aggregation coefficient
+// a_i = taggedHash(tag, L || pk_i) mod N for a single signer.
+func musigCoefficient(l []byte, pk *PublicKey) *big.Int {
+	return EncodedBytesToBigInt(taggedScalar(musigCoefficientTag, l,
+		pk.Serialize()))
+}
+
+// SortPublicKeys sorts pks in place in the lexicographic order of their
+// compressed encodings, the canonical order in which AggregatePublicKeys
+// commits to the list of signers. The keys must not be nil.
+func SortPublicKeys(pks []*PublicKey) {
+	encoded := make([][]byte, len(pks))
+	for i, pk := range pks {
+		encoded[i] = pk.Serialize()
+	}
+	sort.Sort(pubKeysByEncoding{pks, encoded})
+}
+
+// pubKeysByEncoding sorts public keys along with their encodings, so every
+// key is only serialized once.
+type pubKeysByEncoding struct {
+	pks     []*PublicKey
+	encoded [][]byte
+}
+
+// Len, Less and Swap implement sort.Interface.
+func (s pubKeysByEncoding) Len() int {
+	return len(s.pks)
+}
+
+func (s pubKeysByEncoding) Less(i, j int) bool {
+	return bytes.Compare(s.encoded[i], s.encoded[j]) < 0
+}
+
+func (s pubKeysByEncoding) Swap(i, j int) {
+	s.pks[i], s.pks[j] = s.pks[j], s.pks[i]
+	s.encoded[i], s.encoded[j] = s.encoded[j], s.encoded[i]
+}
+
+// AggregatePublicKeys combines the public keys pks into a single MuSig
+// public key X = sum a_i*X_i, where each coefficient a_i = H(L, X_i) commits
+// to the full list of keys L. Unlike the plain sum of CombinePubkeys, no
+// participant can choose their key as a function of the others' to cancel
+// them out (a rogue key attack), since changing any key changes every
+// coefficient. The coefficients are returned in the order of pks and must be
+// passed to SchnorrPartialSignMuSig by the respective signers. L is the
+// hash of the keys sorted with SortPublicKeys, so every signer gets the same
+// aggregate key and coefficients whatever the order it received the keys
+// in; pks itself isn't reordered. An aggregate key that is the identity is
+// rejected with ErrIdentityKey.
+func AggregatePublicKeys(curve *TwistedEdwardsCurve,
+	pks []*PublicKey) (*PublicKey, []*big.Int, error) {
+	if len(pks) == 0 {
+		return nil, nil, fmt.Errorf("no public keys to aggregate")
+	}
+	for i, pk := range pks {
+		if pk == nil || pk.GetX() == nil || pk.GetY() == nil {
+			return nil, nil, fmt.Errorf("public key %v is nil", i)
+		}
+		if !curve.IsOnCurve(pk.GetX(), pk.GetY()) {
+			return nil, nil, fmt.Errorf("public key %v is off curve", i)
+		}
+	}
+
+	sorted := append([]*PublicKey(nil), pks...)
+	SortPublicKeys(sorted)
+	l := musigKeyListHash(sorted)
+	coefficients := make([]*big.Int, len(pks))
+	var aggX, aggY *big.Int
+	for i, pk := range pks {
+		coefficients[i] = musigCoefficient(l, pk)
+		x, y := curve.ScalarMult(pk.GetX(), pk.GetY(),
+			coefficients[i].Bytes())
+		if i == 0 {
+			aggX, aggY = x, y
+			continue
+		}
+		aggX, aggY = curve.Add(aggX, aggY, x, y)
+	}
+
+	if !curve.IsOnCurve(aggX, aggY) {
+		return nil, nil, fmt.Errorf("aggregate public key is off curve")
+	}
+	if isIdentity(aggX, aggY) {
+		return nil, nil, ErrIdentityKey
+	}
+
+	return NewPublicKey(curve, aggX, aggY), coefficients, nil
+}
+
+// SchnorrPartialSignMuSig creates a partial Schnorr signature for a MuSig
+// aggregate public key aggPub, as returned by AggregatePublicKeys. The
+// signer's contribution is weighted by its aggregation coefficient, that is
+// s_i = k_i + hash512(R || X || M) * a_i * x_i, so the partial signatures of
+// all signers can then be combined with SchnorrCombineSigs as usual.
+func SchnorrPartialSignMuSig(curve *TwistedEdwardsCurve, msg []byte,
+	priv *PrivateKey, coefficient *big.Int, aggPub *PublicKey,
+	privNonce *PrivateKey, pubNonceSum *PublicKey) (*big.Int, *big.Int, error) {
+	return schnorrPartialSignScaled(curve, msg, priv, coefficient, aggPub,
+		privNonce, pubNonceSum)
+}
+
+// schnorrPartialSignScaled creates a partial Schnorr signature for aggPub
+// with the private key of priv multiplied by factor mod N, for aggregate
+// keys that are a linear combination of the signers' keys.
+func schnorrPartialSignScaled(curve *TwistedEdwardsCurve, msg []byte,
+	priv *PrivateKey, factor *big.Int, aggPub *PublicKey,
+	privNonce *PrivateKey, pubNonceSum *PublicKey) (*big.Int, *big.Int, error) {
+	if priv == nil || factor == nil {
+		return nil, nil, fmt.Errorf("nil input")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, nil, err
+	}
+
+	scalar := priv.reducedScalar(curve)
+	defer scalar.SetInt64(0)
+	weighted := scalarMulAdd(factor, scalar, zero)
+	defer weighted.SetInt64(0)
+	weightedBytes := BigIntToEncodedBytesNoReverse(weighted)
+	defer zeroSlice(weightedBytes[:])
+
+	weightedPriv, _, err := PrivKeyFromScalar(curve, weightedBytes[:])
+	if err != nil {
+		return nil, nil, err
+	}
+	defer weightedPriv.Wipe()
+
+	return SchnorrPartialSign(curve, msg, weightedPriv, aggPub, privNonce,
+		pubNonceSum)
+}
+
+// WeightedAggregate combines the public keys pubs into the key
+// sum weight_i * pub_i, for committees where each signer counts in
+// proportion to its weight, such as its stake. Weights must be in [0, N)
+// and add up to more than zero; keys with a weight of zero don't contribute
+// to the aggregate and their owners don't take part in signing. The signers
+// create their partial signatures with SchnorrPartialSignWeighted and their
+// own weight, which are combined with SchnorrCombineSigs as usual. Like the
+// plain sum of CombinePubkeys, the aggregate offers no protection against
+// rogue keys, so the keys must be known to belong to their signers. An
+// aggregate key that is the identity is rejected with ErrIdentityKey.
+func WeightedAggregate(pubs []*PublicKey, weights []*big.Int) (*PublicKey,
+	error) {
+	if len(pubs) == 0 {
+		return nil, fmt.Errorf("no public keys to aggregate")
+	}
+	if len(pubs) != len(weights) {
+		return nil, fmt.Errorf("got %v public keys but %v weights",
+			len(pubs), len(weights))
+	}
+	if pubs[0] == nil {
+		return nil, fmt.Errorf("public key 0 is nil")
+	}
+	curve, ok := pubs[0].Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil {
+		return nil, fmt.Errorf("public key 0 has no curve")
+	}
+
+	total := new(big.Int)
+	for i, pub := range pubs {
+		if pub == nil || pub.GetX() == nil || pub.GetY() == nil {
+			return nil, fmt.Errorf("public key %v is nil", i)
+		}
+		if pub.Curve != pubs[0].Curve {
+			return nil, fmt.Errorf("public key %v is on another curve", i)
+		}
+		if !curve.IsOnCurve(pub.GetX(), pub.GetY()) {
+			return nil, fmt.Errorf("public key %v is off curve", i)
+		}
+		w := weights[i]
+		if w == nil || w.Sign() < 0 || w.Cmp(curve.N) >= 0 {
+			return nil, fmt.Errorf("weight %v is not in [0, N)", i)
+		}
+		total.Add(total, w)
+	}
+	if total.Sign() == 0 {
+		return nil, fmt.Errorf("total weight is zero")
+	}
+
+	aggX, aggY := new(big.Int), new(big.Int).Set(one)
+	for i, pub := range pubs {
+		if weights[i].Sign() == 0 {
+			continue
+		}
+		x, y := curve.VarBaseMult(pub.GetX(), pub.GetY(), weights[i].Bytes())
+		aggX, aggY = curve.Add(aggX, aggY, x, y)
+	}
+
+	if !curve.IsOnCurve(aggX, aggY) {
+		return nil, fmt.Errorf("aggregate public key is off curve")
+	}
+	if isIdentity(aggX, aggY) {
+		return nil, ErrIdentityKey
+	}
+
+	return NewPublicKey(curve, aggX, aggY), nil
+}
+
+// SchnorrPartialSignWeighted creates a partial Schnorr signature for a
+// weighted aggregate public key aggPub, as returned by WeightedAggregate,
+// where weight is the signer's own weight. That is
+// s_i = k_i + hash512(R || X || M) * w_i * x_i, so the partial signatures of
+// all signers with a non-zero weight combine with SchnorrCombineSigs into a
+// signature that verifies with aggPub.
+func SchnorrPartialSignWeighted(curve *TwistedEdwardsCurve, msg []byte,
+	priv *PrivateKey, weight *big.Int, aggPub *PublicKey,
+	privNonce *PrivateKey, pubNonceSum *PublicKey) (*big.Int, *big.Int, error) {
+	if weight == nil || weight.Sign() <= 0 || weight.Cmp(curve.N) >= 0 {
+		return nil, nil, fmt.Errorf("weight is not in [1, N)")
+	}
+
+	return schnorrPartialSignScaled(curve, msg, priv, weight, aggPub,
+		privNonce, pubNonceSum)
+}
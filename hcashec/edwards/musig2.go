@@ -0,0 +1,184 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// musig2NonceTag is the domain separation tag for the MuSig2 nonce binding
+// coefficient.
+var musig2NonceTag = []byte("MuSig2-edwards25519-SHA512 noncecoef")
+
+// MuSig2Nonce holds the two secret nonces of a signer for a single MuSig2
+// signing round. It must never be used for more than one signature, and is
+// wiped by SignMuSig2.
+type MuSig2Nonce struct {
+	R1 *PrivateKey
+	R2 *PrivateKey
+}
+
+// MuSig2PubNonce is the pair of public nonces R1 = r1*G and R2 = r2*G a
+// signer sends to the others in the first round of MuSig2, or the sum of
+// the pairs of all the signers as returned by AggregateNoncesMuSig2.
+type MuSig2PubNonce struct {
+	R1 *PublicKey
+	R2 *PublicKey
+}
+
+// GenerateNoncesMuSig2 generates the secret nonce pair of a signer for one
+// MuSig2 signing round, along with the public nonces to send to the other
+// signers. Unlike the single nonce of SchnorrPartialSign, the pair doesn't
+// need to be committed to in a round of its own, since the binding
+// coefficient of the second nonce depends on all of them, so MuSig2 needs
+// two rounds instead of three. The public nonces can even be exchanged
+// before the message is known.
+func GenerateNoncesMuSig2(curve *TwistedEdwardsCurve) (*MuSig2Nonce,
+	*MuSig2PubNonce, error) {
+	return generateNoncesMuSig2(curve, rand.Reader)
+}
+
+// generateNoncesMuSig2 is the implementation of GenerateNoncesMuSig2, taking
+// the source of randomness as an argument.
+func generateNoncesMuSig2(curve *TwistedEdwardsCurve,
+	rand io.Reader) (*MuSig2Nonce, *MuSig2PubNonce, error) {
+	nonce := new(MuSig2Nonce)
+	pubNonce := new(MuSig2PubNonce)
+	for _, pair := range []struct {
+		priv **PrivateKey
+		pub  **PublicKey
+	}{
+		{&nonce.R1, &pubNonce.R1},
+		{&nonce.R2, &pubNonce.R2},
+	} {
+		k, err := NewRandomScalar(curve, rand)
+		if err != nil {
+			return nil, nil, err
+		}
+		*pair.priv, *pair.pub, err = scalarToPrivKey(curve, k)
+		k.SetInt64(0)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return nonce, pubNonce, nil
+}
+
+// AggregateNoncesMuSig2 sums the public nonce pairs of all the signers of a
+// round component-wise. Every signer computes the same sum, or a
+// coordinator computes it and sends it back.
+func AggregateNoncesMuSig2(curve *TwistedEdwardsCurve,
+	pubNonces []*MuSig2PubNonce) (*MuSig2PubNonce, error) {
+	if len(pubNonces) == 0 {
+		return nil, fmt.Errorf("no public nonces to aggregate")
+	}
+
+	var r1X, r1Y, r2X, r2Y *big.Int
+	for i, n := range pubNonces {
+		if n == nil || n.R1 == nil || n.R2 == nil || n.R1.GetX() == nil ||
+			n.R2.GetX() == nil {
+			return nil, fmt.Errorf("public nonce %v is nil", i)
+		}
+		if !curve.IsOnCurve(n.R1.GetX(), n.R1.GetY()) ||
+			!curve.IsOnCurve(n.R2.GetX(), n.R2.GetY()) {
+			return nil, fmt.Errorf("public nonce %v is off curve", i)
+		}
+		if i == 0 {
+			r1X, r1Y = n.R1.GetX(), n.R1.GetY()
+			r2X, r2Y = n.R2.GetX(), n.R2.GetY()
+			continue
+		}
+		r1X, r1Y = curve.Add(r1X, r1Y, n.R1.GetX(), n.R1.GetY())
+		r2X, r2Y = curve.Add(r2X, r2Y, n.R2.GetX(), n.R2.GetY())
+	}
+
+	return &MuSig2PubNonce{
+		R1: NewPublicKey(curve, r1X, r1Y),
+		R2: NewPublicKey(curve, r2X, r2Y),
+	}, nil
+}
+
+// musig2NonceCoefficient computes the binding coefficient of the second
+// nonce, b = hash512(tag || R1 || R2 || X || M) mod N, where R1 and R2 are
+// the aggregate nonces and X the aggregate public key.
+func musig2NonceCoefficient(aggNonce *MuSig2PubNonce, aggPub *PublicKey,
+	msg []byte) *big.Int {
+	var digest [64]byte
+	h := sha512.New()
+	h.Write(musig2NonceTag)
+	h.Write(aggNonce.R1.Serialize())
+	h.Write(aggNonce.R2.Serialize())
+	h.Write(aggPub.Serialize())
+	h.Write(msg)
+	h.Sum(digest[:0])
+
+	var digestReduced [32]byte
+	edwards25519.ScReduce(&digestReduced, &digest)
+	return EncodedBytesToBigInt(&digestReduced)
+}
+
+// musig2EffectiveNonce computes the nonce of the signature R = R1 + b*R2.
+func musig2EffectiveNonce(curve *TwistedEdwardsCurve,
+	aggNonce *MuSig2PubNonce, b *big.Int) *PublicKey {
+	x, y := curve.ScalarMult(aggNonce.R2.GetX(), aggNonce.R2.GetY(),
+		b.Bytes())
+	x, y = curve.Add(aggNonce.R1.GetX(), aggNonce.R1.GetY(), x, y)
+
+	return NewPublicKey(curve, x, y)
+}
+
+// SignMuSig2 creates the partial signature of msg of the signer holding priv
+// in the second round of MuSig2, for the aggregate key aggPub and the
+// signer's aggregation coefficient, both as returned by
+// AggregatePublicKeys, and the aggregate of the public nonces of the round.
+// The partial signature is s_i = r1 + b*r2 + hash512(R || X || M)*a_i*x_i
+// with R = R1 + b*R2 as its R, and the partial signatures of all the signers
+// combine into a signature valid for aggPub with SchnorrCombineSigs. The
+// nonce is wiped once used, so it can't be reused by accident.
+func SignMuSig2(curve *TwistedEdwardsCurve, priv *PrivateKey,
+	coefficient *big.Int, aggPub *PublicKey, nonce *MuSig2Nonce,
+	aggNonce *MuSig2PubNonce, msg []byte) (*Signature, error) {
+	if priv == nil || coefficient == nil || aggPub == nil || nonce == nil ||
+		aggNonce == nil || aggNonce.R1 == nil || aggNonce.R2 == nil ||
+		msg == nil {
+		return nil, fmt.Errorf("nil input")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, err
+	}
+	if err := nonce.R1.signingErr(); err != nil {
+		return nil, err
+	}
+	if err := nonce.R2.signingErr(); err != nil {
+		return nil, err
+	}
+
+	b := musig2NonceCoefficient(aggNonce, aggPub, msg)
+	r := musig2EffectiveNonce(curve, aggNonce, b)
+	if *BigIntPointToEncodedBytes(r.GetX(), r.GetY()) == [32]byte{1} {
+		return nil, fmt.Errorf("aggregate nonce is the identity")
+	}
+	c := frostChallenge(aggPub, r, msg)
+
+	scalar := priv.reducedScalar(curve)
+	defer scalar.SetInt64(0)
+	nonces := scalarMulAdd(b, nonce.R2.GetD(), nonce.R1.GetD())
+	defer nonces.SetInt64(0)
+	ac := scalarMulAdd(c, coefficient, zero)
+	s := scalarMulAdd(ac, scalar, nonces)
+
+	nonce.R1.Wipe()
+	nonce.R2.Wipe()
+
+	rBytes := BigIntPointToEncodedBytes(r.GetX(), r.GetY())
+	return NewSignature(EncodedBytesToBigInt(rBytes), s), nil
+}
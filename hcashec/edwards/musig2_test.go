@@ -0,0 +1,101 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestMuSig2 tests a full two round MuSig2 signing session between three
+// signers, and that nonces can't be reused
+func TestMuSig2(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+	msg := []byte("Hello World in TestMuSig2")
+
+	const numSigners = 3
+	sks := mockUpSecKeysByBytes(curve, numSigners)
+	pks := make([]*PublicKey, numSigners)
+	for i, sk := range sks {
+		pks[i] = sk.PubKey()
+	}
+	aggPub, coefficients, err := AggregatePublicKeys(curve, pks)
+	if err != nil {
+		t.Fatalf("unexpected aggregation error: %v", err)
+	}
+
+	// Round one, every signer sends its public nonce pair.
+	nonces := make([]*MuSig2Nonce, numSigners)
+	pubNonces := make([]*MuSig2PubNonce, numSigners)
+	for i := range nonces {
+		nonces[i], pubNonces[i], err = generateNoncesMuSig2(curve, r)
+		if err != nil {
+			t.Fatalf("signer %d: unexpected nonce error: %v", i, err)
+		}
+	}
+	aggNonce, err := AggregateNoncesMuSig2(curve, pubNonces)
+	if err != nil {
+		t.Fatalf("unexpected nonce aggregation error: %v", err)
+	}
+
+	// Round two, every signer sends its partial signature.
+	partials := make([]*Signature, numSigners)
+	for i, sk := range sks {
+		partials[i], err = SignMuSig2(curve, sk, coefficients[i], aggPub,
+			nonces[i], aggNonce, msg)
+		if err != nil {
+			t.Fatalf("signer %d: unexpected signing error: %v", i, err)
+		}
+	}
+
+	sig, err := SchnorrCombineSigs(curve, partials)
+	if err != nil {
+		t.Fatalf("unexpected combining error: %v", err)
+	}
+	if !Verify(aggPub, msg, sig.GetR(), sig.GetS()) {
+		t.Fatalf("aggregate signature failed to verify")
+	}
+	if Verify(aggPub, []byte("another message"), sig.GetR(), sig.GetS()) {
+		t.Fatalf("aggregate signature verified for another message")
+	}
+
+	// The nonces are wiped once used.
+	if _, err := SignMuSig2(curve, sks[0], coefficients[0], aggPub,
+		nonces[0], aggNonce, msg); err != ErrWipedKey {
+		t.Fatalf("want %v reusing a nonce, got %v", ErrWipedKey, err)
+	}
+
+	// A signer given a different aggregate nonce produces a partial that
+	// spoils the signature.
+	for i := range nonces {
+		nonces[i], pubNonces[i], _ = generateNoncesMuSig2(curve, r)
+	}
+	aggNonce, _ = AggregateNoncesMuSig2(curve, pubNonces)
+	wrongNonce, _ := AggregateNoncesMuSig2(curve, pubNonces[:2])
+	for i, sk := range sks {
+		n := aggNonce
+		if i == 2 {
+			n = wrongNonce
+		}
+		partials[i], err = SignMuSig2(curve, sk, coefficients[i], aggPub,
+			nonces[i], n, msg)
+		if err != nil {
+			t.Fatalf("signer %d: unexpected signing error: %v", i, err)
+		}
+	}
+	if _, err := SchnorrCombineSigs(curve, partials); err == nil {
+		t.Fatalf("combined partials with different nonces")
+	}
+
+	if _, err := AggregateNoncesMuSig2(curve, nil); err == nil {
+		t.Fatalf("expected error aggregating no nonces")
+	}
+	if _, err := AggregateNoncesMuSig2(curve,
+		[]*MuSig2PubNonce{pubNonces[0], nil}); err == nil {
+		t.Fatalf("expected error aggregating a nil nonce")
+	}
+}
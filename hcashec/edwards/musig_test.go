@@ -0,0 +1,272 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// TestMuSigSign tests that partial signatures weighted by the MuSig
+// coefficients combine into a signature valid for the aggregate key
+func TestMuSigSign(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := []byte("Hello World in TestMuSigSign!!!!")
+
+	keyVec := mockUpSchnorrKeyVec(curve, 3, msg)
+	aggPub, coefficients, err := AggregatePublicKeys(curve, keyVec.pkVec)
+	if err != nil {
+		t.Fatalf("unexpected aggregation error: %v", err)
+	}
+	if len(coefficients) != len(keyVec.pkVec) {
+		t.Fatalf("want %v coefficients, got %v", len(keyVec.pkVec),
+			len(coefficients))
+	}
+
+	partials := make([]*Signature, len(keyVec.skVec))
+	for i, sk := range keyVec.skVec {
+		r, s, err := SchnorrPartialSignMuSig(curve, msg, sk, coefficients[i],
+			aggPub, keyVec.secNonceVec[i], keyVec.pubNonceVecSum)
+		if err != nil {
+			t.Fatalf("unexpected partial signing error: %v", err)
+		}
+		partials[i] = NewSignature(r, s)
+	}
+
+	sig, err := SchnorrCombineSigs(curve, partials)
+	if err != nil {
+		t.Fatalf("unexpected combining error: %v", err)
+	}
+	if !Verify(aggPub, msg, sig.GetR(), sig.GetS()) {
+		t.Fatalf("aggregate signature failed to verify")
+	}
+
+	// The naive sum is a different key.
+	if Verify(keyVec.pkVecSum, msg, sig.GetR(), sig.GetS()) {
+		t.Fatalf("aggregate signature verified against the naive key sum")
+	}
+
+	if _, _, err := AggregatePublicKeys(curve, nil); err == nil {
+		t.Fatalf("expected error aggregating no keys")
+	}
+	if _, _, err := AggregatePublicKeys(curve,
+		[]*PublicKey{keyVec.pkVec[0], nil}); err == nil {
+		t.Fatalf("expected error aggregating a nil key")
+	}
+}
+
+// TestMuSigRogueKey tests that a rogue key crafted to cancel out an honest
+// key under the naive sum doesn't allow forging a MuSig aggregate signature
+func TestMuSigRogueKey(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := []byte("Hello World in TestMuSigRogueKey")
+
+	sks := mockUpSecKeysByScalars(curve, 2)
+	honestX, honestY := sks[0].Public()
+	honestPub := NewPublicKey(curve, honestX, honestY)
+
+	// The attacker knows y and publishes Y - X_honest as their key.
+	attackerSk := sks[1]
+	yX, yY := attackerSk.Public()
+	rogueX, rogueY := curve.Sub(yX, yY, honestX, honestY)
+	roguePub := NewPublicKey(curve, rogueX, rogueY)
+	pks := []*PublicKey{honestPub, roguePub}
+
+	forgedR, forgedS, err := Sign(curve, attackerSk, msg)
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+
+	// Against the naive sum the attacker alone can sign.
+	naivePub := CombinePubkeys(curve, pks)
+	if !Verify(naivePub, msg, forgedR, forgedS) {
+		t.Fatalf("rogue key attack against the naive sum should succeed")
+	}
+
+	aggPub, _, err := AggregatePublicKeys(curve, pks)
+	if err != nil {
+		t.Fatalf("unexpected aggregation error: %v", err)
+	}
+	if Verify(aggPub, msg, forgedR, forgedS) {
+		t.Fatalf("rogue key forged a MuSig aggregate signature")
+	}
+}
+
+// TestWeightedAggregate tests that partial signatures weighted by unequal
+// weights combine into a signature valid for the weighted aggregate key, and
+// that bad weights are rejected
+func TestWeightedAggregate(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := []byte("Hello World in WeightedAggregate")
+
+	keyVec := mockUpSchnorrKeyVec(curve, 3, msg)
+	weights := []*big.Int{big.NewInt(1), big.NewInt(5), big.NewInt(1000)}
+	aggPub, err := WeightedAggregate(keyVec.pkVec, weights)
+	if err != nil {
+		t.Fatalf("unexpected aggregation error: %v", err)
+	}
+
+	// The aggregate is the weighted sum of the keys.
+	sumX, sumY := new(big.Int), big.NewInt(1)
+	for i, pk := range keyVec.pkVec {
+		x, y := curve.ScalarMult(pk.GetX(), pk.GetY(), weights[i].Bytes())
+		sumX, sumY = curve.Add(sumX, sumY, x, y)
+	}
+	if !aggPub.Equal(NewPublicKey(curve, sumX, sumY)) {
+		t.Fatalf("aggregate is not the weighted sum of the keys")
+	}
+
+	partials := make([]*Signature, len(keyVec.skVec))
+	for i, sk := range keyVec.skVec {
+		r, s, err := SchnorrPartialSignWeighted(curve, msg, sk, weights[i],
+			aggPub, keyVec.secNonceVec[i], keyVec.pubNonceVecSum)
+		if err != nil {
+			t.Fatalf("unexpected partial signing error: %v", err)
+		}
+		partials[i] = NewSignature(r, s)
+	}
+	sig, err := SchnorrCombineSigs(curve, partials)
+	if err != nil {
+		t.Fatalf("unexpected combining error: %v", err)
+	}
+	if !Verify(aggPub, msg, sig.GetR(), sig.GetS()) {
+		t.Fatalf("weighted signature failed to verify")
+	}
+	if Verify(keyVec.pkVecSum, msg, sig.GetR(), sig.GetS()) {
+		t.Fatalf("weighted signature verified against the unweighted sum")
+	}
+
+	// A signer with weight zero is left out of the aggregate and signing.
+	zeroWeights := []*big.Int{big.NewInt(0), big.NewInt(5), big.NewInt(1000)}
+	aggPub, err = WeightedAggregate(keyVec.pkVec, zeroWeights)
+	if err != nil {
+		t.Fatalf("unexpected aggregation error: %v", err)
+	}
+	want, err := WeightedAggregate(keyVec.pkVec[1:], zeroWeights[1:])
+	if err != nil {
+		t.Fatalf("unexpected aggregation error: %v", err)
+	}
+	if !aggPub.Equal(want) {
+		t.Fatalf("key with weight zero changed the aggregate")
+	}
+	if _, _, err := SchnorrPartialSignWeighted(curve, msg, keyVec.skVec[0],
+		zeroWeights[0], aggPub, keyVec.secNonceVec[0],
+		keyVec.pubNonceVecSum); err == nil {
+		t.Fatalf("signed with a weight of zero")
+	}
+
+	badWeights := [][]*big.Int{
+		{big.NewInt(0), big.NewInt(0), big.NewInt(0)},
+		{big.NewInt(1), big.NewInt(-1), big.NewInt(1)},
+		{big.NewInt(1), new(big.Int).Set(curve.N), big.NewInt(1)},
+		{big.NewInt(1), nil, big.NewInt(1)},
+		{big.NewInt(1), big.NewInt(1)},
+	}
+	for i, ws := range badWeights {
+		if _, err := WeightedAggregate(keyVec.pkVec, ws); err == nil {
+			t.Fatalf("bad weights %d: expected an error", i)
+		}
+	}
+	if _, err := WeightedAggregate(nil, nil); err == nil {
+		t.Fatalf("expected error aggregating no keys")
+	}
+}
+
+// TestIdentityKey tests that combining keys which cancel out, and
+// aggregating or building the identity key, is rejected with ErrIdentityKey
+func TestIdentityKey(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := []byte("Hello World in TestIdentityKey!!!")
+
+	pub := mockUpSecKeysByScalars(curve, 1)[0].PubKey()
+	negX, negY := curve.Neg(pub.GetX(), pub.GetY())
+	cancelling := []*PublicKey{pub, NewPublicKey(curve, negX, negY)}
+
+	if CombinePubkeys(curve, cancelling) != nil {
+		t.Fatalf("combined keys that cancel out")
+	}
+	sig := NewSignature(one, one)
+	if _, err := NewThresholdSignature(curve, cancelling, sig,
+		msg); !errors.Is(err, ErrIdentityKey) {
+		t.Fatalf("got %v, want ErrIdentityKey", err)
+	}
+
+	identity := NewPublicKey(curve, zero, one)
+	if CombinePubkeys(curve, []*PublicKey{identity}) != nil {
+		t.Fatalf("combined the identity alone")
+	}
+	if _, _, err := AggregatePublicKeys(curve,
+		[]*PublicKey{identity}); !errors.Is(err, ErrIdentityKey) {
+		t.Fatalf("got %v, want ErrIdentityKey", err)
+	}
+	if _, err := NewPublicKeyChecked(curve, zero, one); !errors.Is(err,
+		ErrIdentityKey) {
+		t.Fatalf("got %v, want ErrIdentityKey", err)
+	}
+	if _, err := NewPublicKeyChecked(curve, one, one); err == nil {
+		t.Fatalf("created an off curve key")
+	}
+	checked, err := NewPublicKeyChecked(curve, pub.GetX(), pub.GetY())
+	if err != nil || !checked.Equal(pub) {
+		t.Fatalf("failed to create a valid key: %v", err)
+	}
+}
+
+// TestSortPublicKeys tests that SortPublicKeys orders keys by their encoding
+// and that differently ordered keys aggregate to the same key, with every
+// key keeping its coefficient and the input keeping its order
+func TestSortPublicKeys(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := []byte("Hello World in TestSortPublicKey")
+
+	keyVec := mockUpSchnorrKeyVec(curve, 5, msg)
+	pks := keyVec.pkVec
+	aggPub, coefficients, err := AggregatePublicKeys(curve, pks)
+	if err != nil {
+		t.Fatalf("unexpected aggregation error: %v", err)
+	}
+
+	sorted := append([]*PublicKey(nil), pks...)
+	SortPublicKeys(sorted)
+	for i := 1; i < len(sorted); i++ {
+		if bytes.Compare(sorted[i-1].Serialize(), sorted[i].Serialize()) > 0 {
+			t.Fatalf("keys %d and %d are out of order", i-1, i)
+		}
+	}
+
+	// Reversed and rotated orders.
+	perm := []int{4, 3, 2, 1, 0}
+	for shift := 0; shift < 3; shift++ {
+		permuted := make([]*PublicKey, len(pks))
+		for i, j := range perm {
+			permuted[i] = pks[(j+shift)%len(pks)]
+		}
+		before := append([]*PublicKey(nil), permuted...)
+		permAgg, permCoefficients, err := AggregatePublicKeys(curve, permuted)
+		if err != nil {
+			t.Fatalf("unexpected aggregation error: %v", err)
+		}
+		if !permAgg.Equal(aggPub) {
+			t.Fatalf("order %d: aggregate key depends on the key order",
+				shift)
+		}
+		for i, j := range perm {
+			if permCoefficients[i].Cmp(coefficients[(j+shift)%len(pks)]) != 0 {
+				t.Fatalf("order %d: key %d has another coefficient", shift, i)
+			}
+			if permuted[i] != before[i] {
+				t.Fatalf("order %d: AggregatePublicKeys reordered its input",
+					shift)
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"sync"
+)
+
+// ErrNonceReused is returned by a NonceTracker when asked to sign with a
+// secret nonce it has already seen.
+var ErrNonceReused = errors.New("secret nonce has already been used")
+
+// nonceTrackerKey is the key of a NonceTracker entry, the hash of a secret
+// nonce, so the tracker never holds the nonces themselves.
+type nonceTrackerKey [sha256.Size]byte
+
+// NonceTracker remembers the secret nonces a signer has used and refuses to
+// use any of them twice. Signing two different messages (or the same message
+// under two different nonce sums) with the same secret nonce r gives
+// s1 = r + h1*a and s2 = r + h2*a, from which anyone holding both partial
+// signatures recovers the private scalar a = (s1 - s2) / (h1 - h2). The
+// threshold signing path takes its nonces from the caller, so a tracker
+// is a defense in depth against a bug or a malicious coordinator replaying
+// an old nonce. It is not persisted, so it only protects within the lifetime
+// of the process.
+type NonceTracker struct {
+	mtx  sync.Mutex
+	used map[nonceTrackerKey]struct{}
+}
+
+// NewNonceTracker creates an empty nonce tracker.
+func NewNonceTracker() *NonceTracker {
+	return &NonceTracker{used: make(map[nonceTrackerKey]struct{})}
+}
+
+// Use marks the secret nonce privNonce as used, returning ErrNonceReused if
+// it already was.
+//
+// NOTE: This function is safe for concurrent access.
+func (t *NonceTracker) Use(privNonce *PrivateKey) error {
+	if err := privNonce.signingErr(); err != nil {
+		return err
+	}
+
+	nonceBytes := privNonce.Serialize()
+	var key nonceTrackerKey
+	h := sha256.New()
+	h.Write(nonceBytes)
+	h.Sum(key[:0])
+	zeroSlice(nonceBytes)
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if _, ok := t.used[key]; ok {
+		return ErrNonceReused
+	}
+	t.used[key] = struct{}{}
+	return nil
+}
+
+// SchnorrPartialSign creates a partial Schnorr signature like the package
+// level SchnorrPartialSign, after making sure the secret nonce privNonce was
+// never used before. The nonce is marked as used even if signing fails, since
+// it may have been the other inputs that were bad.
+//
+// NOTE: This function is safe for concurrent access.
+func (t *NonceTracker) SchnorrPartialSign(curve *TwistedEdwardsCurve,
+	msg []byte, priv *PrivateKey, groupPub *PublicKey, privNonce *PrivateKey,
+	pubSum *PublicKey) (*big.Int, *big.Int, error) {
+	if err := t.Use(privNonce); err != nil {
+		return nil, nil, err
+	}
+
+	return SchnorrPartialSign(curve, msg, priv, groupPub, privNonce, pubSum)
+}
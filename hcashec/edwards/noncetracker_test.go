@@ -0,0 +1,102 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// TestNonceTracker tests that a nonce tracker signs once with a secret nonce
+// and rejects any further use of it with ErrNonceReused
+func TestNonceTracker(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+	otherMsg := append([]byte{0x00}, msg[1:]...)
+
+	keyVec := mockUpSchnorrKeyVec(curve, 3, msg)
+	tracker := NewNonceTracker()
+	for i := range keyVec.skVec {
+		_, _, err := tracker.SchnorrPartialSign(curve, msg, keyVec.skVec[i],
+			keyVec.pkVecSum, keyVec.secNonceVec[i], keyVec.pubNonceVecSum)
+		if err != nil {
+			t.Fatalf("signer %d: unexpected error: %v", i, err)
+		}
+	}
+	for i := range keyVec.skVec {
+		_, _, err := tracker.SchnorrPartialSign(curve, otherMsg,
+			keyVec.skVec[i], keyVec.pkVecSum, keyVec.secNonceVec[i],
+			keyVec.pubNonceVecSum)
+		if !errors.Is(err, ErrNonceReused) {
+			t.Fatalf("signer %d: got %v, want ErrNonceReused", i, err)
+		}
+	}
+
+	// Trackers are independent.
+	if err := NewNonceTracker().Use(keyVec.secNonceVec[0]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tracker.Use(nil); !errors.Is(err, ErrNoPrivateKey) {
+		t.Fatalf("got %v, want ErrNoPrivateKey", err)
+	}
+}
+
+// TestNonceReuseKeyRecovery demonstrates the attack a NonceTracker guards
+// against: two partial signatures of different messages with the same
+// secret nonce reveal the private scalar of the signer
+func TestNonceReuseKeyRecovery(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg1, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+	msg2 := append([]byte{0x00}, msg1[1:]...)
+
+	keyVec := mockUpSchnorrKeyVec(curve, 3, msg1)
+	const victim = 1
+	challenge := func(msg []byte) *big.Int {
+		var digest [64]byte
+		h := sha512.New()
+		h.Write(keyVec.pubNonceVecSum.Serialize())
+		h.Write(keyVec.pkVecSum.Serialize())
+		h.Write(msg)
+		h.Sum(digest[:0])
+		var reduced [32]byte
+		edwards25519.ScReduce(&reduced, &digest)
+		return EncodedBytesToBigInt(&reduced)
+	}
+
+	var s [2]*big.Int
+	for i, msg := range [][]byte{msg1, msg2} {
+		var err error
+		_, s[i], err = SchnorrPartialSign(curve, msg, keyVec.skVec[victim],
+			keyVec.pkVecSum, keyVec.secNonceVec[victim], keyVec.pubNonceVecSum)
+		if err != nil {
+			t.Fatalf("message %d: unexpected error: %v", i, err)
+		}
+	}
+
+	// a = (s1 - s2) / (h1 - h2)
+	sDiff := new(big.Int).Sub(s[0], s[1])
+	hDiff := new(big.Int).Sub(challenge(msg1), challenge(msg2))
+	hDiff.Mod(hDiff, curve.N)
+	hDiffInv, err := ScalarInverse(hDiff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recovered := new(big.Int).Mul(sDiff, hDiffInv)
+	recovered.Mod(recovered, curve.N)
+
+	want := new(big.Int).Mod(keyVec.skVec[victim].GetD(), curve.N)
+	if recovered.Cmp(want) != 0 {
+		t.Fatalf("failed to recover the private scalar from a reused nonce")
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"io"
+)
+
+// SigningRequestIDSize is the size in bytes of the random identifier which
+// pairs a SigningResponse with its SigningRequest.
+const SigningRequestIDSize = 16
+
+// These constants define the lengths of serialized signing requests and
+// responses.
+const (
+	SigningRequestSize  = SigningRequestIDSize + PrivScalarSize
+	SigningResponseSize = SigningRequestIDSize + PubKeyBytesLen +
+		SignatureSize
+)
+
+// SigningRequest is a message hash to be signed by an offline device which
+// holds the private key, prepared by an online device which doesn't. It
+// holds nothing secret, so it can be carried across an air gap, for
+// instance as a QR code, in its serialized form.
+type SigningRequest struct {
+	// ID identifies the request, so that the response of the offline
+	// device can't be matched with another request.
+	ID [SigningRequestIDSize]byte
+
+	// Msg is the 32 byte message hash, such as a transaction sighash.
+	Msg []byte
+}
+
+// SigningResponse is the answer of the offline device to a SigningRequest,
+// the signature of the request's message along with the public key that
+// made it.
+type SigningResponse struct {
+	ID     [SigningRequestIDSize]byte
+	PubKey *PublicKey
+	Sig    *Signature
+}
+
+// PrepareSigningRequest creates the request for an offline device to sign
+// msg, a 32 byte message hash, with a fresh random ID.
+func PrepareSigningRequest(msg []byte) (*SigningRequest, error) {
+	if len(msg) != PrivScalarSize {
+		return nil, fmt.Errorf("wrong size for message (got %v, want %v)",
+			len(msg), PrivScalarSize)
+	}
+
+	req := &SigningRequest{Msg: append([]byte(nil), msg...)}
+	if _, err := io.ReadFull(rand.Reader, req.ID[:]); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// Serialize returns the request as its ID followed by the message hash.
+func (req SigningRequest) Serialize() []byte {
+	b := make([]byte, 0, SigningRequestSize)
+	b = append(b, req.ID[:]...)
+	return append(b, req.Msg...)
+}
+
+// ParseSigningRequest parses a request serialized with
+// SigningRequest.Serialize, such as on the offline device.
+func ParseSigningRequest(b []byte) (*SigningRequest, error) {
+	if len(b) != SigningRequestSize {
+		return nil, fmt.Errorf("bad signing request size (got %v, want %v)",
+			len(b), SigningRequestSize)
+	}
+
+	req := &SigningRequest{
+		Msg: append([]byte(nil), b[SigningRequestIDSize:]...),
+	}
+	copy(req.ID[:], b[:SigningRequestIDSize])
+
+	return req, nil
+}
+
+// SignRequest signs the message of req with priv on the offline device,
+// returning the response to carry back to the online device. Only Ed25519
+// keys are supported.
+func SignRequest(curve *TwistedEdwardsCurve, priv *PrivateKey,
+	req *SigningRequest) (*SigningResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("signing request is nil")
+	}
+	if len(req.Msg) != PrivScalarSize {
+		return nil, fmt.Errorf("wrong size for message (got %v, want %v)",
+			len(req.Msg), PrivScalarSize)
+	}
+	if curve.isEd448() {
+		return nil, fmt.Errorf("signing requests are only supported on " +
+			"Ed25519")
+	}
+
+	r, s, err := Sign(curve, priv, req.Msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningResponse{
+		ID:     req.ID,
+		PubKey: priv.PubKey(),
+		Sig:    NewSignature(r, s),
+	}, nil
+}
+
+// Serialize returns the response as the request ID, the compressed public
+// key and the 64 byte signature. Only Ed25519 responses can be serialized.
+func (resp SigningResponse) Serialize() ([]byte, error) {
+	if resp.PubKey == nil || resp.PubKey.GetX() == nil ||
+		resp.PubKey.GetY() == nil || resp.Sig == nil ||
+		resp.Sig.GetR() == nil || resp.Sig.GetS() == nil {
+		return nil, fmt.Errorf("cannot serialize incomplete signing response")
+	}
+	if curve, ok := resp.PubKey.Curve.(*TwistedEdwardsCurve); !ok ||
+		curve == nil || curve.isEd448() || resp.Sig.isEd448() {
+		return nil, fmt.Errorf("signing responses are only supported on " +
+			"Ed25519")
+	}
+
+	b := make([]byte, 0, SigningResponseSize)
+	b = append(b, resp.ID[:]...)
+	b = append(b, resp.PubKey.Serialize()...)
+	return append(b, resp.Sig.Serialize()...), nil
+}
+
+// ParseSigningResponse parses a response serialized with
+// SigningResponse.Serialize, validating the public key as ParsePubKey and
+// the signature as ParseSignature do.
+func ParseSigningResponse(curve *TwistedEdwardsCurve, b []byte) (
+	*SigningResponse, error) {
+	if len(b) != SigningResponseSize {
+		return nil, fmt.Errorf("bad signing response size (got %v, want "+
+			"%v)", len(b), SigningResponseSize)
+	}
+
+	pubStart := SigningRequestIDSize
+	sigStart := pubStart + PubKeyBytesLen
+	pub, err := ParsePubKey(curve, b[pubStart:sigStart])
+	if err != nil {
+		return nil, err
+	}
+	sig, err := ParseSignature(curve, b[sigStart:])
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &SigningResponse{PubKey: pub, Sig: sig}
+	copy(resp.ID[:], b[:SigningRequestIDSize])
+
+	return resp, nil
+}
+
+// AssembleSignature checks the response of the offline device to req on
+// the online device and returns its signature. The response must answer
+// this very request and its signature must verify for the message of the
+// request under the public key of the response. That proves the offline
+// device signed the message, but not that it used the intended key, so the
+// caller must still check that the public key of the response is the one
+// it expects.
+func AssembleSignature(req *SigningRequest, resp *SigningResponse) (*Signature,
+	error) {
+	if req == nil || resp == nil || resp.Sig == nil {
+		return nil, fmt.Errorf("nil input")
+	}
+	if subtle.ConstantTimeCompare(req.ID[:], resp.ID[:]) != 1 {
+		return nil, fmt.Errorf("signing response doesn't match the request")
+	}
+	if !Verify(resp.PubKey, req.Msg, resp.Sig.GetR(), resp.Sig.GetS()) {
+		return nil, fmt.Errorf("signature of the signing response is " +
+			"invalid")
+	}
+
+	return NewSignature(resp.Sig.GetR(), resp.Sig.GetS()), nil
+}
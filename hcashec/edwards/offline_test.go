@@ -0,0 +1,107 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+// TestOfflineSigning tests the split between an online device preparing a
+// request and assembling the signature and an offline device signing it,
+// with only serialized bytes passed between them, and that responses to
+// other requests or with bad signatures are rejected
+func TestOfflineSigning(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	var privs []*PrivateKey
+	privs = append(privs, mockUpSecKeysByBytes(curve, 2)...)
+	privs = append(privs, mockUpSecKeysByScalars(curve, 2)...)
+	for i, priv := range privs {
+		msg := sha256.Sum256([]byte{byte(i)})
+
+		// Online: prepare the request and send it over.
+		req, err := PrepareSigningRequest(msg[:])
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		reqBytes := req.Serialize()
+
+		// Offline: sign the request and send the response back.
+		offlineReq, err := ParseSigningRequest(reqBytes)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		resp, err := SignRequest(curve, priv, offlineReq)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		respBytes, err := resp.Serialize()
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+
+		// Online: check the response and assemble the signature.
+		onlineResp, err := ParseSigningResponse(curve, respBytes)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		if !onlineResp.PubKey.Equal(priv.PubKey()) {
+			t.Fatalf("key %d: response has another public key", i)
+		}
+		sig, err := AssembleSignature(req, onlineResp)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		if !Verify(priv.PubKey(), msg[:], sig.GetR(), sig.GetS()) {
+			t.Fatalf("key %d: assembled signature failed to verify", i)
+		}
+		r, s, err := Sign(curve, priv, msg[:])
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(sig.Serialize(), NewSignature(r, s).Serialize()) {
+			t.Fatalf("key %d: offline signature differs from Sign", i)
+		}
+
+		// A response to a request for the same message with another ID.
+		other, err := PrepareSigningRequest(msg[:])
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		if _, err := AssembleSignature(other, onlineResp); err == nil {
+			t.Fatalf("key %d: assembled a response to another request", i)
+		}
+
+		// A response with a signature of another message.
+		otherMsg := sha256.Sum256([]byte("other"))
+		r, s, err = Sign(curve, priv, otherMsg[:])
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		bad := *onlineResp
+		bad.Sig = NewSignature(r, s)
+		if _, err := AssembleSignature(req, &bad); err == nil {
+			t.Fatalf("key %d: assembled a bad signature", i)
+		}
+	}
+
+	if _, err := PrepareSigningRequest(make([]byte, 31)); err == nil {
+		t.Fatalf("prepared a request for a short message")
+	}
+	if _, err := ParseSigningRequest(make([]byte, SigningRequestSize-1)); err ==
+		nil {
+		t.Fatalf("parsed a short signing request")
+	}
+	if _, err := ParseSigningResponse(curve,
+		make([]byte, SigningResponseSize)); err == nil {
+		t.Fatalf("parsed a signing response without a valid key")
+	}
+	if _, err := AssembleSignature(nil, nil); err == nil {
+		t.Fatalf("assembled a signature from nil input")
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// pedersenTag is the domain separation tag hashed along with the base point
+// to derive the second generator of Pedersen commitments.
+var pedersenTag = []byte("pedersen commitment generator H")
+
+var (
+	// pedersenH is the second generator H of Pedersen commitments. It's
+	// derived by hashing the base point to a point, so that nobody knows
+	// its discrete log with respect to G.
+	pedersenH     *edwards25519.ExtendedGroupElement
+	pedersenHOnce sync.Once
+)
+
+// getPedersenH returns the second generator H of Pedersen commitments,
+// deriving it on first use.
+func getPedersenH(curve *TwistedEdwardsCurve) *edwards25519.ExtendedGroupElement {
+	pedersenHOnce.Do(func() {
+		g := BigIntPointToEncodedBytes(curve.Gx, curve.Gy)
+		data := append(append([]byte(nil), pedersenTag...), g[:]...)
+		pedersenH = hashToPoint(data)
+	})
+
+	return pedersenH
+}
+
+// checkCommitScalar returns an error if the scalar k named name is nil or
+// not in [0, N).
+func checkCommitScalar(curve *TwistedEdwardsCurve, k *big.Int,
+	name string) error {
+	if k == nil {
+		return fmt.Errorf("%v is nil", name)
+	}
+	if k.Sign() < 0 || k.Cmp(curve.N) >= 0 {
+		return fmt.Errorf("%v is out of range", name)
+	}
+
+	return nil
+}
+
+// Commit returns the Pedersen commitment C = value*G + blinding*H to value,
+// where H is a second generator whose discrete log with respect to G is
+// unknown. As long as the blinding factor is random and kept secret, C
+// reveals nothing about value, and the committer can't open C to any other
+// value without solving a discrete log. Both value and blinding must be in
+// [0, N). Commitments are additively homomorphic, see CommitmentAdd.
+func Commit(curve *TwistedEdwardsCurve, value, blinding *big.Int) (*PublicKey,
+	error) {
+	if err := checkCommitScalar(curve, value, "value"); err != nil {
+		return nil, err
+	}
+	if err := checkCommitScalar(curve, blinding, "blinding factor"); err != nil {
+		return nil, err
+	}
+
+	// value*G
+	valueLE := BigIntToEncodedBytes(value)
+	defer zeroSlice(valueLE[:])
+	var vG edwards25519.ExtendedGroupElement
+	edwards25519.GeScalarMultBase(&vG, valueLE)
+
+	// blinding*H
+	blindingBE := BigIntToEncodedBytesNoReverse(blinding)
+	defer zeroSlice(blindingBE[:])
+	var bH edwards25519.ExtendedGroupElement
+	geScalarMultConstantTime(&bH, getPedersenH(curve), blindingBE[:])
+
+	var bHCached cachedGroupElement
+	toCached(&bHCached, &bH)
+	var c edwards25519.CompletedGroupElement
+	geAdd(&c, &vG, &bHCached)
+	var sum edwards25519.ExtendedGroupElement
+	c.ToExtended(&sum)
+
+	return geToPublicKey(curve, &sum)
+}
+
+// CommitmentAdd returns the sum of the Pedersen commitments a and b, which
+// is the commitment to the sum of their values with the sum of their
+// blinding factors (both mod N). This lets a verifier check that the values
+// committed to in the inputs and outputs of a transaction balance without
+// learning any of them.
+func CommitmentAdd(curve *TwistedEdwardsCurve, a, b *PublicKey) (*PublicKey,
+	error) {
+	if a == nil || a.GetX() == nil || a.GetY() == nil ||
+		b == nil || b.GetX() == nil || b.GetY() == nil {
+		return nil, fmt.Errorf("nil commitment")
+	}
+
+	var aEGE, bEGE edwards25519.ExtendedGroupElement
+	if !aEGE.FromBytes(BigIntPointToEncodedBytes(a.GetX(), a.GetY())) {
+		return nil, fmt.Errorf("commitment a is not on the curve")
+	}
+	if !bEGE.FromBytes(BigIntPointToEncodedBytes(b.GetX(), b.GetY())) {
+		return nil, fmt.Errorf("commitment b is not on the curve")
+	}
+
+	var bCached cachedGroupElement
+	toCached(&bCached, &bEGE)
+	var c edwards25519.CompletedGroupElement
+	geAdd(&c, &aEGE, &bCached)
+	var sum edwards25519.ExtendedGroupElement
+	c.ToExtended(&sum)
+
+	return geToPublicKey(curve, &sum)
+}
+
+// CommitmentOpening is the opening of a Pedersen commitment, the value and
+// the blinding factor it was created with by Commit.
+type CommitmentOpening struct {
+	Value    *big.Int
+	Blinding *big.Int
+}
+
+// BatchVerifyOpenings checks that each of the Pedersen commitments opens to
+// the opening at the same index, that is C_i = v_i*G + r_i*H. Like
+// BatchVerify, it checks the random linear combination
+// [sum z_i*v_i]G + [sum z_i*r_i]H = sum [z_i]C_i with random 128-bit
+// coefficients z_i in a single multiscalar multiplication, instead of two
+// scalar multiplications per commitment, and a single bad opening makes the
+// whole batch fail. The multiplication is variable time, so the openings
+// must be public to the verifier. The commitments must be points of the
+// prime order subgroup, as ParsePubKey makes sure of, since a small order
+// component could cancel out in the combination. Empty input verifies
+// trivially and slices of mismatched lengths don't verify.
+func BatchVerifyOpenings(curve *TwistedEdwardsCurve, commitments []*PublicKey,
+	openings []*CommitmentOpening) bool {
+	return batchVerifyOpenings(curve, rand.Reader, commitments, openings)
+}
+
+// batchVerifyOpenings is the implementation of BatchVerifyOpenings, taking
+// the source of randomness for the batch coefficients as an argument.
+func batchVerifyOpenings(curve *TwistedEdwardsCurve, rand io.Reader,
+	commitments []*PublicKey, openings []*CommitmentOpening) bool {
+	if len(commitments) != len(openings) {
+		return false
+	}
+	if len(commitments) == 0 {
+		return true
+	}
+
+	scalars := make([]*[32]byte, 0, len(commitments)+2)
+	points := make([]*edwards25519.ExtendedGroupElement, 0,
+		len(commitments)+2)
+	sumV, sumR := new(big.Int), new(big.Int)
+	for i, c := range commitments {
+		o := openings[i]
+		if c == nil || c.GetX() == nil || c.GetY() == nil || o == nil ||
+			checkCommitScalar(curve, o.Value, "value") != nil ||
+			checkCommitScalar(curve, o.Blinding, "blinding factor") != nil {
+			return false
+		}
+		p := new(edwards25519.ExtendedGroupElement)
+		if !p.FromBytes(BigIntPointToEncodedBytes(c.GetX(), c.GetY())) {
+			return false
+		}
+
+		var zBytes [32]byte
+		if _, err := io.ReadFull(rand, zBytes[:batchCoefficientSize]); err != nil {
+			return false
+		}
+		z := EncodedBytesToBigInt(&zBytes)
+		sumV = scalarMulAdd(z, o.Value, sumV)
+		sumR = scalarMulAdd(z, o.Blinding, sumR)
+
+		scalars = append(scalars, BigIntToEncodedBytes(new(big.Int).Sub(
+			curve.N, z)))
+		points = append(points, p)
+	}
+
+	// [sum z_i*v_i]G + [sum z_i*r_i]H - sum [z_i]C_i must be the identity.
+	var g edwards25519.ExtendedGroupElement
+	g.FromBytes(BigIntPointToEncodedBytes(curve.Gx, curve.Gy))
+	scalars = append(scalars, BigIntToEncodedBytes(sumV),
+		BigIntToEncodedBytes(sumR))
+	points = append(points, &g, getPedersenH(curve))
+
+	var check edwards25519.ExtendedGroupElement
+	multiScalarMultVartime(&check, scalars, points)
+	return geIsIdentity(&check)
+}
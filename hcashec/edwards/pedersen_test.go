@@ -0,0 +1,180 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// TestPedersenCommitment tests that Pedersen commitments are additively
+// homomorphic and that the blinding factor hides the committed value
+func TestPedersenCommitment(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	randScalar := func() *big.Int {
+		k, err := NewRandomScalar(curve, rand.Reader)
+		if err != nil {
+			t.Fatalf("unexpected error generating a scalar: %v", err)
+		}
+		return k
+	}
+	commit := func(value, blinding *big.Int) *PublicKey {
+		c, err := Commit(curve, value, blinding)
+		if err != nil {
+			t.Fatalf("unexpected commitment error: %v", err)
+		}
+		return c
+	}
+	mod := func(k *big.Int) *big.Int {
+		return k.Mod(k, curve.N)
+	}
+
+	// H is a point of the prime order subgroup other than G.
+	h := getPedersenH(curve)
+	if !curve.isPrimeOrderPoint(h) {
+		t.Fatalf("second generator is not in the prime order subgroup")
+	}
+	hPub, err := geToPublicKey(curve, h)
+	if err != nil {
+		t.Fatalf("unexpected error converting H: %v", err)
+	}
+	if hPub.GetX().Cmp(curve.Gx) == 0 && hPub.GetY().Cmp(curve.Gy) == 0 {
+		t.Fatalf("second generator is the base point")
+	}
+
+	// Homomorphism, C(v1, r1) + C(v2, r2) = C(v1 + v2, r1 + r2).
+	for i := 0; i < 5; i++ {
+		v1, v2 := big.NewInt(int64(1000*i+7)), randScalar()
+		r1, r2 := randScalar(), randScalar()
+		sum, err := CommitmentAdd(curve, commit(v1, r1), commit(v2, r2))
+		if err != nil {
+			t.Fatalf("test %d: unexpected addition error: %v", i, err)
+		}
+		want := commit(mod(new(big.Int).Add(v1, v2)),
+			mod(new(big.Int).Add(r1, r2)))
+		if !sum.Equal(want) {
+			t.Fatalf("test %d: sum of commitments is not the commitment "+
+				"to the sums", i)
+		}
+	}
+
+	// Hiding, the same value with different blinding factors gives
+	// unrelated commitments, and without blinding the commitment is just
+	// value*G.
+	value := big.NewInt(42)
+	c1, c2 := commit(value, randScalar()), commit(value, randScalar())
+	if c1.Equal(c2) {
+		t.Fatalf("commitments to the same value are equal")
+	}
+	vX, vY := curve.ScalarBaseMult(value.Bytes())
+	if c1.GetX().Cmp(vX) == 0 && c1.GetY().Cmp(vY) == 0 {
+		t.Fatalf("commitment reveals value*G")
+	}
+	if c := commit(value, new(big.Int)); c.GetX().Cmp(vX) != 0 ||
+		c.GetY().Cmp(vY) != 0 {
+		t.Fatalf("commitment with a zero blinding factor is not value*G")
+	}
+
+	// Scalars must be in [0, N).
+	bad := []*big.Int{nil, big.NewInt(-1), curve.N}
+	for _, k := range bad {
+		if _, err := Commit(curve, k, randScalar()); err == nil {
+			t.Fatalf("committed to bad value %v", k)
+		}
+		if _, err := Commit(curve, value, k); err == nil {
+			t.Fatalf("committed with bad blinding factor %v", k)
+		}
+	}
+	if _, err := CommitmentAdd(curve, c1, nil); err == nil {
+		t.Fatalf("added a nil commitment")
+	}
+}
+
+// mockUpCommitments commits to n random values with random blinding factors
+// and returns the commitments along with their openings.
+func mockUpCommitments(tb testing.TB, curve *TwistedEdwardsCurve,
+	n int) ([]*PublicKey, []*CommitmentOpening) {
+	commitments := make([]*PublicKey, n)
+	openings := make([]*CommitmentOpening, n)
+	for i := range commitments {
+		value, err := NewRandomScalar(curve, rand.Reader)
+		if err != nil {
+			tb.Fatalf("unexpected error: %v", err)
+		}
+		blinding, err := NewRandomScalar(curve, rand.Reader)
+		if err != nil {
+			tb.Fatalf("unexpected error: %v", err)
+		}
+		c, err := Commit(curve, value, blinding)
+		if err != nil {
+			tb.Fatalf("unexpected commitment error: %v", err)
+		}
+		commitments[i] = c
+		openings[i] = &CommitmentOpening{value, blinding}
+	}
+
+	return commitments, openings
+}
+
+// TestBatchVerifyOpenings tests that a batch of valid openings verifies and
+// that a single bad opening or commitment anywhere in the batch makes it
+// fail
+func TestBatchVerifyOpenings(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	commitments, openings := mockUpCommitments(t, curve, 8)
+	if !BatchVerifyOpenings(curve, commitments, openings) {
+		t.Fatalf("valid batch failed to verify")
+	}
+	if !BatchVerifyOpenings(curve, commitments[:1], openings[:1]) {
+		t.Fatalf("valid batch of one failed to verify")
+	}
+	if !BatchVerifyOpenings(curve, nil, nil) {
+		t.Fatalf("empty batch failed to verify")
+	}
+
+	for i := range commitments {
+		o := openings[i]
+		badOpenings := []*CommitmentOpening{
+			{new(big.Int).Add(o.Value, one), o.Blinding},
+			{o.Value, new(big.Int).Add(o.Blinding, one)},
+			{o.Blinding, o.Value},
+			{nil, o.Blinding},
+			nil,
+		}
+		for j, bad := range badOpenings {
+			tampered := append([]*CommitmentOpening(nil), openings...)
+			tampered[i] = bad
+			if BatchVerifyOpenings(curve, commitments, tampered) {
+				t.Fatalf("opening %d: bad opening %d verified", i, j)
+			}
+		}
+
+		// The commitment of another opening.
+		tampered := append([]*PublicKey(nil), commitments...)
+		tampered[i] = commitments[(i+1)%len(commitments)]
+		if BatchVerifyOpenings(curve, tampered, openings) {
+			t.Fatalf("commitment %d: swapped commitment verified", i)
+		}
+	}
+
+	// Two bad openings that would cancel out with equal coefficients.
+	tampered := append([]*CommitmentOpening(nil), openings...)
+	tampered[0] = &CommitmentOpening{new(big.Int).Add(openings[0].Value, one),
+		openings[0].Blinding}
+	tampered[1] = &CommitmentOpening{new(big.Int).Sub(openings[1].Value, one),
+		openings[1].Blinding}
+	if BatchVerifyOpenings(curve, commitments, tampered) {
+		t.Fatalf("cancelling bad openings verified")
+	}
+
+	if BatchVerifyOpenings(curve, commitments, openings[1:]) {
+		t.Fatalf("mismatched lengths verified")
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+)
+
+// The PEM block types of Ed25519 keys, as written by OpenSSL.
+const (
+	pemPrivateKeyType = "PRIVATE KEY"
+	pemPublicKeyType  = "PUBLIC KEY"
+)
+
+// oidEd25519 is the algorithm identifier of Ed25519 keys, id-Ed25519 of
+// RFC 8410.
+var oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+// pkixAlgorithm is the AlgorithmIdentifier of RFC 5280. RFC 8410 requires
+// the parameters of Ed25519 to be absent.
+type pkixAlgorithm struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+// pkcs8 is the OneAsymmetricKey structure of RFC 5958, the PKCS #8 private
+// key, along with the optional fields of its version 2.
+type pkcs8 struct {
+	Version    int
+	Algorithm  pkixAlgorithm
+	PrivateKey []byte
+	Attributes asn1.RawValue  `asn1:"optional,tag:0"`
+	PublicKey  asn1.BitString `asn1:"optional,tag:1"`
+}
+
+// subjectPublicKeyInfo is the public key structure of RFC 5280.
+type subjectPublicKeyInfo struct {
+	Algorithm pkixAlgorithm
+	PublicKey asn1.BitString
+}
+
+// checkAlgorithm returns an error unless algo identifies Ed25519 without
+// parameters.
+func checkAlgorithm(algo pkixAlgorithm) error {
+	if !algo.Algorithm.Equal(oidEd25519) {
+		return fmt.Errorf("key algorithm %v is not Ed25519", algo.Algorithm)
+	}
+	if len(algo.Parameters.FullBytes) != 0 {
+		return fmt.Errorf("Ed25519 key algorithm has parameters")
+	}
+
+	return nil
+}
+
+// decodePEM decodes the single PEM block of data, which must be of type
+// blockType.
+func decodePEM(data []byte, blockType string) (*pem.Block, error) {
+	block, rest := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	if block.Type != blockType {
+		return nil, fmt.Errorf("bad PEM block type %q (want %q)", block.Type,
+			blockType)
+	}
+	if len(rest) != 0 {
+		if next, _ := pem.Decode(rest); next != nil {
+			return nil, fmt.Errorf("more than one PEM block found")
+		}
+	}
+
+	return block, nil
+}
+
+// MarshalPEM encodes the private key as a PKCS #8 "PRIVATE KEY" PEM block
+// with the Ed25519 algorithm of RFC 8410, the format of OpenSSL and most
+// other tools. PKCS #8 stores Ed25519 keys as their 32 byte secret seed, so
+// keys created from a bare scalar, which have none, can't be encoded. Only
+// Ed25519 keys are supported. The returned bytes hold the secret and should
+// be wiped once written out.
+func (p PrivateKey) MarshalPEM() ([]byte, error) {
+	if p.IsWiped() {
+		return nil, ErrWipedKey
+	}
+	if p.ed448Seed != nil {
+		return nil, fmt.Errorf("PEM encoding is only supported for Ed25519 " +
+			"keys")
+	}
+	if p.secret == nil {
+		return nil, fmt.Errorf("private key has no secret seed to encode")
+	}
+
+	// The private key is the seed wrapped in an OCTET STRING.
+	inner, err := asn1.Marshal(p.secret[:])
+	if err != nil {
+		return nil, err
+	}
+	defer zeroSlice(inner)
+	der, err := asn1.Marshal(pkcs8{
+		Algorithm:  pkixAlgorithm{Algorithm: oidEd25519},
+		PrivateKey: inner,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer zeroSlice(der)
+
+	return pem.EncodeToMemory(&pem.Block{Type: pemPrivateKeyType,
+		Bytes: der}), nil
+}
+
+// ParsePEM sets the private key to the Ed25519 key of the PKCS #8 "PRIVATE
+// KEY" PEM block in data, such as one generated by OpenSSL. The algorithm
+// must be Ed25519, and if the key includes its public key it must match the
+// one derived from the seed.
+func (p *PrivateKey) ParsePEM(data []byte) error {
+	block, err := decodePEM(data, pemPrivateKeyType)
+	if err != nil {
+		return err
+	}
+	defer zeroSlice(block.Bytes)
+
+	var key pkcs8
+	rest, err := asn1.Unmarshal(block.Bytes, &key)
+	if err != nil {
+		return fmt.Errorf("malformed PKCS #8 private key: %v", err)
+	}
+	defer zeroSlice(key.PrivateKey)
+	if len(rest) != 0 {
+		return fmt.Errorf("trailing data after PKCS #8 private key")
+	}
+	if key.Version != 0 && key.Version != 1 {
+		return fmt.Errorf("unknown PKCS #8 version %v", key.Version)
+	}
+	if err := checkAlgorithm(key.Algorithm); err != nil {
+		return err
+	}
+
+	var seed []byte
+	rest, err = asn1.Unmarshal(key.PrivateKey, &seed)
+	if err != nil || len(rest) != 0 {
+		return fmt.Errorf("malformed Ed25519 private key")
+	}
+	defer zeroSlice(seed)
+	if len(seed) != PrivKeyBytesLen/2 {
+		return fmt.Errorf("bad Ed25519 private key size (got %v, want %v)",
+			len(seed), PrivKeyBytesLen/2)
+	}
+
+	priv, pub := PrivKeyFromSecret(Edwards(), seed)
+	if priv == nil {
+		return fmt.Errorf("failed to expand private key seed")
+	}
+	if key.PublicKey.BitLength != 0 {
+		embedded, err := ParsePubKey(Edwards(), key.PublicKey.RightAlign())
+		if err != nil || !embedded.Equal(pub) {
+			return fmt.Errorf("public key doesn't match the private key")
+		}
+	}
+	*p = *priv
+
+	return nil
+}
+
+// MarshalPEM encodes the public key as a "PUBLIC KEY" PEM block holding the
+// SubjectPublicKeyInfo of RFC 5280 with the Ed25519 algorithm of RFC 8410.
+// Only Ed25519 keys are supported.
+func (p PublicKey) MarshalPEM() ([]byte, error) {
+	if p.Curve == nil || p.X == nil || p.Y == nil {
+		return nil, fmt.Errorf("cannot marshal incomplete public key")
+	}
+	if curve, ok := p.Curve.(*TwistedEdwardsCurve); !ok || curve.isEd448() {
+		return nil, fmt.Errorf("PEM encoding is only supported for Ed25519 " +
+			"keys")
+	}
+
+	encoded := p.Serialize()
+	der, err := asn1.Marshal(subjectPublicKeyInfo{
+		Algorithm: pkixAlgorithm{Algorithm: oidEd25519},
+		PublicKey: asn1.BitString{Bytes: encoded, BitLength: 8 * len(encoded)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: pemPublicKeyType,
+		Bytes: der}), nil
+}
+
+// ParsePEM sets the public key to the Ed25519 key of the "PUBLIC KEY" PEM
+// block in data, such as one written by OpenSSL. The algorithm must be
+// Ed25519 and the key is validated the same way as by ParsePubKey.
+func (p *PublicKey) ParsePEM(data []byte) error {
+	block, err := decodePEM(data, pemPublicKeyType)
+	if err != nil {
+		return err
+	}
+
+	var info subjectPublicKeyInfo
+	rest, err := asn1.Unmarshal(block.Bytes, &info)
+	if err != nil {
+		return fmt.Errorf("malformed public key info: %v", err)
+	}
+	if len(rest) != 0 {
+		return fmt.Errorf("trailing data after public key info")
+	}
+	if err := checkAlgorithm(info.Algorithm); err != nil {
+		return err
+	}
+	if info.PublicKey.BitLength != 8*PubKeyBytesLen {
+		return fmt.Errorf("bad Ed25519 public key size (got %v bits, want "+
+			"%v)", info.PublicKey.BitLength, 8*PubKeyBytesLen)
+	}
+
+	pub, err := ParsePubKey(Edwards(), info.PublicKey.Bytes)
+	if err != nil {
+		return err
+	}
+	*p = *pub
+
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// TestVerifyWithPrecomputed tests that verifying with a precomputed public
+// key accepts and rejects the same signatures as Verify
+func TestVerifyWithPrecomputed(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	sks := mockUpSecKeysByBytes(curve, 4)
+	for i, sk := range sks {
+		pub := sk.PubKey()
+		precomputed, err := NewPrecomputedPublicKey(pub)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		other, _ := NewPrecomputedPublicKey(sks[(i+1)%len(sks)].PubKey())
+
+		for j := 0; j < 4; j++ {
+			msg := []byte{byte(i), byte(j), 'm', 's', 'g'}
+			sig, err := SignDeterministic(curve, sk, msg)
+			if err != nil {
+				t.Fatalf("key %d: unexpected signing error: %v", i, err)
+			}
+
+			nonCanonical := new(big.Int).Add(sig.S, curve.N)
+			badS := new(big.Int).Add(sig.S, one)
+			tests := []struct {
+				pub  *PrecomputedPublicKey
+				msg  []byte
+				r, s *big.Int
+			}{
+				{precomputed, msg, sig.R, sig.S},
+				{precomputed, append(msg, 0), sig.R, sig.S},
+				{other, msg, sig.R, sig.S},
+				{precomputed, msg, sig.R, nonCanonical},
+				{precomputed, msg, sig.R, badS},
+				{precomputed, msg, new(big.Int).Add(sig.R, one), sig.S},
+			}
+			for k, test := range tests {
+				want := Verify(test.pub.PublicKey, test.msg, test.r, test.s)
+				if k == 0 && !want {
+					t.Fatalf("key %d: honest signature rejected by Verify", i)
+				}
+				got := VerifyWithPrecomputed(test.pub, test.msg, test.r,
+					test.s)
+				if got != want {
+					t.Fatalf("key %d, sig %d, test %d: got %v, want %v", i, j,
+						k, got, want)
+				}
+			}
+		}
+	}
+
+	// Keys with a small order component fall back to checking R.
+	tb, _ := hex.DecodeString(smallOrderPoints[4])
+	tx, ty, _ := curve.EncodedBytesToBigIntPoint(copyBytes(tb))
+	pub := sks[0].PubKey()
+	ax, ay := curve.Add(pub.GetX(), pub.GetY(), tx, ty)
+	mixed, err := NewPrecomputedPublicKey(NewPublicKey(curve, ax, ay))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a := sks[0].reducedScalar(curve)
+	for i := 0; i < 16; i++ {
+		msg := []byte{byte(i), 'm', 'i', 'x'}
+		sig, _ := divergentSig(t, curve, a, ax, ay, zero, one, msg)
+		want := Verify(mixed.PublicKey, msg, sig.R, sig.S)
+		if VerifyWithPrecomputed(mixed, msg, sig.R, sig.S) != want {
+			t.Fatalf("mixed order key %d: got %v, want %v", i, !want, want)
+		}
+	}
+
+	if _, err := NewPrecomputedPublicKey(nil); err == nil {
+		t.Fatalf("precomputed a nil key")
+	}
+	if VerifyWithPrecomputed(nil, []byte{1}, one, one) {
+		t.Fatalf("verified with a nil key")
+	}
+}
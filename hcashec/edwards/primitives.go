@@ -84,9 +84,9 @@ func copyBytes64(aB []byte) *[64]byte {
 	return s
 }
 
-// zeroSlice zeroes the memory of a scalar byte slice.
+// zeroSlice zeroes the memory of a byte slice holding secret material.
 func zeroSlice(s []byte) {
-	for i := 0; i < PrivScalarSize; i++ {
+	for i := range s {
 		s[i] = 0x00
 	}
 
@@ -217,7 +217,7 @@ func (curve *TwistedEdwardsCurve) extendedToBigAffine(xi, yi,
 	var recip, x, y edwards25519.FieldElement
 
 	// Normalize to Z=1.
-	edwards25519.FeInvert(&recip, zi)
+	fieldInverse(&recip, zi)
 	edwards25519.FeMul(&x, xi, &recip)
 	edwards25519.FeMul(&y, yi, &recip)
 
@@ -260,6 +260,48 @@ func (curve *TwistedEdwardsCurve) EncodedBytesToBigIntPoint(s *[32]byte) (*big.I
 	return x, y, nil
 }
 
+// CompressPoint encodes the affine point (x, y) in the standard 32 byte
+// compressed form, which is y as a little endian integer with the most
+// significant bit set to the sign (the lowest bit) of x.
+func CompressPoint(x, y *big.Int) []byte {
+	if x == nil || y == nil {
+		return nil
+	}
+
+	return BigIntPointToEncodedBytes(x, y)[:]
+}
+
+// DecompressPoint decodes a point in the standard 32 byte compressed form
+// into affine coordinates, recovering x from y and the sign bit. Encodings
+// of y that are not reduced mod P, for which no x exists (the recovered x^2
+// isn't a square), or which set the sign bit of x = 0 are rejected.
+func DecompressPoint(curve *TwistedEdwardsCurve, b []byte) (x, y *big.Int,
+	err error) {
+	if len(b) != PubKeyBytesLen {
+		return nil, nil, fmt.Errorf("bad compressed point size (got %v, "+
+			"want %v)", len(b), PubKeyBytesLen)
+	}
+
+	s := copyBytes(b)
+	xIsNeg := s[31]>>7 == 1
+	yBytes := *s
+	yBytes[31] &^= 1 << 7
+	if EncodedBytesToBigInt(&yBytes).Cmp(curve.P) >= 0 {
+		return nil, nil, fmt.Errorf("y coordinate is not reduced")
+	}
+
+	// FromBytes fails if (y^2 - 1) / (d*y^2 + 1) has no square root.
+	x, y, err = curve.EncodedBytesToBigIntPoint(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if xIsNeg && new(big.Int).Mod(x, curve.P).Sign() == 0 {
+		return nil, nil, fmt.Errorf("sign bit set for x = 0")
+	}
+
+	return x, y, nil
+}
+
 // EncodedBytesToFieldElement converts a 32 byte little endian integer into
 // a field element.
 func EncodedBytesToFieldElement(s *[32]byte) *edwards25519.FieldElement {
@@ -287,9 +329,51 @@ func FieldElementToEncodedBytes(fe *edwards25519.FieldElement) *[32]byte {
 	return s
 }
 
-// invert inverts a big integer over the Ed25519 curve.
+// invert inverts a big integer over the Ed25519 curve with fieldInverse.
+// Zero has no inverse and gives zero.
 func (curve *TwistedEdwardsCurve) invert(a *big.Int) *big.Int {
-	sub2 := new(big.Int).Sub(curve.P, two)
-	inv := new(big.Int).Exp(a, sub2, curve.P)
-	return inv
+	fe := BigIntToFieldElement(new(big.Int).Mod(a, curve.P))
+	fieldInverse(fe, fe)
+	return FieldElementToBigInt(fe)
+}
+
+// feSquareN sets out = z^(2^n), squaring n times.
+func feSquareN(out, z *edwards25519.FieldElement, n int) {
+	edwards25519.FeSquare(out, z)
+	for i := 1; i < n; i++ {
+		edwards25519.FeSquare(out, out)
+	}
+}
+
+// fieldInverse sets out = z^-1 mod P, computed as z^(P-2) by Fermat's little
+// theorem with the fixed addition chain for P - 2 = 2^255 - 21 of ref10, 254
+// squarings and 11 multiplications. The sequence of operations doesn't
+// depend on z, so unlike big.Int.ModInverse it doesn't leak z through its
+// timing, which matters for points derived from secrets. out and z may be
+// the same element. Zero has no inverse and gives zero.
+func fieldInverse(out, z *edwards25519.FieldElement) {
+	var t0, t1, t2, t3 edwards25519.FieldElement
+
+	edwards25519.FeSquare(&t0, z)     // 2
+	feSquareN(&t1, &t0, 2)            // 8
+	edwards25519.FeMul(&t1, z, &t1)   // 9
+	edwards25519.FeMul(&t0, &t0, &t1) // 11
+	edwards25519.FeSquare(&t2, &t0)   // 22
+	edwards25519.FeMul(&t1, &t1, &t2) // 2^5 - 1
+	feSquareN(&t2, &t1, 5)            // 2^10 - 2^5
+	edwards25519.FeMul(&t1, &t2, &t1) // 2^10 - 1
+	feSquareN(&t2, &t1, 10)           // 2^20 - 2^10
+	edwards25519.FeMul(&t2, &t2, &t1) // 2^20 - 1
+	feSquareN(&t3, &t2, 20)           // 2^40 - 2^20
+	edwards25519.FeMul(&t2, &t3, &t2) // 2^40 - 1
+	feSquareN(&t2, &t2, 10)           // 2^50 - 2^10
+	edwards25519.FeMul(&t1, &t2, &t1) // 2^50 - 1
+	feSquareN(&t2, &t1, 50)           // 2^100 - 2^50
+	edwards25519.FeMul(&t2, &t2, &t1) // 2^100 - 1
+	feSquareN(&t3, &t2, 100)          // 2^200 - 2^100
+	edwards25519.FeMul(&t2, &t3, &t2) // 2^200 - 1
+	feSquareN(&t2, &t2, 50)           // 2^250 - 2^50
+	edwards25519.FeMul(&t1, &t2, &t1) // 2^250 - 1
+	feSquareN(&t1, &t1, 5)            // 2^255 - 2^5
+	edwards25519.FeMul(out, &t1, &t0) // 2^255 - 21
 }
@@ -13,7 +13,11 @@ package edwards
 import (
 	"bytes"
 	"encoding/hex"
+	"math/big"
+	"math/rand"
 	"testing"
+
+	"github.com/agl/ed25519/edwards25519"
 )
 
 // TestConvBetweenBigIntAndEncodedBytes tests the conversion between
@@ -313,3 +317,101 @@ func TestConvBetweenBigIntPointAndEncodedBytes(t *testing.T) {
 		pointIdx++
 	}
 }
+
+// TestCompressPoint tests the round trip between affine points and their
+// compressed encoding, and that invalid encodings are rejected
+func TestCompressPoint(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+
+	for i := 0; i < 100; i++ {
+		k := make([]byte, PrivScalarSize)
+		r.Read(k)
+		x, y := curve.ScalarBaseMult(k)
+
+		b := CompressPoint(x, y)
+		if len(b) != PubKeyBytesLen {
+			t.Fatalf("want %v bytes, got %v", PubKeyBytesLen, len(b))
+		}
+		if (b[31]>>7 == 1) != (x.Bit(0) == 1) {
+			t.Fatalf("sign bit doesn't match x")
+		}
+		dX, dY, err := DecompressPoint(curve, b)
+		if err != nil {
+			t.Fatalf("unexpected decompression error: %v", err)
+		}
+		if dX.Cmp(x) != 0 || dY.Cmp(y) != 0 {
+			t.Fatalf("want (%v, %v), got (%v, %v)", x, y, dX, dY)
+		}
+	}
+
+	// Find a y for which there is no x on the curve.
+	var noSquare []byte
+	for y := int64(2); noSquare == nil; y++ {
+		b := BigIntToEncodedBytes(big.NewInt(y))
+		p := new(edwards25519.ExtendedGroupElement)
+		if !p.FromBytes(b) {
+			noSquare = b[:]
+		}
+	}
+
+	// y = P + 1 is the identity, if y isn't reduced.
+	unreduced := BigIntToEncodedBytes(new(big.Int).Add(curve.P, one))
+
+	// The identity has x = 0, so it can't be negative.
+	negZero := BigIntToEncodedBytes(one)
+	negZero[31] |= 1 << 7
+
+	bad := [][]byte{noSquare, unreduced[:], negZero[:], make([]byte, 31)}
+	for _, b := range bad {
+		if _, _, err := DecompressPoint(curve, b); err == nil {
+			t.Fatalf("decompressed invalid encoding %x", b)
+		}
+	}
+}
+
+// TestFieldInverse tests fieldInverse against big.Int.ModInverse for random
+// field elements, edge values near P and in-place use, and that zero gives
+// zero
+func TestFieldInverse(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(818))
+
+	vals := []*big.Int{
+		big.NewInt(1),
+		big.NewInt(2),
+		new(big.Int).Sub(curve.P, one),
+		new(big.Int).Sub(curve.P, two),
+	}
+	for i := 0; i < 200; i++ {
+		vals = append(vals, new(big.Int).Rand(r, curve.P))
+	}
+	for _, v := range vals {
+		if v.Sign() == 0 {
+			continue
+		}
+		want := new(big.Int).ModInverse(v, curve.P)
+
+		var inv edwards25519.FieldElement
+		fieldInverse(&inv, BigIntToFieldElement(v))
+		if got := FieldElementToBigInt(&inv); got.Cmp(want) != 0 {
+			t.Fatalf("inverse of %v: want %v, got %v", v, want, got)
+		}
+		fe := BigIntToFieldElement(v)
+		fieldInverse(fe, fe)
+		if got := FieldElementToBigInt(fe); got.Cmp(want) != 0 {
+			t.Fatalf("in-place inverse of %v: want %v, got %v", v, want, got)
+		}
+		if got := curve.invert(new(big.Int).Add(v, curve.P)); got.Cmp(want) != 0 {
+			t.Fatalf("invert of unreduced %v: want %v, got %v", v, want, got)
+		}
+	}
+
+	var zeroInv edwards25519.FieldElement
+	fieldInverse(&zeroInv, new(edwards25519.FieldElement))
+	if FieldElementToBigInt(&zeroInv).Sign() != 0 {
+		t.Fatalf("want zero for the inverse of zero")
+	}
+}
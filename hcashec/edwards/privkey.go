@@ -10,10 +10,14 @@ import (
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/sha512"
+	"errors"
 	"fmt"
 	"math/big"
+	"sync"
+	"sync/atomic"
 
 	"github.com/agl/ed25519"
+	"github.com/agl/ed25519/edwards25519"
 )
 
 // These constants define the lengths of serialized private keys.
@@ -22,12 +26,44 @@ const (
 	PrivKeyBytesLen = 64
 )
 
+// ErrWipedKey is returned when signing with a private key which has had its
+// secret material wiped.
+var ErrWipedKey = errors.New("private key has been wiped")
+
+// ErrNoPrivateKey is returned when signing with a private key which holds no
+// private scalar, such as the zero value of PrivateKey. Signing with it would
+// otherwise produce an invalid signature from a nil scalar.
+var ErrNoPrivateKey = errors.New("private key has no private scalar")
+
 // PrivateKey wraps an ecdsa.PrivateKey as a convenience mainly for signing
 // things with the the private key without having to directly import the ecdsa
 // package.
 type PrivateKey struct {
 	ecPk   *ecdsa.PrivateKey
 	secret *[32]byte
+	wiped  bool
+
+	// ed448Seed is the secret seed of keys on the Ed448 curve.
+	ed448Seed *[Ed448SeedSize]byte
+
+	// pubKey caches the public key computed by ComputePubKey and records
+	// whether the key has been wiped. It's a pointer so that the key stays
+	// safe to copy and every copy sees the key wiped by Wipe, since the
+	// copies share the secret scalar. Keys built without one compute the
+	// public key on every call.
+	pubKey *pubKeyCache
+}
+
+// pubKeyCache is the public key computed by ComputePubKey, guarded by a
+// mutex so that the key can be used from several goroutines, along with
+// the wiped state shared by all copies of the key.
+type pubKeyCache struct {
+	mtx sync.Mutex
+	pub *PublicKey
+
+	// wiped is set to 1 by Wipe. It's accessed atomically so that it can
+	// be checked with or without holding mtx.
+	wiped int32
 }
 
 // NewPrivateKey instantiates a new private key from a scalar encoded as a
@@ -48,6 +84,7 @@ func GeneratePrivateKey(curve *TwistedEdwardsCurve) (*PrivateKey, error) {
 
 	pk := new(PrivateKey)
 	pk.ecPk = key
+	pk.pubKey = new(pubKeyCache)
 
 	return pk, nil
 }
@@ -81,28 +118,41 @@ func PrivKeyFromBytes(curve *TwistedEdwardsCurve,
 	// private key 64 bytes long, and stores the private scalar in
 	// the first 32 bytes and the public key in the last 32 bytes.
 	// So, make sure we only grab the actual scalar we care about.
-	privKeyBytes := pk[0:32]
 	pubKeyBytes := pk[32:64]
 	pubKey, err := ParsePubKey(curve, pubKeyBytes)
 	if err != nil {
 		return nil, nil
 	}
 
+	privEd := privKeyFromParts(pk, pubKey)
+
+	return privEd, (*PublicKey)(&privEd.ecPk.PublicKey)
+}
+
+// privKeyFromParts builds the private key of the 64 byte ed25519 private key
+// pk, the secret followed by the encoded public key, with the already
+// decoded public key pubKey.
+func privKeyFromParts(pk *[PrivKeyBytesLen]byte, pubKey *PublicKey) *PrivateKey {
 	priv := &ecdsa.PrivateKey{
 		PublicKey: *pubKey.ToECDSA(),
 		D:         new(big.Int).SetBytes(computeScalar(pk)[:]),
 	}
 	privEd := new(PrivateKey)
 	privEd.ecPk = priv
-	privEd.secret = copyBytes(privKeyBytes)
+	privEd.secret = copyBytes(pk[0:32])
+	privEd.pubKey = new(pubKeyCache)
 
-	return privEd, (*PublicKey)(&priv.PublicKey)
+	return privEd
 }
 
 // PrivKeyFromSecret returns a private and public key for `curve' based on the
-// 32-byte private key secret passed as an argument as a byte slice.
+// 32-byte private key secret passed as an argument as a byte slice. On the
+// Ed448 curve the secret is the 57 byte seed of RFC 8032.
 func PrivKeyFromSecret(curve *TwistedEdwardsCurve, s []byte) (*PrivateKey,
 	*PublicKey) {
+	if curve.isEd448() {
+		return ed448PrivKeyFromSecret(curve, s)
+	}
 	if len(s) != PrivKeyBytesLen/2 {
 		return nil, nil
 	}
@@ -118,28 +168,69 @@ func PrivKeyFromSecret(curve *TwistedEdwardsCurve, s []byte) (*PrivateKey,
 	return PrivKeyFromBytes(curve, pk[:])
 }
 
+// MinSeedSize is the minimum length of a seed accepted by
+// NewPrivateKeyFromSeed.
+const MinSeedSize = 32
+
+// NewPrivateKeyFromSeed deterministically derives a private key from seed,
+// without needing a source of randomness, so that wallets can regenerate the
+// exact same key from a mnemonic-derived seed. The seed must be at least
+// MinSeedSize bytes long. A 32 byte seed is used as the Ed25519 secret as is,
+// while longer seeds are first compressed to the first 32 bytes of their
+// SHA512 hash.
+//
+// The scalar is then derived from the secret following the Ed25519 rules:
+// the first 32 bytes of SHA512(secret) are clamped by clearing the three
+// lowest bits (making it a multiple of the cofactor 8), clearing the highest
+// bit and setting the second highest bit.
+func NewPrivateKeyFromSeed(curve *TwistedEdwardsCurve, seed []byte) (*PrivateKey,
+	error) {
+	if len(seed) < MinSeedSize {
+		return nil, fmt.Errorf("seed too short (got %v, want at least %v)",
+			len(seed), MinSeedSize)
+	}
+
+	secret := make([]byte, PrivKeyBytesLen/2)
+	defer zeroSlice(secret)
+	if len(seed) == len(secret) {
+		copy(secret, seed)
+	} else {
+		digest := sha512.Sum512(seed)
+		copy(secret, digest[:])
+		zeroSlice(digest[:])
+	}
+
+	priv, _ := PrivKeyFromSecret(curve, secret)
+	if priv == nil {
+		return nil, fmt.Errorf("failed to derive key from seed")
+	}
+
+	return priv, nil
+}
+
+// IsValidSecretKey returns whether or not b is a usable private scalar for
+// curve, a 32 byte big endian integer which is non-zero and below the order
+// N, so that it's non-zero mod N and its public key isn't the identity.
+// These are the scalars PrivKeyFromScalar accepts, so importers can check
+// raw key bytes before constructing a key from them.
+func IsValidSecretKey(curve *TwistedEdwardsCurve, b []byte) bool {
+	return checkScalar(curve, b, "private scalar") == nil
+}
+
 // PrivKeyFromScalar returns a private and public key for `curve' based on the
 // 32-byte private scalar passed as an argument as a byte slice (encoded big
-// endian int).
+// endian int). An invalid scalar is rejected with a ScalarError.
 func PrivKeyFromScalar(curve *TwistedEdwardsCurve, p []byte) (*PrivateKey,
 	*PublicKey, error) {
-	if len(p) != PrivScalarSize {
-		return nil, nil, fmt.Errorf("bad private scalar size")
+	// The scalar must be non-zero and in the subgroup.
+	if err := checkScalar(curve, p, "private scalar"); err != nil {
+		return nil, nil, err
 	}
 
 	pk := new(PrivateKey)
 	pk.ecPk = new(ecdsa.PrivateKey)
 	pk.ecPk.D = new(big.Int).SetBytes(p)
-
-	// The scalar must be in the subgroup.
-	if pk.ecPk.D.Cmp(curve.N) > 0 {
-		return nil, nil, fmt.Errorf("not on subgroup (>N)")
-	}
-
-	// The scalar must not be zero or negative.
-	if pk.ecPk.D.Cmp(zero) <= 0 {
-		return nil, nil, fmt.Errorf("zero or negative scalar")
-	}
+	pk.pubKey = new(pubKeyCache)
 
 	pk.ecPk.Curve = curve
 	pk.ecPk.PublicKey.X, pk.ecPk.PublicKey.Y =
@@ -153,11 +244,127 @@ func PrivKeyFromScalar(curve *TwistedEdwardsCurve, p []byte) (*PrivateKey,
 	return pk, &pub, nil
 }
 
-// Public returns the PublicKey corresponding to this private key.
+// reducedScalar returns the private scalar of p reduced mod N. Keys derived
+// from a secret store the clamped scalar little endian in D, so it has to be
+// recomputed from the secret for them.
+func (p PrivateKey) reducedScalar(curve *TwistedEdwardsCurve) *big.Int {
+	var a [32]byte
+	if p.secret == nil {
+		d := BigIntToEncodedBytes(p.ecPk.D)
+		a = ScalarReduce(d[:])
+		zeroSlice(d[:])
+	} else {
+		var pk [PrivKeyBytesLen]byte
+		copy(pk[:], p.secret[:])
+		scalar := computeScalar(&pk)
+		a = ScalarReduce(scalar[:])
+		zeroSlice(pk[:])
+		zeroSlice(scalar[:])
+	}
+	defer zeroSlice(a[:])
+
+	return EncodedBytesToBigInt(&a)
+}
+
+// signingErr returns the error signing with p fails with, ErrWipedKey if its
+// secret material has been wiped and ErrNoPrivateKey if it has no private
+// scalar, or nil if p can be used for signing.
+func (p *PrivateKey) signingErr() error {
+	if p != nil && p.isWiped() {
+		return ErrWipedKey
+	}
+	if p == nil || p.ecPk == nil || p.ecPk.D == nil || p.ecPk.D.Sign() == 0 {
+		return ErrNoPrivateKey
+	}
+
+	return nil
+}
+
+// isWiped returns whether or not p or any other copy of the key has been
+// wiped.
+func (p *PrivateKey) isWiped() bool {
+	return p.wiped || (p.pubKey != nil &&
+		atomic.LoadInt32(&p.pubKey.wiped) != 0)
+}
+
+// PubKey returns the verification-only public key corresponding to this
+// private key. It holds no secret material, so it can be handed to code which
+// only verifies signatures without giving it the ability to sign.
+func (p PrivateKey) PubKey() *PublicKey {
+	curve, _ := p.ecPk.Curve.(*TwistedEdwardsCurve)
+	return NewPublicKey(curve, p.ecPk.PublicKey.X, p.ecPk.PublicKey.Y)
+}
+
+// Public returns the coordinates of the public key corresponding to this
+// private key, as required by the chainec PrivateKey interface. PubKey and
+// ComputePubKey return it as a *PublicKey.
 func (p PrivateKey) Public() (*big.Int, *big.Int) {
 	return p.ecPk.PublicKey.X, p.ecPk.PublicKey.Y
 }
 
+// ComputePubKey computes the public key D*G from the private scalar of the
+// key, rather than returning the one stored alongside it as PubKey does. The
+// result is deterministic and cached, so only the first call pays for the
+// scalar multiplication, and it's safe to call from several goroutines.
+// Every call returns a new copy, so callers may modify it. It returns nil
+// for keys which hold no private scalar, and for wiped keys, whose cached
+// public key is dropped by Wipe.
+func (p *PrivateKey) ComputePubKey() *PublicKey {
+	if p == nil {
+		return nil
+	}
+	if p.pubKey == nil {
+		return p.computePubKey()
+	}
+
+	p.pubKey.mtx.Lock()
+	defer p.pubKey.mtx.Unlock()
+	if p.signingErr() != nil {
+		return nil
+	}
+	if p.pubKey.pub == nil {
+		p.pubKey.pub = p.computePubKey()
+		if p.pubKey.pub == nil {
+			return nil
+		}
+	}
+
+	pub := p.pubKey.pub
+	curve, _ := pub.Curve.(*TwistedEdwardsCurve)
+	return NewPublicKey(curve, new(big.Int).Set(pub.X),
+		new(big.Int).Set(pub.Y))
+}
+
+// computePubKey computes the public key D*G of ComputePubKey without
+// caching it.
+func (p *PrivateKey) computePubKey() *PublicKey {
+	if p.signingErr() != nil {
+		return nil
+	}
+	curve, ok := p.ecPk.Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil {
+		return nil
+	}
+
+	if curve.isEd448() {
+		x, y := curve.ed448BaseMultSecret(p.ecPk.D)
+		return NewPublicKey(curve, x, y)
+	}
+
+	a := p.reducedScalar(curve)
+	aLE := BigIntToEncodedBytes(a)
+	a.SetInt64(0)
+	defer zeroSlice(aLE[:])
+	var pub edwards25519.ExtendedGroupElement
+	edwards25519.GeScalarMultBase(&pub, aLE)
+	pubKey, err := geToPublicKey(curve, &pub)
+	if err != nil {
+		return nil
+	}
+
+	return pubKey
+}
+
 // ToECDSA returns the private key as a *ecdsa.PrivateKey.
 func (p PrivateKey) ToECDSA() *ecdsa.PrivateKey {
 	return p.ecPk
@@ -200,3 +407,65 @@ func (p PrivateKey) GetD() *big.Int {
 func (p PrivateKey) GetType() int {
 	return ecTypeEdwards
 }
+
+// Wipe overwrites the secret scalar and the secret seed of the private key
+// with zeros. The secret nonces used in threshold signing are private keys
+// as well and should be wiped in the same way once the partial signature
+// has been produced. Any later attempt to sign with a wiped key fails with
+// ErrWipedKey, including with copies of the key made before it was wiped,
+// which share its secret material. Wipe is safe to call multiple times.
+//
+// Copies previously handed out by Serialize or SerializeSecret are not
+// affected and must be cleared by the caller.
+func (p *PrivateKey) Wipe() {
+	if p == nil {
+		return
+	}
+	if p.pubKey != nil {
+		p.pubKey.mtx.Lock()
+		defer p.pubKey.mtx.Unlock()
+		p.pubKey.pub = nil
+		atomic.StoreInt32(&p.pubKey.wiped, 1)
+	}
+
+	if p.ecPk != nil && p.ecPk.D != nil {
+		words := p.ecPk.D.Bits()
+		for i := range words {
+			words[i] = 0
+		}
+		p.ecPk.D.SetInt64(0)
+	}
+	if p.secret != nil {
+		for i := range p.secret {
+			p.secret[i] = 0x00
+		}
+	}
+	if p.ed448Seed != nil {
+		zeroSlice(p.ed448Seed[:])
+	}
+	p.wiped = true
+}
+
+// IsWiped returns whether or not the secret material of the private key
+// has been wiped.
+func (p PrivateKey) IsWiped() bool {
+	return p.isWiped()
+}
+
+// Equal reports whether p and other hold the same secret scalar. The
+// scalars are compared in constant time. Equal returns false if other is nil
+// or either key is incomplete or wiped.
+func (p PrivateKey) Equal(other *PrivateKey) bool {
+	if other == nil || p.ecPk == nil || other.ecPk == nil ||
+		p.ecPk.D == nil || other.ecPk.D == nil || p.isWiped() ||
+		other.isWiped() {
+		return false
+	}
+
+	d1 := BigIntToEncodedBytesNoReverse(p.ecPk.D)
+	defer zeroSlice(d1[:])
+	d2 := BigIntToEncodedBytesNoReverse(other.ecPk.D)
+	defer zeroSlice(d2[:])
+
+	return ConstantTimeScalarEqual(d1[:], d2[:])
+}
@@ -0,0 +1,408 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+// TestPrivateKeyWipe tests that wiping clears the secret material and that
+// wiped keys refuse to sign
+func TestPrivateKeyWipe(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := []byte("Hello World in TestPrivateKeyWipe")
+
+	sk := mockUpSecKeysByBytes(curve, 1)[0]
+	d := sk.GetD()
+	secret := sk.secret
+	sk.Wipe()
+	sk.Wipe()
+
+	if !sk.IsWiped() {
+		t.Fatalf("key not marked as wiped")
+	}
+	if d.Sign() != 0 {
+		t.Fatalf("scalar not wiped: %v", d)
+	}
+	for _, b := range secret {
+		if b != 0x00 {
+			t.Fatalf("secret seed not wiped: %x", secret[:])
+		}
+	}
+	if _, _, err := Sign(curve, sk, msg); err != ErrWipedKey {
+		t.Fatalf("want %v, got %v", ErrWipedKey, err)
+	}
+	if _, err := SignDeterministic(curve, sk, msg); err != ErrWipedKey {
+		t.Fatalf("want %v, got %v", ErrWipedKey, err)
+	}
+
+	// A wiped secret nonce must stop threshold signing as well.
+	msg = []byte("Hello World in TestPrivateKeyWipe")[:PrivScalarSize]
+	keyVec := mockUpSchnorrKeyVec(curve, 2, msg)
+	keyVec.secNonceVec[0].Wipe()
+	_, _, err := SchnorrPartialSign(curve, msg, keyVec.skVec[0],
+		keyVec.pkVecSum, keyVec.secNonceVec[0], keyVec.pubNonceVecSum)
+	if err != ErrWipedKey {
+		t.Fatalf("want %v, got %v", ErrWipedKey, err)
+	}
+
+	// Wiping nil is a no-op.
+	var nilKey *PrivateKey
+	nilKey.Wipe()
+}
+
+// TestPrivateKeyWipeCopy tests that copies of a key made before it was
+// wiped, which share its secret material, refuse to sign as well
+func TestPrivateKeyWipeCopy(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	curve448 := new(TwistedEdwardsCurve)
+	curve448.InitParamEd448()
+	msg := []byte("Hello World in TestPrivateKeyWipeCopy")
+
+	seed448 := make([]byte, Ed448SeedSize)
+	seed448[0] = 0x01
+	sk448, _ := PrivKeyFromSecret(curve448, seed448)
+	sk := mockUpSecKeysByBytes(curve, 1)[0]
+	for i, c := range []struct {
+		curve *TwistedEdwardsCurve
+		sk    *PrivateKey
+	}{{curve, sk}, {curve448, sk448}} {
+		cp := *c.sk
+		if _, _, err := Sign(c.curve, &cp, msg); err != nil {
+			t.Fatalf("test %d: unexpected signing error: %v", i, err)
+		}
+		c.sk.Wipe()
+
+		if !cp.IsWiped() {
+			t.Fatalf("test %d: copy not marked as wiped", i)
+		}
+		if _, _, err := Sign(c.curve, &cp, msg); err != ErrWipedKey {
+			t.Fatalf("test %d: want %v, got %v", i, ErrWipedKey, err)
+		}
+		if pub := cp.ComputePubKey(); pub != nil {
+			t.Fatalf("test %d: computed the public key of a wiped copy", i)
+		}
+	}
+
+	// A copy of a key without the shared state still refuses to sign with
+	// the zeroed scalar.
+	sk = mockUpSecKeysByBytes(curve, 1)[0]
+	sk.pubKey = nil
+	cp := *sk
+	sk.Wipe()
+	if _, _, err := Sign(curve, &cp, msg); err != ErrNoPrivateKey {
+		t.Fatalf("want %v, got %v", ErrNoPrivateKey, err)
+	}
+}
+
+// TestPrivateKeyNoScalar tests that keys without a private scalar refuse to
+// sign, and that the public key of a private key still verifies
+func TestPrivateKeyNoScalar(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := []byte("Hello World in TestPrivateKeyNoScalar")[:PrivScalarSize]
+
+	sk := mockUpSecKeysByBytes(curve, 1)[0]
+	pub := sk.PubKey()
+	r, s, err := Sign(curve, sk, msg)
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+	if !Verify(pub, msg, r, s) {
+		t.Fatalf("signature failed to verify with the public key")
+	}
+
+	// A key holding only the public half, as well as the zero value.
+	pubOnly := &PrivateKey{ecPk: &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey(*pub),
+	}}
+	for i, key := range []*PrivateKey{pubOnly, new(PrivateKey)} {
+		if _, _, err := Sign(curve, key, msg); err != ErrNoPrivateKey {
+			t.Fatalf("key %v: want %v, got %v", i, ErrNoPrivateKey, err)
+		}
+		_, err := SignDeterministic(curve, key, msg)
+		if err != ErrNoPrivateKey {
+			t.Fatalf("key %v: want %v, got %v", i, ErrNoPrivateKey, err)
+		}
+		_, _, err = SignFromScalar(curve, key, msg, msg)
+		if err != ErrNoPrivateKey {
+			t.Fatalf("key %v: want %v, got %v", i, ErrNoPrivateKey, err)
+		}
+		_, err = SignAdaptor(curve, key, msg, pub)
+		if err != ErrNoPrivateKey {
+			t.Fatalf("key %v: want %v, got %v", i, ErrNoPrivateKey, err)
+		}
+	}
+
+	// Nonces are private keys as well.
+	keyVec := mockUpSchnorrKeyVec(curve, 2, msg)
+	_, _, err = SchnorrPartialSign(curve, msg, keyVec.skVec[0],
+		keyVec.pkVecSum, new(PrivateKey), keyVec.pubNonceVecSum)
+	if err != ErrNoPrivateKey {
+		t.Fatalf("want %v, got %v", ErrNoPrivateKey, err)
+	}
+}
+
+// TestPrivateKeyEqual tests comparison of private keys
+func TestPrivateKeyEqual(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	sks := mockUpSecKeysByScalars(curve, 2)
+	same, _, err := PrivKeyFromScalar(curve, sks[0].Serialize())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !sks[0].Equal(same) {
+		t.Fatalf("equal keys compared different")
+	}
+	if sks[0].Equal(sks[1]) {
+		t.Fatalf("different keys compared equal")
+	}
+	if sks[0].Equal(nil) || sks[0].Equal(&PrivateKey{}) {
+		t.Fatalf("key compared equal to a nil or empty key")
+	}
+
+	same.Wipe()
+	if sks[0].Equal(same) {
+		t.Fatalf("key compared equal to a wiped key")
+	}
+}
+
+// TestScalarErrors tests that invalid scalars are rejected with a ScalarError
+// carrying the reason
+func TestScalarErrors(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	nMinusOne := new(big.Int).Sub(curve.N, one)
+	tests := []struct {
+		name   string
+		scalar []byte
+		reason ScalarErrorReason
+	}{
+		{"short", make([]byte, PrivScalarSize-1), ScalarWrongLength},
+		{"long", make([]byte, PrivScalarSize+1), ScalarWrongLength},
+		{"zero", make([]byte, PrivScalarSize), ScalarZero},
+		{"N", BigIntToEncodedBytesNoReverse(curve.N)[:], ScalarOutOfRange},
+		{"N+1", BigIntToEncodedBytesNoReverse(
+			new(big.Int).Add(curve.N, one))[:], ScalarOutOfRange},
+	}
+
+	sks := mockUpSecKeysByScalars(curve, 1)
+	msg := []byte("Hello World in TestScalarErrors!")
+	for _, test := range tests {
+		_, _, err := PrivKeyFromScalar(curve, test.scalar)
+		if !errors.Is(err, ErrInvalidScalar) {
+			t.Fatalf("%v: want ErrInvalidScalar, got %v", test.name, err)
+		}
+		if !errors.Is(err, ScalarError{Reason: test.reason}) {
+			t.Fatalf("%v: want reason %v, got %v", test.name, test.reason,
+				err)
+		}
+		if serr, ok := err.(ScalarError); !ok || serr.Reason != test.reason {
+			t.Fatalf("%v: want a ScalarError with reason %v, got %v",
+				test.name, test.reason, err)
+		}
+
+		_, _, err = SignFromScalar(curve, sks[0], test.scalar, msg)
+		if !errors.Is(err, ScalarError{Reason: test.reason}) {
+			t.Fatalf("%v: want nonce reason %v, got %v", test.name,
+				test.reason, err)
+		}
+	}
+
+	// The largest valid scalar is accepted.
+	if _, _, err := PrivKeyFromScalar(curve,
+		BigIntToEncodedBytesNoReverse(nMinusOne)[:]); err != nil {
+		t.Fatalf("unexpected error for N-1: %v", err)
+	}
+}
+
+// TestNewPrivateKeyFromSeed tests deterministic key derivation from seeds
+func TestNewPrivateKeyFromSeed(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	// RFC 8032 section 7.1, test 1.
+	seed, _ := hex.DecodeString("9d61b19deffd5a60ba844af492ec2cc44449c569" +
+		"7b326919703bac031cae7f60")
+	wantPub, _ := hex.DecodeString("d75a980182b10ab7d54bfed3c964073a0ee172f" +
+		"3daa62325af021a68f707511a")
+
+	priv, err := NewPrivateKeyFromSeed(curve, seed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pubX, pubY := priv.Public()
+	pub := NewPublicKey(curve, pubX, pubY)
+	if !bytes.Equal(pub.Serialize(), wantPub) {
+		t.Fatalf("want pubkey %x, got %x", wantPub, pub.Serialize())
+	}
+
+	// The scalar is the clamped hash of the seed.
+	digest := sha512.Sum512(seed)
+	digest[0] &= 248
+	digest[31] &= 127
+	digest[31] |= 64
+	var clamped [32]byte
+	copy(clamped[:], digest[:32])
+	x, y := curve.ScalarBaseMult(EncodedBytesToBigInt(&clamped).Bytes())
+	if x.Cmp(pubX) != 0 || y.Cmp(pubY) != 0 {
+		t.Fatalf("pubkey does not match the clamped scalar")
+	}
+
+	// Longer seeds are deterministic too.
+	longSeed := bytes.Repeat([]byte{0x42}, 64)
+	priv1, err := NewPrivateKeyFromSeed(curve, longSeed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	priv2, err := NewPrivateKeyFromSeed(curve, longSeed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !priv1.Equal(priv2) {
+		t.Fatalf("same seed gave different keys")
+	}
+	if priv1.Equal(priv) {
+		t.Fatalf("different seeds gave the same key")
+	}
+
+	if _, err := NewPrivateKeyFromSeed(curve, seed[:MinSeedSize-1]); err == nil {
+		t.Fatalf("short seed accepted")
+	}
+}
+
+// TestComputePubKey tests that the public key computed from the private
+// scalar matches the one produced along with the key, that it's cached, and
+// that wiping drops it
+func TestComputePubKey(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	var keys []*PrivateKey
+	for i := 0; i < 5; i++ {
+		priv, err := GeneratePrivateKey(curve)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		keys = append(keys, priv)
+	}
+	keys = append(keys, mockUpSecKeysByBytes(curve, 5)...)
+
+	ed448 := new(TwistedEdwardsCurve)
+	ed448.InitParamEd448()
+	ed448Priv, _ := PrivKeyFromSecret(ed448, bytes.Repeat([]byte{0x07},
+		Ed448SeedSize))
+	keys = append(keys, ed448Priv)
+
+	for i, priv := range keys {
+		pub := priv.ComputePubKey()
+		if pub == nil || !pub.Equal(priv.PubKey()) {
+			t.Fatalf("test %d: computed public key %v, want %v", i, pub,
+				priv.PubKey())
+		}
+		if priv.pubKey.pub == nil {
+			t.Fatalf("test %d: public key not cached", i)
+		}
+
+		// Every call returns its own copy of the cached key.
+		pub.X.SetInt64(0)
+		again := priv.ComputePubKey()
+		if again == pub || !again.Equal(priv.PubKey()) {
+			t.Fatalf("test %d: cached public key shared with callers", i)
+		}
+
+		priv.Wipe()
+		if priv.pubKey.pub != nil || priv.ComputePubKey() != nil {
+			t.Fatalf("test %d: public key of a wiped key", i)
+		}
+	}
+
+	var nilKey *PrivateKey
+	if nilKey.ComputePubKey() != nil || new(PrivateKey).ComputePubKey() != nil {
+		t.Fatalf("public key of a key without a scalar")
+	}
+}
+
+// TestComputePubKeyConcurrency tests that ComputePubKey can be called from
+// several goroutines at once, run with -race to catch unguarded accesses to
+// the cache
+func TestComputePubKeyConcurrency(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	priv, err := GeneratePrivateKey(curve)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	const goroutines = 8
+	pubs := make([]*PublicKey, goroutines)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pubs[i] = priv.ComputePubKey()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, pub := range pubs {
+		if pub == nil || !pub.Equal(priv.PubKey()) {
+			t.Fatalf("goroutine %d: computed public key %v, want %v", i, pub,
+				priv.PubKey())
+		}
+	}
+}
+
+// TestIsValidSecretKey tests the validity check of raw private scalars
+func TestIsValidSecretKey(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	nMinusOne := new(big.Int).Sub(curve.N, one)
+	nPlusOne := new(big.Int).Add(curve.N, one)
+	tests := []struct {
+		name  string
+		b     []byte
+		valid bool
+	}{
+		{"zero", make([]byte, PrivScalarSize), false},
+		{"N", BigIntToEncodedBytesNoReverse(curve.N)[:], false},
+		{"N+1", BigIntToEncodedBytesNoReverse(nPlusOne)[:], false},
+		{"all ones", bytes.Repeat([]byte{0xff}, PrivScalarSize), false},
+		{"short", []byte{0x01}, false},
+		{"long", append(make([]byte, PrivScalarSize), 0x01), false},
+		{"nil", nil, false},
+		{"one", BigIntToEncodedBytesNoReverse(one)[:], true},
+		{"N-1", BigIntToEncodedBytesNoReverse(nMinusOne)[:], true},
+		{"normal", mockUpSecKeysByScalars(curve, 1)[0].Serialize(), true},
+	}
+	for _, test := range tests {
+		if got := IsValidSecretKey(curve, test.b); got != test.valid {
+			t.Fatalf("%s: got %v, want %v", test.name, got, test.valid)
+		}
+
+		// Valid scalars are exactly the ones keys can be made of.
+		_, _, err := PrivKeyFromScalar(curve, test.b)
+		if (err == nil) != test.valid {
+			t.Fatalf("%s: PrivKeyFromScalar error %v disagrees", test.name,
+				err)
+		}
+	}
+}
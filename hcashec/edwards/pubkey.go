@@ -7,9 +7,14 @@ package edwards
 
 import (
 	"crypto/ecdsa"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
 )
 
 // These constants define the lengths of serialized public keys.
@@ -18,18 +23,107 @@ const (
 )
 
 // PublicKey is an ecdsa.PublicKey with an additional function to
-// serialize.
+// serialize. A PublicKey holds no secret material, so it can only be used to
+// verify signatures with Verify and friends. PrivateKey.PubKey returns the
+// public key of a private key for code which shouldn't be able to sign.
 type PublicKey ecdsa.PublicKey
 
+// ErrIdentityKey is returned when a public key, typically the result of
+// combining several keys, is the identity point. Every signature verifies
+// under the identity with S = r and R = rG whatever the message, so such a
+// key must never be used, and keys that cancel out when combined are a sign
+// of a rogue key attack.
+var ErrIdentityKey = errors.New("public key is the identity")
+
 // NewPublicKey instantiates a new public key.
 func NewPublicKey(curve *TwistedEdwardsCurve, x *big.Int, y *big.Int) *PublicKey {
 	return &PublicKey{curve, x, y}
 }
 
+// isIdentity returns whether or not (x, y) is the identity (0, 1) of a
+// twisted Edwards curve.
+func isIdentity(x, y *big.Int) bool {
+	return x.Sign() == 0 && y.Cmp(one) == 0
+}
+
+// NewPublicKeyChecked instantiates a new public key like NewPublicKey, after
+// making sure the point is on the curve and is not the identity, in which
+// case ErrIdentityKey is returned.
+func NewPublicKeyChecked(curve *TwistedEdwardsCurve, x *big.Int,
+	y *big.Int) (*PublicKey, error) {
+	if x == nil || y == nil {
+		return nil, fmt.Errorf("public key coordinates are nil")
+	}
+	pub := NewPublicKey(curve, x, y)
+	if !pub.IsOnCurve() {
+		return nil, fmt.Errorf("public key is off curve")
+	}
+	if isIdentity(x, y) {
+		return nil, ErrIdentityKey
+	}
+
+	return pub, nil
+}
+
+// IsOnCurve returns whether or not the coordinates of the public key are a
+// point on its curve, with both of them reduced mod P. Keys built from
+// untrusted coordinates with NewPublicKey should be checked with it, or
+// with IsInSubgroup, before use.
+func (p PublicKey) IsOnCurve() bool {
+	curve, ok := p.Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil || p.X == nil || p.Y == nil {
+		return false
+	}
+	if p.X.Sign() < 0 || p.X.Cmp(curve.P) >= 0 || p.Y.Sign() < 0 ||
+		p.Y.Cmp(curve.P) >= 0 {
+		return false
+	}
+
+	return curve.IsOnCurve(p.X, p.Y)
+}
+
+// IsInSubgroup returns whether or not the public key is a point of the prime
+// order subgroup, the same check ParsePubKey makes. The identity, the other
+// points of small order and points with a small order component are
+// rejected. It costs a scalar multiplication, so IsOnCurve is cheaper where
+// small order points don't matter.
+func (p PublicKey) IsInSubgroup() bool {
+	if !p.IsOnCurve() {
+		return false
+	}
+
+	curve := p.Curve.(*TwistedEdwardsCurve)
+	if curve.isEd448() {
+		return curve.ed448IsPrimeOrderPoint(ed448FromAffine(p.X, p.Y))
+	}
+	var point edwards25519.ExtendedGroupElement
+	if !point.FromBytes(BigIntPointToEncodedBytes(p.X, p.Y)) {
+		return false
+	}
+
+	return curve.isPrimeOrderPoint(&point)
+}
+
 // ParsePubKey parses a public key for an edwards curve from a bytestring into a
-// ecdsa.Publickey, verifying that it is valid.
+// ecdsa.Publickey, verifying that it is valid. The input must be exactly
+// PubKeyBytesLen bytes long. Points which are not in the prime order
+// subgroup are rejected, namely the eight points of small order and points
+// with a small order component, since they allow equivocation in
+// multisignatures and some attacks on verification.
 func ParsePubKey(curve *TwistedEdwardsCurve, pubKeyStr []byte) (key *PublicKey,
 	err error) {
+	if curve.isEd448() {
+		return ed448ParsePubKey(curve, pubKeyStr)
+	}
+
+	if len(pubKeyStr) == 0 {
+		return nil, errors.New("pubkey string is empty")
+	}
+	if len(pubKeyStr) != PubKeyBytesLen {
+		return nil, fmt.Errorf("bad pubkey size; have %v, want %v",
+			len(pubKeyStr), PubKeyBytesLen)
+	}
+
 	pubkey := PublicKey{}
 	pubkey.Curve = curve
 	x, y, err := curve.EncodedBytesToBigIntPoint(copyBytes(pubKeyStr))
@@ -39,9 +133,6 @@ func ParsePubKey(curve *TwistedEdwardsCurve, pubKeyStr []byte) (key *PublicKey,
 	pubkey.X = x
 	pubkey.Y = y
 
-	if len(pubKeyStr) == 0 {
-		return nil, errors.New("pubkey string is empty")
-	}
 	if pubkey.X.Cmp(pubkey.Curve.Params().P) >= 0 {
 		return nil, fmt.Errorf("pubkey X parameter is >= to P")
 	}
@@ -49,6 +140,14 @@ func ParsePubKey(curve *TwistedEdwardsCurve, pubKeyStr []byte) (key *PublicKey,
 		return nil, fmt.Errorf("pubkey Y parameter is >= to P")
 	}
 
+	p := new(edwards25519.ExtendedGroupElement)
+	if !p.FromBytes(BigIntPointToEncodedBytes(x, y)) {
+		return nil, fmt.Errorf("point not on curve")
+	}
+	if !curve.isPrimeOrderPoint(p) {
+		return nil, fmt.Errorf("pubkey is not in the prime order subgroup")
+	}
+
 	return &pubkey, nil
 }
 
@@ -58,11 +157,15 @@ func (p PublicKey) ToECDSA() *ecdsa.PublicKey {
 	return &pkecdsa
 }
 
-// Serialize serializes a public key in a 32-byte compressed little endian format.
+// Serialize serializes a public key in a 32-byte compressed little endian format,
+// or 57 bytes for the Ed448 curve.
 func (p PublicKey) Serialize() []byte {
 	if p.X == nil || p.Y == nil {
 		return nil
 	}
+	if curve, ok := p.Curve.(*TwistedEdwardsCurve); ok && curve.isEd448() {
+		return ed448Encode(p.X, p.Y)
+	}
 	return BigIntPointToEncodedBytes(p.X, p.Y)[:]
 }
 
@@ -100,3 +203,94 @@ func (p PublicKey) GetY() *big.Int {
 func (p PublicKey) GetType() int {
 	return ecTypeEdwards
 }
+
+// Equal reports whether p and other represent the same point. The affine
+// coordinates are compared after being reduced modulo the field prime, so
+// different representations of the same point compare equal. Equal returns
+// false if other is nil or either key is incomplete.
+func (p PublicKey) Equal(other *PublicKey) bool {
+	if other == nil || p.X == nil || p.Y == nil || other.X == nil ||
+		other.Y == nil || p.Curve == nil {
+		return false
+	}
+
+	prime := p.Curve.Params().P
+	x1 := new(big.Int).Mod(p.X, prime)
+	y1 := new(big.Int).Mod(p.Y, prime)
+	x2 := new(big.Int).Mod(other.X, prime)
+	y2 := new(big.Int).Mod(other.Y, prime)
+
+	return x1.Cmp(x2) == 0 && y1.Cmp(y2) == 0
+}
+
+// ConstantTimeEqual reports whether p and other represent the same point,
+// like Equal, but compares the reduced coordinates with
+// subtle.ConstantTimeCompare, so the time taken doesn't depend on how much
+// of them match. Points derived from secrets, such as a public nonce being
+// matched with its commitment, should be compared with it rather than with
+// Equal. Only the comparison itself is constant time: the reduction mod P
+// uses big.Int arithmetic, whose timing can depend on the size of the
+// values. ConstantTimeEqual returns false if other is nil or either key is
+// incomplete.
+func (p PublicKey) ConstantTimeEqual(other *PublicKey) bool {
+	if other == nil || p.X == nil || p.Y == nil || other.X == nil ||
+		other.Y == nil || p.Curve == nil {
+		return false
+	}
+
+	prime := p.Curve.Params().P
+	size := (prime.BitLen() + 7) / 8
+	a := make([]byte, 2*size)
+	b := make([]byte, 2*size)
+	putReduced(a[:size], p.X, prime)
+	putReduced(a[size:], p.Y, prime)
+	putReduced(b[:size], other.X, prime)
+	putReduced(b[size:], other.Y, prime)
+
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// putReduced writes v mod prime to b as a big endian number padded to the
+// length of b, which must be large enough to hold any number below prime.
+func putReduced(b []byte, v, prime *big.Int) {
+	vb := new(big.Int).Mod(v, prime).Bytes()
+	copy(b[len(b)-len(vb):], vb)
+}
+
+// MarshalJSON satisfies the json.Marshaler interface, encoding the public key
+// as the hex string of its compressed form, 32 bytes for Ed25519 and 57
+// bytes for Ed448.
+func (p PublicKey) MarshalJSON() ([]byte, error) {
+	if p.X == nil || p.Y == nil {
+		return nil, errors.New("cannot marshal incomplete public key")
+	}
+
+	return json.Marshal(hex.EncodeToString(p.Serialize()))
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface, decoding a public
+// key from the hex string of its compressed form, on the curve told by its
+// size. The key is validated the same way as by ParsePubKey.
+func (p *PublicKey) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("public key is not a JSON string: %v", err)
+	}
+	b, err := hex.DecodeString(str)
+	if err != nil {
+		return fmt.Errorf("public key is not valid hex: %v", err)
+	}
+	curve := curveForPointSize(len(b))
+	if curve == nil {
+		return fmt.Errorf("bad public key size (got %v, want %v or %v)",
+			len(b), PubKeyBytesLen, Ed448PubKeyBytesLen)
+	}
+
+	pub, err := ParsePubKey(curve, b)
+	if err != nil {
+		return err
+	}
+	*p = *pub
+
+	return nil
+}
@@ -0,0 +1,317 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// TestPublicKeyEqual tests comparison of public keys
+func TestPublicKeyEqual(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	sks := mockUpSecKeysByScalars(curve, 2)
+	x0, y0 := sks[0].Public()
+	x1, y1 := sks[1].Public()
+	pk0 := NewPublicKey(curve, x0, y0)
+	pk1 := NewPublicKey(curve, x1, y1)
+
+	parsed, err := ParsePubKey(curve, pk0.Serialize())
+	if err != nil {
+		t.Fatalf("unexpected parsing error: %v", err)
+	}
+	if !pk0.Equal(parsed) {
+		t.Fatalf("parsed key not equal to the original")
+	}
+
+	// Unreduced coordinates still represent the same point.
+	unreduced := NewPublicKey(curve, new(big.Int).Add(x0, curve.P), y0)
+	if !pk0.Equal(unreduced) || !unreduced.Equal(pk0) {
+		t.Fatalf("unreduced key not equal to the original")
+	}
+
+	if pk0.Equal(pk1) {
+		t.Fatalf("different keys compared equal")
+	}
+	if pk0.Equal(nil) {
+		t.Fatalf("key compared equal to nil")
+	}
+	if pk0.Equal(&PublicKey{}) || (PublicKey{}).Equal(pk0) {
+		t.Fatalf("key compared equal to an empty key")
+	}
+}
+
+// smallOrderPoints are the encodings of the eight points of small order
+// (dividing the cofactor 8) on the curve.
+var smallOrderPoints = []string{
+	// Identity, order 1.
+	"0100000000000000000000000000000000000000000000000000000000000000",
+	// Order 2.
+	"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+	// Order 4.
+	"0000000000000000000000000000000000000000000000000000000000000000",
+	"0000000000000000000000000000000000000000000000000000000000000080",
+	// Order 8.
+	"c7176a703d4dd84fba3c0b760d10670f2a2053fa2c39ccc64ec7fd7792ac037a",
+	"c7176a703d4dd84fba3c0b760d10670f2a2053fa2c39ccc64ec7fd7792ac03fa",
+	"26e8958fc2b227b045c3f489f2ef98f0d5dfac05d3c63339b13802886d53fc05",
+	"26e8958fc2b227b045c3f489f2ef98f0d5dfac05d3c63339b13802886d53fc85",
+}
+
+// TestParsePubKeySmallOrder tests that points outside of the prime order
+// subgroup are rejected
+func TestParsePubKeySmallOrder(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	sks := mockUpSecKeysByScalars(curve, 1)
+	pkX, pkY := sks[0].Public()
+
+	for _, str := range smallOrderPoints {
+		b, _ := hex.DecodeString(str)
+
+		// Make sure the vector really is a point of small order.
+		tX, tY, err := curve.EncodedBytesToBigIntPoint(copyBytes(b))
+		if err != nil {
+			t.Fatalf("vector %v is not a point: %v", str, err)
+		}
+		eX, eY := curve.ScalarMult(tX, tY, []byte{8})
+		if eX.Sign() != 0 || eY.Cmp(one) != 0 {
+			t.Fatalf("vector %v is not of small order", str)
+		}
+
+		if _, err := ParsePubKey(curve, b); err == nil {
+			t.Fatalf("small order point %v was accepted", str)
+		}
+
+		// Adding a small order component to a valid key must be
+		// rejected as well, unless it's the identity.
+		mX, mY := curve.Add(pkX, pkY, tX, tY)
+		mixed := BigIntPointToEncodedBytes(mX, mY)
+		_, err = ParsePubKey(curve, mixed[:])
+		isIdentity := tX.Sign() == 0 && tY.Cmp(one) == 0
+		if isIdentity && err != nil {
+			t.Fatalf("unexpected error parsing a valid key: %v", err)
+		}
+		if !isIdentity && err == nil {
+			t.Fatalf("mixed order point with %v was accepted", str)
+		}
+	}
+}
+
+// TestPublicKeyValidity tests IsOnCurve and IsInSubgroup on valid keys of
+// both curves, off-curve and unreduced coordinates and points of small
+// order
+func TestPublicKeyValidity(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	ed448 := new(TwistedEdwardsCurve)
+	ed448.InitParamEd448()
+
+	ed448Keys, err := GenerateKeys(ed448, rand.Reader, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, pub := range []*PublicKey{
+		mockUpSecKeysByScalars(curve, 1)[0].PubKey(),
+		ed448Keys[0].PubKey(),
+	} {
+		if !pub.IsOnCurve() || !pub.IsInSubgroup() {
+			t.Fatalf("key %d: valid key rejected", i)
+		}
+		if _, err := NewPublicKeyChecked(pub.Curve.(*TwistedEdwardsCurve),
+			pub.GetX(), pub.GetY()); err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+
+		offCurve := NewPublicKey(pub.Curve.(*TwistedEdwardsCurve),
+			pub.GetX(), new(big.Int).Add(pub.GetY(), one))
+		if offCurve.IsOnCurve() || offCurve.IsInSubgroup() {
+			t.Fatalf("key %d: off-curve key accepted", i)
+		}
+	}
+
+	pub := mockUpSecKeysByScalars(curve, 1)[0].PubKey()
+	unreduced := NewPublicKey(curve, new(big.Int).Add(pub.GetX(), curve.P),
+		pub.GetY())
+	if unreduced.IsOnCurve() {
+		t.Fatalf("unreduced coordinate accepted")
+	}
+	if _, err := NewPublicKeyChecked(curve, unreduced.GetX(),
+		unreduced.GetY()); err == nil {
+		t.Fatalf("NewPublicKeyChecked accepted an unreduced coordinate")
+	}
+	if (PublicKey{}).IsOnCurve() || (&PublicKey{Curve: curve}).IsInSubgroup() {
+		t.Fatalf("incomplete key accepted")
+	}
+
+	for _, str := range smallOrderPoints {
+		b, _ := hex.DecodeString(str)
+		x, y, err := curve.EncodedBytesToBigIntPoint(copyBytes(b))
+		if err != nil {
+			t.Fatalf("vector %v is not a point: %v", str, err)
+		}
+		small := NewPublicKey(curve, x, y)
+		if !small.IsOnCurve() {
+			t.Fatalf("small order point %v not on the curve", str)
+		}
+		if small.IsInSubgroup() {
+			t.Fatalf("small order point %v in the subgroup", str)
+		}
+		mX, mY := curve.Add(pub.GetX(), pub.GetY(), x, y)
+		if !isIdentity(x, y) && NewPublicKey(curve, mX, mY).IsInSubgroup() {
+			t.Fatalf("mixed order point with %v in the subgroup", str)
+		}
+	}
+}
+
+// TestParsePubKeySize tests that truncated, oversized and missing public
+// keys are rejected instead of being padded or cut to size
+func TestParsePubKeySize(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	b := mockUpSecKeysByScalars(curve, 1)[0].PubKey().Serialize()
+	for _, bad := range [][]byte{nil, {}, b[:PubKeyBytesLen-1], b[1:],
+		append(b, 0x00), append([]byte{0x00}, b...)} {
+		if _, err := ParsePubKey(curve, bad); err == nil {
+			t.Fatalf("parsed a public key of %v bytes", len(bad))
+		}
+	}
+}
+
+// TestPublicKeyJSON tests the JSON encoding of public keys
+func TestPublicKeyJSON(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	for _, sk := range mockUpSecKeysByScalars(curve, 10) {
+		x, y := sk.Public()
+		pub := NewPublicKey(curve, x, y)
+		b, err := json.Marshal(pub)
+		if err != nil {
+			t.Fatalf("unexpected marshal error: %v", err)
+		}
+		want := `"` + hex.EncodeToString(pub.Serialize()) + `"`
+		if string(b) != want {
+			t.Fatalf("marshaled to %s, want %s", b, want)
+		}
+
+		var decoded PublicKey
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			t.Fatalf("unexpected unmarshal error: %v", err)
+		}
+		if !decoded.Equal(pub) {
+			t.Fatalf("decoded key %x, want %x", decoded.Serialize(),
+				pub.Serialize())
+		}
+	}
+
+	// Ed448 keys round trip on the Ed448 curve.
+	ed448 := new(TwistedEdwardsCurve)
+	ed448.InitParamEd448()
+	sk, _ := hex.DecodeString(ed448TestVectors[0].sk)
+	_, pub448 := PrivKeyFromSecret(ed448, sk)
+	b, err := json.Marshal(pub448)
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+	var decoded PublicKey
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unexpected unmarshal error: %v", err)
+	}
+	if !decoded.Equal(pub448) ||
+		!bytes.Equal(decoded.Serialize(), pub448.Serialize()) {
+		t.Fatalf("decoded Ed448 key %x, want %x", decoded.Serialize(),
+			pub448.Serialize())
+	}
+	sigBytes, _ := hex.DecodeString(ed448TestVectors[0].sig)
+	sig, err := ParseSignature(ed448, sigBytes)
+	if err != nil {
+		t.Fatalf("unexpected parsing error: %v", err)
+	}
+	if !Verify(&decoded, []byte{}, sig.R, sig.S) {
+		t.Fatalf("decoded Ed448 key doesn't verify its signature")
+	}
+
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"not a string", `42`, "not a JSON string"},
+		{"bad hex", `"zz"`, "not valid hex"},
+		{"odd length", `"abc"`, "not valid hex"},
+		{"short", `"` + strings.Repeat("00", 31) + `"`, "bad public key size"},
+		{"small order", `"` + smallOrderPoints[1] + `"`, "prime order"},
+	}
+	for _, test := range tests {
+		var decoded PublicKey
+		err := json.Unmarshal([]byte(test.json), &decoded)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Fatalf("%s: got error %v, want one containing %q", test.name,
+				err, test.want)
+		}
+	}
+
+	if _, err := json.Marshal(PublicKey{}); err == nil {
+		t.Fatalf("expected error marshaling an incomplete key")
+	}
+}
+
+// TestPublicKeyConstantTimeEqual tests that ConstantTimeEqual agrees with
+// Equal for equal and unequal points of both curves, including the negation
+// of a point and unreduced coordinates, and rejects incomplete keys
+func TestPublicKeyConstantTimeEqual(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	ed448 := new(TwistedEdwardsCurve)
+	ed448.InitParamEd448()
+
+	var pubs []*PublicKey
+	for _, priv := range mockUpSecKeysByScalars(curve, 4) {
+		pubs = append(pubs, priv.PubKey())
+	}
+	ed448Privs, err := GenerateKeys(ed448, rand.Reader, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, priv := range ed448Privs {
+		pubs = append(pubs, priv.PubKey())
+	}
+
+	for i, a := range pubs {
+		curve := a.Curve.(*TwistedEdwardsCurve)
+		same := NewPublicKey(curve, new(big.Int).Set(a.X),
+			new(big.Int).Set(a.Y))
+		unreduced := NewPublicKey(curve, new(big.Int).Add(a.X, curve.P),
+			new(big.Int).Add(a.Y, curve.P))
+		negated := NewPublicKey(curve, new(big.Int).Sub(curve.P, a.X), a.Y)
+		if !a.ConstantTimeEqual(same) || !a.ConstantTimeEqual(unreduced) {
+			t.Fatalf("key %d: not equal to itself", i)
+		}
+		if a.ConstantTimeEqual(negated) {
+			t.Fatalf("key %d: equal to its negation", i)
+		}
+		for j, b := range pubs {
+			if a.ConstantTimeEqual(b) != (i == j) ||
+				a.ConstantTimeEqual(b) != a.Equal(b) {
+				t.Fatalf("keys %d and %d: want equal %v, got %v", i, j,
+					i == j, a.ConstantTimeEqual(b))
+			}
+		}
+		if a.ConstantTimeEqual(nil) || a.ConstantTimeEqual(&PublicKey{}) ||
+			(&PublicKey{}).ConstantTimeEqual(a) {
+			t.Fatalf("key %d: equal to an incomplete key", i)
+		}
+	}
+}
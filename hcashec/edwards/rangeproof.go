@@ -0,0 +1,244 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// MaxRangeBits is the largest number of bits a range proof can cover.
+const MaxRangeBits = 64
+
+// rangeProofTag is the domain separation tag of the range proof challenges.
+const rangeProofTag = "edwards25519 range proof"
+
+// RangeBitProof proves that Commitment = bG + rH commits to a bit b, either
+// 0 or 1, without revealing which. It's a proof of knowledge of r for
+// either Commitment or Commitment - G with respect to H, where the branch
+// that isn't true is simulated, and the challenges E0 and E1 of the two
+// branches must sum to the Fiat-Shamir challenge.
+type RangeBitProof struct {
+	Commitment *PublicKey
+	E0, E1     *big.Int
+	S0, S1     *big.Int
+}
+
+// RangeProof proves that a Pedersen commitment (see Commit) is to a value in
+// [0, 2^bits) without revealing it. The value is decomposed into bits, each
+// bit is committed to and proven to be 0 or 1, and the commitments to the
+// bits, weighted by their powers of two, sum to the commitment to the value.
+// The proof grows linearly with the number of bits.
+type RangeProof struct {
+	Bits []*RangeBitProof
+}
+
+// rangeChallenge computes the challenge of the proof for the bit at index i
+// from the encoded bit commitment and the nonce points of both branches.
+func rangeChallenge(bits, i int, commitment *[32]byte,
+	a0, a1 *edwards25519.ExtendedGroupElement) *big.Int {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(bits))
+	binary.BigEndian.PutUint32(header[4:], uint32(i))
+
+	var a0Bytes, a1Bytes [32]byte
+	a0.ToBytes(&a0Bytes)
+	a1.ToBytes(&a1Bytes)
+
+	return EncodedBytesToBigInt(taggedScalar(rangeProofTag, header[:],
+		commitment[:], a0Bytes[:], a1Bytes[:]))
+}
+
+// checkRangeBits returns an error if bits is not a supported range size.
+func checkRangeBits(bits int) error {
+	if bits < 1 || bits > MaxRangeBits {
+		return fmt.Errorf("bad range size %v (want 1 to %v bits)", bits,
+			MaxRangeBits)
+	}
+
+	return nil
+}
+
+// ProveRange proves that the Pedersen commitment Commit(curve, value,
+// blinding) is to a value in [0, 2^bits), without revealing the value or the
+// blinding factor. Values outside of the range are rejected, since no valid
+// proof exists for them.
+func ProveRange(curve *TwistedEdwardsCurve, value, blinding *big.Int,
+	bits int) (*RangeProof, error) {
+	if err := checkRangeBits(bits); err != nil {
+		return nil, err
+	}
+	if err := checkCommitScalar(curve, blinding, "blinding factor"); err != nil {
+		return nil, err
+	}
+	if value == nil || value.Sign() < 0 || value.BitLen() > bits {
+		return nil, fmt.Errorf("value is out of the range of %v bits", bits)
+	}
+
+	// Split the blinding factor so that sum(2^i * r_i) = blinding, which
+	// makes sum(2^i * C_i) the commitment to the value.
+	r := make([]*big.Int, bits)
+	acc := new(big.Int)
+	for i := 0; i < bits-1; i++ {
+		ri, err := NewRandomScalar(curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		r[i] = ri
+		acc = scalarMulAdd(new(big.Int).Lsh(one, uint(i)), ri, acc)
+	}
+	last := new(big.Int).Sub(blinding, acc)
+	last.Mul(last, new(big.Int).ModInverse(new(big.Int).Lsh(one,
+		uint(bits-1)), curve.N))
+	r[bits-1] = last.Mod(last, curve.N)
+	defer func() {
+		for _, ri := range r {
+			ri.SetInt64(0)
+		}
+	}()
+
+	h := getPedersenH(curve)
+	var g edwards25519.ExtendedGroupElement
+	g.FromBytes(BigIntPointToEncodedBytes(curve.Gx, curve.Gy))
+
+	proof := &RangeProof{Bits: make([]*RangeBitProof, bits)}
+	for i := 0; i < bits; i++ {
+		b := value.Bit(i)
+		c, err := Commit(curve, big.NewInt(int64(b)), r[i])
+		if err != nil {
+			return nil, err
+		}
+		encodedC := BigIntPointToEncodedBytes(c.GetX(), c.GetY())
+		var cEGE edwards25519.ExtendedGroupElement
+		cEGE.FromBytes(encodedC)
+
+		// Simulate the false branch with random challenge and response.
+		eSim, err := NewRandomScalar(curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		sSim, err := NewRandomScalar(curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		var aSim edwards25519.ExtendedGroupElement
+		negESim := BigIntToEncodedBytes(new(big.Int).Sub(curve.N, eSim))
+		if b == 0 {
+			// A1 = s1*H - e1*(C - G)
+			multiScalarMultVartime(&aSim, []*[32]byte{
+				BigIntToEncodedBytes(sSim), negESim,
+				BigIntToEncodedBytes(eSim)},
+				[]*edwards25519.ExtendedGroupElement{h, &cEGE, &g})
+		} else {
+			// A0 = s0*H - e0*C
+			multiScalarMultVartime(&aSim, []*[32]byte{
+				BigIntToEncodedBytes(sSim), negESim},
+				[]*edwards25519.ExtendedGroupElement{h, &cEGE})
+		}
+
+		// The true branch is a Schnorr proof for r_i, A = kH.
+		k, err := NewRandomScalar(curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		kBE := BigIntToEncodedBytesNoReverse(k)
+		var aReal edwards25519.ExtendedGroupElement
+		geScalarMultConstantTime(&aReal, h, kBE[:])
+		zeroSlice(kBE[:])
+
+		a0, a1 := &aReal, &aSim
+		if b == 1 {
+			a0, a1 = &aSim, &aReal
+		}
+		e := rangeChallenge(bits, i, encodedC, a0, a1)
+		eReal := new(big.Int).Sub(e, eSim)
+		eReal.Mod(eReal, curve.N)
+		sReal := scalarMulAdd(eReal, r[i], k)
+		k.SetInt64(0)
+
+		bp := &RangeBitProof{Commitment: c}
+		if b == 0 {
+			bp.E0, bp.S0, bp.E1, bp.S1 = eReal, sReal, eSim, sSim
+		} else {
+			bp.E0, bp.S0, bp.E1, bp.S1 = eSim, sSim, eReal, sReal
+		}
+		proof.Bits[i] = bp
+	}
+
+	return proof, nil
+}
+
+// VerifyRange checks the range proof that the Pedersen commitment commitment
+// is to a value in [0, 2^bits), as produced by ProveRange.
+func VerifyRange(curve *TwistedEdwardsCurve, commitment *PublicKey,
+	proof *RangeProof, bits int) bool {
+	if checkRangeBits(bits) != nil || proof == nil ||
+		len(proof.Bits) != bits || commitment == nil ||
+		commitment.GetX() == nil || commitment.GetY() == nil {
+		return false
+	}
+	var c edwards25519.ExtendedGroupElement
+	if !c.FromBytes(BigIntPointToEncodedBytes(commitment.GetX(),
+		commitment.GetY())) {
+		return false
+	}
+
+	h := getPedersenH(curve)
+	var g edwards25519.ExtendedGroupElement
+	g.FromBytes(BigIntPointToEncodedBytes(curve.Gx, curve.Gy))
+
+	inRange := func(k *big.Int) bool {
+		return k != nil && k.Sign() >= 0 && k.Cmp(curve.N) < 0
+	}
+
+	weights := make([]*[32]byte, bits)
+	bitPoints := make([]*edwards25519.ExtendedGroupElement, bits)
+	for i, bp := range proof.Bits {
+		if bp == nil || bp.Commitment == nil ||
+			bp.Commitment.GetX() == nil || bp.Commitment.GetY() == nil ||
+			!inRange(bp.E0) || !inRange(bp.E1) || !inRange(bp.S0) ||
+			!inRange(bp.S1) {
+			return false
+		}
+		encodedC := BigIntPointToEncodedBytes(bp.Commitment.GetX(),
+			bp.Commitment.GetY())
+		cEGE := new(edwards25519.ExtendedGroupElement)
+		if !cEGE.FromBytes(encodedC) || !curve.isPrimeOrderPoint(cEGE) {
+			return false
+		}
+
+		// A0 = s0*H - e0*C, A1 = s1*H - e1*(C - G)
+		var a0, a1 edwards25519.ExtendedGroupElement
+		multiScalarMultVartime(&a0, []*[32]byte{BigIntToEncodedBytes(bp.S0),
+			BigIntToEncodedBytes(new(big.Int).Sub(curve.N, bp.E0))},
+			[]*edwards25519.ExtendedGroupElement{h, cEGE})
+		multiScalarMultVartime(&a1, []*[32]byte{BigIntToEncodedBytes(bp.S1),
+			BigIntToEncodedBytes(new(big.Int).Sub(curve.N, bp.E1)),
+			BigIntToEncodedBytes(bp.E1)},
+			[]*edwards25519.ExtendedGroupElement{h, cEGE, &g})
+
+		e := new(big.Int).Add(bp.E0, bp.E1)
+		e.Mod(e, curve.N)
+		if e.Cmp(rangeChallenge(bits, i, encodedC, &a0, &a1)) != 0 {
+			return false
+		}
+
+		weights[i] = BigIntToEncodedBytes(new(big.Int).Lsh(one, uint(i)))
+		bitPoints[i] = cEGE
+	}
+
+	// sum(2^i * C_i) = C
+	var sum edwards25519.ExtendedGroupElement
+	multiScalarMultVartime(&sum, weights, bitPoints)
+	var sumBytes, cBytes [32]byte
+	sum.ToBytes(&sumBytes)
+	c.ToBytes(&cBytes)
+
+	return sumBytes == cBytes
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// TestRangeProof tests proving and verifying that committed values are in
+// range for 8 and 32 bit ranges, and that values out of range can't be
+// proven
+func TestRangeProof(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	tests := []struct {
+		bits  int
+		value *big.Int
+	}{
+		{8, big.NewInt(0)},
+		{8, big.NewInt(200)},
+		{8, big.NewInt(255)},
+		{32, big.NewInt(4294967295)},
+	}
+	for i, test := range tests {
+		blinding, err := NewRandomScalar(curve, rand.Reader)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		commitment, err := Commit(curve, test.value, blinding)
+		if err != nil {
+			t.Fatalf("test %d: unexpected commitment error: %v", i, err)
+		}
+		proof, err := ProveRange(curve, test.value, blinding, test.bits)
+		if err != nil {
+			t.Fatalf("test %d: unexpected proving error: %v", i, err)
+		}
+		if !VerifyRange(curve, commitment, proof, test.bits) {
+			t.Fatalf("test %d: range proof failed to verify", i)
+		}
+
+		// The proof is bound to its commitment and range size.
+		other, _ := Commit(curve, test.value, new(big.Int).Add(blinding,
+			one))
+		if VerifyRange(curve, other, proof, test.bits) {
+			t.Fatalf("test %d: range proof verified for another "+
+				"commitment", i)
+		}
+		if VerifyRange(curve, commitment, proof, test.bits-1) {
+			t.Fatalf("test %d: range proof verified for a smaller range",
+				i)
+		}
+		if test.bits == 8 {
+			bad := *proof.Bits[3]
+			bad.S0 = new(big.Int).Add(bad.S0, one)
+			bad.S0.Mod(bad.S0, curve.N)
+			tampered := &RangeProof{Bits: append([]*RangeBitProof(nil),
+				proof.Bits...)}
+			tampered.Bits[3] = &bad
+			if VerifyRange(curve, commitment, tampered, test.bits) {
+				t.Fatalf("test %d: tampered range proof verified", i)
+			}
+		}
+	}
+
+	// Out of range values.
+	blinding, _ := NewRandomScalar(curve, rand.Reader)
+	outOfRange := []struct {
+		bits  int
+		value *big.Int
+	}{
+		{8, big.NewInt(256)},
+		{8, big.NewInt(-1)},
+		{32, new(big.Int).Lsh(one, 32)},
+		{32, new(big.Int).Sub(curve.N, one)},
+	}
+	for i, test := range outOfRange {
+		if _, err := ProveRange(curve, test.value, blinding,
+			test.bits); err == nil {
+			t.Fatalf("test %d: proved out of range value %v", i, test.value)
+		}
+	}
+
+	// A proof for v doesn't hold for v + 2^bits, which has the same low
+	// bits.
+	value := big.NewInt(200)
+	proof, err := ProveRange(curve, value, blinding, 8)
+	if err != nil {
+		t.Fatalf("unexpected proving error: %v", err)
+	}
+	big256 := new(big.Int).Add(value, big.NewInt(256))
+	wrapped, _ := Commit(curve, big256, blinding)
+	if VerifyRange(curve, wrapped, proof, 8) {
+		t.Fatalf("range proof verified for a value out of range")
+	}
+
+	// Bad range sizes.
+	for _, bits := range []int{0, MaxRangeBits + 1} {
+		if _, err := ProveRange(curve, value, blinding, bits); err == nil {
+			t.Fatalf("proved a range of %v bits", bits)
+		}
+	}
+}
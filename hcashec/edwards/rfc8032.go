@@ -0,0 +1,161 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"math/big"
+)
+
+// dom2Prefix is the constant prefix of the RFC 8032 dom2 domain separation
+// string for the Ed25519ph and Ed25519ctx variants.
+var dom2Prefix = []byte("SigEd25519 no Ed25519 collisions")
+
+// PrehashSize is the size of the prehashed message taken by Ed25519ph, a
+// SHA512 digest.
+const PrehashSize = sha512.Size
+
+// dom2 builds the RFC 8032 domain separation string
+// dom2(phflag, context) = "SigEd25519 no Ed25519 collisions" ||
+// octet(phflag) || octet(len(context)) || context.
+func dom2(phflag byte, context []byte) []byte {
+	dom := make([]byte, 0, len(dom2Prefix)+2+len(context))
+	dom = append(dom, dom2Prefix...)
+	dom = append(dom, phflag, byte(len(context)))
+	dom = append(dom, context...)
+	return dom
+}
+
+// verifyRFC8032 verifies the signature (r, s) over msg for the RFC 8032
+// variant with the domain separation prefix dom.
+func verifyRFC8032(pub *PublicKey, dom []byte, msg []byte, r,
+	s *big.Int) bool {
+	return verifyWithChallengeHash(pub, sha512.New, dom, msg, r, s)
+}
+
+// verifyWithChallengeHash is verifyRFC8032 with the challenge computed with
+// the 64 byte hash function newHash instead of SHA512.
+func verifyWithChallengeHash(pub *PublicKey, newHash func() hash.Hash,
+	dom []byte, msg []byte, r, s *big.Int) bool {
+	if pub == nil || pub.GetX() == nil || pub.GetY() == nil || msg == nil ||
+		r == nil || s == nil {
+		return false
+	}
+
+	sig := &Signature{r, s}
+	if !IsCanonical(sig) {
+		return false
+	}
+	sigArray := copyBytes64(sig.Serialize())
+
+	// h = hash512(dom || R || A || M)
+	var hramDigest [64]byte
+	h := newHash()
+	h.Write(dom)
+	h.Write(sigArray[:32])
+	h.Write(pub.Serialize())
+	h.Write(msg)
+	h.Sum(hramDigest[:0])
+
+	return verifyHram(pub, sigArray, &hramDigest)
+}
+
+// SignPrehashed signs the SHA512 digest 'prehash' of a message using the
+// Ed25519ph variant of RFC 8032 with an empty context. Signing the digest
+// instead of the message lets callers hash large content once, while the
+// dom2 prefix keeps these signatures from ever being valid as plain Ed25519
+// signatures. The private key must hold a secret seed.
+func SignPrehashed(curve *TwistedEdwardsCurve, priv *PrivateKey,
+	prehash []byte) (r, s *big.Int, err error) {
+	if priv == nil {
+		return nil, nil, fmt.Errorf("private key is nil")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, nil, err
+	}
+	if priv.secret == nil {
+		return nil, nil, fmt.Errorf("private key has no secret seed to " +
+			"derive the nonce from")
+	}
+	if len(prehash) != PrehashSize {
+		return nil, nil, fmt.Errorf("bad prehash size; have %v, want %v",
+			len(prehash), PrehashSize)
+	}
+
+	sig, err := signRFC8032(curve, priv, dom2(1, nil), prehash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sig.GetR(), sig.GetS(), nil
+}
+
+// VerifyPrehashed verifies an Ed25519ph signature (r, s) over the SHA512
+// digest 'prehash' of a message using the public key pub.
+func VerifyPrehashed(curve *TwistedEdwardsCurve, pub *PublicKey,
+	prehash []byte, r, s *big.Int) bool {
+	if len(prehash) != PrehashSize {
+		return false
+	}
+
+	return verifyRFC8032(pub, dom2(1, nil), prehash, r, s)
+}
+
+// MaxContextSize is the maximum size of an Ed25519ctx context string.
+const MaxContextSize = 255
+
+// checkContext makes sure context is a valid Ed25519ctx context string,
+// which must be between 1 and MaxContextSize bytes long.
+func checkContext(context []byte) error {
+	if len(context) == 0 || len(context) > MaxContextSize {
+		return fmt.Errorf("bad context size; have %v, want 1 to %v",
+			len(context), MaxContextSize)
+	}
+
+	return nil
+}
+
+// SignWithContext signs msg using the Ed25519ctx variant of RFC 8032, which
+// mixes the context string into both hashes. Signatures made for one context
+// never verify under another one, nor as plain Ed25519 signatures, so
+// different subsystems can't have their signatures replayed across domains.
+// The context must be 1 to MaxContextSize bytes long and the private key must
+// hold a secret seed.
+func SignWithContext(curve *TwistedEdwardsCurve, priv *PrivateKey, msg,
+	context []byte) (r, s *big.Int, err error) {
+	if priv == nil {
+		return nil, nil, fmt.Errorf("private key is nil")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, nil, err
+	}
+	if priv.secret == nil {
+		return nil, nil, fmt.Errorf("private key has no secret seed to " +
+			"derive the nonce from")
+	}
+	if err := checkContext(context); err != nil {
+		return nil, nil, err
+	}
+
+	sig, err := signRFC8032(curve, priv, dom2(0, context), msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sig.GetR(), sig.GetS(), nil
+}
+
+// VerifyWithContext verifies an Ed25519ctx signature (r, s) over msg for the
+// given context string using the public key pub.
+func VerifyWithContext(curve *TwistedEdwardsCurve, pub *PublicKey, msg,
+	context []byte, r, s *big.Int) bool {
+	if checkContext(context) != nil {
+		return false
+	}
+
+	return verifyRFC8032(pub, dom2(0, context), msg, r, s)
+}
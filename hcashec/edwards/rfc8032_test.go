@@ -0,0 +1,153 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"testing"
+)
+
+// TestSignPrehashed tests Ed25519ph against the RFC 8032 section 7.3 test
+// vector
+func TestSignPrehashed(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	secret, _ := hex.DecodeString("833fe62409237b9d62ec77587520911e" +
+		"9a759cec1d19755b7da901b96dca3d42")
+	wantPub, _ := hex.DecodeString("ec172b93ad5e563bf4932c70e1245034" +
+		"c35467ef2efd4d64ebf819683467e2bf")
+	wantSig, _ := hex.DecodeString("98a70222f0b8121aa9d30f813d683f80" +
+		"9e462b469c7ff87639499bb94e6dae41" +
+		"31f85042463c2a355a2003d062adf5aa" +
+		"a10b8c61e636062aaad11c2a26083406")
+	msg := []byte("abc")
+
+	priv, pub := PrivKeyFromSecret(curve, secret)
+	if !bytes.Equal(pub.Serialize(), wantPub) {
+		t.Fatalf("want public key %x, got %x", wantPub, pub.Serialize())
+	}
+
+	prehash := sha512.Sum512(msg)
+	r, s, err := SignPrehashed(curve, priv, prehash[:])
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+	sig := NewSignature(r, s)
+	if !bytes.Equal(sig.Serialize(), wantSig) {
+		t.Fatalf("want signature %x, got %x", wantSig, sig.Serialize())
+	}
+	if !VerifyPrehashed(curve, pub, prehash[:], r, s) {
+		t.Fatalf("prehashed signature failed to verify")
+	}
+
+	// The signature is neither valid for another prehash nor as a plain
+	// Ed25519 signature.
+	otherPrehash := sha512.Sum512([]byte("abd"))
+	if VerifyPrehashed(curve, pub, otherPrehash[:], r, s) {
+		t.Fatalf("signature verified for the wrong prehash")
+	}
+	if Verify(pub, prehash[:], r, s) {
+		t.Fatalf("prehashed signature verified as plain Ed25519")
+	}
+
+	// Only 64 byte prehashes are accepted.
+	for _, size := range []int{0, 32, 63, 65} {
+		if _, _, err := SignPrehashed(curve, priv, make([]byte, size)); err == nil {
+			t.Fatalf("expected error signing a %v byte prehash", size)
+		}
+		if VerifyPrehashed(curve, pub, make([]byte, size), r, s) {
+			t.Fatalf("verified a %v byte prehash", size)
+		}
+	}
+}
+
+// TestSignWithContext tests Ed25519ctx against the RFC 8032 section 7.2 test
+// vectors
+func TestSignWithContext(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	tests := []struct {
+		secret  string
+		pub     string
+		msg     string
+		context string
+		sig     string
+	}{
+		{
+			"0305334e381af78f141cb666f6199f57bc3495335a256a95bd2a55bf546663f6",
+			"dfc9425e4f968f7f0c29f0259cf5f9aed6851c2bb4ad8bfb860cfee0ab248292",
+			"f726936d19c800494e3fdaff20b276a8",
+			"666f6f",
+			"55a4cc2f70a54e04288c5f4cd1e45a7bb520b36292911876cada7323198dd87a" +
+				"8b36950b95130022907a7fb7c4e9b2d5f6cca685a587b4b21f4b888e4e7edb0d",
+		},
+		{
+			"0305334e381af78f141cb666f6199f57bc3495335a256a95bd2a55bf546663f6",
+			"dfc9425e4f968f7f0c29f0259cf5f9aed6851c2bb4ad8bfb860cfee0ab248292",
+			"f726936d19c800494e3fdaff20b276a8",
+			"626172",
+			"fc60d5872fc46b3aa69f8b5b4351d5808f92bcc044606db097abab6dbcb1aee3" +
+				"216c48e8b3b66431b5b186d1d28f8ee15a5ca2df6668346291c2043d4eb3e90d",
+		},
+	}
+
+	for i, test := range tests {
+		secret, _ := hex.DecodeString(test.secret)
+		wantPub, _ := hex.DecodeString(test.pub)
+		msg, _ := hex.DecodeString(test.msg)
+		context, _ := hex.DecodeString(test.context)
+		wantSig, _ := hex.DecodeString(test.sig)
+
+		priv, pub := PrivKeyFromSecret(curve, secret)
+		if !bytes.Equal(pub.Serialize(), wantPub) {
+			t.Fatalf("test %d: want public key %x, got %x", i, wantPub,
+				pub.Serialize())
+		}
+
+		r, s, err := SignWithContext(curve, priv, msg, context)
+		if err != nil {
+			t.Fatalf("test %d: unexpected signing error: %v", i, err)
+		}
+		sig := NewSignature(r, s)
+		if !bytes.Equal(sig.Serialize(), wantSig) {
+			t.Fatalf("test %d: want signature %x, got %x", i, wantSig,
+				sig.Serialize())
+		}
+		if !VerifyWithContext(curve, pub, msg, context, r, s) {
+			t.Fatalf("test %d: signature failed to verify", i)
+		}
+
+		// The signature is neither valid under another context nor as
+		// a plain Ed25519 signature.
+		if VerifyWithContext(curve, pub, msg, []byte("baz"), r, s) {
+			t.Fatalf("test %d: signature verified for the wrong context", i)
+		}
+		if Verify(pub, msg, r, s) {
+			t.Fatalf("test %d: signature verified as plain Ed25519", i)
+		}
+	}
+
+	// Contexts must be 1 to 255 bytes long.
+	priv, pub := PrivKeyFromSecret(curve, bytes.Repeat([]byte{0x01}, 32))
+	msg := []byte("Hello World in TestSignWithContext")
+	for _, size := range []int{0, MaxContextSize + 1} {
+		context := make([]byte, size)
+		if _, _, err := SignWithContext(curve, priv, msg, context); err == nil {
+			t.Fatalf("expected error signing with a %v byte context", size)
+		}
+	}
+	context := bytes.Repeat([]byte{0xff}, MaxContextSize)
+	r, s, err := SignWithContext(curve, priv, msg, context)
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+	if !VerifyWithContext(curve, pub, msg, context, r, s) {
+		t.Fatalf("signature with the longest context failed to verify")
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// ringChallengeTag is the domain separation tag of the ring signature
+// challenges.
+const ringChallengeTag = "edwards25519 ring signature"
+
+// RingSignature is a linkable ring signature, proving that the holder of the
+// private key of one of the ring members signed a message without revealing
+// which one. The key image I = x*Hp(P) is the same for every signature made
+// with the same key whatever the ring, which allows detecting double spends.
+type RingSignature struct {
+	KeyImage *PublicKey
+	C        *big.Int
+	R        []*big.Int
+}
+
+// geToPublicKey converts an extended group element to a public key.
+func geToPublicKey(curve *TwistedEdwardsCurve,
+	p *edwards25519.ExtendedGroupElement) (*PublicKey, error) {
+	var s [32]byte
+	p.ToBytes(&s)
+	x, y, err := curve.EncodedBytesToBigIntPoint(&s)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPublicKey(curve, x, y), nil
+}
+
+// ringChallengePrefix computes the part of the challenge hash input shared by
+// every ring member, which commits to the ring, the key image and the
+// message.
+func ringChallengePrefix(ring [][32]byte, keyImage *[32]byte,
+	msg []byte) []byte {
+	var b bytes.Buffer
+	for i := range ring {
+		b.Write(ring[i][:])
+	}
+	b.Write(keyImage[:])
+	b.Write(msg)
+	return b.Bytes()
+}
+
+// ringChallenge computes c = taggedHash(tag, prefix || L || R) mod N as a
+// 32 byte little endian scalar.
+func ringChallenge(prefix []byte, l, r *[32]byte) *[32]byte {
+	return taggedScalar(ringChallengeTag, prefix, l[:], r[:])
+}
+
+// ringStep computes L = r*G + c*P and R = r*Hp(P) + c*I for a ring member
+// with public responses r and challenges c.
+func ringStep(p, hp, keyImage *edwards25519.ExtendedGroupElement, r,
+	c *[32]byte) (*[32]byte, *[32]byte) {
+	var lProj edwards25519.ProjectiveGroupElement
+	edwards25519.GeDoubleScalarMultVartime(&lProj, c, p, r)
+	l := new([32]byte)
+	lProj.ToBytes(l)
+
+	var rExt edwards25519.ExtendedGroupElement
+	multiScalarMultVartime(&rExt, []*[32]byte{r, c},
+		[]*edwards25519.ExtendedGroupElement{hp, keyImage})
+	rBytes := new([32]byte)
+	rExt.ToBytes(rBytes)
+
+	return l, rBytes
+}
+
+// keyImagePoint computes the key image I = x*Hp(P) of the private scalar x
+// for the hashed public key hp, with the constant time ladder since x is
+// secret.
+func keyImagePoint(hp *edwards25519.ExtendedGroupElement,
+	x *big.Int) *edwards25519.ExtendedGroupElement {
+	xBE := BigIntToEncodedBytesNoReverse(x)
+	defer zeroSlice(xBE[:])
+	keyImage := new(edwards25519.ExtendedGroupElement)
+	geScalarMultConstantTime(keyImage, hp, xBE[:])
+
+	return keyImage
+}
+
+// ComputeKeyImage returns the key image I = x*Hp(P) of the private key priv
+// with public key P, the one its ring signatures carry. It only depends on
+// the key, so it can be computed ahead of signing, for instance by a wallet
+// to recognize which of its keys have been spent. Only Ed25519 keys are
+// supported.
+func ComputeKeyImage(priv *PrivateKey) (*PublicKey, error) {
+	if priv == nil {
+		return nil, fmt.Errorf("private key is nil")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, err
+	}
+	curve, ok := priv.PubKey().Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil || curve.isEd448() {
+		return nil, fmt.Errorf("key images are only supported on Ed25519")
+	}
+
+	pubX, pubY := priv.Public()
+	x := priv.reducedScalar(curve)
+	defer x.SetInt64(0)
+	hp := hashToPoint(BigIntPointToEncodedBytes(pubX, pubY)[:])
+
+	return geToPublicKey(curve, keyImagePoint(hp, x))
+}
+
+// VerifyKeyImage returns whether keyImage is a well formed key image, a
+// point of the prime order subgroup. Images with a small order component
+// must be rejected before they are compared with the ones already seen,
+// since adding one to the image of a key would give the same key another
+// image and let it spend twice. Whether the image belongs to the signer of
+// a ring signature is proven by the signature itself, and VerifyRing checks
+// this as well.
+func VerifyKeyImage(curve *TwistedEdwardsCurve, keyImage *PublicKey) bool {
+	_, ok := decodeKeyImage(curve, keyImage)
+	return ok
+}
+
+// decodeKeyImage decodes the key image, returning false if it is not a point
+// of the prime order subgroup.
+func decodeKeyImage(curve *TwistedEdwardsCurve,
+	keyImage *PublicKey) (*edwards25519.ExtendedGroupElement, bool) {
+	if curve == nil || curve.isEd448() || keyImage == nil ||
+		keyImage.GetX() == nil || keyImage.GetY() == nil {
+		return nil, false
+	}
+	p := new(edwards25519.ExtendedGroupElement)
+	encoded := BigIntPointToEncodedBytes(keyImage.GetX(), keyImage.GetY())
+	if !p.FromBytes(encoded) || !curve.isPrimeOrderPoint(p) {
+		return nil, false
+	}
+
+	return p, true
+}
+
+// decodeRing decodes the ring members, making sure they are all points of
+// the prime order subgroup.
+func decodeRing(curve *TwistedEdwardsCurve, ring []*PublicKey) ([][32]byte,
+	[]*edwards25519.ExtendedGroupElement, error) {
+	if len(ring) == 0 {
+		return nil, nil, fmt.Errorf("empty ring")
+	}
+
+	encoded := make([][32]byte, len(ring))
+	points := make([]*edwards25519.ExtendedGroupElement, len(ring))
+	for i, pub := range ring {
+		if pub == nil || pub.GetX() == nil || pub.GetY() == nil {
+			return nil, nil, fmt.Errorf("ring member %v is nil", i)
+		}
+		encoded[i] = *BigIntPointToEncodedBytes(pub.GetX(), pub.GetY())
+		points[i] = new(edwards25519.ExtendedGroupElement)
+		if !points[i].FromBytes(&encoded[i]) ||
+			!curve.isPrimeOrderPoint(points[i]) {
+			return nil, nil, fmt.Errorf("ring member %v is not a valid "+
+				"public key", i)
+		}
+	}
+
+	return encoded, points, nil
+}
+
+// SignRing creates a linkable ring signature of msg with the private key
+// priv, whose public key must be a member of ring. Following the bLSAG
+// scheme, a chain of challenges c_{i+1} = H(L_i, R_i) is built around the
+// ring starting from the signer with a random nonce, filling in random
+// responses for every other member, and the chain is closed by solving the
+// signer's response with its private key.
+func SignRing(curve *TwistedEdwardsCurve, priv *PrivateKey,
+	ring []*PublicKey, msg []byte) (*RingSignature, error) {
+	if priv == nil || msg == nil {
+		return nil, fmt.Errorf("nil input")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, err
+	}
+
+	encoded, points, err := decodeRing(curve, ring)
+	if err != nil {
+		return nil, err
+	}
+	pubX, pubY := priv.Public()
+	ownEncoded := BigIntPointToEncodedBytes(pubX, pubY)
+	signer := -1
+	for i := range encoded {
+		if encoded[i] == *ownEncoded {
+			signer = i
+			break
+		}
+	}
+	if signer < 0 {
+		return nil, fmt.Errorf("signer is not a member of the ring")
+	}
+
+	x := priv.reducedScalar(curve)
+	defer x.SetInt64(0)
+
+	// I = x*Hp(P)
+	hps := make([]*edwards25519.ExtendedGroupElement, len(ring))
+	for i := range encoded {
+		hps[i] = hashToPoint(encoded[i][:])
+	}
+	keyImage := keyImagePoint(hps[signer], x)
+	var keyImageBytes [32]byte
+	keyImage.ToBytes(&keyImageBytes)
+	prefix := ringChallengePrefix(encoded, &keyImageBytes, msg)
+
+	// L = alpha*G, R = alpha*Hp(P) for the signer.
+	alpha, err := NewRandomScalar(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	defer alpha.SetInt64(0)
+	alphaBE := BigIntToEncodedBytesNoReverse(alpha)
+	defer zeroSlice(alphaBE[:])
+	alphaLE := BigIntToEncodedBytes(alpha)
+	defer zeroSlice(alphaLE[:])
+
+	var lExt, rExt edwards25519.ExtendedGroupElement
+	edwards25519.GeScalarMultBase(&lExt, alphaLE)
+	geScalarMultConstantTime(&rExt, hps[signer], alphaBE[:])
+	var l, r [32]byte
+	lExt.ToBytes(&l)
+	rExt.ToBytes(&r)
+
+	n := len(ring)
+	cs := make([]*[32]byte, n)
+	rs := make([]*[32]byte, n)
+	cs[(signer+1)%n] = ringChallenge(prefix, &l, &r)
+	for k := 1; k < n; k++ {
+		i := (signer + k) % n
+		ri, err := NewRandomScalar(curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		rs[i] = BigIntToEncodedBytes(ri)
+		li, rri := ringStep(points[i], hps[i], keyImage, rs[i], cs[i])
+		cs[(i+1)%n] = ringChallenge(prefix, li, rri)
+	}
+
+	// r = alpha - c*x = (N - c)*x + alpha
+	negC := new(big.Int).Sub(curve.N, EncodedBytesToBigInt(cs[signer]))
+	responses := make([]*big.Int, n)
+	for i := range rs {
+		if i == signer {
+			responses[i] = scalarMulAdd(negC, x, alpha)
+			continue
+		}
+		responses[i] = EncodedBytesToBigInt(rs[i])
+	}
+
+	keyImagePub, err := geToPublicKey(curve, keyImage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RingSignature{
+		KeyImage: keyImagePub,
+		C:        EncodedBytesToBigInt(cs[0]),
+		R:        responses,
+	}, nil
+}
+
+// VerifyRing verifies the linkable ring signature sig of msg for ring, that
+// is that recomputing the chain of challenges around the ring from c_0 ends
+// up at c_0 again. It doesn't tell which member signed. Checking the key
+// image against the ones already seen is up to the caller.
+func VerifyRing(curve *TwistedEdwardsCurve, ring []*PublicKey, msg []byte,
+	sig *RingSignature) bool {
+	if msg == nil || sig == nil || sig.C == nil || len(sig.R) != len(ring) {
+		return false
+	}
+
+	encoded, points, err := decodeRing(curve, ring)
+	if err != nil {
+		return false
+	}
+
+	// The key image must be in the prime order subgroup, otherwise adding
+	// a small order component would give the same key another image.
+	keyImage, ok := decodeKeyImage(curve, sig.KeyImage)
+	if !ok {
+		return false
+	}
+	keyImageBytes := BigIntPointToEncodedBytes(sig.KeyImage.GetX(),
+		sig.KeyImage.GetY())
+
+	if sig.C.Sign() < 0 || sig.C.Cmp(curve.N) >= 0 {
+		return false
+	}
+	for _, r := range sig.R {
+		if r == nil || r.Sign() < 0 || r.Cmp(curve.N) >= 0 {
+			return false
+		}
+	}
+
+	prefix := ringChallengePrefix(encoded, keyImageBytes, msg)
+	c0 := BigIntToEncodedBytes(sig.C)
+	c := c0
+	for i := range ring {
+		hp := hashToPoint(encoded[i][:])
+		l, r := ringStep(points[i], hp, keyImage,
+			BigIntToEncodedBytes(sig.R[i]), c)
+		c = ringChallenge(prefix, l, r)
+	}
+
+	return *c == *c0
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// mockUpRing creates sz private keys and the ring of their public keys,
+// alternating between keys derived from secrets and bare scalars.
+func mockUpRing(curve *TwistedEdwardsCurve, sz int) ([]*PrivateKey,
+	[]*PublicKey) {
+	bySecret := mockUpSecKeysByBytes(curve, sz)
+	byScalar := mockUpSecKeysByScalars(curve, sz)
+	privs := make([]*PrivateKey, sz)
+	ring := make([]*PublicKey, sz)
+	for i := range privs {
+		privs[i] = bySecret[i]
+		if i%2 == 1 {
+			privs[i] = byScalar[i]
+		}
+		x, y := privs[i].Public()
+		ring[i] = NewPublicKey(curve, x, y)
+	}
+
+	return privs, ring
+}
+
+// TestRingSignature tests signing and verifying ring signatures with the
+// first and last members of rings of several sizes.
+func TestRingSignature(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := sha256.Sum256([]byte("Hello World in TestRingSignature"))
+	otherMsg := sha256.Sum256([]byte("some other message"))
+
+	for _, sz := range []int{2, 8, 32} {
+		privs, ring := mockUpRing(curve, sz)
+		for _, signer := range []int{0, sz - 1} {
+			sig, err := SignRing(curve, privs[signer], ring, msg[:])
+			if err != nil {
+				t.Fatalf("ring of %v, signer %v: unexpected signing "+
+					"error: %v", sz, signer, err)
+			}
+			if !VerifyRing(curve, ring, msg[:], sig) {
+				t.Fatalf("ring of %v, signer %v: signature failed to "+
+					"verify", sz, signer)
+			}
+			if VerifyRing(curve, ring, otherMsg[:], sig) {
+				t.Fatalf("ring of %v, signer %v: signature verified "+
+					"for the wrong message", sz, signer)
+			}
+
+			// Tampering with any part of the signature must be caught.
+			// The larger rings only add verification time here.
+			if sz > 8 {
+				continue
+			}
+			tampered := *sig
+			tampered.C = new(big.Int).Add(sig.C, one)
+			if VerifyRing(curve, ring, msg[:], &tampered) {
+				t.Fatalf("ring of %v, signer %v: signature verified "+
+					"with a tampered challenge", sz, signer)
+			}
+			tampered = *sig
+			tampered.R = append([]*big.Int(nil), sig.R...)
+			tampered.R[sz-1] = new(big.Int).Add(sig.R[sz-1], one)
+			if VerifyRing(curve, ring, msg[:], &tampered) {
+				t.Fatalf("ring of %v, signer %v: signature verified "+
+					"with a tampered response", sz, signer)
+			}
+			tampered = *sig
+			tampered.KeyImage = ring[signer]
+			if VerifyRing(curve, ring, msg[:], &tampered) {
+				t.Fatalf("ring of %v, signer %v: signature verified "+
+					"with a tampered key image", sz, signer)
+			}
+
+			// Swapping two ring members changes the ring.
+			swapped := append([]*PublicKey(nil), ring...)
+			swapped[0], swapped[1] = swapped[1], swapped[0]
+			if VerifyRing(curve, swapped, msg[:], sig) {
+				t.Fatalf("ring of %v, signer %v: signature verified "+
+					"for a reordered ring", sz, signer)
+			}
+		}
+	}
+
+	// Signing requires being a member of the ring.
+	privs, ring := mockUpRing(curve, 3)
+	if _, err := SignRing(curve, privs[2], ring[:2], msg[:]); err == nil {
+		t.Fatalf("expected error signing for a ring without the signer")
+	}
+	if _, err := SignRing(curve, privs[0], nil, msg[:]); err == nil {
+		t.Fatalf("expected error signing for an empty ring")
+	}
+}
+
+// TestRingKeyImage tests that signatures made with the same key have the same
+// key image whatever the message and ring, and signatures made with different
+// keys don't.
+func TestRingKeyImage(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := sha256.Sum256([]byte("Hello World in TestRingKeyImage"))
+	otherMsg := sha256.Sum256([]byte("some other message"))
+
+	privs, ring := mockUpRing(curve, 8)
+	sig1, err := SignRing(curve, privs[3], ring[:4], msg[:])
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+	sig2, err := SignRing(curve, privs[3], ring[2:], otherMsg[:])
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+	sig3, err := SignRing(curve, privs[4], ring[2:], msg[:])
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+
+	if !VerifyRing(curve, ring[:4], msg[:], sig1) ||
+		!VerifyRing(curve, ring[2:], otherMsg[:], sig2) ||
+		!VerifyRing(curve, ring[2:], msg[:], sig3) {
+		t.Fatalf("signature failed to verify")
+	}
+	if sig1.C.Cmp(sig2.C) == 0 {
+		t.Fatalf("signatures unexpectedly share a challenge")
+	}
+
+	image1 := sig1.KeyImage.Serialize()
+	image2 := sig2.KeyImage.Serialize()
+	image3 := sig3.KeyImage.Serialize()
+	if string(image1) != string(image2) {
+		t.Fatalf("same key gave different key images: %x, %x", image1,
+			image2)
+	}
+	if string(image1) == string(image3) {
+		t.Fatalf("different keys gave the same key image %x", image1)
+	}
+}
+
+// TestComputeKeyImage tests that the key image of a key is always the same
+// and is the one its ring signatures carry, that different keys have
+// different images, and that VerifyKeyImage rejects images with a small
+// order component
+func TestComputeKeyImage(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := sha256.Sum256([]byte("Hello World in TestComputeKeyImage"))
+
+	privs, ring := mockUpRing(curve, 6)
+	seen := make(map[string]int)
+	for i, priv := range privs {
+		image, err := ComputeKeyImage(priv)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		again, err := ComputeKeyImage(priv)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		if !image.Equal(again) {
+			t.Fatalf("key %d: key image changed between calls", i)
+		}
+		if !VerifyKeyImage(curve, image) {
+			t.Fatalf("key %d: key image failed to verify", i)
+		}
+
+		sig, err := SignRing(curve, priv, ring, msg[:])
+		if err != nil {
+			t.Fatalf("key %d: unexpected signing error: %v", i, err)
+		}
+		if !sig.KeyImage.Equal(image) {
+			t.Fatalf("key %d: ring signature has another key image", i)
+		}
+
+		encoded := string(image.Serialize())
+		if j, ok := seen[encoded]; ok {
+			t.Fatalf("keys %d and %d have the same key image", j, i)
+		}
+		seen[encoded] = i
+	}
+
+	// An image plus a point of small order has the same prime order part.
+	image, _ := ComputeKeyImage(privs[0])
+	b, _ := hex.DecodeString(smallOrderPoints[len(smallOrderPoints)-1])
+	sx, sy, err := curve.EncodedBytesToBigIntPoint(copyBytes(b))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if VerifyKeyImage(curve, NewPublicKey(curve, sx, sy)) {
+		t.Fatalf("small order key image verified")
+	}
+	tx, ty := curve.Add(image.GetX(), image.GetY(), sx, sy)
+	if VerifyKeyImage(curve, NewPublicKey(curve, tx, ty)) {
+		t.Fatalf("key image with a small order component verified")
+	}
+	if VerifyKeyImage(curve, nil) || VerifyKeyImage(curve, &PublicKey{}) {
+		t.Fatalf("nil key image verified")
+	}
+
+	if _, err := ComputeKeyImage(nil); err == nil {
+		t.Fatalf("computed the key image of a nil key")
+	}
+	wiped := mockUpSecKeysByBytes(curve, 1)[0]
+	wiped.Wipe()
+	if _, err := ComputeKeyImage(wiped); err == nil {
+		t.Fatalf("computed the key image of a wiped key")
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// scTwoPow256 is 2^256 mod N, encoded as a 32 byte little endian integer.
+var scTwoPow256 = func() [32]byte {
+	var wide [64]byte
+	wide[32] = 1
+	var r [32]byte
+	edwards25519.ScReduce(&r, &wide)
+	return r
+}()
+
+// scOrderMinusTwo is N-2, the exponent of the inverse by Fermat's little
+// theorem, encoded as a 32 byte little endian integer.
+var scOrderMinusTwo = func() [32]byte {
+	return *BigIntToEncodedBytes(new(big.Int).Sub(Edwards().N,
+		big.NewInt(2)))
+}()
+
+// ScalarReduce reduces the little endian integer k modulo the group order N
+// in constant time, returning the result as a 32 byte little endian integer.
+// Inputs of up to 64 bytes are reduced with the reference implementation's
+// sc_reduce, while longer ones are folded from the most significant end in
+// 32 byte chunks, acc = acc * 2^256 + chunk. The running time only depends on
+// the length of k, never on its value, unlike big.Int.Mod.
+func ScalarReduce(k []byte) [32]byte {
+	var r [32]byte
+	if len(k) <= 64 {
+		var wide [64]byte
+		copy(wide[:], k)
+		edwards25519.ScReduce(&r, &wide)
+		zeroSlice(wide[:])
+		return r
+	}
+
+	numChunks := (len(k) + 31) / 32
+	for i := numChunks - 1; i >= 0; i-- {
+		var chunk [32]byte
+		end := (i + 1) * 32
+		if end > len(k) {
+			end = len(k)
+		}
+		copy(chunk[:], k[i*32:end])
+
+		var acc [32]byte
+		edwards25519.ScMulAdd(&acc, &r, &scTwoPow256, &chunk)
+		r = acc
+		zeroSlice(chunk[:])
+		zeroSlice(acc[:])
+	}
+
+	return r
+}
+
+// scalarMulAdd computes a*b + c mod N for the scalars a, b and c with the
+// constant time sc_muladd, so that secret scalars never go through
+// big.Int.Mod. The inputs must be below 2^256.
+func scalarMulAdd(a, b, c *big.Int) *big.Int {
+	aLE := BigIntToEncodedBytes(a)
+	defer zeroSlice(aLE[:])
+	bLE := BigIntToEncodedBytes(b)
+	defer zeroSlice(bLE[:])
+	cLE := BigIntToEncodedBytes(c)
+	defer zeroSlice(cLE[:])
+
+	var r [32]byte
+	edwards25519.ScMulAdd(&r, aLE, bLE, cLE)
+	defer zeroSlice(r[:])
+
+	return EncodedBytesToBigInt(&r)
+}
+
+// ScalarToBytesLE returns k mod N as the 32 byte little endian encoding
+// Ed25519 uses for scalars, such as S in a signature. k is reduced first,
+// negative values included, so the result is always canonical. Note that
+// big.Int.Bytes is big endian, as is the private scalar format of
+// PrivKeyFromScalar and PrivateKey.Serialize, so those bytes must never be
+// used as an Ed25519 scalar without reversing them. A nil k is encoded as
+// zero.
+func ScalarToBytesLE(k *big.Int) [32]byte {
+	var r [32]byte
+	if k == nil {
+		return r
+	}
+
+	be := new(big.Int).Abs(k).Bytes()
+	le := make([]byte, len(be))
+	for i, b := range be {
+		le[len(be)-1-i] = b
+	}
+	zeroSlice(be)
+	r = ScalarReduce(le)
+	zeroSlice(le)
+
+	if k.Sign() < 0 {
+		neg := new(big.Int).Sub(Edwards().N, EncodedBytesToBigInt(&r))
+		r = ScalarReduce(BigIntToEncodedBytes(neg)[:])
+	}
+
+	return r
+}
+
+// ScalarFromBytesLE decodes the 32 byte little endian scalar b, the inverse of
+// ScalarToBytesLE. Only canonical encodings in [0, N) are accepted, anything
+// else is rejected with a ScalarError.
+func ScalarFromBytesLE(b []byte) (*big.Int, error) {
+	if len(b) != PrivScalarSize {
+		return nil, scalarError(ScalarWrongLength, fmt.Sprintf("wrong size "+
+			"for little endian scalar (got %v, want %v)", len(b),
+			PrivScalarSize))
+	}
+	if !scMinimal(b) {
+		return nil, scalarError(ScalarOutOfRange, "little endian scalar is "+
+			"not below the order of the curve")
+	}
+
+	var le [32]byte
+	copy(le[:], b)
+	return EncodedBytesToBigInt(&le), nil
+}
+
+// ConstantTimeScalarEqual reports whether the scalars encoded in a and b are
+// the same bytes. The time taken only depends on the lengths of the slices,
+// which aren't secret, so unlike bytes.Equal it's safe to use on secret
+// scalars. Slices of different lengths are never equal.
+func ConstantTimeScalarEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// NewRandomScalar returns a uniformly random scalar in [1, N-1] read from
+// rand. It reads 64 bytes at a time and reduces them mod N in constant time,
+// which makes the bias of the reduction negligible, and reads again in the
+// unlikely case that the result is zero. Errors from rand are returned as is.
+func NewRandomScalar(curve *TwistedEdwardsCurve, rand io.Reader) (*big.Int,
+	error) {
+	for {
+		var wide [64]byte
+		if _, err := io.ReadFull(rand, wide[:]); err != nil {
+			return nil, err
+		}
+		reduced := ScalarReduce(wide[:])
+		zeroSlice(wide[:])
+		k := EncodedBytesToBigInt(&reduced)
+		zeroSlice(reduced[:])
+		if k.Sign() != 0 {
+			return k, nil
+		}
+	}
+}
+
+// ScalarInverse returns k^-1 mod N, the inverse of k modulo the order of the
+// Ed25519 group. It's computed by Fermat's little theorem as k^(N-2) mod N,
+// a fixed sequence of multiplications with the constant time sc_muladd that
+// only depends on the public exponent, so unlike the extended Euclidean
+// algorithm of big.Int.ModInverse it doesn't leak k through its timing. An
+// error is returned if k is zero mod N, which has no inverse.
+func ScalarInverse(k *big.Int) (*big.Int, error) {
+	if k == nil {
+		return nil, fmt.Errorf("scalar is nil")
+	}
+	n := Edwards().N
+	reduced := k
+	if k.Sign() < 0 || k.Cmp(n) >= 0 {
+		reduced = new(big.Int).Mod(k, n)
+	}
+	if reduced.Sign() == 0 {
+		return nil, fmt.Errorf("scalar is zero mod N and has no inverse")
+	}
+
+	kLE := BigIntToEncodedBytes(reduced)
+	defer zeroSlice(kLE[:])
+	var zeroLE, r [32]byte
+	r[0] = 1
+	defer zeroSlice(r[:])
+	for i := 255; i >= 0; i-- {
+		edwards25519.ScMulAdd(&r, &r, &r, &zeroLE)
+		if scOrderMinusTwo[i/8]>>uint(i%8)&1 == 1 {
+			edwards25519.ScMulAdd(&r, &r, kLE, &zeroLE)
+		}
+	}
+
+	// N is prime, so this can't fail, but a wrong inverse would silently
+	// break whatever uses it.
+	var check [32]byte
+	edwards25519.ScMulAdd(&check, &r, kLE, &zeroLE)
+	if EncodedBytesToBigInt(&check).Cmp(one) != 0 {
+		return nil, fmt.Errorf("scalar is not invertible mod N")
+	}
+
+	return EncodedBytesToBigInt(&r), nil
+}
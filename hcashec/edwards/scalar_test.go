@@ -0,0 +1,267 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// TestScalarReduce compares the constant time reduction against big.Int.Mod
+// on random inputs of many different lengths
+func TestScalarReduce(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+
+	// Edge cases around the order of the curve.
+	nMinusOne := new(big.Int).Sub(curve.N, one)
+	inputs := [][]byte{
+		nil,
+		{0x01},
+		BigIntToEncodedBytes(curve.N)[:],
+		BigIntToEncodedBytes(nMinusOne)[:],
+		bytes.Repeat([]byte{0xff}, 32),
+		bytes.Repeat([]byte{0xff}, 64),
+		bytes.Repeat([]byte{0xff}, 65),
+		bytes.Repeat([]byte{0xff}, 200),
+	}
+	for i := 0; i < 2000; i++ {
+		k := make([]byte, r.Intn(130))
+		r.Read(k)
+		inputs = append(inputs, k)
+	}
+
+	for _, k := range inputs {
+		// big.Int wants big endian.
+		kBE := make([]byte, len(k))
+		for i, b := range k {
+			kBE[len(k)-1-i] = b
+		}
+		want := new(big.Int).SetBytes(kBE)
+		want.Mod(want, curve.N)
+
+		got := ScalarReduce(k)
+		if EncodedBytesToBigInt(&got).Cmp(want) != 0 {
+			t.Fatalf("ScalarReduce(%x): want %x, got %x", k,
+				BigIntToEncodedBytes(want)[:], got)
+		}
+	}
+}
+
+// TestScalarMulAdd tests the constant time a*b + c mod N against big.Int
+func TestScalarMulAdd(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+
+	for i := 0; i < 500; i++ {
+		a := new(big.Int).Rand(r, curve.N)
+		b := new(big.Int).Rand(r, curve.N)
+		c := new(big.Int).Rand(r, curve.N)
+
+		want := new(big.Int).Mul(a, b)
+		want.Add(want, c)
+		want.Mod(want, curve.N)
+		if got := scalarMulAdd(a, b, c); got.Cmp(want) != 0 {
+			t.Fatalf("scalarMulAdd(%v, %v, %v): want %v, got %v", a, b, c,
+				want, got)
+		}
+	}
+}
+
+// TestNewRandomScalar tests that random scalars are in range, that zero is
+// rejected and that errors from the reader are returned
+func TestNewRandomScalar(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+
+	for i := 0; i < 100; i++ {
+		k, err := NewRandomScalar(curve, r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if k.Sign() <= 0 || k.Cmp(curve.N) >= 0 {
+			t.Fatalf("scalar %v out of range", k)
+		}
+	}
+
+	// A reader rigged to first return values reducing to zero, zero
+	// itself and N, must make it read again.
+	want := bytes.Repeat([]byte{0x42}, 64)
+	nWide := make([]byte, 64)
+	copy(nWide, BigIntToEncodedBytes(curve.N)[:])
+	rigged := bytes.NewReader(append(append(make([]byte, 64), nWide...),
+		want...))
+	k, err := NewRandomScalar(curve, rigged)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantK := ScalarReduce(want)
+	if k.Cmp(EncodedBytesToBigInt(&wantK)) != 0 {
+		t.Fatalf("want %x, got %v", wantK, k)
+	}
+	if rigged.Len() != 0 {
+		t.Fatalf("want all %v bytes read", 3*64)
+	}
+
+	// Running out of randomness is an error.
+	if _, err := NewRandomScalar(curve, bytes.NewReader(make([]byte, 100))); err == nil {
+		t.Fatalf("expected an error from a short reader")
+	}
+}
+
+// TestScalarInverse tests that k * ScalarInverse(k) = 1 mod N for random
+// and edge case scalars, that the inverse matches big.Int.ModInverse, and
+// that zero mod N has no inverse
+func TestScalarInverse(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+
+	ks := []*big.Int{
+		big.NewInt(1),
+		big.NewInt(2),
+		new(big.Int).Sub(curve.N, one),
+		new(big.Int).Add(curve.N, big.NewInt(5)),
+		big.NewInt(-3),
+	}
+	for i := 0; i < 100; i++ {
+		k, err := NewRandomScalar(curve, r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ks = append(ks, k)
+	}
+
+	for _, k := range ks {
+		inv, err := ScalarInverse(k)
+		if err != nil {
+			t.Fatalf("unexpected error inverting %v: %v", k, err)
+		}
+		prod := new(big.Int).Mul(k, inv)
+		if prod.Mod(prod, curve.N).Cmp(one) != 0 {
+			t.Fatalf("%v * %v != 1 mod N", k, inv)
+		}
+		want := new(big.Int).Mod(k, curve.N)
+		want.ModInverse(want, curve.N)
+		if inv.Cmp(want) != 0 {
+			t.Fatalf("inverse of %v: want %v, got %v", k, want, inv)
+		}
+	}
+
+	for _, k := range []*big.Int{nil, big.NewInt(0), curve.N,
+		new(big.Int).Lsh(curve.N, 1)} {
+		if _, err := ScalarInverse(k); err == nil {
+			t.Fatalf("inverted %v", k)
+		}
+	}
+}
+
+// TestConstantTimeScalarEqual tests the constant time comparison of encoded
+// scalars, including slices of different lengths
+func TestConstantTimeScalarEqual(t *testing.T) {
+	a := bytes.Repeat([]byte{0x42}, PrivScalarSize)
+	b := append([]byte(nil), a...)
+	if !ConstantTimeScalarEqual(a, b) {
+		t.Fatalf("equal scalars compare unequal")
+	}
+	for _, pos := range []int{0, PrivScalarSize / 2, PrivScalarSize - 1} {
+		c := append([]byte(nil), a...)
+		c[pos] ^= 0x01
+		if ConstantTimeScalarEqual(a, c) {
+			t.Fatalf("scalars differing at byte %v compare equal", pos)
+		}
+	}
+
+	// A prefix, or the scalar with trailing zeros, is a different length.
+	if ConstantTimeScalarEqual(a, a[:PrivScalarSize-1]) ||
+		ConstantTimeScalarEqual(a[:PrivScalarSize-1], a) {
+		t.Fatalf("scalars of different lengths compare equal")
+	}
+	if ConstantTimeScalarEqual(a, append(b, 0x00)) {
+		t.Fatalf("zero padded scalar compares equal")
+	}
+	if ConstantTimeScalarEqual(a, nil) || ConstantTimeScalarEqual(nil, a) {
+		t.Fatalf("scalar compares equal to nil")
+	}
+}
+
+// TestScalarBytesLE tests that ScalarToBytesLE and ScalarFromBytesLE
+// roundtrip, reduce out of range scalars, match the little endian layouts of
+// the order and of S in the RFC 8032 test vectors, and reject non-canonical
+// encodings
+func TestScalarBytesLE(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+
+	for i := 0; i < 100; i++ {
+		k, err := NewRandomScalar(curve, r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		b := ScalarToBytesLE(k)
+		back, err := ScalarFromBytesLE(b[:])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if back.Cmp(k) != 0 {
+			t.Fatalf("scalar %v changed to %v in a roundtrip", k, back)
+		}
+		if *BigIntToEncodedBytes(k) != b {
+			t.Fatalf("scalar %v isn't encoded like BigIntToEncodedBytes", k)
+		}
+	}
+
+	nMinusOne := new(big.Int).Sub(curve.N, one)
+	tests := []struct {
+		k  *big.Int
+		le string
+	}{
+		{nil, "0000000000000000000000000000000000000000000000000000000000000000"},
+		{one, "0100000000000000000000000000000000000000000000000000000000000000"},
+		{big.NewInt(0x0102), "0201000000000000000000000000000000000000000000000000000000000000"},
+		{nMinusOne, "ecd3f55c1a631258d69cf7a2def9de1400000000000000000000000000000010"},
+		{curve.N, "0000000000000000000000000000000000000000000000000000000000000000"},
+		{new(big.Int).Add(curve.N, one), "0100000000000000000000000000000000000000000000000000000000000000"},
+		{big.NewInt(-1), "ecd3f55c1a631258d69cf7a2def9de1400000000000000000000000000000010"},
+		{new(big.Int).Lsh(one, 300), hex.EncodeToString(BigIntToEncodedBytes(
+			new(big.Int).Mod(new(big.Int).Lsh(one, 300), curve.N))[:])},
+	}
+	for i, test := range tests {
+		if got := ScalarToBytesLE(test.k); hex.EncodeToString(got[:]) !=
+			test.le {
+			t.Fatalf("test %d: want %v, got %x", i, test.le, got)
+		}
+	}
+
+	// S of the signatures of RFC 8032 section 7.1, TEST 1 and TEST 2.
+	for i, test := range rfc8032Vectors[:2] {
+		sig, _ := hex.DecodeString(test.sig)
+		s, err := ScalarFromBytesLE(sig[32:])
+		if err != nil {
+			t.Fatalf("vector %d: unexpected error: %v", i, err)
+		}
+		if b := ScalarToBytesLE(s); !bytes.Equal(b[:], sig[32:]) {
+			t.Fatalf("vector %d: S encoded as %x, want %x", i, b, sig[32:])
+		}
+		if be := s.Bytes(); bytes.Equal(be, sig[32:]) {
+			t.Fatalf("vector %d: big endian bytes match the encoding", i)
+		}
+	}
+
+	nLE := BigIntToEncodedBytes(curve.N)
+	for i, b := range [][]byte{nil, make([]byte, 31), make([]byte, 33),
+		nLE[:], bytes.Repeat([]byte{0xff}, 32)} {
+		if _, err := ScalarFromBytesLE(b); err == nil {
+			t.Fatalf("bad scalar %d: accepted %x", i, b)
+		}
+	}
+}
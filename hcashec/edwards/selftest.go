@@ -0,0 +1,102 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+)
+
+// These are the known answers of SelfTest, the first test vector of
+// RFC 8032 section 7.1.
+const (
+	selfTestSecret = "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"
+	selfTestPubKey = "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"
+	selfTestSig    = "e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e06522490155" +
+		"5fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b"
+)
+
+// SelfTest checks the Ed25519 implementation against known answers, so that
+// a miscompiled or corrupted binary is caught before it signs or verifies
+// anything. It derives the public key of a fixed secret, which checks the
+// base point multiplication against its known encoding and against the
+// generic scalar multiplication, checks that the base point has order N,
+// reproduces the reference signature of the secret byte for byte, and
+// verifies it, making sure a corrupted message is rejected. The daemon runs
+// it at startup and refuses to start if it returns an error.
+func SelfTest() error {
+	curve := Edwards()
+	secret, _ := hex.DecodeString(selfTestSecret)
+	wantPub, _ := hex.DecodeString(selfTestPubKey)
+	wantSig, _ := hex.DecodeString(selfTestSig)
+
+	// Point multiplication.
+	priv, pub := PrivKeyFromSecret(curve, secret)
+	if priv == nil || pub == nil {
+		return fmt.Errorf("self test: failed to derive the key")
+	}
+	defer priv.Wipe()
+	if !bytes.Equal(pub.Serialize(), wantPub) {
+		return fmt.Errorf("self test: public key %x, want %x",
+			pub.Serialize(), wantPub)
+	}
+	a := BigIntToEncodedBytesNoReverse(priv.reducedScalar(curve))
+	defer zeroSlice(a[:])
+	x, y := curve.ScalarMult(curve.Gx, curve.Gy, a[:])
+	if x.Cmp(pub.GetX()) != 0 || y.Cmp(pub.GetY()) != 0 {
+		return fmt.Errorf("self test: base point and generic scalar " +
+			"multiplication disagree")
+	}
+	x, y = curve.ScalarMult(curve.Gx, curve.Gy, curve.N.Bytes())
+	if x.Sign() != 0 || y.Cmp(one) != 0 {
+		return fmt.Errorf("self test: the base point is not of order N")
+	}
+
+	// Signing.
+	msg := []byte{}
+	sig, err := SignDeterministic(curve, priv, msg)
+	if err != nil {
+		return fmt.Errorf("self test: signing failed: %v", err)
+	}
+	if !bytes.Equal(sig.Serialize(), wantSig) {
+		return fmt.Errorf("self test: signature %x, want %x",
+			sig.Serialize(), wantSig)
+	}
+
+	// Verification.
+	if !Verify(pub, msg, sig.GetR(), sig.GetS()) {
+		return fmt.Errorf("self test: the reference signature failed to " +
+			"verify")
+	}
+	if Verify(pub, []byte{0x01}, sig.GetR(), sig.GetS()) {
+		return fmt.Errorf("self test: a signature of another message " +
+			"verified")
+	}
+
+	return nil
+}
+
+// SignVerifyRoundtrip signs msg with priv and verifies the signature under
+// the public key of priv, returning an error if either step fails. It's a
+// quick check that an imported key or a key held by a hardware device is
+// usable and matches its public key, not a substitute for testing the
+// implementation, which SelfTest does against known answers.
+func SignVerifyRoundtrip(curve *TwistedEdwardsCurve, priv *PrivateKey,
+	msg []byte) error {
+	if priv == nil {
+		return fmt.Errorf("private key is nil")
+	}
+
+	r, s, err := Sign(curve, priv, msg)
+	if err != nil {
+		return fmt.Errorf("signing failed: %v", err)
+	}
+	if !Verify(priv.PubKey(), msg, r, s) {
+		return fmt.Errorf("signature failed to verify under the public key")
+	}
+
+	return nil
+}
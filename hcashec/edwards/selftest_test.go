@@ -0,0 +1,50 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"testing"
+)
+
+// TestSelfTest tests that the startup self test passes
+func TestSelfTest(t *testing.T) {
+	if err := SelfTest(); err != nil {
+		t.Fatalf("self test failed: %v", err)
+	}
+}
+
+// TestSignVerifyRoundtrip tests the roundtrip check on working keys, and
+// that it reports keys which can't sign or whose public key doesn't match
+func TestSignVerifyRoundtrip(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := []byte("Hello World in TestSignVerifyRoundtrip")
+
+	sks := append(mockUpSecKeysByBytes(curve, 3),
+		mockUpSecKeysByScalars(curve, 3)...)
+	for i, sk := range sks {
+		if err := SignVerifyRoundtrip(curve, sk, msg); err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+	}
+
+	// A key paired with the wrong public key.
+	mismatched := *sks[0]
+	ecPk := *sks[0].ecPk
+	ecPk.PublicKey = sks[1].ecPk.PublicKey
+	mismatched.ecPk = &ecPk
+	if err := SignVerifyRoundtrip(curve, &mismatched, msg); err == nil {
+		t.Fatalf("expected an error for a mismatched public key")
+	}
+
+	wiped := mockUpSecKeysByBytes(curve, 1)[0]
+	wiped.Wipe()
+	if err := SignVerifyRoundtrip(curve, wiped, msg); err == nil {
+		t.Fatalf("expected an error for a wiped key")
+	}
+	if err := SignVerifyRoundtrip(curve, nil, msg); err == nil {
+		t.Fatalf("expected an error for a nil key")
+	}
+}
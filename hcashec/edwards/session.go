@@ -0,0 +1,281 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SessionState is the round a SigningSession is in.
+type SessionState int
+
+// The states of a SigningSession, in the order they are passed through.
+const (
+	// AwaitingCommitments is the first round, in which every signer
+	// publishes the commitment to its public nonce.
+	AwaitingCommitments SessionState = iota
+
+	// AwaitingNonces is the round in which the signers reveal their public
+	// nonces, once all commitments are known.
+	AwaitingNonces
+
+	// AwaitingPartials is the round in which the signers create partial
+	// signatures for the group key and the sum of the public nonces.
+	AwaitingPartials
+
+	// Complete is the final state, in which the combined signature is
+	// available.
+	Complete
+)
+
+// String returns the name of the state.
+func (s SessionState) String() string {
+	switch s {
+	case AwaitingCommitments:
+		return "AwaitingCommitments"
+	case AwaitingNonces:
+		return "AwaitingNonces"
+	case AwaitingPartials:
+		return "AwaitingPartials"
+	case Complete:
+		return "Complete"
+	}
+	return fmt.Sprintf("SessionState(%d)", int(s))
+}
+
+// ErrSessionState is returned when a SigningSession method is called in a
+// round it doesn't belong to.
+var ErrSessionState = errors.New("call out of order for the signing session " +
+	"state")
+
+// SigningSession tracks the rounds of the threshold Schnorr signing of a
+// message by a fixed group of signers, identified by their index in the
+// list of public keys. Each round collects one contribution per signer and
+// validates it before it is accepted: revealed nonces must match their
+// commitments and partial signatures must verify with
+// VerifyPartialSignature. The session advances to the next state once every
+// signer has contributed, and calls that don't belong to the current state
+// fail with ErrSessionState. A session isn't safe for concurrent use.
+type SigningSession struct {
+	curve    *TwistedEdwardsCurve
+	msg      []byte
+	pubKeys  []*PublicKey
+	groupPub *PublicKey
+	state    SessionState
+
+	commitments [][]byte
+	nonces      []*PublicKey
+	partials    []*Signature
+
+	// done marks the signers that contributed to the current round.
+	done     []bool
+	received int
+
+	nonceSum *PublicKey
+	sig      *Signature
+
+	// maxSigners is the limit on the number of signers of the session.
+	maxSigners int
+}
+
+// NewSigningSession starts a session for the signers with the public keys
+// pubKeys to sign msg, a 32 byte message hash, with their combined key.
+func NewSigningSession(curve *TwistedEdwardsCurve, pubKeys []*PublicKey,
+	msg []byte) (*SigningSession, error) {
+	return NewSigningSessionWithMaxSigners(curve, pubKeys, msg, MaxSigners)
+}
+
+// NewSigningSessionWithMaxSigners is NewSigningSession for groups of up to
+// maxSigners signers instead of MaxSigners. The session combines the
+// partial signatures under the same limit.
+func NewSigningSessionWithMaxSigners(curve *TwistedEdwardsCurve,
+	pubKeys []*PublicKey, msg []byte, maxSigners int) (*SigningSession,
+	error) {
+	if len(msg) != PrivScalarSize {
+		return nil, fmt.Errorf("wrong size for message (got %v, want %v)",
+			len(msg), PrivScalarSize)
+	}
+	if len(pubKeys) == 0 {
+		return nil, fmt.Errorf("no signers")
+	}
+	if len(pubKeys) > maxSigners {
+		return nil, ErrTooManySigners
+	}
+	for i, pub := range pubKeys {
+		if pub == nil || pub.GetX() == nil || pub.GetY() == nil {
+			return nil, fmt.Errorf("public key %v is nil", i)
+		}
+	}
+	groupPub := CombinePubkeys(curve, pubKeys)
+	if groupPub == nil {
+		return nil, ErrIdentityKey
+	}
+
+	n := len(pubKeys)
+	return &SigningSession{
+		curve:       curve,
+		msg:         append([]byte(nil), msg...),
+		pubKeys:     append([]*PublicKey(nil), pubKeys...),
+		groupPub:    groupPub,
+		state:       AwaitingCommitments,
+		commitments: make([][]byte, n),
+		nonces:      make([]*PublicKey, n),
+		partials:    make([]*Signature, n),
+		done:        make([]bool, n),
+		maxSigners:  maxSigners,
+	}, nil
+}
+
+// State returns the round the session is in.
+func (s *SigningSession) State() SessionState {
+	return s.state
+}
+
+// GroupPubKey returns the combined public key the session signs for.
+func (s *SigningSession) GroupPubKey() *PublicKey {
+	return s.groupPub
+}
+
+// checkSigner returns an error if the session isn't in state want or if
+// index isn't a signer that still has to contribute to the round.
+func (s *SigningSession) checkSigner(want SessionState, index int) error {
+	if s.state != want {
+		return ErrSessionState
+	}
+	if index < 0 || index >= len(s.pubKeys) {
+		return fmt.Errorf("signer index %v is out of range (%v signers)",
+			index, len(s.pubKeys))
+	}
+	if s.done[index] {
+		return fmt.Errorf("duplicate contribution from signer %v", index)
+	}
+
+	return nil
+}
+
+// lastSigner returns whether only one signer has yet to contribute to the
+// round.
+func (s *SigningSession) lastSigner() bool {
+	return s.received+1 == len(s.pubKeys)
+}
+
+// advance records the contribution of the signer at index and moves to the
+// next state once every signer has contributed to the round.
+func (s *SigningSession) advance(index int) {
+	s.done[index] = true
+	s.received++
+	if s.received < len(s.pubKeys) {
+		return
+	}
+	for i := range s.done {
+		s.done[i] = false
+	}
+	s.received = 0
+	s.state++
+}
+
+// AddCommitment records the nonce commitment of the signer at index, as
+// created by NonceCommitment. The session moves on to AwaitingNonces once
+// all commitments are in.
+func (s *SigningSession) AddCommitment(index int, commitment []byte) error {
+	if err := s.checkSigner(AwaitingCommitments, index); err != nil {
+		return err
+	}
+	if len(commitment) != NonceCommitmentSize {
+		return fmt.Errorf("bad commitment size for signer %v", index)
+	}
+
+	s.commitments[index] = append([]byte(nil), commitment...)
+	s.advance(index)
+	return nil
+}
+
+// AddNonce records the public nonce revealed by the signer at index, which
+// must match its commitment. Once all nonces are in, they are summed and the
+// session moves on to AwaitingPartials.
+func (s *SigningSession) AddNonce(index int, pubNonce *PublicKey) error {
+	if err := s.checkSigner(AwaitingNonces, index); err != nil {
+		return err
+	}
+	if !VerifyNonceCommitment(s.commitments[index], pubNonce) {
+		return fmt.Errorf("public nonce of signer %v doesn't match its "+
+			"commitment", index)
+	}
+
+	s.nonces[index] = pubNonce
+	if s.lastSigner() {
+		nonceSum, err := AggregatePublicNonces(s.curve, s.nonces)
+		if err != nil {
+			s.nonces[index] = nil
+			return err
+		}
+		s.nonceSum = nonceSum
+	}
+	s.advance(index)
+	return nil
+}
+
+// PublicNonceSum returns the sum of the public nonces the partial signatures
+// are made for, or nil before the session reaches AwaitingPartials.
+func (s *SigningSession) PublicNonceSum() *PublicKey {
+	return s.nonceSum
+}
+
+// PartialSign creates the partial signature of the signer at index, with
+// its private key and the secret nonce it committed to, for the group key
+// and the sum of the public nonces. The partial signature isn't recorded;
+// pass it to AddPartial.
+func (s *SigningSession) PartialSign(index int, priv,
+	privNonce *PrivateKey) (*Signature, error) {
+	if err := s.checkSigner(AwaitingPartials, index); err != nil {
+		return nil, err
+	}
+
+	r, sc, err := SchnorrPartialSign(s.curve, s.msg, priv, s.groupPub,
+		privNonce, s.nonceSum)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSignature(r, sc), nil
+}
+
+// AddPartial records the partial signature of the signer at index after
+// checking it with VerifyPartialSignature. Once all partial signatures are
+// in, they are combined and the session is Complete.
+func (s *SigningSession) AddPartial(index int, partial *Signature) error {
+	if err := s.checkSigner(AwaitingPartials, index); err != nil {
+		return err
+	}
+	if !VerifyPartialSignature(s.curve, partial, s.pubKeys[index],
+		s.nonces[index], s.nonceSum, s.groupPub, s.msg) {
+		return fmt.Errorf("invalid partial signature from signer %v",
+			index)
+	}
+
+	s.partials[index] = partial
+	if s.lastSigner() {
+		sig, err := SchnorrCombineSigsWithMaxSigners(s.curve, s.partials,
+			s.maxSigners)
+		if err != nil {
+			s.partials[index] = nil
+			return err
+		}
+		s.sig = sig
+	}
+	s.advance(index)
+	return nil
+}
+
+// Signature returns the combined signature of a Complete session, which
+// verifies with the group public key.
+func (s *SigningSession) Signature() (*Signature, error) {
+	if s.state != Complete {
+		return nil, ErrSessionState
+	}
+
+	return s.sig, nil
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// Share is one of the n Shamir shares of a secret scalar, the evaluation
+// p(Index) of a random polynomial of degree Threshold-1 with p(0) being the
+// secret. Any Threshold of the shares recover the secret.
+type Share struct {
+	// Index is the non-zero x-coordinate of the share.
+	Index uint32
+
+	// Threshold is the number of shares required to recover the secret.
+	Threshold int
+
+	// Value is p(Index) mod N.
+	Value *big.Int
+}
+
+// evalPolynomial evaluates the polynomial with the given coefficients, the
+// constant term first, at x mod N with Horner's method.
+func evalPolynomial(coefficients []*big.Int, x *big.Int) *big.Int {
+	y := new(big.Int)
+	for j := len(coefficients) - 1; j >= 0; j-- {
+		next := scalarMulAdd(y, x, coefficients[j])
+		y.SetInt64(0)
+		y = next
+	}
+
+	return y
+}
+
+// SplitSecret splits secret, a scalar mod N, into n Shamir shares with the
+// indices 1 to n, any t of which recover it with RecombineSecret, while
+// fewer give no information about it. Only the Ed25519 group order is
+// supported.
+func SplitSecret(curve *TwistedEdwardsCurve, secret *big.Int, t,
+	n int) ([]*Share, error) {
+	shares, _, err := splitSecret(curve, rand.Reader, secret, t, n)
+	return shares, err
+}
+
+// splitSecret is the implementation of SplitSecret and
+// SplitSecretVerifiable, taking the source of randomness for the polynomial
+// as an argument. Along with the shares, it returns the Feldman commitments
+// a_j*G to the coefficients of the polynomial.
+func splitSecret(curve *TwistedEdwardsCurve, rand io.Reader, secret *big.Int,
+	t, n int) ([]*Share, []*PublicKey, error) {
+	if curve == nil || curve.isEd448() {
+		return nil, nil, fmt.Errorf("secret sharing is only supported " +
+			"on Ed25519")
+	}
+	if secret == nil || secret.Sign() < 0 || secret.Cmp(curve.N) >= 0 {
+		return nil, nil, fmt.Errorf("secret is not a scalar mod N")
+	}
+	if t < 1 || t > n {
+		return nil, nil, fmt.Errorf("invalid threshold %v for %v shares",
+			t, n)
+	}
+	if uint64(n) > uint64(^uint32(0)) {
+		return nil, nil, fmt.Errorf("too many shares")
+	}
+
+	coefficients := make([]*big.Int, t)
+	defer func() {
+		for _, c := range coefficients[1:] {
+			if c != nil {
+				c.SetInt64(0)
+			}
+		}
+	}()
+	coefficients[0] = secret
+	for i := 1; i < t; i++ {
+		c, err := NewRandomScalar(curve, rand)
+		if err != nil {
+			return nil, nil, err
+		}
+		coefficients[i] = c
+	}
+
+	commitments := make([]*PublicKey, t)
+	for i, c := range coefficients {
+		cLE := BigIntToEncodedBytes(c)
+		var p edwards25519.ExtendedGroupElement
+		edwards25519.GeScalarMultBase(&p, cLE)
+		zeroSlice(cLE[:])
+		pub, err := geToPublicKey(curve, &p)
+		if err != nil {
+			return nil, nil, err
+		}
+		commitments[i] = pub
+	}
+
+	shares := make([]*Share, n)
+	for i := range shares {
+		index := uint32(i + 1)
+		shares[i] = &Share{
+			Index:     index,
+			Threshold: t,
+			Value: evalPolynomial(coefficients,
+				new(big.Int).SetUint64(uint64(index))),
+		}
+	}
+
+	return shares, commitments, nil
+}
+
+// RecombineSecret recovers the secret from at least Threshold of its shares
+// as produced by SplitSecret, by Lagrange interpolation at zero. All the
+// shares given are used, so they must come from the same split; shares with
+// mismatched thresholds, duplicate indices or too few shares are rejected.
+func RecombineSecret(shares []*Share) (*big.Int, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("no shares")
+	}
+	n := Edwards().N
+	indices := make([]*big.Int, len(shares))
+	for i, share := range shares {
+		if share == nil || share.Value == nil {
+			return nil, fmt.Errorf("share %v is nil", i)
+		}
+		if share.Threshold != shares[0].Threshold {
+			return nil, fmt.Errorf("shares have different thresholds")
+		}
+		if share.Value.Sign() < 0 || share.Value.Cmp(n) >= 0 {
+			return nil, fmt.Errorf("share %v is out of bounds", share.Index)
+		}
+		indices[i] = new(big.Int).SetUint64(uint64(share.Index))
+	}
+	if len(shares) < shares[0].Threshold {
+		return nil, fmt.Errorf("need %v shares to recombine, have %v",
+			shares[0].Threshold, len(shares))
+	}
+
+	secret := new(big.Int)
+	for i, share := range shares {
+		lambda, err := LagrangeCoefficient(indices, indices[i])
+		if err != nil {
+			return nil, err
+		}
+		next := scalarMulAdd(lambda, share.Value, secret)
+		secret.SetInt64(0)
+		secret = next
+	}
+
+	return secret, nil
+}
+
+// LagrangeCoefficient returns the Lagrange coefficient at zero of the index i
+// over the set of indices, lambda_i = prod x_j / (x_j - x_i) for j != i, mod
+// the order N of the Ed25519 group. Given the Shamir shares p(x_j) of a
+// secret, sum lambda_j * p(x_j) = p(0) is the secret, and the same weights
+// combine FROST partial signatures. The indices are reduced mod N, and an
+// error is returned if any of them is zero or appears twice, since both
+// would make the reconstruction meaningless, or if i is not one of them.
+func LagrangeCoefficient(indices []*big.Int, i *big.Int) (*big.Int, error) {
+	if i == nil {
+		return nil, fmt.Errorf("index is nil")
+	}
+	n := Edwards().N
+	xi := new(big.Int).Mod(i, n)
+
+	num := big.NewInt(1)
+	den := big.NewInt(1)
+	reduced := make([]*big.Int, 0, len(indices))
+	found := false
+	for pos, index := range indices {
+		if index == nil {
+			return nil, fmt.Errorf("index %v is nil", pos)
+		}
+		xj := new(big.Int).Mod(index, n)
+		if xj.Sign() == 0 {
+			return nil, fmt.Errorf("index %v is zero", pos)
+		}
+		for _, prev := range reduced {
+			if prev.Cmp(xj) == 0 {
+				return nil, fmt.Errorf("index %v is a duplicate", pos)
+			}
+		}
+		reduced = append(reduced, xj)
+
+		if xj.Cmp(xi) == 0 {
+			found = true
+			continue
+		}
+		num.Mul(num, xj)
+		num.Mod(num, n)
+		diff := new(big.Int).Sub(xj, xi)
+		den.Mul(den, diff)
+		den.Mod(den, n)
+	}
+	if !found {
+		return nil, fmt.Errorf("index %v is not in the set", i)
+	}
+
+	inv, err := ScalarInverse(den)
+	if err != nil {
+		return nil, err
+	}
+	num.Mul(num, inv)
+	return num.Mod(num, n), nil
+}
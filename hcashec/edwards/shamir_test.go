@@ -0,0 +1,201 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// TestLagrangeCoefficient tests reconstructing a known secret from subsets
+// of its Shamir shares, and that bad index sets are rejected
+func TestLagrangeCoefficient(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+
+	// p(x) = secret + c1*x + c2*x^2, three shares reconstruct it.
+	secret := big.NewInt(123456789)
+	coefficients := []*big.Int{secret}
+	for i := 0; i < 2; i++ {
+		c, err := NewRandomScalar(curve, r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		coefficients = append(coefficients, c)
+	}
+	share := func(x *big.Int) *big.Int {
+		return evalPolynomial(coefficients, x)
+	}
+
+	subsets := [][]int64{
+		{1, 2, 3},
+		{2, 4, 5},
+		{5, 1, 3},
+		{7, 100, 65536},
+		{1, 2, 3, 4, 5},
+	}
+	for i, subset := range subsets {
+		indices := make([]*big.Int, len(subset))
+		for j, x := range subset {
+			indices[j] = big.NewInt(x)
+		}
+
+		got := new(big.Int)
+		for _, x := range indices {
+			lambda, err := LagrangeCoefficient(indices, x)
+			if err != nil {
+				t.Fatalf("test %d: unexpected error: %v", i, err)
+			}
+			got = scalarMulAdd(lambda, share(x), got)
+		}
+		if got.Cmp(secret) != 0 {
+			t.Fatalf("test %d: reconstructed %v, want %v", i, got, secret)
+		}
+	}
+
+	// Too few shares give an unrelated value.
+	indices := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	got := new(big.Int)
+	for _, x := range indices {
+		lambda, _ := LagrangeCoefficient(indices, x)
+		got = scalarMulAdd(lambda, share(x), got)
+	}
+	if got.Cmp(secret) == 0 {
+		t.Fatalf("reconstructed the secret from too few shares")
+	}
+
+	bad := []struct {
+		name    string
+		indices []*big.Int
+		i       *big.Int
+	}{
+		{"zero index", []*big.Int{big.NewInt(0), big.NewInt(1)},
+			big.NewInt(1)},
+		{"index N", []*big.Int{curve.N, big.NewInt(1)}, big.NewInt(1)},
+		{"duplicate", []*big.Int{big.NewInt(1), big.NewInt(2),
+			big.NewInt(1)}, big.NewInt(2)},
+		{"duplicate mod N", []*big.Int{big.NewInt(1),
+			new(big.Int).Add(curve.N, one)}, big.NewInt(1)},
+		{"nil index", []*big.Int{big.NewInt(1), nil}, big.NewInt(1)},
+		{"missing index", []*big.Int{big.NewInt(1), big.NewInt(2)},
+			big.NewInt(3)},
+		{"nil i", []*big.Int{big.NewInt(1)}, nil},
+	}
+	for _, test := range bad {
+		if _, err := LagrangeCoefficient(test.indices, test.i); err == nil {
+			t.Fatalf("%s: expected an error", test.name)
+		}
+	}
+}
+
+// TestSplitSecret tests that 2-of-3 and 3-of-5 splits recover the exact
+// secret from any t shares, and that t-1 shares can't be recombined and
+// don't interpolate to the secret
+func TestSplitSecret(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+
+	tests := []struct {
+		t, n int
+	}{
+		{2, 3},
+		{3, 5},
+		{1, 1},
+	}
+	for i, test := range tests {
+		secret, err := NewRandomScalar(curve, r)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		shares, _, err := splitSecret(curve, r, secret, test.t, test.n)
+		if err != nil {
+			t.Fatalf("test %d: unexpected split error: %v", i, err)
+		}
+		if len(shares) != test.n {
+			t.Fatalf("test %d: got %v shares, want %v", i, len(shares),
+				test.n)
+		}
+
+		// Every subset of at least t shares recovers the secret.
+		for mask := 1; mask < 1<<uint(test.n); mask++ {
+			var subset []*Share
+			for j, share := range shares {
+				if mask&(1<<uint(j)) != 0 {
+					subset = append(subset, share)
+				}
+			}
+
+			got, err := RecombineSecret(subset)
+			if len(subset) < test.t {
+				if err == nil {
+					t.Fatalf("test %d: recombined %v of %v shares", i,
+						len(subset), test.t)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("test %d: unexpected recombine error: %v", i, err)
+			}
+			if got.Cmp(secret) != 0 {
+				t.Fatalf("test %d: recombined %v, want %v", i, got, secret)
+			}
+		}
+
+		// Interpolating t-1 shares as if they were enough gives an
+		// unrelated value.
+		if test.t > 1 {
+			subset := make([]*Share, test.t-1)
+			for j := range subset {
+				s := *shares[j]
+				s.Threshold = test.t - 1
+				subset[j] = &s
+			}
+			got, err := RecombineSecret(subset)
+			if err != nil {
+				t.Fatalf("test %d: unexpected recombine error: %v", i, err)
+			}
+			if got.Cmp(secret) == 0 {
+				t.Fatalf("test %d: %v shares revealed the secret", i,
+					test.t-1)
+			}
+		}
+	}
+
+	// Shares of a split and of another split of the same secret can't be
+	// mixed.
+	secret := big.NewInt(42)
+	a, _, _ := splitSecret(curve, r, secret, 2, 3)
+	b, _, _ := splitSecret(curve, r, secret, 2, 3)
+	if got, _ := RecombineSecret([]*Share{a[0], b[1]}); got.Cmp(secret) == 0 {
+		t.Fatalf("recombined shares of different splits")
+	}
+	if got, err := RecombineSecret([]*Share{a[0], a[0]}); err == nil {
+		t.Fatalf("recombined duplicate shares to %v", got)
+	}
+	c, _, _ := splitSecret(curve, r, secret, 3, 3)
+	if _, err := RecombineSecret([]*Share{a[0], a[1], c[2]}); err == nil {
+		t.Fatalf("recombined shares with different thresholds")
+	}
+
+	bad := []struct {
+		secret *big.Int
+		t, n   int
+	}{
+		{nil, 2, 3},
+		{curve.N, 2, 3},
+		{big.NewInt(-1), 2, 3},
+		{secret, 0, 3},
+		{secret, 4, 3},
+	}
+	for i, test := range bad {
+		if _, err := SplitSecret(curve, test.secret, test.t,
+			test.n); err == nil {
+			t.Fatalf("bad test %d: expected an error", i)
+		}
+	}
+}
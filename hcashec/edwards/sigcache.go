@@ -0,0 +1,285 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"sync"
+	"sync/atomic"
+
+	"github.com/HcashOrg/hcashd/chaincfg/chainhash"
+)
+
+// sigCacheKey is the key of a SignatureCache entry, the hash of the
+// signature, the public key and the message.
+type sigCacheKey [sha256.Size]byte
+
+// writeSized writes b to h preceded by its length as 8 bytes. Ed25519 and
+// Ed448 signatures and keys have different sizes, so without the length the
+// bytes of a signature, a key and a message could be split up differently
+// into another triple that hashes the same.
+func writeSized(h hash.Hash, b []byte) {
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(b)))
+	h.Write(length[:])
+	h.Write(b)
+}
+
+// newSigCacheKey computes the cache key of a signature of msg by pub.
+func newSigCacheKey(sig *Signature, pub *PublicKey, msg []byte) sigCacheKey {
+	var key sigCacheKey
+	h := sha256.New()
+	writeSized(h, sig.Serialize())
+	writeSized(h, pub.Serialize())
+	h.Write(msg)
+	h.Sum(key[:0])
+	return key
+}
+
+// SignatureCache is a cache of valid signatures with a least recently used
+// eviction policy, so that signatures seen again as a transaction
+// propagates don't have to be verified over and over. Only valid signatures
+// should be added. Entries are keyed by the hash of the signature, the
+// public key and the message together, so a hit always means that exact
+// triple was verified before.
+type SignatureCache struct {
+	mtx        sync.Mutex
+	entries    map[sigCacheKey]*list.Element
+	lru        *list.List
+	maxEntries uint
+}
+
+// NewSignatureCache creates a signature cache holding at most maxEntries
+// entries. A cache with maxEntries set to zero never stores anything.
+func NewSignatureCache(maxEntries uint) *SignatureCache {
+	return &SignatureCache{
+		entries:    make(map[sigCacheKey]*list.Element, maxEntries),
+		lru:        list.New(),
+		maxEntries: maxEntries,
+	}
+}
+
+// Exists returns true if the signature sig of msg by pub is in the cache,
+// marking it as recently used. Otherwise, false is returned.
+//
+// NOTE: This function is safe for concurrent access.
+func (c *SignatureCache) Exists(sig *Signature, pub *PublicKey,
+	msg []byte) bool {
+	if sig == nil || pub == nil {
+		return false
+	}
+	key := newSigCacheKey(sig, pub, msg)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	elem, ok := c.entries[key]
+	if ok {
+		c.lru.MoveToFront(elem)
+	}
+	return ok
+}
+
+// Add adds the valid signature sig of msg by pub to the cache. If the cache
+// is full, the least recently used entry is evicted to make room for it.
+//
+// NOTE: This function is safe for concurrent access.
+func (c *SignatureCache) Add(sig *Signature, pub *PublicKey, msg []byte) {
+	if sig == nil || pub == nil || c.maxEntries == 0 {
+		return
+	}
+	key := newSigCacheKey(sig, pub, msg)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	if uint(c.lru.Len()) >= c.maxEntries {
+		oldest := c.lru.Back()
+		delete(c.entries, oldest.Value.(sigCacheKey))
+		c.lru.Remove(oldest)
+	}
+	c.entries[key] = c.lru.PushFront(key)
+}
+
+// Len returns the number of signatures in the cache.
+//
+// NOTE: This function is safe for concurrent access.
+func (c *SignatureCache) Len() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.lru.Len()
+}
+
+// Verify verifies the signature sig of msg by pub like Verify, skipping the
+// elliptic curve operations if the signature is already in the cache and
+// adding it to the cache if it's valid.
+//
+// NOTE: This function is safe for concurrent access.
+func (c *SignatureCache) Verify(pub *PublicKey, msg []byte,
+	sig *Signature) bool {
+	if sig == nil {
+		return false
+	}
+	if c.Exists(sig, pub, msg) {
+		return true
+	}
+	if !Verify(pub, msg, sig.R, sig.S) {
+		return false
+	}
+
+	c.Add(sig, pub, msg)
+	return true
+}
+
+// BlockSignature is a signature of a block to be verified by
+// BlockSigCache.VerifyBlock, along with the public key and the message it
+// signs.
+type BlockSignature struct {
+	PubKey *PublicKey
+	Msg    []byte
+	Sig    *Signature
+}
+
+// blockSigsDigest hashes the signatures of a block together with their
+// public keys and messages, length prefixing each of them so that no two
+// lists of signatures hash the same. It returns false if any of them is
+// incomplete.
+func blockSigsDigest(sigs []*BlockSignature) ([sha256.Size]byte, bool) {
+	var digest [sha256.Size]byte
+	h := sha256.New()
+	for _, bs := range sigs {
+		if bs == nil || bs.PubKey == nil || bs.PubKey.X == nil ||
+			bs.PubKey.Y == nil || bs.Msg == nil || bs.Sig == nil ||
+			bs.Sig.R == nil || bs.Sig.S == nil {
+			return digest, false
+		}
+		writeSized(h, bs.Sig.Serialize())
+		writeSized(h, bs.PubKey.Serialize())
+		writeSized(h, bs.Msg)
+	}
+	h.Sum(digest[:0])
+	return digest, true
+}
+
+// blockSigEntry is the cached verification result of the signatures of a
+// block. done is closed once valid has been set, so that callers verifying
+// the same block at the same time wait for the first one instead of
+// repeating its work.
+type blockSigEntry struct {
+	hash   chainhash.Hash
+	digest [sha256.Size]byte
+	valid  bool
+	done   chan struct{}
+}
+
+// BlockSigCache caches the result of verifying all the signatures of a
+// block at once, keyed by the block hash, so that blocks processed again
+// while handling a chain reorganization don't have to be verified again.
+// Both valid and invalid results are cached, along with a digest of the
+// signatures, public keys and messages they were computed for. A block
+// whose signatures differ from the cached ones is verified again and
+// replaces the entry, so a cached result is never returned for signatures
+// other than those it was computed for. The least recently used block is
+// evicted when the cache is full.
+type BlockSigCache struct {
+	// verifications counts the blocks actually verified, for the tests.
+	verifications uint64
+
+	curve      *TwistedEdwardsCurve
+	mtx        sync.Mutex
+	entries    map[chainhash.Hash]*list.Element
+	lru        *list.List
+	maxEntries uint
+}
+
+// NewBlockSigCache creates a cache of block verification results for the
+// signatures on curve, holding the results of at most maxEntries blocks. A
+// cache with maxEntries set to zero never stores anything.
+func NewBlockSigCache(curve *TwistedEdwardsCurve,
+	maxEntries uint) *BlockSigCache {
+	return &BlockSigCache{
+		curve:      curve,
+		entries:    make(map[chainhash.Hash]*list.Element, maxEntries),
+		lru:        list.New(),
+		maxEntries: maxEntries,
+	}
+}
+
+// VerifyBlock returns whether all the signatures sigs of the block with
+// hash blockHash are valid. They are verified together with BatchVerify the
+// first time and the result is cached, so calling VerifyBlock again with
+// the same signatures returns the cached result without doing any elliptic
+// curve operations. BatchVerify accepts exactly the signatures Verify
+// accepts, whatever its random coefficients, so the cached result doesn't
+// depend on the verification that happened to come first. Concurrent calls for the same block and signatures
+// share a single verification. A block without signatures verifies
+// trivially, and incomplete signatures fail without being cached.
+//
+// NOTE: This function is safe for concurrent access.
+func (c *BlockSigCache) VerifyBlock(blockHash *chainhash.Hash,
+	sigs ...*BlockSignature) bool {
+	if blockHash == nil {
+		return false
+	}
+	digest, ok := blockSigsDigest(sigs)
+	if !ok {
+		return false
+	}
+
+	c.mtx.Lock()
+	if elem, ok := c.entries[*blockHash]; ok {
+		entry := elem.Value.(*blockSigEntry)
+		if entry.digest == digest {
+			c.lru.MoveToFront(elem)
+			c.mtx.Unlock()
+			<-entry.done
+			return entry.valid
+		}
+		delete(c.entries, *blockHash)
+		c.lru.Remove(elem)
+	}
+	entry := &blockSigEntry{hash: *blockHash, digest: digest,
+		done: make(chan struct{})}
+	if c.maxEntries > 0 {
+		if uint(c.lru.Len()) >= c.maxEntries {
+			oldest := c.lru.Back()
+			delete(c.entries, oldest.Value.(*blockSigEntry).hash)
+			c.lru.Remove(oldest)
+		}
+		c.entries[*blockHash] = c.lru.PushFront(entry)
+	}
+	c.mtx.Unlock()
+
+	pubkeys := make([]*PublicKey, len(sigs))
+	msgs := make([][]byte, len(sigs))
+	blockSigs := make([]*Signature, len(sigs))
+	for i, bs := range sigs {
+		pubkeys[i], msgs[i], blockSigs[i] = bs.PubKey, bs.Msg, bs.Sig
+	}
+	entry.valid, _ = BatchVerify(c.curve, pubkeys, msgs, blockSigs)
+	atomic.AddUint64(&c.verifications, 1)
+	close(entry.done)
+
+	return entry.valid
+}
+
+// Len returns the number of blocks in the cache.
+//
+// NOTE: This function is safe for concurrent access.
+func (c *BlockSigCache) Len() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.lru.Len()
+}
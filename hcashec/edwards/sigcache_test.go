@@ -0,0 +1,322 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"encoding/hex"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/HcashOrg/hcashd/chaincfg/chainhash"
+)
+
+// mockUpCachedSigs signs n distinct messages for use in the signature cache
+// tests.
+func mockUpCachedSigs(t *testing.T, curve *TwistedEdwardsCurve,
+	n int) ([]*Signature, *PublicKey, [][]byte) {
+	priv := mockUpSecKeysByBytes(curve, 1)[0]
+	pubX, pubY := priv.Public()
+	pub := NewPublicKey(curve, pubX, pubY)
+
+	sigs := make([]*Signature, n)
+	msgs := make([][]byte, n)
+	for i := range sigs {
+		msgs[i] = []byte{byte(i), byte(i >> 8)}
+		sig, err := SignDeterministic(curve, priv, msgs[i])
+		if err != nil {
+			t.Fatalf("unexpected signing error: %v", err)
+		}
+		sigs[i] = sig
+	}
+
+	return sigs, pub, msgs
+}
+
+// TestSignatureCache tests adding, looking up and evicting entries
+func TestSignatureCache(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	sigs, pub, msgs := mockUpCachedSigs(t, curve, 4)
+
+	cache := NewSignatureCache(3)
+	for i := 0; i < 3; i++ {
+		if !cache.Verify(pub, msgs[i], sigs[i]) {
+			t.Fatalf("signature %v failed to verify", i)
+		}
+	}
+	if cache.Len() != 3 {
+		t.Fatalf("want 3 entries, got %v", cache.Len())
+	}
+
+	// The same signature for another message is a different entry.
+	if cache.Exists(sigs[0], pub, msgs[1]) {
+		t.Fatalf("found a signature for the wrong message")
+	}
+
+	// Touch the first entry so the second one is the least recently used
+	// and gets evicted.
+	if !cache.Exists(sigs[0], pub, msgs[0]) {
+		t.Fatalf("signature 0 not found")
+	}
+	cache.Add(sigs[3], pub, msgs[3])
+	if cache.Len() != 3 {
+		t.Fatalf("want 3 entries, got %v", cache.Len())
+	}
+	if cache.Exists(sigs[1], pub, msgs[1]) {
+		t.Fatalf("least recently used signature was not evicted")
+	}
+	for _, i := range []int{0, 2, 3} {
+		if !cache.Exists(sigs[i], pub, msgs[i]) {
+			t.Fatalf("signature %v was evicted", i)
+		}
+	}
+
+	// Invalid signatures never make it into the cache.
+	if cache.Verify(pub, msgs[1], sigs[2]) {
+		t.Fatalf("invalid signature verified")
+	}
+	if cache.Exists(sigs[2], pub, msgs[1]) {
+		t.Fatalf("invalid signature was cached")
+	}
+
+	empty := NewSignatureCache(0)
+	empty.Add(sigs[0], pub, msgs[0])
+	if empty.Exists(sigs[0], pub, msgs[0]) {
+		t.Fatalf("cache of size zero stored an entry")
+	}
+}
+
+// TestSignatureCacheEd448 tests that an Ed448 signature is cached under its
+// full encoding, so that a tampered signature isn't taken for the cached one
+func TestSignatureCacheEd448(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParamEd448()
+
+	sk, _ := hex.DecodeString(ed448TestVectors[0].sk)
+	priv, pub := PrivKeyFromSecret(curve, sk)
+	msg := []byte("Hello World in TestSignatureCacheEd448")
+	r, s, err := Sign(curve, priv, msg)
+	if err != nil {
+		t.Fatalf("unexpected signing error: %v", err)
+	}
+
+	cache := NewSignatureCache(4)
+	if !cache.Verify(pub, msg, NewSignature(r, s)) {
+		t.Fatalf("signature failed to verify")
+	}
+	tampered := NewSignature(r, new(big.Int).Add(s, one))
+	if cache.Exists(tampered, pub, msg) || cache.Verify(pub, msg, tampered) {
+		t.Fatalf("tampered signature found in the cache")
+	}
+	if !cache.Exists(NewSignature(r, s), pub, msg) {
+		t.Fatalf("signature not found in the cache")
+	}
+}
+
+// TestSignatureCacheConcurrency tests concurrent use of the signature cache
+func TestSignatureCacheConcurrency(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	sigs, pub, msgs := mockUpCachedSigs(t, curve, 16)
+
+	cache := NewSignatureCache(8)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				j := (g + i) % len(sigs)
+				cache.Add(sigs[j], pub, msgs[j])
+				cache.Exists(sigs[j], pub, msgs[j])
+				cache.Exists(sigs[j], pub, msgs[(j+1)%len(msgs)])
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if cache.Len() > 8 {
+		t.Fatalf("cache grew beyond its size: %v entries", cache.Len())
+	}
+	for i := range sigs {
+		if cache.Exists(sigs[i], pub, msgs[(i+1)%len(msgs)]) {
+			t.Fatalf("found a signature for the wrong message")
+		}
+	}
+}
+
+// mockUpBlockSigs returns the signatures of n blocks of four signatures each
+// along with their block hashes, the signatures of the last block having
+// one of them swapped for the signature of another message.
+func mockUpBlockSigs(t *testing.T, curve *TwistedEdwardsCurve,
+	n int) ([]chainhash.Hash, [][]*BlockSignature) {
+	sigs, pub, msgs := mockUpCachedSigs(t, curve, 4*n)
+
+	hashes := make([]chainhash.Hash, n)
+	blocks := make([][]*BlockSignature, n)
+	for i := range blocks {
+		hashes[i] = chainhash.HashH([]byte{byte(i)})
+		for j := 4 * i; j < 4*i+4; j++ {
+			blocks[i] = append(blocks[i], &BlockSignature{pub, msgs[j],
+				sigs[j]})
+		}
+	}
+	last := blocks[n-1]
+	last[0] = &BlockSignature{pub, last[0].Msg, last[1].Sig}
+
+	return hashes, blocks
+}
+
+// TestBlockSigCache tests that block verification results are cached by
+// block hash and signatures, and that the least recently used block is
+// evicted
+func TestBlockSigCache(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	hashes, blocks := mockUpBlockSigs(t, curve, 4)
+	bad := len(blocks) - 1
+
+	cache := NewBlockSigCache(curve, 3)
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 3; i++ {
+			if cache.VerifyBlock(&hashes[i], blocks[i]...) != (i != bad) {
+				t.Fatalf("round %d: wrong result for block %d", round, i)
+			}
+		}
+		if cache.VerifyBlock(&hashes[bad], blocks[bad]...) {
+			t.Fatalf("round %d: verified the bad block", round)
+		}
+	}
+	// The bad block evicted block 0 in the first round, which evicted
+	// block 1 in the second, and so on, so every lookup missed.
+	if cache.verifications != 8 || cache.Len() != 3 {
+		t.Fatalf("want 8 verifications and 3 entries, got %v and %v",
+			cache.verifications, cache.Len())
+	}
+
+	cache = NewBlockSigCache(curve, 4)
+	for round := 0; round < 3; round++ {
+		for i := range blocks {
+			if cache.VerifyBlock(&hashes[i], blocks[i]...) != (i != bad) {
+				t.Fatalf("round %d: wrong result for block %d", round, i)
+			}
+		}
+	}
+	if cache.verifications != uint64(len(blocks)) {
+		t.Fatalf("want %v verifications, got %v", len(blocks),
+			cache.verifications)
+	}
+
+	// Other signatures under a cached block hash are verified again.
+	if !cache.VerifyBlock(&hashes[bad], blocks[0]...) {
+		t.Fatalf("cached result returned for other signatures")
+	}
+	if cache.VerifyBlock(&hashes[0], blocks[bad]...) {
+		t.Fatalf("cached result returned for other signatures")
+	}
+	if !cache.VerifyBlock(&hashes[0], blocks[0][:3]...) ||
+		cache.verifications != uint64(len(blocks))+3 {
+		t.Fatalf("a subset of the signatures wasn't verified again")
+	}
+
+	if !cache.VerifyBlock(&hashes[1]) {
+		t.Fatalf("block without signatures failed to verify")
+	}
+	if cache.VerifyBlock(nil, blocks[0]...) {
+		t.Fatalf("verified a block without hash")
+	}
+	incomplete := []*BlockSignature{blocks[0][0], {blocks[0][1].PubKey,
+		nil, blocks[0][1].Sig}}
+	if cache.VerifyBlock(&hashes[2], incomplete...) ||
+		cache.VerifyBlock(&hashes[2], blocks[0][0], nil) {
+		t.Fatalf("verified incomplete signatures")
+	}
+
+	cache = NewBlockSigCache(curve, 0)
+	cache.VerifyBlock(&hashes[0], blocks[0]...)
+	if cache.Len() != 0 {
+		t.Fatalf("zero sized cache stored a block")
+	}
+}
+
+// mockUpTorsionBlockSig returns a block signature of msg whose R has a
+// component of order 2, which Verify rejects but the batch equation alone
+// would accept for half of the random coefficients.
+func mockUpTorsionBlockSig(t *testing.T, curve *TwistedEdwardsCurve,
+	msg []byte) *BlockSignature {
+	sk := mockUpSecKeysByBytes(curve, 1)[0]
+	pub := sk.PubKey()
+	sig, _ := divergentSig(t, curve, sk.reducedScalar(curve), pub.GetX(),
+		pub.GetY(), new(big.Int), new(big.Int).Sub(curve.P, one), msg)
+	if Verify(pub, msg, sig.R, sig.S) {
+		t.Fatalf("Verify accepted a torsion R")
+	}
+
+	return &BlockSignature{pub, msg, sig}
+}
+
+// TestBlockSigCacheTorsion tests that a block with a signature Verify
+// rejects is rejected by every fresh cache, so the cached result can't
+// depend on the random coefficients of the batch
+func TestBlockSigCacheTorsion(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	hashes, blocks := mockUpBlockSigs(t, curve, 1)
+	block := append(blocks[0][:1:1], mockUpTorsionBlockSig(t, curve,
+		[]byte("torsion")))
+
+	for i := 0; i < 16; i++ {
+		cache := NewBlockSigCache(curve, 1)
+		if cache.VerifyBlock(&hashes[0], block...) {
+			t.Fatalf("run %d: verified a block with a torsion R", i)
+		}
+		if cache.VerifyBlock(&hashes[0], block...) || cache.verifications != 1 {
+			t.Fatalf("run %d: cached result isn't the one of Verify", i)
+		}
+	}
+}
+
+// TestBlockSigCacheConcurrency tests that blocks validated in parallel, as
+// when competing branches of a reorganization are processed at the same
+// time, each are verified only once and get the right results, including
+// a block with a signature that Verify rejects
+func TestBlockSigCacheConcurrency(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	hashes, blocks := mockUpBlockSigs(t, curve, 4)
+	bad := len(blocks) - 1
+	torsion := len(blocks)
+	hashes = append(hashes, chainhash.HashH([]byte("torsion")))
+	blocks = append(blocks, append(blocks[0][:3:3],
+		mockUpTorsionBlockSig(t, curve, []byte("torsion"))))
+
+	cache := NewBlockSigCache(curve, uint(len(blocks)))
+	var wrong uint32
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 8; i++ {
+				j := (g + i) % len(blocks)
+				want := j != bad && j != torsion
+				if cache.VerifyBlock(&hashes[j], blocks[j]...) != want {
+					atomic.StoreUint32(&wrong, 1)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if wrong != 0 {
+		t.Fatalf("wrong result for a block verified concurrently")
+	}
+	if cache.verifications != uint64(len(blocks)) {
+		t.Fatalf("want %v verifications, got %v", len(blocks),
+			cache.verifications)
+	}
+}
@@ -6,6 +6,10 @@
 package edwards
 
 import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 )
@@ -19,6 +23,10 @@ type Signature struct {
 // SignatureSize is the size of an encoded ECDSA signature.
 const SignatureSize = 64
 
+// ExpandedSignatureSize is the size of a signature with R given by both of
+// its affine coordinates, see SerializeExpanded.
+const ExpandedSignatureSize = 96
+
 // NewSignature instantiates a new signature given some R,S values.
 func NewSignature(r, s *big.Int) *Signature {
 	return &Signature{r, s}
@@ -30,7 +38,18 @@ func NewSignature(r, s *big.Int) *Signature {
 //   sig[0:32]  R, a point encoded as little endian
 //   sig[32:64] S, scalar multiplication/addition results = (ab+c) mod l
 //     encoded also as little endian
+//
+// The result is exactly SignatureSize bytes for every Ed25519 signature,
+// which makes it usable as a fixed size wire format, and ParseSignature reads
+// it back. A nil R or S is encoded as zero. Ed448 signatures, whose R and S
+// don't fit in 32 bytes, are never truncated and are serialized in the
+// Ed448SignatureSize bytes of SerializeEd448 instead, which ParseSignature
+// reads back on the Ed448 curve.
 func (sig Signature) Serialize() []byte {
+	if sig.isEd448() {
+		return sig.SerializeEd448()
+	}
+
 	rBytes := BigIntToEncodedBytes(sig.R)
 	sBytes := BigIntToEncodedBytes(sig.S)
 
@@ -39,6 +58,134 @@ func (sig Signature) Serialize() []byte {
 	return all
 }
 
+// SerializeExpanded returns the Ed25519 signature in ExpandedSignatureSize
+// bytes, with R given by its affine coordinates instead of in compressed
+// form, for wire formats that store points that way. The bytes are the x
+// and then the y coordinate of R followed by S, each of them 32 bytes little
+// endian. R is kept compressed in a Signature, as in the 32 byte native
+// encoding of Ed25519, so it is decompressed here and an error is returned if
+// it isn't a point. ParseExpandedSignature reads the result back.
+func (sig Signature) SerializeExpanded() ([]byte, error) {
+	if sig.R == nil || sig.S == nil {
+		return nil, fmt.Errorf("signature is incomplete")
+	}
+	if sig.R.Sign() < 0 || sig.R.BitLen() > 256 {
+		return nil, fmt.Errorf("r is not a 32 byte encoded point")
+	}
+	x, y, err := Edwards().EncodedBytesToBigIntPoint(
+		BigIntToEncodedBytes(sig.R))
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, ExpandedSignatureSize)
+	b = append(b, BigIntToEncodedBytes(x)[:]...)
+	b = append(b, BigIntToEncodedBytes(y)[:]...)
+	return append(b, BigIntToEncodedBytes(sig.S)[:]...), nil
+}
+
+// ParseExpandedSignature parses an Ed25519 signature serialized with
+// SerializeExpanded. R must be a point on the curve with both coordinates
+// reduced mod P, and S must be in [1, N) as for ParseSignature. R is
+// compressed into the usual representation, so the signature verifies, and
+// serializes with Serialize, exactly like the 64 byte form of the same
+// signature.
+func ParseExpandedSignature(curve *TwistedEdwardsCurve,
+	sigStr []byte) (*Signature, error) {
+	if curve.isEd448() {
+		return nil, fmt.Errorf("expanded signatures are only supported on " +
+			"Ed25519")
+	}
+	if len(sigStr) != ExpandedSignatureSize {
+		return nil, fmt.Errorf("bad signature size; have %v, want %v",
+			len(sigStr), ExpandedSignatureSize)
+	}
+
+	x := EncodedBytesToBigInt(copyBytes(sigStr[0:32]))
+	y := EncodedBytesToBigInt(copyBytes(sigStr[32:64]))
+	if !NewPublicKey(curve, x, y).IsOnCurve() {
+		return nil, fmt.Errorf("r is not a point on the curve")
+	}
+	r := EncodedBytesToBigInt(BigIntPointToEncodedBytes(x, y))
+
+	s := EncodedBytesToBigInt(copyBytes(sigStr[64:96]))
+	if s.Cmp(curve.N) >= 0 || s.Sign() == 0 {
+		return nil, fmt.Errorf("s scalar is empty or larger than the order of " +
+			"the curve")
+	}
+
+	return &Signature{r, s}, nil
+}
+
+// order is the order of the curve as little endian 64-bit words.
+var order = [4]uint64{0x5812631a5cf5d3ed, 0x14def9dea2f79cd6, 0,
+	0x1000000000000000}
+
+// scMinimal returns whether or not the 32 byte little endian scalar s is
+// fully reduced, that is s < N.
+func scMinimal(s []byte) bool {
+	for i := 3; ; i-- {
+		v := binary.LittleEndian.Uint64(s[i*8:])
+		if v > order[i] {
+			return false
+		} else if v < order[i] {
+			break
+		} else if i == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsCanonical returns whether or not the signature is in its canonical
+// form, with S fully reduced modulo the order of the curve. The Ed25519
+// check that S < 2^253 on its own lets both S and S+N verify, so without
+// enforcing this a third party could change the signature bytes (and thus
+// the txid) of a transaction while keeping it valid.
+func IsCanonical(sig *Signature) bool {
+	if sig == nil || sig.R == nil || sig.S == nil {
+		return false
+	}
+	if sig.R.Sign() < 0 || sig.R.BitLen() > 256 ||
+		sig.S.Sign() < 0 || sig.S.BitLen() > 256 {
+		return false
+	}
+
+	return scMinimal(BigIntToEncodedBytes(sig.S)[:])
+}
+
+// Normalize returns the canonical form of the Ed25519 signature, with S
+// reduced mod N and R re-encoded from the point it decodes to, with y
+// reduced mod P and no sign bit for x = 0. Every encoding of the same
+// logical signature normalizes to the same bytes, and Verify only accepts
+// signatures that are already normalized. An error is returned if R isn't a
+// point or S is a multiple of N.
+func (sig Signature) Normalize() (*Signature, error) {
+	if sig.R == nil || sig.S == nil {
+		return nil, fmt.Errorf("signature is incomplete")
+	}
+	if sig.R.Sign() < 0 || sig.R.BitLen() > 256 {
+		return nil, fmt.Errorf("r is not a 32 byte encoded point")
+	}
+
+	curve := Edwards()
+	x, y, err := curve.EncodedBytesToBigIntPoint(BigIntToEncodedBytes(sig.R))
+	if err != nil {
+		return nil, err
+	}
+	x.Mod(x, curve.P)
+	y.Mod(y, curve.P)
+	r := EncodedBytesToBigInt(BigIntPointToEncodedBytes(x, y))
+
+	s := new(big.Int).Mod(sig.S, curve.N)
+	if s.Sign() == 0 {
+		return nil, fmt.Errorf("s scalar is zero mod the order of the curve")
+	}
+
+	return &Signature{r, s}, nil
+}
+
 // parseSig is the default method of parsing a serialized Ed25519 signature.
 func parseSig(curve *TwistedEdwardsCurve, sigStr []byte, der bool) (*Signature,
 	error) {
@@ -72,9 +219,15 @@ func parseSig(curve *TwistedEdwardsCurve, sigStr []byte, der bool) (*Signature,
 }
 
 // ParseSignature parses a signature in BER format for the curve type `curve'
-// into a Signature type, perfoming some basic sanity checks.
+// into a Signature type, perfoming some basic sanity checks. The input must
+// be exactly SignatureSize bytes as produced by Serialize, R must decode to a
+// point and S must be in [1, N), so only canonical encodings parse. Ed448
+// signatures are 114 bytes long, see SerializeEd448.
 func ParseSignature(curve *TwistedEdwardsCurve, sigStr []byte) (*Signature,
 	error) {
+	if curve.isEd448() {
+		return ed448ParseSig(curve, sigStr)
+	}
 	return parseSig(curve, sigStr, false)
 }
 
@@ -93,6 +246,22 @@ func RecoverCompact(signature, hash []byte) (*PublicKey, bool, error) {
 	return nil, false, nil
 }
 
+// ErrKeyRecoveryUnsupported is returned by RecoverPublicKey, since Ed25519
+// signatures do not allow recovering the public key.
+var ErrKeyRecoveryUnsupported = errors.New("public key recovery is not " +
+	"possible for ed25519 signatures")
+
+// RecoverPublicKey would recover the public key that produced the signature
+// sig over msg, analogous to ECDSA key recovery. Ed25519 does not support
+// this: the challenge h = hash512(R || A || M) commits to the public key A
+// itself, so solving sB = R + hA for A would require knowing A to compute h
+// in the first place. It therefore always returns ErrKeyRecoveryUnsupported,
+// and the public key has to be stored or sent along with the signature.
+func RecoverPublicKey(curve *TwistedEdwardsCurve, sig *Signature,
+	msg []byte) (*PublicKey, error) {
+	return nil, ErrKeyRecoveryUnsupported
+}
+
 // GetR satisfies the chainec Signature interface.
 func (sig Signature) GetR() *big.Int {
 	return sig.R
@@ -107,3 +276,41 @@ func (sig Signature) GetS() *big.Int {
 func (sig Signature) GetType() int {
 	return ecTypeEdwards
 }
+
+// MarshalJSON satisfies the json.Marshaler interface, encoding the signature
+// as the hex string of its serialized form, 64 bytes for Ed25519 and 114
+// bytes for Ed448.
+func (sig Signature) MarshalJSON() ([]byte, error) {
+	if sig.R == nil || sig.S == nil {
+		return nil, errors.New("cannot marshal incomplete signature")
+	}
+
+	return json.Marshal(hex.EncodeToString(sig.Serialize()))
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface, decoding a
+// signature from the hex string of its serialized form, which is parsed as
+// an Ed448 signature if it's 114 bytes long. The signature is validated the
+// same way as by ParseSignature.
+func (sig *Signature) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("signature is not a JSON string: %v", err)
+	}
+	b, err := hex.DecodeString(str)
+	if err != nil {
+		return fmt.Errorf("signature is not valid hex: %v", err)
+	}
+
+	curve := Edwards()
+	if len(b) == Ed448SignatureSize {
+		curve = curveForPointSize(Ed448PubKeyBytesLen)
+	}
+	parsed, err := ParseSignature(curve, b)
+	if err != nil {
+		return err
+	}
+	*sig = *parsed
+
+	return nil
+}
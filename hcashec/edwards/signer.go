@@ -0,0 +1,48 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"fmt"
+)
+
+// Signer is implemented by anything that can produce signatures for a public
+// key, such as an in-memory PrivateKey or a key held by a hardware security
+// module or hardware wallet. Code which signs through a Signer never needs
+// access to the private scalar, so the storage of the key and the policy of
+// when to sign are left to the implementation.
+type Signer interface {
+	// PublicKey returns the public key the signatures verify under.
+	PublicKey() *PublicKey
+
+	// Sign signs the message msg, returning a signature which verifies
+	// with Verify under PublicKey.
+	Sign(msg []byte) (*Signature, error)
+}
+
+// PublicKey returns the public key of the private key, satisfying the Signer
+// interface. It's the same key as returned by PubKey.
+func (p *PrivateKey) PublicKey() *PublicKey {
+	return p.PubKey()
+}
+
+// Sign signs the message msg with the private key, satisfying the Signer
+// interface. It signs with the curve of the key as Sign does.
+func (p *PrivateKey) Sign(msg []byte) (*Signature, error) {
+	if err := p.signingErr(); err != nil {
+		return nil, err
+	}
+	curve, ok := p.ecPk.Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil {
+		return nil, fmt.Errorf("private key has no curve")
+	}
+
+	r, s, err := Sign(curve, p, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSignature(r, s), nil
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"errors"
+	"testing"
+)
+
+// mockSigner is a Signer standing in for a hardware device. It keeps its key
+// to itself, counts the signatures it makes and can be told to refuse.
+type mockSigner struct {
+	key    *PrivateKey
+	refuse bool
+	signed int
+}
+
+func (m *mockSigner) PublicKey() *PublicKey {
+	return m.key.PubKey()
+}
+
+func (m *mockSigner) Sign(msg []byte) (*Signature, error) {
+	if m.refuse {
+		return nil, errors.New("signing refused by the device")
+	}
+	m.signed++
+	return m.key.Sign(msg)
+}
+
+// TestSigner tests signing through the Signer interface with both a private
+// key and a mock device, and that the signatures verify
+func TestSigner(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg := []byte("message in TestSigner")
+
+	for i, sk := range mockUpSecKeysByBytes(curve, 5) {
+		device := &mockSigner{key: sk}
+		for _, signer := range []Signer{sk, device} {
+			r, s, err := SignFromSecretNoReader(signer, msg)
+			if err != nil {
+				t.Fatalf("test %d: unexpected signing error: %v", i, err)
+			}
+			if !Verify(signer.PublicKey(), msg, r, s) {
+				t.Fatalf("test %d: signature failed to verify", i)
+			}
+
+			// Deterministic, so both signers agree with Sign.
+			wantR, wantS, _ := Sign(curve, sk, msg)
+			if r.Cmp(wantR) != 0 || s.Cmp(wantS) != 0 {
+				t.Fatalf("test %d: signature differs from Sign", i)
+			}
+		}
+		if device.signed != 1 {
+			t.Fatalf("test %d: device signed %v times, want 1", i,
+				device.signed)
+		}
+
+		device.refuse = true
+		if _, _, err := SignFromSecret(nil, device, msg); err == nil {
+			t.Fatalf("test %d: expected the device's refusal", i)
+		}
+	}
+
+	// Private keys which can't sign report it through the interface.
+	wiped := mockUpSecKeysByBytes(curve, 1)[0]
+	wiped.Wipe()
+	if _, err := wiped.Sign(msg); err != ErrWipedKey {
+		t.Fatalf("want %v signing with a wiped key, got %v", ErrWipedKey, err)
+	}
+	if _, _, err := SignFromSecretNoReader(new(PrivateKey),
+		msg); err != ErrNoPrivateKey {
+		t.Fatalf("want %v signing with an empty key, got %v",
+			ErrNoPrivateKey, err)
+	}
+	if _, _, err := SignFromSecretNoReader(nil, msg); err == nil {
+		t.Fatalf("expected error signing with a nil signer")
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// HardenedKeyStart is the index at which hardened child keys start.
+	HardenedKeyStart = 0x80000000 // 2^31
+
+	// ChainCodeSize is the size of a chain code in bytes.
+	ChainCodeSize = 32
+)
+
+// ErrNonHardenedIndex is returned by DeriveChild for child indices below
+// HardenedKeyStart. Public (non-hardened) derivation does not work with
+// Ed25519, since the secret scalar is the clamped hash of the key rather
+// than something keys can be added to.
+var ErrNonHardenedIndex = errors.New("only hardened derivation is " +
+	"supported for ed25519")
+
+// slip10MasterKey is the HMAC key used to derive the master key from a seed
+// as specified by SLIP-0010.
+var slip10MasterKey = []byte("ed25519 seed")
+
+// splitHMAC computes HMAC-SHA512(key, data) and returns the key secret (the
+// left half) and the chain code (the right half) derived from it.
+func splitHMAC(curve *TwistedEdwardsCurve, key, data []byte) (*PrivateKey,
+	[]byte, error) {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	i := mac.Sum(nil)
+	defer zeroSlice(i[:PrivKeyBytesLen/2])
+
+	priv, _ := PrivKeyFromSecret(curve, i[:PrivKeyBytesLen/2])
+	if priv == nil {
+		return nil, nil, fmt.Errorf("failed to derive key")
+	}
+	chainCode := make([]byte, ChainCodeSize)
+	copy(chainCode, i[PrivKeyBytesLen/2:])
+
+	return priv, chainCode, nil
+}
+
+// NewMasterKey derives the SLIP-0010 master private key and chain code from
+// seed, which is typically derived from a mnemonic. The seed must be between
+// 16 and 64 bytes long.
+func NewMasterKey(curve *TwistedEdwardsCurve, seed []byte) (*PrivateKey,
+	[]byte, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, nil, fmt.Errorf("bad seed size (got %v, want 16 to 64)",
+			len(seed))
+	}
+
+	return splitHMAC(curve, slip10MasterKey, seed)
+}
+
+// DeriveChild derives the hardened child with the given index of the parent
+// private key and its chain code as specified by SLIP-0010, returning the
+// child private key and chain code. The parent key must hold its secret, as
+// the keys returned by NewMasterKey and DeriveChild do, and the index must
+// be at least HardenedKeyStart, otherwise ErrNonHardenedIndex is returned.
+func DeriveChild(parentPriv *PrivateKey, chainCode []byte,
+	index uint32) (*PrivateKey, []byte, error) {
+	if parentPriv == nil {
+		return nil, nil, fmt.Errorf("nil parent key")
+	}
+	if err := parentPriv.signingErr(); err != nil {
+		return nil, nil, err
+	}
+	if parentPriv.secret == nil {
+		return nil, nil, fmt.Errorf("parent key has no secret")
+	}
+	if len(chainCode) != ChainCodeSize {
+		return nil, nil, fmt.Errorf("bad chain code size (got %v, want %v)",
+			len(chainCode), ChainCodeSize)
+	}
+	if index < HardenedKeyStart {
+		return nil, nil, ErrNonHardenedIndex
+	}
+	curve, ok := parentPriv.ecPk.Curve.(*TwistedEdwardsCurve)
+	if !ok {
+		return nil, nil, fmt.Errorf("parent key is not an edwards key")
+	}
+
+	// data = 0x00 || secret || ser32(index)
+	data := make([]byte, 1+PrivKeyBytesLen/2+4)
+	defer zeroSlice(data)
+	copy(data[1:], parentPriv.secret[:])
+	binary.BigEndian.PutUint32(data[1+PrivKeyBytesLen/2:], index)
+
+	return splitHMAC(curve, chainCode, data)
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// stealthTag is the domain separation tag of the one-time key tweaks of
+// stealth addresses.
+const stealthTag = "edwards25519 stealth address"
+
+// checkStealthRecipient returns an error if the recipient key of a stealth
+// address isn't an Ed25519 key of the prime order subgroup, whose one-time
+// keys couldn't be recovered.
+func checkStealthRecipient(pub *PublicKey) error {
+	if pub == nil || pub.GetX() == nil || pub.GetY() == nil {
+		return fmt.Errorf("recipient public key is nil")
+	}
+	if curve, ok := pub.Curve.(*TwistedEdwardsCurve); !ok || curve == nil ||
+		curve.isEd448() {
+		return fmt.Errorf("stealth addresses are only supported on Ed25519")
+	}
+	if !pub.IsInSubgroup() {
+		return fmt.Errorf("recipient public key is not in the prime order " +
+			"subgroup")
+	}
+
+	return nil
+}
+
+// stealthTweak computes h = taggedHash(tag, S || R || B) mod N, the scalar
+// added to the recipient key B for the one-time key, from the ECDH secret S
+// of the ephemeral key R and B.
+func stealthTweak(curve *TwistedEdwardsCurve, secret []byte, ephemeralPub,
+	recipientPub *PublicKey) *big.Int {
+	return EncodedBytesToBigInt(taggedScalar(stealthTag, secret,
+		ephemeralPub.Serialize(), recipientPub.Serialize()))
+}
+
+// GenerateStealthAddress derives a fresh one-time public key P = B + hG for
+// the recipient with the public key B, with a new ephemeral key r whose
+// public key R = rG is returned along with it. The tweak h is a hash of the
+// ECDH secret of r and B, so only the recipient, given R, can tell that P
+// is theirs and recover its private key with RecoverStealthKey, while P and
+// R can't be linked to B or to other one-time keys of the same recipient.
+// The ephemeral private key is discarded.
+func GenerateStealthAddress(recipientPub *PublicKey) (oneTimePub,
+	ephemeralPub *PublicKey, err error) {
+	if err := checkStealthRecipient(recipientPub); err != nil {
+		return nil, nil, err
+	}
+	curve := recipientPub.Curve.(*TwistedEdwardsCurve)
+
+	ephemeral, err := GeneratePrivateKey(curve)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer ephemeral.Wipe()
+	secret, err := ECDH(ephemeral, recipientPub)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroSlice(secret)
+	ephemeralPub = ephemeral.PubKey()
+
+	h := stealthTweak(curve, secret, ephemeralPub, recipientPub)
+	hX, hY := curve.ScalarBaseMult(h.Bytes())
+	x, y := curve.Add(recipientPub.GetX(), recipientPub.GetY(), hX, hY)
+	if isIdentity(x, y) {
+		return nil, nil, ErrIdentityKey
+	}
+
+	return NewPublicKey(curve, x, y), ephemeralPub, nil
+}
+
+// RecoverStealthKey recovers the private key b + h mod N of the one-time
+// key that GenerateStealthAddress derived for the public key of
+// recipientPriv with the ephemeral public key ephemeralPub. The recovered
+// key is a scalar key without a secret seed, whose public key is the
+// one-time public key.
+func RecoverStealthKey(recipientPriv *PrivateKey,
+	ephemeralPub *PublicKey) (*PrivateKey, error) {
+	if recipientPriv == nil {
+		return nil, fmt.Errorf("recipient private key is nil")
+	}
+	if err := recipientPriv.signingErr(); err != nil {
+		return nil, err
+	}
+	recipientPub := recipientPriv.PubKey()
+	if err := checkStealthRecipient(recipientPub); err != nil {
+		return nil, err
+	}
+	curve := recipientPub.Curve.(*TwistedEdwardsCurve)
+
+	secret, err := ECDH(recipientPriv, ephemeralPub)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroSlice(secret)
+
+	h := stealthTweak(curve, secret, ephemeralPub, recipientPub)
+	b := recipientPriv.reducedScalar(curve)
+	defer b.SetInt64(0)
+	k := new(big.Int).Add(b, h)
+	k.Mod(k, curve.N)
+	defer k.SetInt64(0)
+	if k.Sign() == 0 {
+		return nil, ErrIdentityKey
+	}
+
+	priv, _, err := scalarToPrivKey(curve, k)
+	return priv, err
+}
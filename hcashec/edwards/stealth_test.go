@@ -0,0 +1,85 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// TestStealthAddress tests that the one-time key recovered by the recipient
+// matches the one derived by the sender and signs for it, that every
+// address is fresh, and that other keys recover other one-time keys
+func TestStealthAddress(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	var recipients []*PrivateKey
+	recipients = append(recipients, mockUpSecKeysByBytes(curve, 2)...)
+	recipients = append(recipients, mockUpSecKeysByScalars(curve, 2)...)
+	for i, recipient := range recipients {
+		oneTimePub, ephemeralPub, err := GenerateStealthAddress(
+			recipient.PubKey())
+		if err != nil {
+			t.Fatalf("recipient %d: unexpected error: %v", i, err)
+		}
+		if oneTimePub.Equal(recipient.PubKey()) {
+			t.Fatalf("recipient %d: one-time key is the recipient key", i)
+		}
+
+		oneTimePriv, err := RecoverStealthKey(recipient, ephemeralPub)
+		if err != nil {
+			t.Fatalf("recipient %d: unexpected error: %v", i, err)
+		}
+		if !oneTimePriv.PubKey().Equal(oneTimePub) {
+			t.Fatalf("recipient %d: recovered key doesn't match the "+
+				"one-time key", i)
+		}
+		msg := []byte("spend from a one-time key")
+		r, s, err := Sign(curve, oneTimePriv, msg)
+		if err != nil {
+			t.Fatalf("recipient %d: unexpected error: %v", i, err)
+		}
+		if !Verify(oneTimePub, msg, r, s) {
+			t.Fatalf("recipient %d: one-time signature failed to verify", i)
+		}
+
+		// Every address is fresh.
+		again, _, err := GenerateStealthAddress(recipient.PubKey())
+		if err != nil {
+			t.Fatalf("recipient %d: unexpected error: %v", i, err)
+		}
+		if again.Equal(oneTimePub) {
+			t.Fatalf("recipient %d: one-time key reused", i)
+		}
+
+		other := recipients[(i+1)%len(recipients)]
+		otherPriv, err := RecoverStealthKey(other, ephemeralPub)
+		if err != nil {
+			t.Fatalf("recipient %d: unexpected error: %v", i, err)
+		}
+		if otherPriv.PubKey().Equal(oneTimePub) {
+			t.Fatalf("recipient %d: another key recovered the one-time "+
+				"key", i)
+		}
+	}
+
+	// Recipient keys of small order are rejected.
+	b, _ := hex.DecodeString(smallOrderPoints[len(smallOrderPoints)-1])
+	x, y, err := curve.EncodedBytesToBigIntPoint(copyBytes(b))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := GenerateStealthAddress(NewPublicKey(curve, x,
+		y)); err == nil {
+		t.Fatalf("derived an address for a small order key")
+	}
+	if _, _, err := GenerateStealthAddress(nil); err == nil {
+		t.Fatalf("derived an address for a nil key")
+	}
+	if _, err := RecoverStealthKey(recipients[0], nil); err == nil {
+		t.Fatalf("recovered a key for a nil ephemeral key")
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/sha512"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// taggedHash computes the 64 byte tagged hash
+// hash512(hash512(tag) || hash512(tag) || data...) following the BIP-340
+// pattern. The 128 byte prefix fills a whole SHA512 block, so different tags
+// can never be confused with each other, nor with the plain SHA512 hashes
+// of Ed25519, whatever the data. Every hash of a protocol defined by this
+// package, such as its challenges, coefficients and nonce derivations, goes
+// through it with a tag of its own. The exceptions are the challenges
+// hash512(R || A || M) of signatures that must verify as plain Ed25519
+// signatures, the hashes fixed by a specification, like those of RFC 8032,
+// FROST and MuSig2, and the blocks of the deterministic reader, which are
+// keyed with a tagged hash.
+func taggedHash(tag string, data ...[]byte) []byte {
+	tagDigest := sha512.Sum512([]byte(tag))
+	h := sha512.New()
+	h.Write(tagDigest[:])
+	h.Write(tagDigest[:])
+	for _, d := range data {
+		h.Write(d)
+	}
+
+	return h.Sum(nil)
+}
+
+// taggedScalar computes the tagged hash of data reduced mod N, as a 32 byte
+// little endian scalar.
+func taggedScalar(tag string, data ...[]byte) *[32]byte {
+	var digest [64]byte
+	copy(digest[:], taggedHash(tag, data...))
+	defer zeroSlice(digest[:])
+
+	reduced := new([32]byte)
+	edwards25519.ScReduce(reduced, &digest)
+	return reduced
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"testing"
+)
+
+// TestTaggedHash tests that tagged hashes follow the BIP-340 construction,
+// that they differ across tags for the same data, also when the tag and data
+// split the same bytes differently, and that they don't depend on how the
+// data is split
+func TestTaggedHash(t *testing.T) {
+	data := []byte("the same data")
+	tag := sha512.Sum512([]byte("tag"))
+	want := sha512.Sum512(append(append(tag[:], tag[:]...), data...))
+	if got := taggedHash("tag", data); !bytes.Equal(got, want[:]) {
+		t.Fatalf("tagged hash %x, want %x", got, want)
+	}
+	if got := taggedHash("tag", data[:4], nil, data[4:]); !bytes.Equal(got,
+		want[:]) {
+		t.Fatalf("tagged hash depends on how the data is split")
+	}
+	plain := sha512.Sum512(data)
+	if bytes.Equal(taggedHash("", data), plain[:]) {
+		t.Fatalf("tagged hash with an empty tag is the plain hash")
+	}
+
+	tags := []string{"", "tag", "tag2", "Tag", dlogProofTag, stealthTag,
+		ringChallengeTag, rangeProofTag, hashToPointTag, deriveNonceTag,
+		hedgedNonceTag, halfAggListTag, halfAggCoefficientTag,
+		deterministicRandTag, aggregateListTag, aggregateChallengeTag,
+		musigKeyListTag, musigCoefficientTag}
+	seen := make(map[string]string)
+	for _, tag := range tags {
+		h := string(taggedHash(tag, data))
+		if other, ok := seen[h]; ok {
+			t.Fatalf("tags %q and %q give the same hash", tag, other)
+		}
+		seen[h] = tag
+	}
+
+	// Moving bytes between the tag and the data changes the hash.
+	if bytes.Equal(taggedHash("ab", []byte("c")), taggedHash("a",
+		[]byte("bc"))) {
+		t.Fatalf("tag and data boundary is ambiguous")
+	}
+
+	// The scalar is the hash reduced mod N.
+	var digest [64]byte
+	copy(digest[:], taggedHash("tag", data))
+	if *taggedScalar("tag", data) != ScalarReduce(digest[:]) {
+		t.Fatalf("tagged scalar is not the reduced tagged hash")
+	}
+}
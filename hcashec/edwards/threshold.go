@@ -5,8 +5,16 @@
 package edwards
 
 import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
 )
 
 // Sha512VersionStringRFC6979 is the RFC6979 nonce version for a Schnorr signature
@@ -14,7 +22,8 @@ import (
 var Sha512VersionStringRFC6979 = []byte("Edwards+SHA512  ")
 
 // CombinePubkeys combines a slice of public keys into a single public key
-// by adding them together with point addition.
+// by adding them together with point addition. Nil is returned if the keys
+// sum to the identity, e.g. because they cancel out.
 func CombinePubkeys(curve *TwistedEdwardsCurve,
 	pks []*PublicKey) *PublicKey {
 	numPubKeys := len(pks)
@@ -24,6 +33,10 @@ func CombinePubkeys(curve *TwistedEdwardsCurve,
 		return nil
 	}
 	if numPubKeys == 1 {
+		if pks[0] == nil || pks[0].GetX() == nil || pks[0].GetY() == nil ||
+			isIdentity(pks[0].GetX(), pks[0].GetY()) {
+			return nil
+		}
 		return pks[0]
 	}
 	if pks == nil {
@@ -46,13 +59,115 @@ func CombinePubkeys(curve *TwistedEdwardsCurve,
 		}
 	}
 
-	if !curve.IsOnCurve(pkSumX, pkSumY) {
+	if !curve.IsOnCurve(pkSumX, pkSumY) || isIdentity(pkSumX, pkSumY) {
 		return nil
 	}
 
 	return NewPublicKey(curve, pkSumX, pkSumY)
 }
 
+// PubKeyAccumulator sums public keys streamed in one at a time, so that the
+// combined key of a large group of signers can be computed without holding
+// all of their keys, like CombinePubkeys does for a slice.
+type PubKeyAccumulator struct {
+	curve      *TwistedEdwardsCurve
+	sumX, sumY *big.Int
+	count      int
+}
+
+// NewPubKeyAccumulator creates an empty public key accumulator for curve.
+func NewPubKeyAccumulator(curve *TwistedEdwardsCurve) *PubKeyAccumulator {
+	return &PubKeyAccumulator{
+		curve: curve,
+		sumX:  new(big.Int),
+		sumY:  new(big.Int).Set(one),
+	}
+}
+
+// Add adds the public key pub to the sum. Nil and off curve keys are
+// rejected and leave the sum unchanged.
+func (a *PubKeyAccumulator) Add(pub *PublicKey) error {
+	if pub == nil || pub.GetX() == nil || pub.GetY() == nil {
+		return fmt.Errorf("public key is nil")
+	}
+	if !a.curve.IsOnCurve(pub.GetX(), pub.GetY()) {
+		return fmt.Errorf("public key is off curve")
+	}
+
+	a.sumX, a.sumY = a.curve.Add(a.sumX, a.sumY, pub.GetX(), pub.GetY())
+	a.count++
+	return nil
+}
+
+// Count returns the number of public keys added so far.
+func (a *PubKeyAccumulator) Count() int {
+	return a.count
+}
+
+// Result returns the sum of the public keys added so far, which is the
+// identity if none were.
+func (a *PubKeyAccumulator) Result() *PublicKey {
+	return NewPublicKey(a.curve, new(big.Int).Set(a.sumX),
+		new(big.Int).Set(a.sumY))
+}
+
+// AggregatePublicNonces sums the public nonces of all the signers of a
+// threshold signature into the aggregate nonce that every partial signature
+// commits to. Unlike CombinePubkeys, the nonces are validated: they must all
+// be non-nil points on the curve and their sum must not be the identity,
+// which would let the last signer cancel the nonces of the others.
+func AggregatePublicNonces(curve *TwistedEdwardsCurve,
+	nonces []*PublicKey) (*PublicKey, error) {
+	if len(nonces) == 0 {
+		return nil, fmt.Errorf("no public nonces to aggregate")
+	}
+
+	var sumX, sumY *big.Int
+	for i, n := range nonces {
+		if n == nil || n.GetX() == nil || n.GetY() == nil {
+			return nil, fmt.Errorf("public nonce %v is nil", i)
+		}
+		if !curve.IsOnCurve(n.GetX(), n.GetY()) {
+			return nil, fmt.Errorf("public nonce %v is off curve", i)
+		}
+		if i == 0 {
+			sumX, sumY = n.GetX(), n.GetY()
+			continue
+		}
+		sumX, sumY = curve.Add(sumX, sumY, n.GetX(), n.GetY())
+	}
+	if isIdentity(sumX, sumY) {
+		return nil, fmt.Errorf("aggregate public nonce is the identity")
+	}
+
+	return NewPublicKey(curve, sumX, sumY), nil
+}
+
+// VerifyNonceSet reports whether the revealed public nonces partials sum to
+// claimedAggregate, the aggregate nonce a coordinator handed out for the
+// partial signatures, so that a nonce which was swapped or corrupted after
+// the aggregate was computed is caught before anyone signs with it. The sum
+// is recomputed with AggregatePublicNonces on the curve of
+// claimedAggregate, and the encodings are compared in constant time.
+func VerifyNonceSet(partials []*PublicKey, claimedAggregate *PublicKey) bool {
+	if claimedAggregate == nil || claimedAggregate.GetX() == nil ||
+		claimedAggregate.GetY() == nil {
+		return false
+	}
+	curve, ok := claimedAggregate.Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil {
+		return false
+	}
+
+	sum, err := AggregatePublicNonces(curve, partials)
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(sum.Serialize(),
+		claimedAggregate.Serialize()) == 1
+}
+
 // generateNoncePair deterministically generate a nonce pair for use in
 // partial signing of a message. Returns a public key (nonce to dissemanate)
 // and a private nonce to keep as a secret for the signer.
@@ -82,6 +197,10 @@ func generateNoncePair(curve *TwistedEdwardsCurve, msg []byte, priv []byte,
 func GenerateNoncePair(curve *TwistedEdwardsCurve, msg []byte,
 	privkey *PrivateKey, extra []byte,
 	version []byte) (*PrivateKey, *PublicKey, error) {
+	if err := privkey.signingErr(); err != nil {
+		return nil, nil, err
+	}
+
 	priv, pubNonce, err := generateNoncePair(curve, msg, privkey.Serialize(),
 		nonceRFC6979, extra, version)
 	if err != nil {
@@ -93,6 +212,147 @@ func GenerateNoncePair(curve *TwistedEdwardsCurve, msg []byte,
 	return privNonce, pubNonce, nil
 }
 
+// deriveNonceTag domain separates the nonces of DeriveNonce from every other
+// hash of the private scalar.
+const deriveNonceTag = "Edwards+SHA512 threshold nonce"
+
+// DeriveNonce deterministically derives the secret nonce of a signer, and
+// the matching public nonce, for the threshold signing session sessionID
+// over msg, as k = taggedHash(tag, a || len(sessionID) || sessionID || M)
+// mod N with a the private scalar. The same inputs always give the same
+// nonce, so the caller doesn't have to generate and keep one, while
+// different sessions, messages or keys give unrelated nonces.
+//
+// Signing two different challenges with one nonce leaks the private key, so
+// sessionID must be unique to the signing session and commit to everything
+// that affects the challenge besides the message that is not fixed by the
+// caller: at least the set of co-signers (e.g. their aggregate public key)
+// and, since co-signers can change their own nonces, a fresh value from the
+// coordinator that is never reused. Use a NonceTracker to guard against a
+// session being replayed. Nil is returned if the private key can't sign or
+// isn't on the Ed25519 curve.
+func DeriveNonce(priv *PrivateKey, msg []byte, sessionID []byte) (*PrivateKey,
+	*PublicKey) {
+	if priv.signingErr() != nil {
+		return nil, nil
+	}
+	curve, ok := priv.ecPk.Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil || curve.isEd448() {
+		return nil, nil
+	}
+
+	privBytes := priv.Serialize()
+	defer zeroSlice(privBytes)
+	var sessionLen [4]byte
+	binary.BigEndian.PutUint32(sessionLen[:], uint32(len(sessionID)))
+
+	kLE := taggedScalar(deriveNonceTag, privBytes, sessionLen[:], sessionID,
+		msg)
+	defer zeroSlice(kLE[:])
+	k := EncodedBytesToBigInt(kLE)
+	defer k.SetInt64(0)
+
+	privNonce, pubNonce, err := scalarToPrivKey(curve, k)
+	if err != nil {
+		return nil, nil
+	}
+
+	return privNonce, pubNonce
+}
+
+// hedgedNonceTag domain separates the nonces of GenerateHedgedNonce from
+// every other hash of the private scalar.
+const hedgedNonceTag = "Edwards+SHA512 hedged nonce"
+
+// hedgedNonceEntropySize is the number of random bytes GenerateHedgedNonce
+// reads.
+const hedgedNonceEntropySize = 32
+
+// GenerateHedgedNonce generates a secret nonce for signing msg with priv in
+// a threshold signing session, for signers which don't want their nonces to
+// be deterministic. It reads 32 bytes from rand, which should be
+// crypto/rand.Reader, and computes k = taggedHash(tag, a || rand || counter
+// || M) mod N with a the private scalar, starting with a zero 4 byte counter
+// which is only incremented in the unlikely case that k is zero. Since the
+// private key and the message go into the hash as well, a weak or
+// repeating system random number generator doesn't immediately give the
+// same nonce for different messages, which would leak the key, nor a nonce
+// an observer can predict without the key. With a deterministic rand the
+// nonce is reproducible, which is only meant for tests.
+func GenerateHedgedNonce(priv *PrivateKey, msg []byte,
+	rand io.Reader) (*PrivateKey, error) {
+	if err := priv.signingErr(); err != nil {
+		return nil, err
+	}
+	curve, ok := priv.ecPk.Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil || curve.isEd448() {
+		return nil, fmt.Errorf("hedged nonces are only supported on Ed25519")
+	}
+
+	var entropy [hedgedNonceEntropySize]byte
+	if _, err := io.ReadFull(rand, entropy[:]); err != nil {
+		return nil, err
+	}
+	defer zeroSlice(entropy[:])
+	privBytes := priv.Serialize()
+	defer zeroSlice(privBytes)
+
+	for counter := uint32(0); ; counter++ {
+		var counterBytes [4]byte
+		binary.BigEndian.PutUint32(counterBytes[:], counter)
+
+		kLE := taggedScalar(hedgedNonceTag, privBytes, entropy[:],
+			counterBytes[:], msg)
+		k := EncodedBytesToBigInt(kLE)
+		zeroSlice(kLE[:])
+		privNonce, _, err := scalarToPrivKey(curve, k)
+		k.SetInt64(0)
+		if err == nil {
+			return privNonce, nil
+		}
+	}
+}
+
+// NonceCommitmentSize is the size of a commitment to a public nonce.
+const NonceCommitmentSize = sha256.Size
+
+// nonceCommitment computes the commitment to an encoded public nonce.
+func nonceCommitment(pubNonce []byte) []byte {
+	h := sha256.Sum256(pubNonce)
+	return h[:]
+}
+
+// NonceCommitment returns a hash commitment to the public nonce of the secret
+// nonce secNonce. In an interactive threshold signing session each signer
+// first publishes only the commitment. Once the coordinator has collected the
+// commitments of all signers, the public nonces are revealed and checked with
+// VerifyNonceCommitment before they are summed. Since nobody can pick their
+// nonce after seeing the others', this prevents Wagner-style attacks on the
+// combined nonce.
+func NonceCommitment(secNonce *PrivateKey) []byte {
+	if secNonce == nil || secNonce.isWiped() {
+		return nil
+	}
+	pubX, pubY := secNonce.Public()
+	if pubX == nil || pubY == nil {
+		return nil
+	}
+
+	return nonceCommitment(BigIntPointToEncodedBytes(pubX, pubY)[:])
+}
+
+// VerifyNonceCommitment checks that the revealed public nonce pubNonce
+// matches a commitment previously created with NonceCommitment.
+func VerifyNonceCommitment(commitment []byte, pubNonce *PublicKey) bool {
+	if len(commitment) != NonceCommitmentSize || pubNonce == nil ||
+		pubNonce.GetX() == nil || pubNonce.GetY() == nil {
+		return false
+	}
+
+	expected := nonceCommitment(pubNonce.Serialize())
+	return subtle.ConstantTimeCompare(commitment, expected) == 1
+}
+
 // schnorrPartialSign creates a partial Schnorr signature which may be combined
 // with other Schnorr signatures to create a valid signature for a group pubkey.
 func schnorrPartialSign(curve *TwistedEdwardsCurve, msg []byte, priv []byte,
@@ -137,16 +397,9 @@ func schnorrPartialSign(curve *TwistedEdwardsCurve, msg []byte, priv []byte,
 	}
 	privBig.SetInt64(0)
 
-	privNonceBig := new(big.Int).SetBytes(privNonce)
-	if privNonceBig.Cmp(zero) == 0 {
-		str := fmt.Sprintf("privNonce scalar is zero")
-		return nil, nil, fmt.Errorf("%v", str)
-	}
-	if privNonceBig.Cmp(curve.N) >= 0 {
-		str := fmt.Sprintf("privNonce scalar is out of bounds")
-		return nil, nil, fmt.Errorf("%v", str)
+	if err := checkNonce(curve, privNonce); err != nil {
+		return nil, nil, err
 	}
-	privNonceBig.SetInt64(0)
 
 	gpkX, gpkY, err := curve.EncodedBytesToBigIntPoint(copyBytes(groupPublicKey))
 	if err != nil {
@@ -168,10 +421,22 @@ func schnorrPartialSign(curve *TwistedEdwardsCurve, msg []byte, priv []byte,
 		return nil, nil, fmt.Errorf("%v", str)
 	}
 
-	privDecoded, _, _ := PrivKeyFromScalar(curve, priv)
-	groupPubKeyDecoded, _ := ParsePubKey(curve, groupPublicKey)
-	privNonceDecoded, _, _ := PrivKeyFromScalar(curve, privNonce)
-	pubNonceSumDecoded, _ := ParsePubKey(curve, pubNonceSum)
+	privDecoded, _, err := PrivKeyFromScalar(curve, priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	groupPubKeyDecoded, err := ParsePubKey(curve, groupPublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	privNonceDecoded, _, err := PrivKeyFromScalar(curve, privNonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	pubNonceSumDecoded, err := ParsePubKey(curve, pubNonceSum)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	return SignThreshold(curve, privDecoded, groupPubKeyDecoded, msg,
 		privNonceDecoded, pubNonceSumDecoded)
@@ -182,6 +447,16 @@ func schnorrPartialSign(curve *TwistedEdwardsCurve, msg []byte, priv []byte,
 func SchnorrPartialSign(curve *TwistedEdwardsCurve, msg []byte,
 	priv *PrivateKey, groupPub *PublicKey, privNonce *PrivateKey,
 	pubSum *PublicKey) (*big.Int, *big.Int, error) {
+	if groupPub == nil || pubSum == nil {
+		return nil, nil, fmt.Errorf("nil input")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, nil, err
+	}
+	if err := privNonce.signingErr(); err != nil {
+		return nil, nil, err
+	}
+
 	privBytes := priv.Serialize()
 	defer zeroSlice(privBytes)
 	privNonceBytes := privNonce.Serialize()
@@ -220,13 +495,36 @@ func schnorrCombineSigs(curve *TwistedEdwardsCurve, sigss [][]byte) (*big.Int,
 	return combinedSigS, nil
 }
 
+// MaxSigners is the default limit on the number of signers of the functions
+// combining or aggregating signatures, such as SchnorrCombineSigs and
+// VerifyHalfAggregate. It bounds the work and allocations an untrusted
+// caller can cause. Applications which need larger groups pass their own
+// limit to the WithMaxSigners variants of these functions.
+const MaxSigners = 1024
+
+// ErrTooManySigners is returned when combining more signatures than the
+// limit on the number of signers allows.
+var ErrTooManySigners = errors.New("too many signers")
+
 // SchnorrCombineSigs is the generalized and exported version of
-// generateNoncePair.
+// generateNoncePair. At most MaxSigners signatures can be combined, and
+// none of them may be nil.
 func SchnorrCombineSigs(curve *TwistedEdwardsCurve,
 	sigs []*Signature) (*Signature, error) {
+	return SchnorrCombineSigsWithMaxSigners(curve, sigs, MaxSigners)
+}
+
+// SchnorrCombineSigsWithMaxSigners is SchnorrCombineSigs with a limit of
+// maxSigners signatures instead of MaxSigners.
+func SchnorrCombineSigsWithMaxSigners(curve *TwistedEdwardsCurve,
+	sigs []*Signature, maxSigners int) (*Signature, error) {
+	if len(sigs) > maxSigners {
+		return nil, ErrTooManySigners
+	}
+
 	sigss := make([][]byte, len(sigs), len(sigs))
 	for i, sig := range sigs {
-		if sig == nil {
+		if sig == nil || sig.GetR() == nil || sig.GetS() == nil {
 			return nil, fmt.Errorf("nil signature")
 		}
 
@@ -248,3 +546,312 @@ func SchnorrCombineSigs(curve *TwistedEdwardsCurve,
 
 	return NewSignature(sigs[0].R, combinedSigS), nil
 }
+
+// VerifyPartialSignature checks the partial signature of the signer with the
+// public key signerPub and public nonce signerNonce, as created by
+// SchnorrPartialSign over msg for the group key aggPub and the sum of all
+// the public nonces aggNonce. The signer's own nonce is needed since the
+// partial signature only carries the sum. A valid partial has R = aggNonce
+// and S*G = signerNonce + hash512(aggNonce || aggPub || M)*signerPub, so a
+// coordinator can check every contribution before SchnorrCombineSigs and
+// tell which signer spoiled a combined signature.
+func VerifyPartialSignature(curve *TwistedEdwardsCurve, partial *Signature,
+	signerPub, signerNonce, aggNonce, aggPub *PublicKey, msg []byte) bool {
+	if partial == nil || partial.GetR() == nil || partial.GetS() == nil ||
+		msg == nil {
+		return false
+	}
+	for _, pub := range []*PublicKey{signerPub, signerNonce, aggNonce,
+		aggPub} {
+		if pub == nil || pub.GetX() == nil || pub.GetY() == nil {
+			return false
+		}
+	}
+	if !IsCanonical(partial) {
+		return false
+	}
+
+	encodedAggNonce := BigIntPointToEncodedBytes(aggNonce.GetX(),
+		aggNonce.GetY())
+	if *BigIntToEncodedBytes(partial.GetR()) != *encodedAggNonce {
+		return false
+	}
+
+	var A edwards25519.ExtendedGroupElement
+	if !A.FromBytes(BigIntPointToEncodedBytes(signerPub.GetX(),
+		signerPub.GetY())) {
+		return false
+	}
+	edwards25519.FeNeg(&A.X, &A.X)
+	edwards25519.FeNeg(&A.T, &A.T)
+
+	// h = hash512(k || A || M)
+	var hramDigest [64]byte
+	h := sha512.New()
+	h.Write(encodedAggNonce[:])
+	h.Write(aggPub.Serialize())
+	h.Write(msg)
+	h.Sum(hramDigest[:0])
+	var hramDigestReduced [32]byte
+	edwards25519.ScReduce(&hramDigestReduced, &hramDigest)
+
+	// S*G - h*A = R_i
+	var checkR [32]byte
+	var proj edwards25519.ProjectiveGroupElement
+	edwards25519.GeDoubleScalarMultVartime(&proj, &hramDigestReduced, &A,
+		BigIntToEncodedBytes(partial.GetS()))
+	proj.ToBytes(&checkR)
+
+	return checkR == *BigIntPointToEncodedBytes(signerNonce.GetX(),
+		signerNonce.GetY())
+}
+
+// FindInvalidPartial looks for the signer whose partial signature spoiled a
+// combined signature which failed to verify, from the partial signatures,
+// public keys and public nonces of the signers stored at the same indices
+// and the message, without running the protocol again. The group key and
+// the sum of the nonces are recomputed from the stored keys and nonces with
+// CombinePubkeys and AggregatePublicNonces, and every partial is checked in
+// isolation with VerifyPartialSignature. The index of the first invalid
+// partial is returned, or -1 if all of them are valid, in which case the
+// combined signature can only be wrong if it wasn't combined from these
+// partials. An error is returned for malformed input, such as slices of
+// different lengths, for which no signer can be blamed. At most MaxSigners
+// partials can be checked.
+func FindInvalidPartial(curve *TwistedEdwardsCurve, partials []*Signature,
+	signerPubs, signerNonces []*PublicKey, msg []byte) (int, error) {
+	return FindInvalidPartialWithMaxSigners(curve, partials, signerPubs,
+		signerNonces, msg, MaxSigners)
+}
+
+// FindInvalidPartialWithMaxSigners is FindInvalidPartial with a limit of
+// maxSigners partials instead of MaxSigners.
+func FindInvalidPartialWithMaxSigners(curve *TwistedEdwardsCurve,
+	partials []*Signature, signerPubs, signerNonces []*PublicKey, msg []byte,
+	maxSigners int) (int, error) {
+	if len(partials) == 0 {
+		return 0, fmt.Errorf("no partial signatures")
+	}
+	if len(signerPubs) != len(partials) || len(signerNonces) != len(partials) {
+		return 0, fmt.Errorf("mismatched number of partial signatures "+
+			"(%v), public keys (%v) and nonces (%v)", len(partials),
+			len(signerPubs), len(signerNonces))
+	}
+	if len(partials) > maxSigners {
+		return 0, ErrTooManySigners
+	}
+	for i, pub := range signerPubs {
+		if pub == nil || pub.GetX() == nil || pub.GetY() == nil {
+			return 0, fmt.Errorf("public key %v is nil", i)
+		}
+	}
+	aggPub := CombinePubkeys(curve, signerPubs)
+	if aggPub == nil {
+		return 0, ErrIdentityKey
+	}
+	aggNonce, err := AggregatePublicNonces(curve, signerNonces)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, partial := range partials {
+		if !VerifyPartialSignature(curve, partial, signerPubs[i],
+			signerNonces[i], aggNonce, aggPub, msg) {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
+
+// PartialSignatureSize is the size of a serialized PartialSignature.
+const PartialSignatureSize = 4 + NonceCommitmentSize + SignatureSize
+
+// PartialSignature is a partial Schnorr signature tagged with the index of
+// the signer that produced it and the commitment to the signer's public
+// nonce, so that partial signatures exchanged across a network are self
+// describing.
+type PartialSignature struct {
+	Signature   *Signature
+	SignerIndex uint32
+	Commitment  []byte
+}
+
+// NewPartialSignature instantiates a new partial signature.
+func NewPartialSignature(sig *Signature, signerIndex uint32,
+	commitment []byte) *PartialSignature {
+	return &PartialSignature{sig, signerIndex, commitment}
+}
+
+// Serialize returns the partial signature encoded as the 4 byte big endian
+// signer index, followed by the nonce commitment and the 64 byte signature.
+// It returns nil if the signature is missing or isn't an Ed25519 signature.
+func (p PartialSignature) Serialize() []byte {
+	sig := p.Signature
+	if sig == nil || sig.R == nil || sig.S == nil || sig.isEd448() {
+		return nil
+	}
+
+	b := make([]byte, PartialSignatureSize)
+	binary.BigEndian.PutUint32(b[0:4], p.SignerIndex)
+	copy(b[4:4+NonceCommitmentSize], p.Commitment)
+	copy(b[4+NonceCommitmentSize:], sig.Serialize())
+
+	return b
+}
+
+// ParsePartialSignature parses a partial signature serialized with
+// PartialSignature.Serialize, performing the same checks on the signature as
+// ParseSignature.
+func ParsePartialSignature(curve *TwistedEdwardsCurve,
+	b []byte) (*PartialSignature, error) {
+	if len(b) != PartialSignatureSize {
+		return nil, fmt.Errorf("bad partial signature size; have %v, want %v",
+			len(b), PartialSignatureSize)
+	}
+
+	sig, err := ParseSignature(curve, b[4+NonceCommitmentSize:])
+	if err != nil {
+		return nil, err
+	}
+	commitment := make([]byte, NonceCommitmentSize)
+	copy(commitment, b[4:4+NonceCommitmentSize])
+
+	return NewPartialSignature(sig, binary.BigEndian.Uint32(b[0:4]),
+		commitment), nil
+}
+
+// SchnorrCombinePartialSigs combines the partial signatures of a group of
+// numSigners signers like SchnorrCombineSigs, after making sure that every
+// signer index is in range and that no signer contributed more than once.
+func SchnorrCombinePartialSigs(curve *TwistedEdwardsCurve,
+	partials []*PartialSignature, numSigners uint32) (*Signature, error) {
+	return SchnorrCombinePartialSigsWithMaxSigners(curve, partials,
+		numSigners, MaxSigners)
+}
+
+// SchnorrCombinePartialSigsWithMaxSigners is SchnorrCombinePartialSigs with
+// a limit of maxSigners partial signatures instead of MaxSigners.
+func SchnorrCombinePartialSigsWithMaxSigners(curve *TwistedEdwardsCurve,
+	partials []*PartialSignature, numSigners uint32,
+	maxSigners int) (*Signature, error) {
+	if len(partials) > maxSigners {
+		return nil, ErrTooManySigners
+	}
+
+	seen := make(map[uint32]struct{}, len(partials))
+	sigs := make([]*Signature, len(partials))
+	for i, p := range partials {
+		if p == nil || p.Signature == nil {
+			return nil, fmt.Errorf("nil partial signature")
+		}
+		if p.SignerIndex >= numSigners {
+			str := fmt.Sprintf("signer index %v of partial signature %v is "+
+				"out of range (%v signers)", p.SignerIndex, i, numSigners)
+			return nil, fmt.Errorf("%v", str)
+		}
+		if _, ok := seen[p.SignerIndex]; ok {
+			str := fmt.Sprintf("duplicate partial signature for signer %v",
+				p.SignerIndex)
+			return nil, fmt.Errorf("%v", str)
+		}
+		if len(p.Commitment) != NonceCommitmentSize {
+			str := fmt.Sprintf("bad commitment size for signer %v",
+				p.SignerIndex)
+			return nil, fmt.Errorf("%v", str)
+		}
+		seen[p.SignerIndex] = struct{}{}
+		sigs[i] = p.Signature
+	}
+
+	return SchnorrCombineSigsWithMaxSigners(curve, sigs, maxSigners)
+}
+
+// ThresholdSignatureSize is the size of a serialized ThresholdSignature.
+const ThresholdSignatureSize = PubKeyBytesLen + SignatureSize
+
+// ThresholdSignature is the compact form of an n-of-n threshold Schnorr
+// signature: the combined signature along with the aggregate public key of
+// the group, which is all a verifier needs. Storing the individual signature
+// of every signer instead takes n*(PubKeyBytesLen+SignatureSize) bytes, and
+// the per signer nonces and partial signatures of the protocol grow with n
+// as well, while a ThresholdSignature is ThresholdSignatureSize (96) bytes
+// for any number of signers. The trade off is that it doesn't reveal who
+// signed, only that the whole group did.
+type ThresholdSignature struct {
+	PubKey    *PublicKey
+	Signature *Signature
+}
+
+// NewThresholdSignature packages the combined signature sig of the group of
+// signers with the public keys pks, as returned by SchnorrCombineSigs, with
+// their aggregate public key. The signature is verified before it is
+// packaged, and ErrIdentityKey is returned if the keys cancel out.
+func NewThresholdSignature(curve *TwistedEdwardsCurve, pks []*PublicKey,
+	sig *Signature, msg []byte) (*ThresholdSignature, error) {
+	if sig == nil {
+		return nil, fmt.Errorf("signature is nil")
+	}
+	if len(pks) == 0 {
+		return nil, fmt.Errorf("no public keys to combine")
+	}
+	acc := NewPubKeyAccumulator(curve)
+	for i, pk := range pks {
+		if err := acc.Add(pk); err != nil {
+			return nil, fmt.Errorf("public key %v: %v", i, err)
+		}
+	}
+	aggPub := acc.Result()
+	if isIdentity(aggPub.GetX(), aggPub.GetY()) {
+		return nil, ErrIdentityKey
+	}
+	ts := &ThresholdSignature{aggPub, sig}
+	if !ts.Verify(msg) {
+		return nil, fmt.Errorf("signature is not valid for the aggregate " +
+			"public key")
+	}
+
+	return ts, nil
+}
+
+// Verify verifies the threshold signature over msg under its aggregate
+// public key.
+func (ts *ThresholdSignature) Verify(msg []byte) bool {
+	if ts == nil || ts.PubKey == nil || ts.Signature == nil {
+		return false
+	}
+
+	return Verify(ts.PubKey, msg, ts.Signature.GetR(), ts.Signature.GetS())
+}
+
+// Serialize returns the threshold signature encoded as the 32 byte aggregate
+// public key followed by the 64 byte signature.
+func (ts ThresholdSignature) Serialize() []byte {
+	b := make([]byte, ThresholdSignatureSize)
+	copy(b[:PubKeyBytesLen], ts.PubKey.Serialize())
+	copy(b[PubKeyBytesLen:], ts.Signature.Serialize())
+
+	return b
+}
+
+// ParseThresholdSignature parses a threshold signature serialized with
+// ThresholdSignature.Serialize, performing the same checks on its parts as
+// ParsePubKey and ParseSignature.
+func ParseThresholdSignature(curve *TwistedEdwardsCurve,
+	b []byte) (*ThresholdSignature, error) {
+	if len(b) != ThresholdSignatureSize {
+		return nil, fmt.Errorf("bad threshold signature size; have %v, "+
+			"want %v", len(b), ThresholdSignatureSize)
+	}
+
+	pub, err := ParsePubKey(curve, b[:PubKeyBytesLen])
+	if err != nil {
+		return nil, err
+	}
+	sig, err := ParseSignature(curve, b[PubKeyBytesLen:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &ThresholdSignature{pub, sig}, nil
+}
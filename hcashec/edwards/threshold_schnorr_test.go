@@ -17,6 +17,19 @@ import (
 // * TestSchnorrThresholdSigOnBadSecNonce
 // * TestSchnorrThresholdSigOnBadSk
 // * TestSchnorrThresholdSigOnBadSecNonce
+// * TestNonceCommitment
+// * TestSchnorrCombineSigsLimit
+// * TestPartialSignature
+// * TestVerifyPartialSignature
+// * TestFindInvalidPartial
+// * TestAggregatePublicNonces
+// * TestThresholdSignature
+// * TestDeriveNonce
+// * TestPubKeyAccumulator
+// * TestSigningSession
+// * TestSigningSessionOrder
+// * TestGenerateHedgedNonce
+// * TestVerifyNonceSet
 
 // TestStdSchnorrThresholdSig test Schnorr threshold signature
 func TestStdSchnorrThresholdSig(t *testing.T) {
@@ -217,6 +230,43 @@ func TestSchnorrThresholdSigOnBadPubNonce(t *testing.T) {
 	}
 }
 
+// TestSchnorrPartialSignTorsion tests that partial signing rejects a group
+// key or nonce sum with a torsion component with an error, and that
+// SignThreshold rejects a nil group key, instead of panicking
+func TestSchnorrPartialSignTorsion(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+
+	keyVec := mockUpSchnorrKeyVec(curve, 3, msg)
+	tb, _ := hex.DecodeString(smallOrderPoints[4])
+	tx, ty, _ := curve.EncodedBytesToBigIntPoint(copyBytes(tb))
+	withTorsion := func(pub *PublicKey) *PublicKey {
+		x, y := curve.Add(pub.GetX(), pub.GetY(), tx, ty)
+		return NewPublicKey(curve, x, y)
+	}
+
+	sk, nonce := keyVec.skVec[0], keyVec.secNonceVec[0]
+	if _, _, err := SchnorrPartialSign(curve, msg, sk,
+		withTorsion(keyVec.pkVecSum), nonce,
+		keyVec.pubNonceVecSum); err == nil {
+		t.Fatalf("signed for a group key with a torsion component")
+	}
+	if _, _, err := SchnorrPartialSign(curve, msg, sk, keyVec.pkVecSum,
+		nonce, withTorsion(keyVec.pubNonceVecSum)); err == nil {
+		t.Fatalf("signed with a nonce sum with a torsion component")
+	}
+	if _, _, err := SchnorrPartialSign(curve, msg, sk, nil, nonce,
+		keyVec.pubNonceVecSum); err == nil {
+		t.Fatalf("signed for a nil group key")
+	}
+	if _, _, err := SignThreshold(curve, sk, nil, msg, nonce,
+		keyVec.pubNonceVecSum); err == nil {
+		t.Fatalf("threshold signed for a nil group key")
+	}
+}
+
 // TestSchnorrThresholdSigOnBadSk test Schnorr threshold signature
 // being verified by wrong secret keys
 func TestSchnorrThresholdSigOnBadSk(t *testing.T) {
@@ -295,3 +345,903 @@ func TestSchnorrThresholdSigOnBadSecNonce(t *testing.T) {
 		}
 	}
 }
+
+// TestNonceCommitment tests the commit-then-reveal round for public nonces
+func TestNonceCommitment(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	msg, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+	keyVec := mockUpSchnorrKeyVec(curve, 3, msg)
+
+	// Round one: every signer commits to its nonce.
+	commitments := make([][]byte, len(keyVec.secNonceVec))
+	for i, secNonce := range keyVec.secNonceVec {
+		commitments[i] = NonceCommitment(secNonce)
+		if len(commitments[i]) != NonceCommitmentSize {
+			t.Fatalf("bad commitment size %v", len(commitments[i]))
+		}
+	}
+
+	// Round two: the revealed nonces must match the commitments.
+	for i, pubNonce := range keyVec.pubNonceVec {
+		if !VerifyNonceCommitment(commitments[i], pubNonce) {
+			t.Fatalf("honest nonce %v failed to verify", i)
+		}
+	}
+
+	// A signer which switches its nonce after committing is caught.
+	otherNonce := keyVec.pubNonceVec[1]
+	if VerifyNonceCommitment(commitments[0], otherNonce) {
+		t.Fatalf("switched nonce matched the commitment")
+	}
+	badCommitment := make([]byte, NonceCommitmentSize)
+	copy(badCommitment, commitments[0])
+	badCommitment[0] ^= 0x01
+	if VerifyNonceCommitment(badCommitment, keyVec.pubNonceVec[0]) {
+		t.Fatalf("nonce matched a corrupted commitment")
+	}
+	if VerifyNonceCommitment(commitments[0][:16], keyVec.pubNonceVec[0]) {
+		t.Fatalf("nonce matched a truncated commitment")
+	}
+}
+
+// TestPartialSignature tests the round trip serialization of partial
+// signatures, that those without a signature don't serialize, and that
+// combining them rejects bad signer indices
+func TestPartialSignature(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+
+	const numSigners = 3
+	keyVec := mockUpSchnorrKeyVec(curve, numSigners, msg)
+	partials := make([]*PartialSignature, numSigners)
+	for i, sk := range keyVec.skVec {
+		r, s, err := SchnorrPartialSign(curve, msg, sk, keyVec.pkVecSum,
+			keyVec.secNonceVec[i], keyVec.pubNonceVecSum)
+		if err != nil {
+			t.Fatalf("unexpected error %s, ", err)
+		}
+		p := NewPartialSignature(NewSignature(r, s), uint32(i),
+			NonceCommitment(keyVec.secNonceVec[i]))
+
+		// Round trip.
+		b := p.Serialize()
+		if len(b) != PartialSignatureSize {
+			t.Fatalf("want %v bytes, got %v", PartialSignatureSize, len(b))
+		}
+		parsed, err := ParsePartialSignature(curve, b)
+		if err != nil {
+			t.Fatalf("unexpected parsing error %s, ", err)
+		}
+		if parsed.SignerIndex != p.SignerIndex ||
+			!bytes.Equal(parsed.Commitment, p.Commitment) ||
+			!bytes.Equal(parsed.Signature.Serialize(), p.Signature.Serialize()) {
+			t.Fatalf("round trip mismatch for signer %v", i)
+		}
+		partials[i] = parsed
+	}
+	if _, err := ParsePartialSignature(curve,
+		partials[0].Serialize()[1:]); err == nil {
+		t.Fatalf("parsed a truncated partial signature")
+	}
+	for _, sig := range []*Signature{nil, {}, {R: partials[0].Signature.R}} {
+		p := NewPartialSignature(sig, 0, partials[0].Commitment)
+		if b := p.Serialize(); b != nil {
+			t.Fatalf("serialized a partial signature with signature %v "+
+				"to %x", sig, b)
+		}
+	}
+
+	sig, err := SchnorrCombinePartialSigs(curve, partials, numSigners)
+	if err != nil {
+		t.Fatalf("unexpected error %s, ", err)
+	}
+	if !Verify(keyVec.pkVecSum, msg, sig.GetR(), sig.GetS()) {
+		t.Fatalf("combined signature failed to verify")
+	}
+
+	// The same signer twice.
+	dup := []*PartialSignature{partials[0], partials[1], partials[1]}
+	if _, err := SchnorrCombinePartialSigs(curve, dup, numSigners); err == nil {
+		t.Fatalf("combined a duplicated partial signature")
+	}
+
+	// A signer outside of the group.
+	outOfRange := *partials[2]
+	outOfRange.SignerIndex = numSigners
+	bad := []*PartialSignature{partials[0], partials[1], &outOfRange}
+	if _, err := SchnorrCombinePartialSigs(curve, bad, numSigners); err == nil {
+		t.Fatalf("combined a partial signature with an out of range index")
+	}
+}
+
+// TestSchnorrCombineSigsLimit tests that combining rejects more than
+// MaxSigners signatures and nil entries
+func TestSchnorrCombineSigsLimit(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+
+	const numSigners = 3
+	keyVec := mockUpSchnorrKeyVec(curve, numSigners, msg)
+	sigs := make([]*Signature, numSigners)
+	for i, sk := range keyVec.skVec {
+		r, s, err := SchnorrPartialSign(curve, msg, sk, keyVec.pkVecSum,
+			keyVec.secNonceVec[i], keyVec.pubNonceVecSum)
+		if err != nil {
+			t.Fatalf("unexpected error %s, ", err)
+		}
+		sigs[i] = NewSignature(r, s)
+	}
+
+	// The default limit.
+	tooMany := make([]*Signature, MaxSigners+1)
+	for i := range tooMany {
+		tooMany[i] = sigs[0]
+	}
+	if _, err := SchnorrCombineSigs(curve, tooMany); err != ErrTooManySigners {
+		t.Fatalf("want %v combining %v signatures, got %v",
+			ErrTooManySigners, len(tooMany), err)
+	}
+	if _, err := SchnorrCombineSigs(curve,
+		tooMany[:MaxSigners]); err != nil {
+		t.Fatalf("unexpected error combining %v signatures: %v", MaxSigners,
+			err)
+	}
+
+	// Exactly at a lower limit, one above it, and a higher limit.
+	sig, err := SchnorrCombineSigsWithMaxSigners(curve, sigs, numSigners)
+	if err != nil {
+		t.Fatalf("unexpected error at the limit: %v", err)
+	}
+	if !Verify(keyVec.pkVecSum, msg, sig.GetR(), sig.GetS()) {
+		t.Fatalf("combined signature failed to verify")
+	}
+	extra := append(sigs[:numSigners:numSigners], sigs[0])
+	if _, err := SchnorrCombineSigsWithMaxSigners(curve, extra,
+		numSigners); err != ErrTooManySigners {
+		t.Fatalf("want %v above the limit, got %v", ErrTooManySigners, err)
+	}
+	if _, err := SchnorrCombineSigsWithMaxSigners(curve,
+		append(tooMany, sigs[0]), MaxSigners+2); err != nil {
+		t.Fatalf("unexpected error under a raised limit: %v", err)
+	}
+	partials := make([]*PartialSignature, numSigners+1)
+	for i := range partials {
+		partials[i] = NewPartialSignature(sigs[0], uint32(i),
+			make([]byte, NonceCommitmentSize))
+	}
+	if _, err := SchnorrCombinePartialSigsWithMaxSigners(curve, partials,
+		numSigners+1, numSigners); err != ErrTooManySigners {
+		t.Fatalf("want %v above the limit, got %v", ErrTooManySigners, err)
+	}
+
+	// Nil entries are rejected rather than dereferenced.
+	withNil := []*Signature{sigs[0], nil, sigs[2]}
+	if _, err := SchnorrCombineSigs(curve, withNil); err == nil {
+		t.Fatalf("combined a nil signature")
+	}
+	withNilS := []*Signature{sigs[0], NewSignature(sigs[1].GetR(), nil),
+		sigs[2]}
+	if _, err := SchnorrCombineSigs(curve, withNilS); err == nil {
+		t.Fatalf("combined a signature with a nil S")
+	}
+}
+
+// TestVerifyPartialSignature tests that honest partial signatures verify
+// and that a corrupted partial is flagged as coming from its signer
+func TestVerifyPartialSignature(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+
+	const numSigners = 4
+	keyVec := mockUpSchnorrKeyVec(curve, numSigners, msg)
+	partials := make([]*Signature, numSigners)
+	for i := range partials {
+		r, s, err := SchnorrPartialSign(curve, msg, keyVec.skVec[i],
+			keyVec.pkVecSum, keyVec.secNonceVec[i], keyVec.pubNonceVecSum)
+		if err != nil {
+			t.Fatalf("signer %d: unexpected error: %v", i, err)
+		}
+		partials[i] = NewSignature(r, s)
+	}
+
+	verify := func(i int, partial *Signature) bool {
+		return VerifyPartialSignature(curve, partial, keyVec.pkVec[i],
+			keyVec.pubNonceVec[i], keyVec.pubNonceVecSum, keyVec.pkVecSum,
+			msg)
+	}
+	for i, partial := range partials {
+		if !verify(i, partial) {
+			t.Fatalf("signer %d: honest partial signature rejected", i)
+		}
+		// A partial is only valid for its own signer.
+		if verify((i+1)%numSigners, partial) {
+			t.Fatalf("signer %d: partial verified for another signer", i)
+		}
+	}
+
+	// Corrupt the partial of one signer, the coordinator finds it.
+	const bad = 2
+	corruptS := new(big.Int).Add(partials[bad].GetS(), one)
+	corruptS.Mod(corruptS, curve.N)
+	partials[bad] = NewSignature(partials[bad].GetR(), corruptS)
+	combined, err := SchnorrCombineSigs(curve, partials)
+	if err != nil {
+		t.Fatalf("unexpected combining error: %v", err)
+	}
+	if Verify(keyVec.pkVecSum, msg, combined.GetR(), combined.GetS()) {
+		t.Fatalf("combined signature with a corrupted partial verified")
+	}
+	for i, partial := range partials {
+		want := i != bad
+		if verify(i, partial) != want {
+			t.Fatalf("signer %d: got %v, want %v", i, !want, want)
+		}
+	}
+
+	// A partial for another message or nonce sum is rejected.
+	if VerifyPartialSignature(curve, partials[0], keyVec.pkVec[0],
+		keyVec.pubNonceVec[0], keyVec.pubNonceVecSum, keyVec.pkVecSum,
+		append([]byte{0x00}, msg[1:]...)) {
+		t.Fatalf("partial verified for another message")
+	}
+	if VerifyPartialSignature(curve, partials[0], keyVec.pkVec[0],
+		keyVec.pubNonceVec[0], keyVec.pubNonceVec[1], keyVec.pkVecSum, msg) {
+		t.Fatalf("partial verified for another nonce sum")
+	}
+	if VerifyPartialSignature(curve, nil, keyVec.pkVec[0],
+		keyVec.pubNonceVec[0], keyVec.pubNonceVecSum, keyVec.pkVecSum, msg) {
+		t.Fatalf("nil partial verified")
+	}
+}
+
+// TestFindInvalidPartial tests that the one corrupted signer among five is
+// found from the stored partials, keys and nonces, that all honest partials
+// give -1, and that malformed input is an error
+func TestFindInvalidPartial(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+
+	const numSigners = 5
+	keyVec := mockUpSchnorrKeyVec(curve, numSigners, msg)
+	partials := make([]*Signature, numSigners)
+	for i := range partials {
+		r, s, err := SchnorrPartialSign(curve, msg, keyVec.skVec[i],
+			keyVec.pkVecSum, keyVec.secNonceVec[i], keyVec.pubNonceVecSum)
+		if err != nil {
+			t.Fatalf("signer %d: unexpected error: %v", i, err)
+		}
+		partials[i] = NewSignature(r, s)
+	}
+
+	bad, err := FindInvalidPartial(curve, partials, keyVec.pkVec,
+		keyVec.pubNonceVec, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bad != -1 {
+		t.Fatalf("honest signer %d blamed", bad)
+	}
+
+	for corrupted := 0; corrupted < numSigners; corrupted++ {
+		spoiled := append([]*Signature(nil), partials...)
+		corruptS := new(big.Int).Add(partials[corrupted].GetS(), one)
+		corruptS.Mod(corruptS, curve.N)
+		spoiled[corrupted] = NewSignature(partials[corrupted].GetR(),
+			corruptS)
+		combined, err := SchnorrCombineSigs(curve, spoiled)
+		if err != nil {
+			t.Fatalf("unexpected combining error: %v", err)
+		}
+		if Verify(keyVec.pkVecSum, msg, combined.GetR(), combined.GetS()) {
+			t.Fatalf("combined signature with a corrupted partial verified")
+		}
+
+		bad, err := FindInvalidPartial(curve, spoiled, keyVec.pkVec,
+			keyVec.pubNonceVec, msg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bad != corrupted {
+			t.Fatalf("want signer %d blamed, got %d", corrupted, bad)
+		}
+	}
+
+	// Partials made for another message are all invalid for msg.
+	otherMsg := append([]byte{0x00}, msg[1:]...)
+	if bad, err := FindInvalidPartial(curve, partials, keyVec.pkVec,
+		keyVec.pubNonceVec, otherMsg); err != nil || bad != 0 {
+		t.Fatalf("want signer 0 blamed for another message, got %d (%v)",
+			bad, err)
+	}
+
+	if _, err := FindInvalidPartial(curve, nil, nil, nil, msg); err == nil {
+		t.Fatalf("expected error for no partials")
+	}
+	if _, err := FindInvalidPartial(curve, partials, keyVec.pkVec[1:],
+		keyVec.pubNonceVec, msg); err == nil {
+		t.Fatalf("expected error for mismatched lengths")
+	}
+	withNil := append([]*PublicKey(nil), keyVec.pkVec...)
+	withNil[2] = nil
+	if _, err := FindInvalidPartial(curve, partials, withNil,
+		keyVec.pubNonceVec, msg); err == nil {
+		t.Fatalf("expected error for a nil public key")
+	}
+}
+
+// TestAggregatePublicNonces tests that the aggregate public nonce is the
+// base point multiple of the sum of the secret nonces, and that nil, off
+// curve and cancelling nonces are rejected
+func TestAggregatePublicNonces(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	msg, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+	for _, numSigners := range []int{1, 2, 7} {
+		keyVec := mockUpSchnorrKeyVec(curve, numSigners, msg)
+		aggNonce, err := AggregatePublicNonces(curve, keyVec.pubNonceVec)
+		if err != nil {
+			t.Fatalf("%d signers: unexpected error: %v", numSigners, err)
+		}
+
+		secSum := new(big.Int)
+		for _, secNonce := range keyVec.secNonceVec {
+			secSum.Add(secSum, secNonce.GetD())
+		}
+		secSum.Mod(secSum, curve.N)
+		wantX, wantY := curve.ScalarBaseMult(secSum.Bytes())
+		if aggNonce.GetX().Cmp(wantX) != 0 || aggNonce.GetY().Cmp(wantY) != 0 {
+			t.Fatalf("%d signers: aggregate nonce is not the multiple of "+
+				"the summed secret nonces", numSigners)
+		}
+	}
+
+	keyVec := mockUpSchnorrKeyVec(curve, 3, msg)
+	if _, err := AggregatePublicNonces(curve, nil); err == nil {
+		t.Fatalf("aggregated no nonces")
+	}
+	withNil := []*PublicKey{keyVec.pubNonceVec[0], nil}
+	if _, err := AggregatePublicNonces(curve, withNil); err == nil {
+		t.Fatalf("aggregated a nil nonce")
+	}
+	offCurve := NewPublicKey(curve, one, one)
+	if _, err := AggregatePublicNonces(curve, []*PublicKey{
+		keyVec.pubNonceVec[0], offCurve}); err == nil {
+		t.Fatalf("aggregated an off curve nonce")
+	}
+	n := keyVec.pubNonceVec[1]
+	negX, negY := curve.Neg(n.GetX(), n.GetY())
+	cancelling := []*PublicKey{n, NewPublicKey(curve, negX, negY)}
+	if _, err := AggregatePublicNonces(curve, cancelling); err == nil {
+		t.Fatalf("aggregated nonces summing to the identity")
+	}
+}
+
+// TestThresholdSignature tests that a packaged threshold signature verifies
+// exactly when every signer's partial signature does, and that it survives
+// a serialization roundtrip
+func TestThresholdSignature(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+
+	const numSigners = 5
+	keyVec := mockUpSchnorrKeyVec(curve, numSigners, msg)
+	partials := make([]*Signature, numSigners)
+	for i := range partials {
+		r, s, err := SchnorrPartialSign(curve, msg, keyVec.skVec[i],
+			keyVec.pkVecSum, keyVec.secNonceVec[i], keyVec.pubNonceVecSum)
+		if err != nil {
+			t.Fatalf("signer %d: unexpected error: %v", i, err)
+		}
+		partials[i] = NewSignature(r, s)
+		if !VerifyPartialSignature(curve, partials[i], keyVec.pkVec[i],
+			keyVec.pubNonceVec[i], keyVec.pubNonceVecSum, keyVec.pkVecSum,
+			msg) {
+			t.Fatalf("signer %d: partial signature rejected", i)
+		}
+	}
+
+	combined, err := SchnorrCombineSigs(curve, partials)
+	if err != nil {
+		t.Fatalf("unexpected combining error: %v", err)
+	}
+	ts, err := NewThresholdSignature(curve, keyVec.pkVec, combined, msg)
+	if err != nil {
+		t.Fatalf("unexpected packaging error: %v", err)
+	}
+	if !ts.Verify(msg) {
+		t.Fatalf("threshold signature failed to verify")
+	}
+	if ts.Verify(append([]byte{0x00}, msg[1:]...)) {
+		t.Fatalf("threshold signature verified for another message")
+	}
+
+	b := ts.Serialize()
+	if len(b) != ThresholdSignatureSize {
+		t.Fatalf("serialized threshold signature is %v bytes, want %v",
+			len(b), ThresholdSignatureSize)
+	}
+	if len(b) >= numSigners*(PubKeyBytesLen+SignatureSize) {
+		t.Fatalf("threshold signature is no smaller than the individual " +
+			"signatures")
+	}
+	parsed, err := ParseThresholdSignature(curve, b)
+	if err != nil {
+		t.Fatalf("unexpected parsing error: %v", err)
+	}
+	if !bytes.Equal(parsed.Serialize(), b) || !parsed.Verify(msg) {
+		t.Fatalf("threshold signature changed in a roundtrip")
+	}
+	if _, err := ParseThresholdSignature(curve, b[1:]); err == nil {
+		t.Fatalf("parsed a truncated threshold signature")
+	}
+
+	// With one partial corrupted, both the aggregate and that signer's
+	// partial stop verifying.
+	const bad = 3
+	corruptS := new(big.Int).Add(partials[bad].GetS(), one)
+	corruptS.Mod(corruptS, curve.N)
+	partials[bad] = NewSignature(partials[bad].GetR(), corruptS)
+	if VerifyPartialSignature(curve, partials[bad], keyVec.pkVec[bad],
+		keyVec.pubNonceVec[bad], keyVec.pubNonceVecSum, keyVec.pkVecSum,
+		msg) {
+		t.Fatalf("corrupted partial signature verified")
+	}
+	combined, _ = SchnorrCombineSigs(curve, partials)
+	if (&ThresholdSignature{keyVec.pkVecSum, combined}).Verify(msg) {
+		t.Fatalf("threshold signature with a corrupted partial verified")
+	}
+	if _, err := NewThresholdSignature(curve, keyVec.pkVec, combined,
+		msg); err == nil {
+		t.Fatalf("packaged an invalid threshold signature")
+	}
+}
+
+// TestDeriveNonce tests that derived nonces are reproducible, that they
+// diverge across sessions, messages and keys, and that a group signing with
+// derived nonces produces a valid signature
+func TestDeriveNonce(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+	otherMsg := append([]byte{0x00}, msg[1:]...)
+
+	const numSigners = 3
+	keyVec := mockUpSchnorrKeyVec(curve, numSigners, msg)
+	sessionID := append(keyVec.pkVecSum.Serialize(), 0x01)
+	otherSessionID := append(keyVec.pkVecSum.Serialize(), 0x02)
+
+	secNonces := make([]*PrivateKey, numSigners)
+	pubNonces := make([]*PublicKey, numSigners)
+	for i, sk := range keyVec.skVec {
+		secNonces[i], pubNonces[i] = DeriveNonce(sk, msg, sessionID)
+		if secNonces[i] == nil || pubNonces[i] == nil {
+			t.Fatalf("signer %d: failed to derive a nonce", i)
+		}
+		if x, y := secNonces[i].Public(); x.Cmp(pubNonces[i].GetX()) != 0 ||
+			y.Cmp(pubNonces[i].GetY()) != 0 {
+			t.Fatalf("signer %d: public nonce doesn't match", i)
+		}
+
+		again, _ := DeriveNonce(sk, msg, sessionID)
+		if !again.Equal(secNonces[i]) {
+			t.Fatalf("signer %d: same inputs gave another nonce", i)
+		}
+		for j, other := range []*PrivateKey{
+			firstNonce(DeriveNonce(sk, msg, otherSessionID)),
+			firstNonce(DeriveNonce(sk, otherMsg, sessionID)),
+			firstNonce(DeriveNonce(sk, msg, nil)),
+		} {
+			if other == nil || other.Equal(secNonces[i]) {
+				t.Fatalf("signer %d, variant %d: nonce didn't diverge", i, j)
+			}
+		}
+		if i > 0 && secNonces[i].Equal(secNonces[0]) {
+			t.Fatalf("signer %d: two keys gave the same nonce", i)
+		}
+	}
+
+	pubNonceSum, err := AggregatePublicNonces(curve, pubNonces)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	partials := make([]*Signature, numSigners)
+	for i, sk := range keyVec.skVec {
+		r, s, err := SchnorrPartialSign(curve, msg, sk, keyVec.pkVecSum,
+			secNonces[i], pubNonceSum)
+		if err != nil {
+			t.Fatalf("signer %d: unexpected signing error: %v", i, err)
+		}
+		partials[i] = NewSignature(r, s)
+	}
+	sig, err := SchnorrCombineSigs(curve, partials)
+	if err != nil {
+		t.Fatalf("unexpected combining error: %v", err)
+	}
+	if !Verify(keyVec.pkVecSum, msg, sig.GetR(), sig.GetS()) {
+		t.Fatalf("signature with derived nonces failed to verify")
+	}
+
+	if k, _ := DeriveNonce(nil, msg, sessionID); k != nil {
+		t.Fatalf("derived a nonce from a nil key")
+	}
+}
+
+// firstNonce returns the secret nonce of a DeriveNonce result.
+func firstNonce(k *PrivateKey, _ *PublicKey) *PrivateKey {
+	return k
+}
+
+// TestPubKeyAccumulator tests that accumulating public keys one at a time
+// gives the same sum as combining them at once, for both curves, and that
+// an empty accumulator holds the identity
+func TestPubKeyAccumulator(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	ed448 := new(TwistedEdwardsCurve)
+	ed448.InitParamEd448()
+
+	for _, c := range []*TwistedEdwardsCurve{curve, ed448} {
+		acc := NewPubKeyAccumulator(c)
+		if sum := acc.Result(); sum.GetX().Sign() != 0 ||
+			sum.GetY().Cmp(one) != 0 {
+			t.Fatalf("empty accumulator is not the identity")
+		}
+
+		sks, err := GenerateKeys(c, crand.Reader, 8)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var pks []*PublicKey
+		for i, sk := range sks {
+			pks = append(pks, sk.PubKey())
+			if err := acc.Add(sk.PubKey()); err != nil {
+				t.Fatalf("key %d: unexpected error: %v", i, err)
+			}
+			if acc.Count() != i+1 {
+				t.Fatalf("key %d: count is %v", i, acc.Count())
+			}
+			if !acc.Result().Equal(CombinePubkeys(c, pks)) {
+				t.Fatalf("key %d: accumulated sum differs from the "+
+					"combined sum", i)
+			}
+		}
+
+		if err := acc.Add(nil); err == nil {
+			t.Fatalf("added a nil key")
+		}
+		if err := acc.Add(NewPublicKey(c, one, one)); err == nil {
+			t.Fatalf("added an off curve key")
+		}
+		if acc.Count() != len(pks) || !acc.Result().Equal(
+			CombinePubkeys(c, pks)) {
+			t.Fatalf("rejected keys changed the sum")
+		}
+	}
+}
+
+// TestSigningSession tests driving the rounds of a SigningSession for three
+// signers to a combined signature that verifies with the group key
+func TestSigningSession(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	msg, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+	keyVec := mockUpSchnorrKeyVec(curve, 3, msg)
+
+	session, err := NewSigningSession(curve, keyVec.pkVec, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !session.GroupPubKey().Equal(keyVec.pkVecSum) {
+		t.Fatalf("session group key differs from the combined key")
+	}
+
+	// Contributions may arrive in any order within a round.
+	order := []int{2, 0, 1}
+	for _, i := range order {
+		if session.State() != AwaitingCommitments {
+			t.Fatalf("want state AwaitingCommitments, got %v",
+				session.State())
+		}
+		err := session.AddCommitment(i, NonceCommitment(keyVec.secNonceVec[i]))
+		if err != nil {
+			t.Fatalf("signer %d: unexpected error: %v", i, err)
+		}
+	}
+	for _, i := range order {
+		if session.State() != AwaitingNonces {
+			t.Fatalf("want state AwaitingNonces, got %v", session.State())
+		}
+		if err := session.AddNonce(i, keyVec.pubNonceVec[i]); err != nil {
+			t.Fatalf("signer %d: unexpected error: %v", i, err)
+		}
+	}
+	if !session.PublicNonceSum().Equal(keyVec.pubNonceVecSum) {
+		t.Fatalf("session nonce sum differs from the aggregated nonces")
+	}
+	for _, i := range order {
+		if session.State() != AwaitingPartials {
+			t.Fatalf("want state AwaitingPartials, got %v", session.State())
+		}
+		partial, err := session.PartialSign(i, keyVec.skVec[i],
+			keyVec.secNonceVec[i])
+		if err != nil {
+			t.Fatalf("signer %d: unexpected error: %v", i, err)
+		}
+		if err := session.AddPartial(i, partial); err != nil {
+			t.Fatalf("signer %d: unexpected error: %v", i, err)
+		}
+	}
+	if session.State() != Complete {
+		t.Fatalf("want state Complete, got %v", session.State())
+	}
+
+	sig, err := session.Signature()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !Verify(keyVec.pkVecSum, msg, sig.GetR(), sig.GetS()) {
+		t.Fatalf("failed to verify the session signature")
+	}
+	want, err := mockUpSchnorrMultiSign(curve, msg, keyVec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(sig.Serialize(), want.Serialize()) {
+		t.Fatalf("session signature differs from the combined partials")
+	}
+}
+
+// TestSigningSessionOrder tests that SigningSession rejects calls out of
+// order, duplicate and out of range signers, nonces that don't match their
+// commitments and invalid partial signatures, without changing state
+func TestSigningSessionOrder(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	msg, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+	keyVec := mockUpSchnorrKeyVec(curve, 3, msg)
+
+	if _, err := NewSigningSession(curve, nil, msg); err == nil {
+		t.Fatalf("started a session without signers")
+	}
+	if _, err := NewSigningSession(curve, keyVec.pkVec, msg[1:]); err == nil {
+		t.Fatalf("started a session for a short message")
+	}
+
+	session, err := NewSigningSession(curve, keyVec.pkVec, msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	commitment := NonceCommitment(keyVec.secNonceVec[0])
+
+	// Nothing but commitments is accepted in the first round.
+	if err := session.AddNonce(0, keyVec.pubNonceVec[0]); err !=
+		ErrSessionState {
+		t.Fatalf("want ErrSessionState for an early nonce, got %v", err)
+	}
+	if _, err := session.PartialSign(0, keyVec.skVec[0],
+		keyVec.secNonceVec[0]); err != ErrSessionState {
+		t.Fatalf("want ErrSessionState for an early partial signature, "+
+			"got %v", err)
+	}
+	if _, err := session.Signature(); err != ErrSessionState {
+		t.Fatalf("want ErrSessionState for an early signature, got %v", err)
+	}
+	for _, i := range []int{-1, 3} {
+		if err := session.AddCommitment(i, commitment); err == nil {
+			t.Fatalf("accepted signer index %d", i)
+		}
+	}
+	if err := session.AddCommitment(0, commitment[1:]); err == nil {
+		t.Fatalf("accepted a short commitment")
+	}
+	if err := session.AddCommitment(0, commitment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := session.AddCommitment(0, commitment); err == nil {
+		t.Fatalf("accepted a duplicate commitment")
+	}
+	for i := 1; i < 3; i++ {
+		err := session.AddCommitment(i, NonceCommitment(keyVec.secNonceVec[i]))
+		if err != nil {
+			t.Fatalf("signer %d: unexpected error: %v", i, err)
+		}
+	}
+
+	if err := session.AddCommitment(1, commitment); err != ErrSessionState {
+		t.Fatalf("want ErrSessionState for a late commitment, got %v", err)
+	}
+	if err := session.AddNonce(0, keyVec.pubNonceVec[1]); err == nil {
+		t.Fatalf("accepted a nonce not matching its commitment")
+	}
+	for i := 0; i < 3; i++ {
+		if err := session.AddNonce(i, keyVec.pubNonceVec[i]); err != nil {
+			t.Fatalf("signer %d: unexpected error: %v", i, err)
+		}
+	}
+	if session.State() != AwaitingPartials {
+		t.Fatalf("want state AwaitingPartials, got %v", session.State())
+	}
+
+	if err := session.AddNonce(0, keyVec.pubNonceVec[0]); err !=
+		ErrSessionState {
+		t.Fatalf("want ErrSessionState for a late nonce, got %v", err)
+	}
+	partial, err := session.PartialSign(1, keyVec.skVec[1],
+		keyVec.secNonceVec[1])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := session.AddPartial(0, partial); err == nil {
+		t.Fatalf("accepted the partial signature of another signer")
+	}
+	if err := session.AddPartial(1, partial); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := session.AddPartial(1, partial); err == nil {
+		t.Fatalf("accepted a duplicate partial signature")
+	}
+	if session.State() != AwaitingPartials {
+		t.Fatalf("rejected calls changed the state to %v", session.State())
+	}
+}
+
+// TestGenerateHedgedNonce tests that hedged nonces are reproducible with a
+// deterministic rand, differ for other random bytes, messages and keys,
+// and produce valid threshold signatures
+func TestGenerateHedgedNonce(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	msg, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+	otherMsg := append([]byte{0x00}, msg[1:]...)
+
+	const numSigners = 3
+	keyVec := mockUpSchnorrKeyVec(curve, numSigners, msg)
+	entropy := bytes.Repeat([]byte{0x5a}, 32)
+	otherEntropy := bytes.Repeat([]byte{0xa5}, 32)
+
+	secNonces := make([]*PrivateKey, numSigners)
+	pubNonces := make([]*PublicKey, numSigners)
+	for i, sk := range keyVec.skVec {
+		k, err := GenerateHedgedNonce(sk, msg, bytes.NewReader(entropy))
+		if err != nil {
+			t.Fatalf("signer %d: unexpected error: %v", i, err)
+		}
+		again, err := GenerateHedgedNonce(sk, msg, bytes.NewReader(entropy))
+		if err != nil {
+			t.Fatalf("signer %d: unexpected error: %v", i, err)
+		}
+		if !again.Equal(k) {
+			t.Fatalf("signer %d: same random bytes gave another nonce", i)
+		}
+		seeded, _ := GenerateHedgedNonce(sk, msg, rand.New(rand.NewSource(1)))
+		seededAgain, _ := GenerateHedgedNonce(sk, msg,
+			rand.New(rand.NewSource(1)))
+		if seeded == nil || !seeded.Equal(seededAgain) {
+			t.Fatalf("signer %d: seeded rand gave different nonces", i)
+		}
+
+		for j, test := range []struct {
+			msg     []byte
+			entropy []byte
+		}{
+			{msg, otherEntropy},
+			{otherMsg, entropy},
+		} {
+			other, err := GenerateHedgedNonce(sk, test.msg,
+				bytes.NewReader(test.entropy))
+			if err != nil || other.Equal(k) {
+				t.Fatalf("signer %d, variant %d: nonce didn't diverge", i, j)
+			}
+		}
+		if i > 0 && k.Equal(secNonces[0]) {
+			t.Fatalf("signer %d: two keys gave the same nonce", i)
+		}
+		secNonces[i], pubNonces[i] = k, k.PubKey()
+	}
+
+	pubNonceSum, err := AggregatePublicNonces(curve, pubNonces)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	partials := make([]*Signature, numSigners)
+	for i, sk := range keyVec.skVec {
+		r, s, err := SchnorrPartialSign(curve, msg, sk, keyVec.pkVecSum,
+			secNonces[i], pubNonceSum)
+		if err != nil {
+			t.Fatalf("signer %d: unexpected signing error: %v", i, err)
+		}
+		partials[i] = NewSignature(r, s)
+	}
+	sig, err := SchnorrCombineSigs(curve, partials)
+	if err != nil {
+		t.Fatalf("unexpected combining error: %v", err)
+	}
+	if !Verify(keyVec.pkVecSum, msg, sig.GetR(), sig.GetS()) {
+		t.Fatalf("signature with hedged nonces failed to verify")
+	}
+
+	if _, err := GenerateHedgedNonce(keyVec.skVec[0], msg,
+		bytes.NewReader(entropy[:31])); err == nil {
+		t.Fatalf("generated a nonce from a short read")
+	}
+	if _, err := GenerateHedgedNonce(nil, msg,
+		bytes.NewReader(entropy)); err == nil {
+		t.Fatalf("generated a nonce from a nil key")
+	}
+}
+
+// TestVerifyNonceSet tests that the nonce set check accepts the public nonces
+// of a signing session for their sum, and catches the corruption of the sum
+// simulated by TestSchnorrThresholdSigOnBadPubNonce as well as a changed,
+// missing or extra nonce
+func TestVerifyNonceSet(t *testing.T) {
+	const MAX_SIGNATORIES = 10
+	const NUM_TEST = 5
+
+	tRand := rand.New(rand.NewSource(543212345))
+
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	msg, _ := hex.DecodeString(
+		"d04b98f48e8f8bcc15c6ae5ac050801cd6dcfd428fb5f9e65c4e16e7807340fa")
+
+	for i := 0; i < NUM_TEST; i++ {
+		numKeysForTest := tRand.Intn(MAX_SIGNATORIES-2) + 2
+		schnorrKeyVec := mockUpSchnorrKeyVec(curve, numKeysForTest, msg)
+		nonces := schnorrKeyVec.pubNonceVec
+		if !VerifyNonceSet(nonces, schnorrKeyVec.pubNonceVecSum) {
+			t.Fatalf("test %d: rejected the nonces of the session", i)
+		}
+
+		// simulate corruption causing the final sum(pubNonce_i) to change
+		_, xDelta, yDelta, err := GenerateKey(curve, crand.Reader)
+		if nil != err {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		xBad, yBad := curve.Add(schnorrKeyVec.pubNonceVecSum.GetX(),
+			schnorrKeyVec.pubNonceVecSum.GetY(), xDelta, yDelta)
+		if VerifyNonceSet(nonces, NewPublicKey(curve, xBad, yBad)) {
+			t.Fatalf("test %d: accepted a corrupted nonce sum", i)
+		}
+
+		delta := NewPublicKey(curve, xDelta, yDelta)
+		badNonces := append([]*PublicKey{}, nonces...)
+		badNonces[0] = delta
+		if VerifyNonceSet(badNonces, schnorrKeyVec.pubNonceVecSum) {
+			t.Fatalf("test %d: accepted a swapped nonce", i)
+		}
+		if VerifyNonceSet(nonces[1:], schnorrKeyVec.pubNonceVecSum) {
+			t.Fatalf("test %d: accepted a missing nonce", i)
+		}
+		if VerifyNonceSet(append(badNonces, nonces[0]),
+			schnorrKeyVec.pubNonceVecSum) {
+			t.Fatalf("test %d: accepted an extra nonce", i)
+		}
+	}
+
+	if VerifyNonceSet(nil, nil) {
+		t.Fatalf("accepted an empty nonce set")
+	}
+}
@@ -187,9 +187,11 @@ func mockUpSchnorrKeyVec(curve *TwistedEdwardsCurve, sz int, msg []byte) *Schnor
 	}
 
 	keyVec.pkVecSum = CombinePubkeys(curve, keyVec.pkVec)
-	keyVec.pubNonceVecSum = CombinePubkeys(curve, keyVec.pubNonceVec)
-	if nil == keyVec.pubNonceVecSum {
-		panic("unexpected sum of public nonces")
+	var err error
+	keyVec.pubNonceVecSum, err = AggregatePublicNonces(curve,
+		keyVec.pubNonceVec)
+	if err != nil {
+		panic("unexpected sum of public nonces: " + err.Error())
 	}
 
 	return keyVec
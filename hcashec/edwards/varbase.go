@@ -0,0 +1,239 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// nafWidth is the window width of the NAF scalars of the variable base
+// multiplication. Digits are odd and in (-2^(w-1), 2^(w-1)), so the tables
+// hold the 2^(w-2) odd multiples P, 3P, ..., 15P.
+const nafWidth = 5
+
+// wNAF returns the width nafWidth non-adjacent form of the non-negative k,
+// least significant digit first. At most one in every nafWidth consecutive
+// digits is non-zero.
+func wNAF(k *big.Int) []int8 {
+	k = new(big.Int).Set(k)
+	naf := make([]int8, 0, k.BitLen()+1)
+	window := big.NewInt(1 << nafWidth)
+	mask := big.NewInt(1<<nafWidth - 1)
+	d := new(big.Int)
+	for k.Sign() > 0 {
+		var digit int8
+		if k.Bit(0) == 1 {
+			d.And(k, mask)
+			if d.Int64() >= 1<<(nafWidth-1) {
+				d.Sub(d, window)
+			}
+			digit = int8(d.Int64())
+			k.Sub(k, d)
+		}
+		naf = append(naf, digit)
+		k.Rsh(k, 1)
+	}
+
+	return naf
+}
+
+// oddMultiples fills table with the odd multiples P, 3P, ..., 15P of p for
+// scalar multiplication with wNAF digits.
+func oddMultiples(table *[1 << (nafWidth - 2)]cachedGroupElement,
+	p *edwards25519.ExtendedGroupElement) {
+	var p2 edwards25519.ExtendedGroupElement
+	var c edwards25519.CompletedGroupElement
+	p.Double(&c)
+	c.ToExtended(&p2)
+	var p2Cached cachedGroupElement
+	toCached(&p2Cached, &p2)
+
+	toCached(&table[0], p)
+	acc := *p
+	for i := 1; i < len(table); i++ {
+		geAdd(&c, &acc, &p2Cached)
+		c.ToExtended(&acc)
+		toCached(&table[i], &acc)
+	}
+}
+
+// addNAFDigit adds digit * P to r, where table holds the odd multiples of P
+// and digit is a wNAF digit. Negative digits add the negated multiple, whose
+// cached form swaps y+x and y-x and negates 2dT.
+func addNAFDigit(r *edwards25519.ExtendedGroupElement,
+	table *[1 << (nafWidth - 2)]cachedGroupElement, digit int8) {
+	if digit == 0 {
+		return
+	}
+
+	q := table[(abs8(digit)-1)/2]
+	if digit < 0 {
+		q.yPlusX, q.yMinusX = q.yMinusX, q.yPlusX
+		edwards25519.FeNeg(&q.T2d, &q.T2d)
+	}
+	var c edwards25519.CompletedGroupElement
+	geAdd(&c, r, &q)
+	c.ToExtended(r)
+}
+
+// abs8 returns the absolute value of a wNAF digit.
+func abs8(d int8) int8 {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// VarBaseMult returns k*(Px,Py) where k is a number in big-endian form, like
+// ScalarMult, using the width 5 non-adjacent form of k and a table of 8 odd
+// multiples of the point. That takes one addition for every 6 bits of k on
+// average instead of one for every other bit. It's variable time, both in
+// its additions and its table lookups, so it must only be used with public
+// inputs, such as the public key and challenge of a signature being
+// verified. k isn't reduced, so points outside the prime order subgroup are
+// multiplied correctly as well. Points off the curve give nil. On Ed448 the
+// generic ScalarMult is used.
+func (curve *TwistedEdwardsCurve) VarBaseMult(Px, Py *big.Int,
+	k []byte) (x, y *big.Int) {
+	if curve.isEd448() {
+		return curve.ScalarMult(Px, Py, k)
+	}
+	if Px == nil || Py == nil || !curve.IsOnCurve(Px, Py) {
+		return nil, nil
+	}
+
+	var p edwards25519.ExtendedGroupElement
+	if !p.FromBytes(BigIntPointToEncodedBytes(Px, Py)) {
+		return nil, nil
+	}
+	var table [1 << (nafWidth - 2)]cachedGroupElement
+	oddMultiples(&table, &p)
+
+	naf := wNAF(new(big.Int).SetBytes(k))
+	var r edwards25519.ExtendedGroupElement
+	r.Zero()
+	for i := len(naf) - 1; i >= 0; i-- {
+		var c edwards25519.CompletedGroupElement
+		r.Double(&c)
+		c.ToExtended(&r)
+		addNAFDigit(&r, &table, naf[i])
+	}
+
+	var finalBytes [32]byte
+	r.ToBytes(&finalBytes)
+	x, y, err := curve.EncodedBytesToBigIntPoint(&finalBytes)
+	if err != nil {
+		return nil, nil
+	}
+
+	return x, y
+}
+
+var (
+	// baseOddMultiples holds the odd multiples G, 3G, ..., 15G of the base
+	// point for doubleScalarMultVartime.
+	baseOddMultiples     [1 << (nafWidth - 2)]cachedGroupElement
+	baseOddMultiplesOnce sync.Once
+)
+
+// getBaseOddMultiples returns the odd multiples of the base point, computing
+// them on first use.
+func getBaseOddMultiples() *[1 << (nafWidth - 2)]cachedGroupElement {
+	baseOddMultiplesOnce.Do(func() {
+		curve := Edwards()
+		var g edwards25519.ExtendedGroupElement
+		g.FromBytes(BigIntPointToEncodedBytes(curve.Gx, curve.Gy))
+		oddMultiples(&baseOddMultiples, &g)
+	})
+
+	return &baseOddMultiples
+}
+
+// doubleScalarMultVartime sets r = a*A + b*G, where G is the base point and
+// a and b are little endian scalars, in the argument order of
+// edwards25519.GeDoubleScalarMultVartime. The NAF digits of both scalars
+// are added in a single pass of doublings (Shamir's trick), so the cost is
+// about that of one VarBaseMult. It's variable time and must only be used
+// with public inputs.
+func doubleScalarMultVartime(r *edwards25519.ExtendedGroupElement, a *[32]byte,
+	A *edwards25519.ExtendedGroupElement, b *[32]byte) {
+	doubleScalarMultNAF(r, wNAF(EncodedBytesToBigInt(a)), A,
+		wNAF(EncodedBytesToBigInt(b)))
+}
+
+// doubleScalarMultNAF sets r = a*A + b*G for the scalars a and b given by
+// their NAF digits, which may be of any length, as in
+// doubleScalarMultVartime.
+func doubleScalarMultNAF(r *edwards25519.ExtendedGroupElement, aNAF []int8,
+	A *edwards25519.ExtendedGroupElement, bNAF []int8) {
+	var aTable [1 << (nafWidth - 2)]cachedGroupElement
+	oddMultiples(&aTable, A)
+	bTable := getBaseOddMultiples()
+
+	n := len(aNAF)
+	if len(bNAF) > n {
+		n = len(bNAF)
+	}
+
+	r.Zero()
+	for i := n - 1; i >= 0; i-- {
+		var c edwards25519.CompletedGroupElement
+		r.Double(&c)
+		c.ToExtended(r)
+		if i < len(aNAF) {
+			addNAFDigit(r, &aTable, aNAF[i])
+		}
+		if i < len(bNAF) {
+			addNAFDigit(r, bTable, bNAF[i])
+		}
+	}
+}
+
+// DoubleScalarMult returns k1*G + k2*(Px,Py), where G is the base point and
+// k1 and k2 are numbers in big-endian form, such as sG - hA in signature
+// verification. Both multiplications share their doublings, which makes it
+// about as fast as a single VarBaseMult and much faster than two scalar
+// multiplications and an addition. It's variable time like VarBaseMult, so
+// it must only be used with public inputs. Like VarBaseMult, k2 isn't
+// reduced, so points outside the prime order subgroup are multiplied
+// correctly whatever the length of k2. k1 is reduced mod N, which doesn't
+// change k1*G. Points off the curve give nil, and Ed448 isn't supported.
+func (curve *TwistedEdwardsCurve) DoubleScalarMult(k1, k2 []byte, Px,
+	Py *big.Int) (x, y *big.Int) {
+	if curve.isEd448() || Px == nil || Py == nil || !curve.IsOnCurve(Px, Py) {
+		return nil, nil
+	}
+
+	var p edwards25519.ExtendedGroupElement
+	if !p.FromBytes(BigIntPointToEncodedBytes(Px, Py)) {
+		return nil, nil
+	}
+
+	var r edwards25519.ExtendedGroupElement
+	doubleScalarMultNAF(&r, wNAF(new(big.Int).SetBytes(k2)), &p,
+		wNAF(EncodedBytesToBigInt(scalarBytesLE(k1))))
+
+	var finalBytes [32]byte
+	r.ToBytes(&finalBytes)
+	x, y, err := curve.EncodedBytesToBigIntPoint(&finalBytes)
+	if err != nil {
+		return nil, nil
+	}
+
+	return x, y
+}
+
+// scalarBytesLE converts the big endian k to a 32 byte little endian scalar,
+// reducing it mod N if it doesn't fit.
+func scalarBytesLE(k []byte) *[32]byte {
+	if len(k) > PrivScalarSize {
+		r := ScalarToBytesLE(new(big.Int).SetBytes(k))
+		return &r
+	}
+	return BigIntToEncodedBytes(new(big.Int).SetBytes(k))
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/sha512"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// VerifyMode selects the rule used to verify an Ed25519 signature. RFC 8032
+// allows checking either [S]B = R + [k]A or the equation multiplied by the
+// cofactor, [8][S]B = [8]R + [8][k]A, and implementations differ. The two
+// only disagree on signatures where R or A has a component of small order,
+// which no honest signer produces, but a node accepting a signature that
+// other nodes reject splits the network, so consensus code has to pick one
+// rule and stick to it.
+type VerifyMode int
+
+// These constants define the available verification rules.
+const (
+	// VerifyStrict is the rule of Verify and the one the consensus layer
+	// (through chainec) uses. S must be canonical, R must be encoded
+	// canonically and not be of small order, and the equation is checked
+	// without the cofactor as the reference implementation does. It must
+	// not be changed for consensus validation.
+	VerifyStrict VerifyMode = iota
+
+	// VerifyCofactorless checks [S]B - [k]A = R by comparing encodings,
+	// as the reference Ed25519 implementation does, accepting any R that
+	// decodes to a point. S must be canonical.
+	VerifyCofactorless
+
+	// VerifyCofactored checks [8]([S]B - [k]A - R) = 0 as recommended by
+	// RFC 8032, which also accepts signatures whose R or A has a small
+	// order component. S must be canonical, and so must the encoding of R
+	// as RFC 8032 requires of every point it decodes.
+	VerifyCofactored
+
+	// VerifyPrimeOrder is VerifyStrict which also requires R to be in the
+	// prime order subgroup, so signatures on which the other rules
+	// disagree are rejected whatever the rule of the verifying node. The
+	// subgroup check makes it reject signatures Verify accepts, so it
+	// must not be used for consensus validation.
+	VerifyPrimeOrder
+)
+
+// String returns the VerifyMode as a human-readable name.
+func (m VerifyMode) String() string {
+	switch m {
+	case VerifyStrict:
+		return "VerifyStrict"
+	case VerifyCofactorless:
+		return "VerifyCofactorless"
+	case VerifyCofactored:
+		return "VerifyCofactored"
+	case VerifyPrimeOrder:
+		return "VerifyPrimeOrder"
+	}
+	return "Unknown VerifyMode"
+}
+
+// VerifyWithMode verifies the signature (r, s) of the message 'hash' under
+// the public key pub with the rule selected by mode. VerifyStrict is the
+// same as Verify. Only VerifyStrict supports keys on the Ed448 curve, the
+// other modes reject them.
+func VerifyWithMode(pub *PublicKey, hash []byte, r, s *big.Int,
+	mode VerifyMode) bool {
+	if mode == VerifyStrict {
+		return Verify(pub, hash, r, s)
+	}
+	if mode == VerifyPrimeOrder {
+		return verifyPrimeOrder(pub, hash, r, s)
+	}
+	if mode != VerifyCofactorless && mode != VerifyCofactored {
+		return false
+	}
+
+	if pub == nil || pub.GetX() == nil || pub.GetY() == nil || hash == nil ||
+		r == nil || s == nil {
+		return false
+	}
+	if curve, ok := pub.Curve.(*TwistedEdwardsCurve); !ok || curve == nil ||
+		curve.isEd448() {
+		return false
+	}
+	sig := &Signature{r, s}
+	if !IsCanonical(sig) {
+		return false
+	}
+	sigArray := copyBytes64(sig.Serialize())
+
+	var encodedR [32]byte
+	copy(encodedR[:], sigArray[:32])
+	var R edwards25519.ExtendedGroupElement
+	if !R.FromBytes(&encodedR) {
+		return false
+	}
+	var reencodedR [32]byte
+	R.ToBytes(&reencodedR)
+	if reencodedR != encodedR {
+		return false
+	}
+	encodedA := BigIntPointToEncodedBytes(pub.GetX(), pub.GetY())
+	var A edwards25519.ExtendedGroupElement
+	if !A.FromBytes(encodedA) {
+		return false
+	}
+	edwards25519.FeNeg(&A.X, &A.X)
+	edwards25519.FeNeg(&A.T, &A.T)
+
+	// k = hash512(R || A || M)
+	var hramDigest [64]byte
+	h := sha512.New()
+	h.Write(encodedR[:])
+	h.Write(encodedA[:])
+	h.Write(hash)
+	h.Sum(hramDigest[:0])
+	var k [32]byte
+	edwards25519.ScReduce(&k, &hramDigest)
+
+	// [S]B - [k]A
+	var sBytes [32]byte
+	copy(sBytes[:], sigArray[32:])
+	var proj edwards25519.ProjectiveGroupElement
+	edwards25519.GeDoubleScalarMultVartime(&proj, &k, &A, &sBytes)
+	var checkR [32]byte
+	proj.ToBytes(&checkR)
+
+	if mode == VerifyCofactorless {
+		return checkR == encodedR
+	}
+
+	// [8]([S]B - [k]A - R)
+	var p edwards25519.ExtendedGroupElement
+	if !p.FromBytes(&checkR) {
+		return false
+	}
+	edwards25519.FeNeg(&R.X, &R.X)
+	edwards25519.FeNeg(&R.T, &R.T)
+	var rCached cachedGroupElement
+	toCached(&rCached, &R)
+	var c edwards25519.CompletedGroupElement
+	geAdd(&c, &p, &rCached)
+	var diff edwards25519.ExtendedGroupElement
+	c.ToExtended(&diff)
+	clearCofactor(&diff)
+
+	return geIsIdentity(&diff)
+}
+
+// verifyPrimeOrder verifies the signature (r, s) like Verify, also rejecting
+// it if R has a small order component.
+func verifyPrimeOrder(pub *PublicKey, hash []byte, r, s *big.Int) bool {
+	if pub == nil || r == nil {
+		return false
+	}
+	curve, ok := pub.Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil || curve.isEd448() {
+		return false
+	}
+	if r.Sign() < 0 || r.BitLen() > 256 {
+		return false
+	}
+	var R edwards25519.ExtendedGroupElement
+	if !R.FromBytes(BigIntToEncodedBytes(r)) || !curve.isPrimeOrderPoint(&R) {
+		return false
+	}
+
+	return Verify(pub, hash, r, s)
+}
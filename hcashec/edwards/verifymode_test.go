@@ -0,0 +1,273 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// divergentSig signs msg with the scalar a under the public point (ax, ay)
+// and the nonce point rB + (tx, ty), which needn't be the public key or
+// nonce of a, returning the signature and the challenge k.
+func divergentSig(t *testing.T, curve *TwistedEdwardsCurve, a *big.Int,
+	ax, ay, tx, ty *big.Int, msg []byte) (*Signature, *big.Int) {
+	r, err := NewRandomScalar(curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rx, ry := curve.BaseMult(r.Bytes())
+	rx, ry = curve.Add(rx, ry, tx, ty)
+	encodedR := BigIntPointToEncodedBytes(rx, ry)
+	encodedA := BigIntPointToEncodedBytes(ax, ay)
+
+	var hramDigest [64]byte
+	h := sha512.New()
+	h.Write(encodedR[:])
+	h.Write(encodedA[:])
+	h.Write(msg)
+	h.Sum(hramDigest[:0])
+	var kBytes [32]byte
+	edwards25519.ScReduce(&kBytes, &hramDigest)
+	k := EncodedBytesToBigInt(&kBytes)
+
+	return &Signature{EncodedBytesToBigInt(encodedR), scalarMulAdd(k, a, r)},
+		k
+}
+
+// TestVerifyModes tests that all verification modes accept honest
+// signatures, and that they diverge as documented on signatures whose nonce
+// point or public key has a small order component
+func TestVerifyModes(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	modes := []VerifyMode{VerifyStrict, VerifyCofactorless, VerifyCofactored,
+		VerifyPrimeOrder}
+	b, _ := hex.DecodeString(smallOrderPoints[4])
+	tx, ty, err := curve.EncodedBytesToBigIntPoint(copyBytes(b))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, sk := range mockUpSecKeysByBytes(curve, 5) {
+		pub := sk.PubKey()
+		msg := []byte{byte(i), 'm', 's', 'g'}
+
+		r, s, err := Sign(curve, sk, msg)
+		if err != nil {
+			t.Fatalf("test %d: unexpected signing error: %v", i, err)
+		}
+		for _, mode := range modes {
+			if !VerifyWithMode(pub, msg, r, s, mode) {
+				t.Fatalf("test %d: %v rejected an honest signature", i, mode)
+			}
+			if VerifyWithMode(pub, append(msg, 0), r, s, mode) {
+				t.Fatalf("test %d: %v accepted a signature of another "+
+					"message", i, mode)
+			}
+			nonCanonical := new(big.Int).Add(s, curve.N)
+			if VerifyWithMode(pub, msg, r, nonCanonical, mode) {
+				t.Fatalf("test %d: %v accepted a non canonical S", i, mode)
+			}
+		}
+
+		// R = rB + T with T of order 8 only satisfies the cofactored
+		// equation.
+		a := sk.reducedScalar(curve)
+		sig, _ := divergentSig(t, curve, a, pub.GetX(), pub.GetY(), tx, ty,
+			msg)
+		want := map[VerifyMode]bool{VerifyStrict: false,
+			VerifyCofactorless: false, VerifyCofactored: true,
+			VerifyPrimeOrder: false}
+		for _, mode := range modes {
+			if VerifyWithMode(pub, msg, sig.R, sig.S, mode) != want[mode] {
+				t.Fatalf("test %d: %v on a small order R: got %v, want %v",
+					i, mode, !want[mode], want[mode])
+			}
+		}
+
+		// A = aB + T with T of order 8 satisfies the cofactorless equation
+		// only if 8 divides k.
+		ax, ay := curve.Add(pub.GetX(), pub.GetY(), tx, ty)
+		mixedPub := NewPublicKey(curve, ax, ay)
+		sig, k := divergentSig(t, curve, a, ax, ay, zero, one, msg)
+		eightDivides := new(big.Int).Mod(k, big.NewInt(8)).Sign() == 0
+		want = map[VerifyMode]bool{VerifyStrict: eightDivides,
+			VerifyCofactorless: eightDivides, VerifyCofactored: true,
+			VerifyPrimeOrder: eightDivides}
+		for _, mode := range modes {
+			if VerifyWithMode(mixedPub, msg, sig.R, sig.S,
+				mode) != want[mode] {
+				t.Fatalf("test %d: %v on a small order A component: got "+
+					"%v, want %v", i, mode, !want[mode], want[mode])
+			}
+		}
+	}
+
+	if VerifyWithMode(nil, []byte{1}, one, one, VerifyCofactored) {
+		t.Fatalf("verified with a nil key")
+	}
+	pub := mockUpSecKeysByBytes(curve, 1)[0].PubKey()
+	if VerifyWithMode(pub, []byte{1}, one, one, VerifyMode(4)) {
+		t.Fatalf("verified with an unknown mode")
+	}
+}
+
+// speccheckVectors are the edge case signatures of "Taming the many
+// EdDSAs" (Chalkias, Garillot and Nikolaenko, 2020), published as the
+// cases of ed25519-speccheck, with the result of every verification mode.
+// They cover small and mixed order R and A, S >= N, and non-canonical
+// encodings of R and A.
+var speccheckVectors = []struct {
+	msg, pub, sig string
+	// strict, cofactorless, cofactored and primeOrder are the results of
+	// VerifyStrict, VerifyCofactorless, VerifyCofactored and
+	// VerifyPrimeOrder.
+	strict, cofactorless, cofactored, primeOrder bool
+}{
+	// 0: S = 0, small order A and R.
+	{
+		"8c93255d71dcab10e8f379c26200f3c7bd5f09d9bc3068d3ef4edeb4853022b6",
+		"c7176a703d4dd84fba3c0b760d10670f2a2053fa2c39ccc64ec7fd7792ac03fa",
+		"c7176a703d4dd84fba3c0b760d10670f2a2053fa2c39ccc64ec7fd7792ac037a" +
+			"0000000000000000000000000000000000000000000000000000000000000000",
+		false, true, true, false,
+	},
+	// 1: small order A, mixed order R.
+	{
+		"9bd9f44f4dcc75bd531b56b2cd280b0bb38fc1cd6d1230e14861d861de092e79",
+		"c7176a703d4dd84fba3c0b760d10670f2a2053fa2c39ccc64ec7fd7792ac03fa",
+		"f7badec5b8abeaf699583992219b7b223f1df3fbbea919844e3f7c554a43dd43" +
+			"a5bb704786be79fc476f91d3f3f89b03984d8068dcf1bb7dfc6637b45450ac04",
+		true, true, true, false,
+	},
+	// 2: mixed order A, small order R.
+	{
+		"aebf3f2601a0c8c5d39cc7d8911642f740b78168218da8471772b35f9d35b9ab",
+		"f7badec5b8abeaf699583992219b7b223f1df3fbbea919844e3f7c554a43dd43",
+		"c7176a703d4dd84fba3c0b760d10670f2a2053fa2c39ccc64ec7fd7792ac03fa" +
+			"8c4bd45aecaca5b24fb97bc10ac27ac8751a7dfe1baff8b953ec9f5833ca260e",
+		false, true, true, false,
+	},
+	// 3: mixed order A and R, passing both equations.
+	{
+		"9bd9f44f4dcc75bd531b56b2cd280b0bb38fc1cd6d1230e14861d861de092e79",
+		"cdb267ce40c5cd45306fa5d2f29731459387dbf9eb933b7bd5aed9a765b88d4d",
+		"9046a64750444938de19f227bb80485e92b83fdb4b6506c160484c016cc1852f" +
+			"87909e14428a7a1d62e9f22f3d3ad7802db02eb2e688b6c52fcd6648a98bd009",
+		true, true, true, false,
+	},
+	// 4: mixed order A and R, passing only the cofactored equation.
+	{
+		"e47d62c63f830dc7a6851a0b1f33ae4bb2f507fb6cffec4011eaccd55b53f56c",
+		"cdb267ce40c5cd45306fa5d2f29731459387dbf9eb933b7bd5aed9a765b88d4d",
+		"160a1cb0dc9c0258cd0a7d23e94d8fa878bcb1925f2c64246b2dee1796bed512" +
+			"5ec6bc982a269b723e0668e540911a9a6a58921d6925e434ab10aa7940551a09",
+		false, false, true, false,
+	},
+	// 5: mixed order A, prime order R, passing only the cofactored
+	// equation.
+	{
+		"e47d62c63f830dc7a6851a0b1f33ae4bb2f507fb6cffec4011eaccd55b53f56c",
+		"cdb267ce40c5cd45306fa5d2f29731459387dbf9eb933b7bd5aed9a765b88d4d",
+		"21122a84e0b5fca4052f5b1235c80a537878b38f3142356b2c2384ebad4668b7" +
+			"e40bc836dac0f71076f9abe3a53f9c03c1ceeeddb658d0030494ace586687405",
+		false, false, true, false,
+	},
+	// 6: S > N, valid once S is reduced.
+	{
+		"85e241a07d148b41e47d62c63f830dc7a6851a0b1f33ae4bb2f507fb6cffec40",
+		"442aad9f089ad9e14647b1ef9099a1ff4798d78589e66f28eca69c11f582a623",
+		"e96f66be976d82e60150baecff9906684aebb1ef181f67a7189ac78ea23b6c0e" +
+			"547f7690a0e2ddcd04d87dbc3490dc19b3b3052f7ff0538cb68afb369ba3a514",
+		false, false, false, false,
+	},
+	// 7: S much larger than N, valid once S is reduced.
+	{
+		"85e241a07d148b41e47d62c63f830dc7a6851a0b1f33ae4bb2f507fb6cffec40",
+		"442aad9f089ad9e14647b1ef9099a1ff4798d78589e66f28eca69c11f582a623",
+		"8ce5b96c8f26d0ab6c47958c9e68b937104cd36e13c33566acd2fe8d38aa1942" +
+			"7e71f98a473474f2f13f06f97c20d58cc3f54b8bd0d272f42b695dd7e89a8c22",
+		false, false, false, false,
+	},
+	// 8: mixed order A, small order R.
+	{
+		"9bedc267423725d473888631ebf45988bad3db83851ee85c85e241a07d148b41",
+		"f7badec5b8abeaf699583992219b7b223f1df3fbbea919844e3f7c554a43dd43",
+		"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f" +
+			"03be9678ac102edcd92b0210bb34d7428d12ffc5df5f37e359941266a4e35f0f",
+		false, true, true, false,
+	},
+	// 9: mixed order A, non-canonical encoding of a small order R, which
+	// RFC 8032 decoding rejects.
+	{
+		"9bedc267423725d473888631ebf45988bad3db83851ee85c85e241a07d148b41",
+		"f7badec5b8abeaf699583992219b7b223f1df3fbbea919844e3f7c554a43dd43",
+		"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff" +
+			"ca8c5b64cd208982aa38d4936621a4775aa233aa0505711d8fdcfdaa943d4908",
+		false, false, false, false,
+	},
+	// 10: non-canonical encoding of a small order A, passing only the
+	// cofactored equation with the encoding as given. The modes take a
+	// decoded key and hash its canonical encoding instead, with which the
+	// order 2 A passes both.
+	{
+		"e96b7021eb39c1a163b6da4e3093dcd3f21387da4cc4572be588fafae23c155b",
+		"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"a9d55260f765261eb9b84e106f665e00b867287a761990d7135963ee0a7d59dc" +
+			"a5bb704786be79fc476f91d3f3f89b03984d8068dcf1bb7dfc6637b45450ac04",
+		true, true, true, false,
+	},
+	// 11: non-canonical encoding of a small order A, passing both
+	// equations with the encoding as given. With the canonical encoding
+	// hashed by the modes it only passes the cofactored one.
+	{
+		"39a591f5321bbe07fd5a23dc2f39d025d74526615746727ceefd6e82ae65c06f",
+		"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"a9d55260f765261eb9b84e106f665e00b867287a761990d7135963ee0a7d59dc" +
+			"a5bb704786be79fc476f91d3f3f89b03984d8068dcf1bb7dfc6637b45450ac04",
+		false, false, true, false,
+	},
+}
+
+// TestVerifyModesSpeccheck tests every verification mode against the edge
+// case vectors of "Taming the many EdDSAs". The public keys are decoded
+// without the subgroup check of ParsePubKey, which would reject most of
+// them before any mode is reached
+func TestVerifyModesSpeccheck(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	for i, v := range speccheckVectors {
+		msg, _ := hex.DecodeString(v.msg)
+		pubBytes, _ := hex.DecodeString(v.pub)
+		sigBytes, _ := hex.DecodeString(v.sig)
+
+		var pub *PublicKey
+		x, y, err := curve.EncodedBytesToBigIntPoint(copyBytes(pubBytes))
+		if err == nil {
+			pub = NewPublicKey(curve, x, y)
+		}
+		r := EncodedBytesToBigInt(copyBytes(sigBytes[:32]))
+		s := EncodedBytesToBigInt(copyBytes(sigBytes[32:]))
+
+		want := map[VerifyMode]bool{VerifyStrict: v.strict,
+			VerifyCofactorless: v.cofactorless, VerifyCofactored: v.cofactored,
+			VerifyPrimeOrder: v.primeOrder}
+		for _, mode := range []VerifyMode{VerifyStrict, VerifyCofactorless,
+			VerifyCofactored, VerifyPrimeOrder} {
+			if got := VerifyWithMode(pub, msg, r, s, mode); got != want[mode] {
+				t.Errorf("vector %d: %v got %v, want %v", i, mode, got,
+					want[mode])
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/sha512"
+	"hash"
+	"math/big"
+)
+
+// StreamVerifier verifies a signature over a message which is written to it
+// incrementally, so that large messages never have to be held in memory as
+// a whole. Since the Ed25519 challenge is hash512(R || A || M), the message
+// can be fed straight into the challenge hash as it arrives.
+//
+// StreamVerifier implements hash.Hash, where the running sum is the
+// challenge digest. Call Verify once the complete message has been written.
+type StreamVerifier struct {
+	pub *PublicKey
+	sig *[64]byte
+	h   hash.Hash
+}
+
+// Ensure StreamVerifier implements hash.Hash.
+var _ hash.Hash = (*StreamVerifier)(nil)
+
+// VerifyStream returns a StreamVerifier for the signature (r, s) under the
+// public key pub. The message to verify is written to the returned
+// StreamVerifier, which yields the same result as the one-shot Verify over
+// the concatenation of everything written to it.
+func VerifyStream(curve *TwistedEdwardsCurve, pub *PublicKey, r,
+	s *big.Int) *StreamVerifier {
+	sv := &StreamVerifier{
+		pub: pub,
+		h:   sha512.New(),
+	}
+	if sig := (&Signature{r, s}); IsCanonical(sig) {
+		sv.sig = copyBytes64(sig.Serialize())
+	}
+	sv.Reset()
+
+	return sv
+}
+
+// Write adds more of the message to the verifier. It never returns an
+// error.
+func (sv *StreamVerifier) Write(p []byte) (int, error) {
+	return sv.h.Write(p)
+}
+
+// Sum appends the challenge digest over the message written so far to b.
+// It does not change the underlying state.
+func (sv *StreamVerifier) Sum(b []byte) []byte {
+	return sv.h.Sum(b)
+}
+
+// Reset discards the message written so far.
+func (sv *StreamVerifier) Reset() {
+	sv.h.Reset()
+	if sv.sig == nil || sv.pub == nil || sv.pub.GetX() == nil ||
+		sv.pub.GetY() == nil {
+		return
+	}
+
+	// h = hash512(R || A || M)
+	sv.h.Write(sv.sig[:32])
+	sv.h.Write(BigIntPointToEncodedBytes(sv.pub.GetX(), sv.pub.GetY())[:])
+}
+
+// Size returns the size of the challenge digest.
+func (sv *StreamVerifier) Size() int {
+	return sv.h.Size()
+}
+
+// BlockSize returns the block size of the challenge hash.
+func (sv *StreamVerifier) BlockSize() int {
+	return sv.h.BlockSize()
+}
+
+// Verify reports whether the signature is valid for the message written so
+// far. It does not change the underlying state, so more of the message may
+// be written and verified afterwards.
+func (sv *StreamVerifier) Verify() bool {
+	if sv.sig == nil || sv.pub == nil || sv.pub.GetX() == nil ||
+		sv.pub.GetY() == nil {
+		return false
+	}
+
+	var hramDigest [64]byte
+	sv.h.Sum(hramDigest[:0])
+	return verifyHram(sv.pub, sv.sig, &hramDigest)
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestVerifyStream tests that streaming and one-shot verification agree
+func TestVerifyStream(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+
+	sks := mockUpSecKeysByBytes(curve, 4)
+	for i, sk := range sks {
+		pkX, pkY := sk.Public()
+		pk := NewPublicKey(curve, pkX, pkY)
+
+		// Include the zero length message.
+		msg := make([]byte, i*10000)
+		r.Read(msg)
+		sigR, sigS, err := Sign(curve, sk, msg)
+		if err != nil {
+			t.Fatalf("unexpected signing error: %v", err)
+		}
+
+		sv := VerifyStream(curve, pk, sigR, sigS)
+		for rest := msg; len(rest) > 0; {
+			n := r.Intn(4096) + 1
+			if n > len(rest) {
+				n = len(rest)
+			}
+			sv.Write(rest[:n])
+			rest = rest[n:]
+		}
+		if sv.Verify() != Verify(pk, msg, sigR, sigS) || !sv.Verify() {
+			t.Fatalf("streaming verification mismatch for message %v", i)
+		}
+
+		// Any additional data breaks the signature, and resetting
+		// starts over.
+		sv.Write([]byte{0x00})
+		if sv.Verify() {
+			t.Fatalf("verification succeeded on the wrong message")
+		}
+		sv.Reset()
+		sv.Write(msg)
+		if !sv.Verify() {
+			t.Fatalf("verification failed after reset")
+		}
+	}
+
+	// Missing signature values never verify.
+	sk := sks[0]
+	pkX, pkY := sk.Public()
+	if VerifyStream(curve, NewPublicKey(curve, pkX, pkY), nil, nil).Verify() {
+		t.Fatalf("nil signature verified")
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// These constants define the sizes of the VRF proofs and outputs.
+const (
+	// VRFProofSize is the size of a VRF proof, Gamma || c || s.
+	VRFProofSize = 32 + vrfChallengeSize + 32
+
+	// VRFOutputSize is the size of the VRF output beta.
+	VRFOutputSize = sha512.Size
+
+	// vrfChallengeSize is the size of the truncated challenge c.
+	vrfChallengeSize = 16
+)
+
+// vrfSuite is the suite string of ECVRF-EDWARDS25519-SHA512-TAI.
+const vrfSuite = 0x03
+
+// vrfHashToCurve implements ECVRF_encode_to_curve_try_and_increment, hashing
+// the public key and alpha with a one byte counter until the digest decodes
+// to a point, which is then multiplied by the cofactor.
+func vrfHashToCurve(pub []byte,
+	alpha []byte) (*edwards25519.ExtendedGroupElement, error) {
+	for ctr := 0; ctr < 256; ctr++ {
+		var digest [64]byte
+		h := sha512.New()
+		h.Write([]byte{vrfSuite, 0x01})
+		h.Write(pub)
+		h.Write(alpha)
+		h.Write([]byte{byte(ctr), 0x00})
+		h.Sum(digest[:0])
+
+		var s [32]byte
+		copy(s[:], digest[:32])
+		p := new(edwards25519.ExtendedGroupElement)
+		if !p.FromBytes(&s) {
+			continue
+		}
+		clearCofactor(p)
+
+		return p, nil
+	}
+
+	return nil, fmt.Errorf("failed to hash to the curve")
+}
+
+// vrfChallenge implements ECVRF_challenge_generation, hashing the public key
+// and the points H, Gamma, U = kB and V = kH into the truncated challenge c,
+// returned as a 32 byte little endian scalar.
+func vrfChallenge(pub []byte,
+	points ...*edwards25519.ExtendedGroupElement) *[32]byte {
+	var digest [64]byte
+	h := sha512.New()
+	h.Write([]byte{vrfSuite, 0x02})
+	h.Write(pub)
+	for _, p := range points {
+		var s [32]byte
+		p.ToBytes(&s)
+		h.Write(s[:])
+	}
+	h.Write([]byte{0x00})
+	h.Sum(digest[:0])
+
+	c := new([32]byte)
+	copy(c[:], digest[:vrfChallengeSize])
+	return c
+}
+
+// vrfProofToHash implements ECVRF_proof_to_hash, computing the output
+// beta = hash512(suite || 0x03 || [8]Gamma || 0x00).
+func vrfProofToHash(gamma *edwards25519.ExtendedGroupElement) []byte {
+	g := *gamma
+	clearCofactor(&g)
+	var s [32]byte
+	g.ToBytes(&s)
+
+	h := sha512.New()
+	h.Write([]byte{vrfSuite, 0x03})
+	h.Write(s[:])
+	h.Write([]byte{0x00})
+	return h.Sum(nil)
+}
+
+// ProveVRF evaluates the verifiable random function ECVRF-EDWARDS25519-
+// SHA512-TAI of RFC 9381 on alpha with the private key priv, returning the
+// pseudorandom output beta and the proof that beta was computed correctly.
+// Anyone holding the public key can check the proof with VerifyVRF, but
+// nobody can predict beta without the private key. The output is
+// deterministic, so the same key and alpha always give the same beta. The
+// private key must hold its secret seed, since the nonce is derived from it
+// as in RFC 8032.
+func ProveVRF(curve *TwistedEdwardsCurve, priv *PrivateKey,
+	alpha []byte) (beta, proof []byte, err error) {
+	if priv == nil {
+		return nil, nil, fmt.Errorf("private key is nil")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, nil, err
+	}
+	if priv.secret == nil {
+		return nil, nil, fmt.Errorf("private key has no secret seed to " +
+			"derive the nonce from")
+	}
+
+	pubX, pubY := priv.Public()
+	pub := BigIntPointToEncodedBytes(pubX, pubY)
+	h, err := vrfHashToCurve(pub[:], alpha)
+	if err != nil {
+		return nil, nil, err
+	}
+	var hString [32]byte
+	h.ToBytes(&hString)
+
+	// Gamma = xH
+	x := priv.reducedScalar(curve)
+	defer x.SetInt64(0)
+	xBE := BigIntToEncodedBytesNoReverse(x)
+	defer zeroSlice(xBE[:])
+	var gamma edwards25519.ExtendedGroupElement
+	geScalarMultConstantTime(&gamma, h, xBE[:])
+
+	// k = hash512(hash512(seed)[32:] || H) mod N
+	expanded := sha512.Sum512(priv.secret[:])
+	defer zeroSlice(expanded[:])
+	var kDigest [64]byte
+	kh := sha512.New()
+	kh.Write(expanded[32:])
+	kh.Write(hString[:])
+	kh.Sum(kDigest[:0])
+	defer zeroSlice(kDigest[:])
+	var kLE [32]byte
+	edwards25519.ScReduce(&kLE, &kDigest)
+	defer zeroSlice(kLE[:])
+	kBE := kLE
+	reverse(&kBE)
+	defer zeroSlice(kBE[:])
+
+	// U = kB, V = kH
+	var u, v edwards25519.ExtendedGroupElement
+	edwards25519.GeScalarMultBase(&u, &kLE)
+	geScalarMultConstantTime(&v, h, kBE[:])
+
+	// s = k + cx
+	c := vrfChallenge(pub[:], h, &gamma, &u, &v)
+	xLE := BigIntToEncodedBytes(x)
+	defer zeroSlice(xLE[:])
+	var s [32]byte
+	edwards25519.ScMulAdd(&s, c, xLE, &kLE)
+
+	var gammaBytes [32]byte
+	gamma.ToBytes(&gammaBytes)
+	proof = make([]byte, VRFProofSize)
+	copy(proof, gammaBytes[:])
+	copy(proof[32:], c[:vrfChallengeSize])
+	copy(proof[32+vrfChallengeSize:], s[:])
+
+	return vrfProofToHash(&gamma), proof, nil
+}
+
+// VerifyVRF checks the VRF proof of RFC 9381 for alpha under the public key
+// pub, as produced by ProveVRF, and returns the output beta if it's valid.
+// Public keys of small order are rejected, since they would let their owner
+// prove any output.
+func VerifyVRF(curve *TwistedEdwardsCurve, pub *PublicKey, alpha,
+	proof []byte) (beta []byte, ok bool) {
+	if pub == nil || pub.GetX() == nil || pub.GetY() == nil ||
+		len(proof) != VRFProofSize {
+		return nil, false
+	}
+
+	pubBytes := BigIntPointToEncodedBytes(pub.GetX(), pub.GetY())
+	var y edwards25519.ExtendedGroupElement
+	if !y.FromBytes(pubBytes) {
+		return nil, false
+	}
+	y8 := y
+	clearCofactor(&y8)
+	if geIsIdentity(&y8) {
+		return nil, false
+	}
+
+	var gammaBytes [32]byte
+	copy(gammaBytes[:], proof[:32])
+	var gamma edwards25519.ExtendedGroupElement
+	if !gamma.FromBytes(&gammaBytes) {
+		return nil, false
+	}
+	var c, s [32]byte
+	copy(c[:], proof[32:32+vrfChallengeSize])
+	copy(s[:], proof[32+vrfChallengeSize:])
+	if !scMinimal(s[:]) {
+		return nil, false
+	}
+
+	h, err := vrfHashToCurve(pubBytes[:], alpha)
+	if err != nil {
+		return nil, false
+	}
+
+	// U = sB - cY
+	negY := y
+	edwards25519.FeNeg(&negY.X, &negY.X)
+	edwards25519.FeNeg(&negY.T, &negY.T)
+	var uProj edwards25519.ProjectiveGroupElement
+	edwards25519.GeDoubleScalarMultVartime(&uProj, &c, &negY, &s)
+	var uBytes [32]byte
+	uProj.ToBytes(&uBytes)
+	var u edwards25519.ExtendedGroupElement
+	if !u.FromBytes(&uBytes) {
+		return nil, false
+	}
+
+	// V = sH - cGamma
+	negC := BigIntToEncodedBytes(new(big.Int).Sub(curve.N,
+		EncodedBytesToBigInt(&c)))
+	var v edwards25519.ExtendedGroupElement
+	multiScalarMultVartime(&v, []*[32]byte{&s, negC},
+		[]*edwards25519.ExtendedGroupElement{h, &gamma})
+
+	if *vrfChallenge(pubBytes[:], h, &gamma, &u, &v) != c {
+		return nil, false
+	}
+
+	return vrfProofToHash(&gamma), true
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// vrfTestVectors are the ECVRF-EDWARDS25519-SHA512-TAI test vectors of RFC
+// 9381 appendix B.3, which use the keys of the RFC 8032 test vectors.
+var vrfTestVectors = []struct {
+	sk    string
+	pk    string
+	alpha string
+	pi    string
+	beta  string
+}{
+	{
+		sk:    "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+		pk:    "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+		alpha: "",
+		pi: "8657106690b5526245a92b003bb079ccd1a92130477671f6fc01ad16f26f72" +
+			"3f26f8a57ccaed74ee1b190bed1f479d9727d2d0f9b005a6e456a35d4fb0da" +
+			"ab1268a1b0db10836d9826a528ca76567805",
+		beta: "90cf1df3b703cce59e2a35b925d411164068269d7b2d29f3301c03dd757876" +
+			"ff66b71dda49d2de59d03450451af026798e8f81cd2e333de5cdf4f3e140fd" +
+			"d8ae",
+	},
+	{
+		sk:    "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+		pk:    "3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c",
+		alpha: "72",
+		pi: "f3141cd382dc42909d19ec5110469e4feae18300e94f304590abdced48aed5" +
+			"933bf0864a62558b3ed7f2fea45c92a465301b3bbf5e3e54ddf2d935be3b67" +
+			"926da3ef39226bbc355bdc9850112c8f4b02",
+		beta: "eb4440665d3891d668e7e0fcaf587f1b4bd7fbfe99d0eb2211ccec90496310" +
+			"eb5e33821bc613efb94db5e5b54c70a848a0bef4553a41befc57663b56373a" +
+			"5031",
+	},
+	{
+		sk:    "c5aa8df43f9f837bedb7442f31dcb7b166d38535076f094b85ce3a2e0b4458f7",
+		pk:    "fc51cd8e6218a1a38da47ed00230f0580816ed13ba3303ac5deb911548908025",
+		alpha: "af82",
+		pi: "9bc0f79119cc5604bf02d23b4caede71393cedfbb191434dd016d30177ccbf" +
+			"8096bb474e53895c362d8628ee9f9ea3c0e52c7a5c691b6c18c9979866568a" +
+			"dd7a2d41b00b05081ed0f58ee5e31b3a970e",
+		beta: "645427e5d00c62a23fb703732fa5d892940935942101e456ecca7bb217c61c" +
+			"452118fec1219202a0edcf038bb6373241578be7217ba85a2687f7a0310b2d" +
+			"f19f",
+	},
+}
+
+// TestVRF tests proving and verifying against the RFC 9381 test vectors
+func TestVRF(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	for i, v := range vrfTestVectors {
+		sk, _ := hex.DecodeString(v.sk)
+		wantPk, _ := hex.DecodeString(v.pk)
+		alpha, _ := hex.DecodeString(v.alpha)
+		wantPi, _ := hex.DecodeString(v.pi)
+		wantBeta, _ := hex.DecodeString(v.beta)
+
+		priv, pub := PrivKeyFromSecret(curve, sk)
+		if priv == nil {
+			t.Fatalf("test %d: failed to derive the key", i)
+		}
+		if !bytes.Equal(pub.Serialize(), wantPk) {
+			t.Fatalf("test %d: got public key %x, want %x", i,
+				pub.Serialize(), wantPk)
+		}
+
+		beta, pi, err := ProveVRF(curve, priv, alpha)
+		if err != nil {
+			t.Fatalf("test %d: unexpected proving error: %v", i, err)
+		}
+		if !bytes.Equal(pi, wantPi) {
+			t.Fatalf("test %d: got proof %x, want %x", i, pi, wantPi)
+		}
+		if !bytes.Equal(beta, wantBeta) {
+			t.Fatalf("test %d: got output %x, want %x", i, beta, wantBeta)
+		}
+
+		gotBeta, ok := VerifyVRF(curve, pub, alpha, pi)
+		if !ok {
+			t.Fatalf("test %d: proof failed to verify", i)
+		}
+		if !bytes.Equal(gotBeta, wantBeta) {
+			t.Fatalf("test %d: verified output %x, want %x", i, gotBeta,
+				wantBeta)
+		}
+
+		// Any tampering must be caught.
+		if _, ok := VerifyVRF(curve, pub, []byte("other"), pi); ok {
+			t.Fatalf("test %d: proof verified for the wrong input", i)
+		}
+		other := vrfTestVectors[(i+1)%len(vrfTestVectors)]
+		otherPk, _ := hex.DecodeString(other.pk)
+		otherPub, _ := ParsePubKey(curve, otherPk)
+		if _, ok := VerifyVRF(curve, otherPub, alpha, pi); ok {
+			t.Fatalf("test %d: proof verified for the wrong key", i)
+		}
+		for _, pos := range []int{0, 32, VRFProofSize - 1} {
+			tampered := append([]byte(nil), pi...)
+			tampered[pos] ^= 0x01
+			if _, ok := VerifyVRF(curve, pub, alpha, tampered); ok {
+				t.Fatalf("test %d: proof with byte %d flipped verified",
+					i, pos)
+			}
+		}
+		if _, ok := VerifyVRF(curve, pub, alpha, pi[:VRFProofSize-1]); ok {
+			t.Fatalf("test %d: truncated proof verified", i)
+		}
+	}
+
+	// Small order public keys are rejected.
+	for _, str := range smallOrderPoints {
+		b, _ := hex.DecodeString(str)
+		x, y, err := curve.EncodedBytesToBigIntPoint(copyBytes(b))
+		if err != nil {
+			t.Fatalf("vector %v is not a point: %v", str, err)
+		}
+		pi, _ := hex.DecodeString(vrfTestVectors[0].pi)
+		if _, ok := VerifyVRF(curve, NewPublicKey(curve, x, y), nil,
+			pi); ok {
+			t.Fatalf("proof verified for small order key %v", str)
+		}
+	}
+}
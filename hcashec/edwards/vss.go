@@ -0,0 +1,74 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/rand"
+	"math/big"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// SplitSecretVerifiable splits secret into n Shamir shares like SplitSecret,
+// and additionally returns the Feldman commitments C_j = a_j*G to the t
+// coefficients of the polynomial, C_0 being the public key of the secret.
+// The dealer publishes the commitments so that every recipient can check
+// its share with VerifyShare, which prevents a malicious dealer from handing
+// out shares that don't recombine to a single secret. The commitments reveal
+// secret*G, but nothing more about the secret.
+func SplitSecretVerifiable(curve *TwistedEdwardsCurve, secret *big.Int, t,
+	n int) ([]*Share, []*PublicKey, error) {
+	return splitSecret(curve, rand.Reader, secret, t, n)
+}
+
+// VerifyShare checks the share against the Feldman commitments of the
+// dealer, as returned by SplitSecretVerifiable, that is
+// share.Value*G = sum(Index^j * C_j). The number of commitments must match
+// the threshold of the share.
+func VerifyShare(share *Share, commitments []*PublicKey) bool {
+	if share == nil || share.Value == nil || share.Index == 0 ||
+		len(commitments) == 0 || len(commitments) != share.Threshold {
+		return false
+	}
+	curve, ok := commitments[0].Curve.(*TwistedEdwardsCurve)
+	if !ok || curve == nil || curve.isEd448() {
+		return false
+	}
+	if share.Value.Sign() < 0 || share.Value.Cmp(curve.N) >= 0 {
+		return false
+	}
+
+	// sum(Index^j * C_j)
+	x := new(big.Int).SetUint64(uint64(share.Index))
+	power := big.NewInt(1)
+	weights := make([]*[32]byte, len(commitments))
+	points := make([]*edwards25519.ExtendedGroupElement, len(commitments))
+	for j, c := range commitments {
+		if c == nil || c.GetX() == nil || c.GetY() == nil {
+			return false
+		}
+		points[j] = new(edwards25519.ExtendedGroupElement)
+		if !points[j].FromBytes(BigIntPointToEncodedBytes(c.GetX(),
+			c.GetY())) {
+			return false
+		}
+		weights[j] = BigIntToEncodedBytes(power)
+		power = scalarMulAdd(power, x, zero)
+	}
+	var expected edwards25519.ExtendedGroupElement
+	multiScalarMultVartime(&expected, weights, points)
+
+	// share.Value*G
+	valueLE := BigIntToEncodedBytes(share.Value)
+	defer zeroSlice(valueLE[:])
+	var got edwards25519.ExtendedGroupElement
+	edwards25519.GeScalarMultBase(&got, valueLE)
+
+	var expectedBytes, gotBytes [32]byte
+	expected.ToBytes(&expectedBytes)
+	got.ToBytes(&gotBytes)
+
+	return expectedBytes == gotBytes
+}
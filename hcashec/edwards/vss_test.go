@@ -0,0 +1,76 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// TestVerifyShare tests that honestly dealt shares verify against the
+// Feldman commitments of the dealer, and that tampered shares and
+// commitments are detected
+func TestVerifyShare(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+	r := rand.New(rand.NewSource(54321))
+
+	secret, err := NewRandomScalar(curve, r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	shares, commitments, err := splitSecret(curve, r, secret, 3, 5)
+	if err != nil {
+		t.Fatalf("unexpected split error: %v", err)
+	}
+
+	// C_0 is the public key of the secret.
+	secretBytes := BigIntToEncodedBytesNoReverse(secret)
+	_, secretPub, err := PrivKeyFromScalar(curve, secretBytes[:])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if commitments[0].GetX().Cmp(secretPub.GetX()) != 0 ||
+		commitments[0].GetY().Cmp(secretPub.GetY()) != 0 {
+		t.Fatalf("first commitment is not the public key of the secret")
+	}
+
+	for i, share := range shares {
+		if !VerifyShare(share, commitments) {
+			t.Fatalf("share %d failed to verify", i)
+		}
+
+		// A tampered share is detected.
+		tampered := *share
+		tampered.Value = new(big.Int).Add(share.Value, one)
+		tampered.Value.Mod(tampered.Value, curve.N)
+		if VerifyShare(&tampered, commitments) {
+			t.Fatalf("tampered share %d verified", i)
+		}
+
+		// So is a share claiming another index.
+		moved := *share
+		moved.Index++
+		if VerifyShare(&moved, commitments) {
+			t.Fatalf("share %d verified at index %v", i, moved.Index)
+		}
+	}
+
+	// Commitments of another polynomial don't match.
+	_, other, err := splitSecret(curve, r, secret, 3, 5)
+	if err != nil {
+		t.Fatalf("unexpected split error: %v", err)
+	}
+	if VerifyShare(shares[0], other) {
+		t.Fatalf("share verified against the commitments of another split")
+	}
+	if VerifyShare(shares[0], commitments[:2]) {
+		t.Fatalf("share verified against too few commitments")
+	}
+	if VerifyShare(nil, commitments) || VerifyShare(shares[0], nil) {
+		t.Fatalf("verified nil input")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/agl/ed25519/edwards25519"
+)
+
+// X25519Size is the size of X25519 scalars, u-coordinates and shared
+// secrets.
+const X25519Size = 32
+
+// x25519A24 is (486662 - 2) / 4, the curve constant of the Montgomery
+// ladder step.
+var x25519A24 = edwards25519.FieldElement{121665}
+
+// x25519Ladder computes the u-coordinate of k*P, where P is the point with
+// u-coordinate u and k is a little endian scalar used as is, with the
+// constant time Montgomery ladder of RFC 7748.
+func x25519Ladder(out, k, u *[32]byte) {
+	var x1, x2, z2, x3, z3 edwards25519.FieldElement
+	edwards25519.FeFromBytes(&x1, u)
+	edwards25519.FeOne(&x2)
+	edwards25519.FeZero(&z2)
+	edwards25519.FeCopy(&x3, &x1)
+	edwards25519.FeOne(&z3)
+
+	var a, aa, b, bb, e, c, d, da, cb edwards25519.FieldElement
+	swap := int32(0)
+	for t := 254; t >= 0; t-- {
+		kt := int32(k[t/8]>>uint(t%8)) & 1
+		swap ^= kt
+		feCondSwap(&x2, &x3, swap)
+		feCondSwap(&z2, &z3, swap)
+		swap = kt
+
+		edwards25519.FeAdd(&a, &x2, &z2)
+		edwards25519.FeSquare(&aa, &a)
+		edwards25519.FeSub(&b, &x2, &z2)
+		edwards25519.FeSquare(&bb, &b)
+		edwards25519.FeSub(&e, &aa, &bb)
+		edwards25519.FeAdd(&c, &x3, &z3)
+		edwards25519.FeSub(&d, &x3, &z3)
+		edwards25519.FeMul(&da, &d, &a)
+		edwards25519.FeMul(&cb, &c, &b)
+
+		edwards25519.FeAdd(&x3, &da, &cb)
+		edwards25519.FeSquare(&x3, &x3)
+		edwards25519.FeSub(&z3, &da, &cb)
+		edwards25519.FeSquare(&z3, &z3)
+		edwards25519.FeMul(&z3, &z3, &x1)
+		edwards25519.FeMul(&x2, &aa, &bb)
+		edwards25519.FeMul(&z2, &x25519A24, &e)
+		edwards25519.FeAdd(&z2, &z2, &aa)
+		edwards25519.FeMul(&z2, &z2, &e)
+	}
+	feCondSwap(&x2, &x3, swap)
+	feCondSwap(&z2, &z3, swap)
+
+	fieldInverse(&z2, &z2)
+	edwards25519.FeMul(&x2, &x2, &z2)
+	edwards25519.FeToBytes(out, &x2)
+}
+
+// x25519Checked runs the ladder and rejects an all zero result, which means
+// the point had small order (or the scalar was a multiple of its order), so
+// the output doesn't depend on the secret.
+func x25519Checked(k, u *[32]byte) ([]byte, error) {
+	var out [32]byte
+	x25519Ladder(&out, k, u)
+	if subtle.ConstantTimeCompare(out[:], make([]byte, X25519Size)) == 1 {
+		return nil, fmt.Errorf("shared secret is zero, the peer public key " +
+			"has low order")
+	}
+
+	return out[:], nil
+}
+
+// X25519 computes the RFC 7748 X25519 function of the 32 byte scalar and
+// u-coordinate, both little endian. The scalar is clamped and the top bit of
+// u is ignored, as the RFC specifies. An error is returned if the result is
+// all zeros, which happens for low order u.
+func X25519(scalar, u []byte) ([]byte, error) {
+	if len(scalar) != X25519Size {
+		return nil, fmt.Errorf("bad scalar size; have %v, want %v",
+			len(scalar), X25519Size)
+	}
+	if len(u) != X25519Size {
+		return nil, fmt.Errorf("bad u-coordinate size; have %v, want %v",
+			len(u), X25519Size)
+	}
+
+	k := copyBytes(scalar)
+	defer zeroSlice(k[:])
+	k[0] &= 248
+	k[31] &= 127
+	k[31] |= 64
+
+	return x25519Checked(k, copyBytes(u))
+}
+
+// ECDH computes the 32 byte X25519 shared secret of the Ed25519 private key
+// priv and the Ed25519 public key of the peer, so the same keys can be used
+// for both signing and key agreement. The peer key is mapped to its
+// Curve25519 u-coordinate with EdwardsToMontgomery. When priv holds a secret
+// seed, its scalar is the clamped lower half of hash512(seed), which makes
+// the result the same as the X25519 function of libsodium style converted
+// keys; otherwise the private scalar is used. Both sides compute the same
+// secret, and peer keys of small order are rejected, as is an all zero
+// shared secret. Unlike GenerateSharedSecret, the secret should be passed
+// through a key derivation function before it is used as a key.
+func ECDH(priv *PrivateKey, peerPub *PublicKey) ([]byte, error) {
+	if priv == nil {
+		return nil, fmt.Errorf("private key is nil")
+	}
+	if err := priv.signingErr(); err != nil {
+		return nil, err
+	}
+	if peerPub == nil || peerPub.GetX() == nil || peerPub.GetY() == nil {
+		return nil, fmt.Errorf("peer public key is nil")
+	}
+	if curve, ok := peerPub.Curve.(*TwistedEdwardsCurve); !ok ||
+		curve == nil || curve.isEd448() {
+		return nil, fmt.Errorf("ECDH is only supported on Ed25519")
+	}
+
+	var A edwards25519.ExtendedGroupElement
+	if !A.FromBytes(BigIntPointToEncodedBytes(peerPub.GetX(),
+		peerPub.GetY())) {
+		return nil, fmt.Errorf("peer public key is not on the curve")
+	}
+	clearCofactor(&A)
+	if geIsIdentity(&A) {
+		return nil, fmt.Errorf("peer public key has low order")
+	}
+	u := EdwardsToMontgomery(peerPub.GetX(), peerPub.GetY())
+	if u == nil {
+		return nil, fmt.Errorf("peer public key has no u-coordinate")
+	}
+
+	var k *[32]byte
+	if priv.secret != nil {
+		expanded := &ExpandedPrivateKey{}
+		expandSeed(expanded, priv.secret[:])
+		defer expanded.Wipe()
+		k = &expanded.scalar
+	} else {
+		k = copyBytes(priv.Serialize())
+		reverse(k) // BE --> LE
+		defer zeroSlice(k[:])
+	}
+
+	return x25519Checked(k, BigIntToEncodedBytes(u))
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2017 The Hcash developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package edwards
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"testing"
+)
+
+// TestX25519 tests the X25519 function against the scalar multiplication,
+// iterated and Diffie-Hellman test vectors of RFC 7748, and that low order
+// u-coordinates are rejected
+func TestX25519(t *testing.T) {
+	tests := []struct {
+		scalar string
+		u      string
+		out    string
+	}{
+		// Section 5.2.
+		{
+			"a546e36bf0527c9d3b16154b82465edd62144c0ac1fc5a18506a2244ba449ac4",
+			"e6db6867583030db3594c1a424b15f7c726624ec26b3353b10a903a6d0ab1c4c",
+			"c3da55379de9c6908e94ea4df28d084f32eccf03491c71f754b4075577a28552",
+		},
+		{
+			"4b66e9d4d1b4673c5ad22691957d6af5c11b6421e0ea01d42ca4169e7918ba0d",
+			"e5210f12786811d3f4b7959d0538ae2c31dbe7106fc03c3efc4cd549c715a493",
+			"95cbde9476e8907d7aade45cb4b873f88b595a68799fa152e6f8f7647aac7957",
+		},
+		// Section 6.1, the public keys of Alice and Bob and their shared
+		// secret.
+		{
+			"77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a",
+			"0900000000000000000000000000000000000000000000000000000000000000",
+			"8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a",
+		},
+		{
+			"5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb",
+			"0900000000000000000000000000000000000000000000000000000000000000",
+			"de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f",
+		},
+		{
+			"77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a",
+			"de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f",
+			"4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742",
+		},
+		{
+			"5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb",
+			"8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a",
+			"4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742",
+		},
+	}
+	for i, test := range tests {
+		scalar, _ := hex.DecodeString(test.scalar)
+		u, _ := hex.DecodeString(test.u)
+		out, err := X25519(scalar, u)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if hex.EncodeToString(out) != test.out {
+			t.Fatalf("test %d: want %v, got %x", i, test.out, out)
+		}
+	}
+
+	// Section 5.2 iterates k, u = X25519(k, u), k starting from the base
+	// point. Only the first iteration is checked in short mode.
+	iterated := []string{
+		"422c8e7a6227d7bca1350b3e2bb7279f7897b87bb6854b783c60e80311ae3079",
+		"684cf59ba83309552800ef566f2f4d3c1c3887c49360e3875f2eb94d99532c51",
+	}
+	k, _ := hex.DecodeString(
+		"0900000000000000000000000000000000000000000000000000000000000000")
+	u := k
+	iterations := 1000
+	if testing.Short() {
+		iterations = 1
+	}
+	for i := 1; i <= iterations; i++ {
+		out, err := X25519(k, u)
+		if err != nil {
+			t.Fatalf("iteration %d: unexpected error: %v", i, err)
+		}
+		k, u = out, k
+		if i == 1 && hex.EncodeToString(k) != iterated[0] ||
+			i == 1000 && hex.EncodeToString(k) != iterated[1] {
+			t.Fatalf("iteration %d: got %x", i, k)
+		}
+	}
+
+	// u = 0 and u = 1 have low order.
+	for _, lowOrder := range []string{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+	} {
+		u, _ := hex.DecodeString(lowOrder)
+		if _, err := X25519(k, u); err == nil {
+			t.Fatalf("accepted low order u %v", lowOrder)
+		}
+	}
+	if _, err := X25519(k[1:], u); err == nil {
+		t.Fatalf("accepted a short scalar")
+	}
+}
+
+// TestECDH tests that both sides of an ECDH key agreement between Ed25519
+// keys, with or without a secret seed, compute the same secret, which for
+// seeded keys is the X25519 secret of the converted keys, and that low order
+// peer keys are rejected
+func TestECDH(t *testing.T) {
+	curve := new(TwistedEdwardsCurve)
+	curve.InitParam25519()
+
+	seed1, _ := hex.DecodeString(
+		"9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	seed2, _ := hex.DecodeString(
+		"4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb")
+	priv1, pub1 := PrivKeyFromSecret(curve, seed1)
+	priv2, pub2 := PrivKeyFromSecret(curve, seed2)
+
+	secret12, err := ECDH(priv1, pub2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	secret21, err := ECDH(priv2, pub1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(secret12, secret21) {
+		t.Fatalf("the two sides computed different secrets")
+	}
+	digest := sha512.Sum512(seed1)
+	u2 := BigIntToEncodedBytes(EdwardsToMontgomery(pub2.GetX(), pub2.GetY()))
+	want, _ := X25519(digest[:32], u2[:])
+	if !bytes.Equal(secret12, want) {
+		t.Fatalf("secret %x differs from the X25519 secret %x", secret12,
+			want)
+	}
+
+	// Keys holding only a scalar agree with seeded keys too.
+	for i, sk := range mockUpSecKeysByScalars(curve, 3) {
+		a, err := ECDH(sk, pub1)
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		b, err := ECDH(priv1, sk.PubKey())
+		if err != nil {
+			t.Fatalf("key %d: unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(a, b) {
+			t.Fatalf("key %d: the two sides computed different secrets", i)
+		}
+	}
+
+	for i, str := range smallOrderPoints {
+		b, _ := hex.DecodeString(str)
+		x, y, err := curve.EncodedBytesToBigIntPoint(copyBytes(b))
+		if err != nil {
+			continue
+		}
+		if _, err := ECDH(priv1, NewPublicKey(curve, x, y)); err == nil {
+			t.Fatalf("small order point %d: accepted a low order peer key",
+				i)
+		}
+	}
+	if _, err := ECDH(priv1, nil); err == nil {
+		t.Fatalf("accepted a nil peer key")
+	}
+	if _, err := ECDH(nil, pub2); err == nil {
+		t.Fatalf("accepted a nil private key")
+	}
+}